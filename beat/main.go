@@ -5,6 +5,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/Aereum/aereum/core/crypto"
@@ -12,10 +13,32 @@ import (
 	"github.com/Aereum/aereum/core/util"
 
 	"github.com/Aereum/aereum/core/consensus"
-	"github.com/Aereum/aereum/core/consensus/authority"
+	"github.com/Aereum/aereum/core/consensus/breeze"
 	"github.com/Aereum/aereum/core/network"
 )
 
+// dataDir is the directory the state of the chain is persisted on.
+const dataDir = "beat-data"
+
+// genesisTime returns the genesis time recorded on dir, recording the current
+// time if dir has none. Epochs are counted from it, so it must survive
+// restarts together with the state.
+func genesisTime(dir string) (time.Time, error) {
+	path := filepath.Join(dir, "genesis")
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		now := time.Now()
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return now, err
+		}
+		return now, os.WriteFile(path, []byte(now.Format(time.RFC3339Nano)), 0600)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, string(data))
+}
+
 func main() {
 
 	var token crypto.PrivateKey
@@ -67,9 +90,30 @@ func main() {
 		}
 	}
 
-	chain := consensus.NewGenesisBlockChain(token)
-	consensus := authority.NewProofOfAtuhority(chain, token)
+	genesis, err := genesisTime(dataDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	chain, err := consensus.OpenBlockChain(dataDir, token, genesis)
+	if err != nil {
+		log.Fatal(err)
+	}
+	consensus := breeze.NewBreeze(chain, token)
 	network.NewNode(token, make(map[crypto.Token]string), consensus, 1)
+	go func() {
+		for halt := range consensus.Halted {
+			if halt.Halted {
+				fmt.Printf("state checksum diverged at epoch %v: block production halted\n", halt.Epoch)
+			} else {
+				fmt.Printf("state checksum agrees at epoch %v: block production resumed\n", halt.Epoch)
+			}
+		}
+	}()
+	go func() {
+		for err := range consensus.Errors {
+			log.Println(err)
+		}
+	}()
 
 	conns := make([]*network.SecureConnection, 10)
 	for n := 0; n < 10; n++ {
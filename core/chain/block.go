@@ -16,7 +16,7 @@
 package chain
 
 import (
-	"fmt"
+	"errors"
 	"time"
 
 	"github.com/Aereum/aereum/core/crypto"
@@ -25,22 +25,39 @@ import (
 	"github.com/Aereum/aereum/core/util"
 )
 
+var (
+	ErrInvalidBlockSignature    = errors.New("invalid block signature")
+	ErrInstructionsRootMismatch = errors.New("instructions root does not match block instructions")
+	ErrBlockHashMismatch        = errors.New("block hash does not match block header")
+)
+
+// MaxInstructionAge is the maximum number of epochs between the epoch of an
+// instruction and the epoch of the block incorporating it. Included
+// instructions are remembered for that long to reject replays.
+const MaxInstructionAge = 100
+
 type Block struct {
-	epoch         uint64
-	Parent        crypto.Hash
-	CheckPoint    uint64
-	Publisher     crypto.Token
-	PublishedAt   time.Time
-	Instructions  [][]byte
-	Hash          crypto.Hash
-	FeesCollected uint64
-	Signature     crypto.Signature
-	validator     *MutatingState
-	mutations     *mutation
+	epoch            uint64
+	Parent           crypto.Hash
+	CheckPoint       uint64
+	Publisher        crypto.Token
+	PublishedAt      time.Time
+	Instructions     [][]byte
+	Hash             crypto.Hash
+	FeesCollected    uint64
+	StateRoot        crypto.Hash
+	InstructionsRoot crypto.Hash
+	Minted           uint64
+	Signature        crypto.Signature
+	rewards          RewardSchedule
+	validator        *MutatingState
+	mutations        *Mutation
+	spent            map[crypto.Hash]uint64 // spending of powers of attorney within the block
+	spentBefore      map[crypto.Hash]uint64 // spending prior to the instruction being validated
 }
 
 func NewBlock(parent crypto.Hash, checkpoint, epoch uint64, publisher crypto.Token, validator *MutatingState) *Block {
-	return &Block{
+	block := &Block{
 		Parent:       parent,
 		epoch:        epoch,
 		CheckPoint:   checkpoint,
@@ -48,49 +65,132 @@ func NewBlock(parent crypto.Hash, checkpoint, epoch uint64, publisher crypto.Tok
 		Instructions: make([][]byte, 0),
 		validator:    validator,
 		mutations:    NewMutation(),
+		spent:        make(map[crypto.Hash]uint64),
 	}
+	if validator != nil && validator.State != nil {
+		block.rewards = validator.State.Rewards
+	}
+	return block
 }
 
-func (b *Block) Incorporate(instruction instructions.Instruction) bool {
+// Incorporate validates instruction against the block and, if valid, appends
+// it to the block. It returns the reason the instruction is not valid.
+func (b *Block) Incorporate(instruction instructions.Instruction) error {
+	if instruction.Epoch() > b.epoch {
+		return instructions.ErrFutureEpoch
+	}
+	if b.epoch-instruction.Epoch() > MaxInstructionAge {
+		return instructions.ErrTooOld
+	}
+	serialized := instruction.Serialize()
+	hash := instructions.ReplayHash(instruction)
+	if b.mutations.HasIncluded(hash) || b.validator.included(hash) {
+		return instructions.ErrDuplicateInstruction
+	}
 	payments := instruction.Payments()
-	if !b.CanPay(payments) {
-		return false
+	if instruction.Kind() != instructions.IMultisigTransfer && b.debitsMultisig(payments) {
+		return instructions.ErrMultisigWallet
 	}
-	if !instruction.Validate(b) {
-		return false
+	deltas, err := b.paymentDeltas(payments)
+	if err != nil {
+		return err
 	}
-	b.TransferPayments(payments)
-	b.Instructions = append(b.Instructions, instruction.Serialize())
-	return true
+	// payments are transferred before validation so that instructions moving
+	// whole balances see them already debited.
+	previous := b.setDeltas(deltas)
+	b.spentBefore = make(map[crypto.Hash]uint64)
+	if err := instruction.Validate(b); err != nil {
+		b.setDeltas(previous)
+		for hash, spent := range b.spentBefore {
+			b.spent[hash] = spent
+		}
+		return err
+	}
+	b.mutations.Included[hash] = instruction.Epoch()
+	b.Instructions = append(b.Instructions, serialized)
+	return nil
 }
 
-func (b *Block) CanPay(payments *instructions.Payment) bool {
+// debitsMultisig checks if payments debit a multisig wallet, which only a
+// multisig transfer can do.
+func (b *Block) debitsMultisig(payments *instructions.Payment) bool {
 	for _, debit := range payments.Debit {
-		existingBalance := b.validator.balance(debit.Account)
-		delta := b.mutations.DeltaBalance(debit.Account)
-		if int(existingBalance) < int(debit.FungibleTokens)+delta {
-			return false
+		if ok, _ := b.Multisig(debit.Account); ok {
+			return true
 		}
 	}
-	return true
+	return false
 }
 
-func (b *Block) TransferPayments(payments *instructions.Payment) {
-	for _, debit := range payments.Debit {
-		if delta, ok := b.mutations.DeltaWallets[debit.Account]; ok {
-			b.mutations.DeltaWallets[debit.Account] = delta - int(debit.FungibleTokens)
+// setDeltas replaces the wallet deltas of the block by deltas and returns the
+// ones replaced. A missing delta is returned as zero.
+func (b *Block) setDeltas(deltas map[crypto.Hash]int64) map[crypto.Hash]int64 {
+	previous := make(map[crypto.Hash]int64)
+	for acc, delta := range deltas {
+		previous[acc] = b.mutations.DeltaWallets[acc]
+		if delta == 0 {
+			delete(b.mutations.DeltaWallets, acc)
 		} else {
-			b.mutations.DeltaWallets[debit.Account] = -int(debit.FungibleTokens)
-			// fmt.Println(debit.Account, debit.FungibleTokens)
+			b.mutations.DeltaWallets[acc] = delta
 		}
 	}
-	for _, credit := range payments.Credit {
-		if delta, ok := b.mutations.DeltaWallets[credit.Account]; ok {
-			b.mutations.DeltaWallets[credit.Account] = delta + int(credit.FungibleTokens)
-		} else {
-			b.mutations.DeltaWallets[credit.Account] = int(credit.FungibleTokens)
+	return previous
+}
+
+// paymentDeltas returns the wallet deltas of the block for every account
+// touched by payments, as they would be after the transfer of payments. It
+// returns ErrInsufficientFunds if a balance would go negative and ErrOverflow
+// if an amount does not fit.
+func (b *Block) paymentDeltas(payments *instructions.Payment) (map[crypto.Hash]int64, error) {
+	if payments.Overflows() {
+		return nil, instructions.ErrOverflow
+	}
+	deltas := make(map[crypto.Hash]int64)
+	transfer := func(wallets []instructions.Wallet, debit bool) bool {
+		for _, wallet := range wallets {
+			current, ok := deltas[wallet.Account]
+			if !ok {
+				current = b.mutations.DeltaBalance(wallet.Account)
+			}
+			delta, ok := util.ToDelta(wallet.FungibleTokens, debit)
+			if !ok {
+				return false
+			}
+			if deltas[wallet.Account], ok = util.AddInt64(current, delta); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	if !transfer(payments.Debit, true) || !transfer(payments.Credit, false) {
+		return nil, instructions.ErrOverflow
+	}
+	for acc, delta := range deltas {
+		if _, ok := util.ApplyDelta(b.validator.balance(acc), delta); !ok {
+			if delta < 0 {
+				return nil, instructions.ErrInsufficientFunds
+			}
+			return nil, instructions.ErrOverflow
 		}
 	}
+	return deltas, nil
+}
+
+// CanPay checks that payments can be transferred within the block.
+func (b *Block) CanPay(payments *instructions.Payment) bool {
+	_, err := b.paymentDeltas(payments)
+	return err == nil
+}
+
+// TransferPayments transfers payments within the block. Nothing is
+// transferred if the payments cannot be paid.
+func (b *Block) TransferPayments(payments *instructions.Payment) error {
+	deltas, err := b.paymentDeltas(payments)
+	if err != nil {
+		return err
+	}
+	b.setDeltas(deltas)
+	return nil
 }
 
 func setNewHash(hash crypto.Hash, store map[crypto.Hash]struct{}) bool {
@@ -101,12 +201,59 @@ func setNewHash(hash crypto.Hash, store map[crypto.Hash]struct{}) bool {
 	return true
 }
 
-func (b *Block) SetNewGrantPower(hash crypto.Hash) bool {
-	return setNewHash(hash, b.mutations.GrantPower)
+// SetNewGrantPower grants the power of attorney hash by author with scope.
+func (b *Block) SetNewGrantPower(hash crypto.Hash, scope store.AttorneyScope, author crypto.Token) bool {
+	if _, ok := b.mutations.GrantPower[hash]; ok {
+		return false
+	}
+	if !b.shiftHoldings(crypto.HashToken(author), 1, false) {
+		return false
+	}
+	b.mutations.GrantPower[hash] = scope
+	return true
+}
+
+// shiftHoldings adds value to the holdings of member, or subtracts it if
+// release is set. It returns false on overflow.
+func (b *Block) shiftHoldings(member crypto.Hash, value uint64, release bool) bool {
+	delta, ok := shiftDelta(b.mutations.DeltaHoldings, member, value, release)
+	if !ok {
+		return false
+	}
+	b.mutations.DeltaHoldings[member] = delta
+	return true
 }
 
-func (b *Block) SetNewRevokePower(hash crypto.Hash) bool {
-	return setNewHash(hash, b.mutations.RevokePower)
+// SetAttorneySpending adds value to the spending of the power of attorney
+// hash within the block. It returns false if the spending would go over
+// limit.
+func (b *Block) SetAttorneySpending(hash crypto.Hash, value, limit uint64) bool {
+	if b.spent == nil {
+		b.spent = make(map[crypto.Hash]uint64)
+	}
+	spent, ok := util.AddUint64(b.spent[hash], value)
+	if !ok || spent > limit {
+		return false
+	}
+	if b.spentBefore != nil {
+		if _, ok := b.spentBefore[hash]; !ok {
+			b.spentBefore[hash] = b.spent[hash]
+		}
+	}
+	b.spent[hash] = spent
+	return true
+}
+
+// SetNewRevokePower revokes the power of attorney hash granted by author.
+func (b *Block) SetNewRevokePower(hash crypto.Hash, author crypto.Token) bool {
+	if _, ok := b.mutations.RevokePower[hash]; ok {
+		return false
+	}
+	if !b.shiftHoldings(crypto.HashToken(author), 1, true) {
+		return false
+	}
+	b.mutations.RevokePower[hash] = struct{}{}
+	return true
 }
 
 func (b *Block) SetNewUseSpnOffer(hash crypto.Hash) bool {
@@ -121,8 +268,24 @@ func (b *Block) SetNewSpnOffer(hash crypto.Hash, expire uint64) bool {
 	return true
 }
 
+// SetNewGrantSponsor grants the publication of the sponsored content with
+// contentHash to the hash of sponsor token + audience. It returns false if an
+// unpublished sponsorship has already been granted to hash.
+func (b *Block) SetNewGrantSponsor(hash, contentHash crypto.Hash) bool {
+	if ok, _ := b.HasGrantedSponser(hash); ok {
+		return false
+	}
+	b.mutations.GrantSponsor[hash] = contentHash
+	return true
+}
+
+// SetPublishSponsor consumes the sponsorship granted to hash.
 func (b *Block) SetPublishSponsor(hash crypto.Hash) bool {
-	return setNewHash(hash, b.mutations.PublishSpn)
+	if !setNewHash(hash, b.mutations.PublishSpn) {
+		return false
+	}
+	delete(b.mutations.GrantSponsor, hash)
+	return true
 }
 
 func (b *Block) SetNewEphemeralToken(hash crypto.Hash, expire uint64) bool {
@@ -133,23 +296,170 @@ func (b *Block) SetNewEphemeralToken(hash crypto.Hash, expire uint64) bool {
 	return true
 }
 
-func (b *Block) SetNewMember(tokenHash crypto.Hash, captionHash crypto.Hash) bool {
+func (b *Block) SetNewMember(token crypto.Token, captionHash crypto.Hash) bool {
+	tokenHash := crypto.HashToken(token)
 	if _, ok := b.mutations.NewMembers[tokenHash]; ok {
 		return false
 	}
-	if _, ok := b.mutations.NewMembers[captionHash]; ok {
+	if !b.SetNewCaption(token, captionHash) {
 		return false
 	}
 	b.mutations.NewMembers[tokenHash] = struct{}{}
-	b.mutations.NewCaption[captionHash] = struct{}{}
 	return true
 }
 
-func (b *Block) SetNewAudience(hash crypto.Hash, stage store.StageKeys) bool {
+// captionTouched checks if the block already changes the owner of caption or
+// the caption of any of members.
+func (b *Block) captionTouched(caption crypto.Hash, members ...crypto.Hash) bool {
+	if _, ok := b.mutations.NewCaption[caption]; ok {
+		return true
+	}
+	for _, member := range members {
+		if _, ok := b.mutations.NewMemberCaption[member]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// releaseCaption releases the current caption of member, if any.
+func (b *Block) releaseCaption(member crypto.Hash) {
+	if ok, previous := b.validator.memberCaption(member); ok {
+		b.mutations.NewCaption[previous] = crypto.ZeroToken
+	}
+}
+
+// SetNewCaption assigns the caption hash to token, releasing the previous
+// caption of token.
+func (b *Block) SetNewCaption(token crypto.Token, captionHash crypto.Hash) bool {
+	member := crypto.HashToken(token)
+	if b.captionTouched(captionHash, member) {
+		return false
+	}
+	b.releaseCaption(member)
+	b.mutations.NewCaption[captionHash] = token
+	b.mutations.NewMemberCaption[member] = captionHash
+	return true
+}
+
+// SetCaptionTransfer moves the caption hash from one member to another. The
+// previous caption of the recipient is released and the sender is left
+// without a caption.
+func (b *Block) SetCaptionTransfer(from, to crypto.Token, captionHash crypto.Hash) bool {
+	sender, recipient := crypto.HashToken(from), crypto.HashToken(to)
+	if b.captionTouched(captionHash, sender, recipient) {
+		return false
+	}
+	b.releaseCaption(recipient)
+	b.mutations.NewCaption[captionHash] = to
+	b.mutations.NewMemberCaption[recipient] = captionHash
+	b.mutations.NewMemberCaption[sender] = crypto.Hash{}
+	return true
+}
+
+// SetNewAudience creates the stage hash owned by owner.
+func (b *Block) SetNewAudience(hash crypto.Hash, stage store.StageKeys, owner crypto.Token) bool {
 	if _, ok := b.mutations.NewStages[hash]; ok {
 		return false
 	}
+	if _, ok := b.mutations.StageOwner[hash]; ok {
+		return false
+	}
+	if !b.shiftHoldings(crypto.HashToken(owner), 1, false) {
+		return false
+	}
 	b.mutations.NewStages[hash] = stage
+	b.mutations.StageOwner[hash] = owner
+	return true
+}
+
+// SetNewRotation moves the membership, the caption, the wallet balance, the
+// powers of attorney granted to attorneys and the ownership of stages from
+// one token to another, together with the count of holdings. Nothing is
+// changed if any of them is already changed by the block.
+func (b *Block) SetNewRotation(from, to crypto.Token, attorneys []crypto.Token, stages []crypto.Hash) bool {
+	old, next := crypto.HashToken(from), crypto.HashToken(to)
+	for _, member := range []crypto.Hash{old, next} {
+		if b.mutations.HasMember(member) || b.mutations.HasRemovedMember(member) {
+			return false
+		}
+	}
+	if ok, _ := b.Multisig(old); ok {
+		return false
+	}
+	hasCaption, caption := b.validator.memberCaption(old)
+	if b.captionTouched(caption, old, next) {
+		return false
+	}
+	grants := make([]crypto.Hash, 0, len(attorneys))
+	revokes := make([]crypto.Hash, 0, len(attorneys))
+	scopes := make([]store.AttorneyScope, 0, len(attorneys))
+	for _, attorney := range attorneys {
+		grant := crypto.Hasher(append(to[:], attorney[:]...))
+		revoke := crypto.Hasher(append(from[:], attorney[:]...))
+		for _, hash := range []crypto.Hash{grant, revoke} {
+			if b.mutations.HasGrantPower(hash) || b.mutations.HasRevokePower(hash) {
+				return false
+			}
+		}
+		scope := b.validator.attorneyScope(revoke)
+		if scope == nil {
+			return false
+		}
+		grants, revokes = append(grants, grant), append(revokes, revoke)
+		scopes = append(scopes, *scope)
+	}
+	for _, stage := range stages {
+		if _, ok := b.mutations.StageOwner[stage]; ok {
+			return false
+		}
+	}
+	for _, member := range []crypto.Hash{old, next} {
+		if _, ok := b.mutations.NewGuardians[member]; ok {
+			return false
+		}
+	}
+	balance := addDelta(b.validator.balance(old), b.mutations.DeltaBalance(old))
+	debit, okDebit := util.ToDelta(balance, true)
+	credit, okCredit := util.ToDelta(balance, false)
+	if !okDebit || !okCredit {
+		return false
+	}
+	oldDelta, okOld := util.AddInt64(b.mutations.DeltaBalance(old), debit)
+	newDelta, okNew := util.AddInt64(b.mutations.DeltaBalance(next), credit)
+	if !okOld || !okNew {
+		return false
+	}
+	if _, ok := util.ApplyDelta(b.validator.balance(next), newDelta); !ok {
+		return false
+	}
+	holdings := b.Holdings(old)
+	if !b.shiftHoldings(old, holdings, true) {
+		return false
+	}
+	if !b.shiftHoldings(next, holdings, false) {
+		b.shiftHoldings(old, holdings, false)
+		return false
+	}
+	b.mutations.RemovedMembers[old] = struct{}{}
+	b.mutations.NewMembers[next] = struct{}{}
+	b.mutations.NewMemberCaption[old] = crypto.Hash{}
+	if hasCaption {
+		b.mutations.NewCaption[caption] = to
+		b.mutations.NewMemberCaption[next] = caption
+	}
+	for n := range grants {
+		b.mutations.RevokePower[revokes[n]] = struct{}{}
+		b.mutations.GrantPower[grants[n]] = scopes[n]
+	}
+	for _, stage := range stages {
+		b.mutations.StageOwner[stage] = to
+	}
+	if ok, guardians := b.validator.guardians(old); ok {
+		b.mutations.NewGuardians[old] = crypto.Hash{}
+		b.mutations.NewGuardians[next] = guardians
+	}
+	b.setDeltas(map[crypto.Hash]int64{old: oldDelta, next: newDelta})
 	return true
 }
 
@@ -161,10 +471,275 @@ func (b *Block) UpdateAudience(hash crypto.Hash, stage store.StageKeys) bool {
 	return true
 }
 
+// SetNewGuardians replaces the guardians commitment of member. A zero
+// commitment removes the guardians.
+func (b *Block) SetNewGuardians(member crypto.Token, guardians crypto.Hash) bool {
+	hash := crypto.HashToken(member)
+	if _, ok := b.mutations.NewGuardians[hash]; ok {
+		return false
+	}
+	b.mutations.NewGuardians[hash] = guardians
+	return true
+}
+
+// SetNewMultisig puts the wallet hash under the control of the multisig
+// policy. The policy of a wallet cannot be replaced.
+func (b *Block) SetNewMultisig(hash crypto.Hash, policy crypto.Hash) bool {
+	if ok, _ := b.Multisig(hash); ok {
+		return false
+	}
+	b.mutations.NewMultisig[hash] = policy
+	return true
+}
+
+// SetNewEscrow escrows value under hash, to be released to the recipient
+// from the release epoch on as set by the terms of escrow.
+func (b *Block) SetNewEscrow(hash crypto.Hash, escrow store.Escrow, release, value uint64) bool {
+	if _, ok := b.mutations.EscrowRelease[hash]; ok || b.validator.escrow(hash) != nil {
+		return false
+	}
+	credit, ok := util.ToDelta(value, false)
+	if !ok {
+		return false
+	}
+	if b.mutations.DeltaEscrowed[hash], ok = util.AddInt64(b.mutations.DeltaEscrowed[hash], credit); !ok {
+		return false
+	}
+	b.mutations.NewEscrow[hash] = escrow
+	b.mutations.EscrowRelease[hash] = release
+	return true
+}
+
+// SetNewSlash punishes the validator offender, burning SlashPercentage of
+// its own stake. An offender can only be slashed once.
+func (b *Block) SetNewSlash(offender crypto.Token) bool {
+	hash := crypto.HashToken(offender)
+	if b.Slashed(hash) {
+		return false
+	}
+	burn, ok := mulDiv(b.Stake(hash), SlashPercentage, 100, false)
+	if !ok {
+		return false
+	}
+	delta, okDelta := shiftDelta(b.mutations.DeltaStakes, hash, burn, true)
+	burned, okBurned := util.AddUint64(b.mutations.Burned, burn)
+	if !okDelta || !okBurned {
+		return false
+	}
+	b.mutations.DeltaStakes[hash] = delta
+	b.mutations.Burned = burned
+	b.mutations.Slashed[hash] = struct{}{}
+	return true
+}
+
+// SetCancelEscrow cancels the escrow hash, moving the aero not yet released
+// back to the wallet of the payer.
+func (b *Block) SetCancelEscrow(hash crypto.Hash) bool {
+	if _, ok := b.mutations.EscrowRelease[hash]; ok {
+		return false
+	}
+	escrow := b.validator.escrow(hash)
+	if escrow == nil {
+		return false
+	}
+	payer := crypto.HashToken(escrow.Payer)
+	remaining := addDelta(b.validator.escrowed(hash), b.mutations.DeltaEscrowed[hash])
+	debit, okDebit := util.ToDelta(remaining, true)
+	credit, okCredit := util.ToDelta(remaining, false)
+	if !okDebit || !okCredit {
+		return false
+	}
+	escrowDelta, okEscrow := util.AddInt64(b.mutations.DeltaEscrowed[hash], debit)
+	payerDelta, okPayer := util.AddInt64(b.mutations.DeltaBalance(payer), credit)
+	if !okEscrow || !okPayer {
+		return false
+	}
+	if _, ok := util.ApplyDelta(b.validator.balance(payer), payerDelta); !ok {
+		return false
+	}
+	b.mutations.DeltaEscrowed[hash] = escrowDelta
+	b.mutations.EscrowRelease[hash] = 0
+	b.setDeltas(map[crypto.Hash]int64{payer: payerDelta})
+	return true
+}
+
+// SetNewRecovery starts the recovery of member to the token. It can be
+// completed after RecoveryDelay epochs.
+func (b *Block) SetNewRecovery(member, to crypto.Token) bool {
+	hash := RecoveryHash(member, to)
+	if _, ok := b.mutations.NewRecovery[hash]; ok {
+		return false
+	}
+	b.mutations.NewRecovery[hash] = b.epoch + RecoveryDelay
+	return true
+}
+
+// SetCancelRecovery removes the pending recovery of member to the token.
+func (b *Block) SetCancelRecovery(member, to crypto.Token) bool {
+	hash := RecoveryHash(member, to)
+	if _, ok := b.mutations.NewRecovery[hash]; ok {
+		return false
+	}
+	b.mutations.NewRecovery[hash] = 0
+	return true
+}
+
+// SetCompleteRecovery removes the pending recovery of member to the token and
+// rotates the identity of member to the token.
+func (b *Block) SetCompleteRecovery(member, to crypto.Token, attorneys []crypto.Token, stages []crypto.Hash) bool {
+	hash := RecoveryHash(member, to)
+	if _, ok := b.mutations.NewRecovery[hash]; ok {
+		return false
+	}
+	if !b.SetNewRotation(member, to, attorneys, stages) {
+		return false
+	}
+	b.mutations.NewRecovery[hash] = 0
+	return true
+}
+
+// SetNewDeposit adds value to the stake of token. Only one deposit or withdraw
+// of the same stake is accepted per block.
+func (b *Block) SetNewDeposit(token crypto.Token, value uint64) bool {
+	hash := crypto.HashToken(token)
+	if _, ok := b.mutations.DeltaStakes[hash]; ok {
+		return false
+	}
+	delta, ok := util.ToDelta(value, false)
+	if !ok {
+		return false
+	}
+	b.mutations.DeltaStakes[hash] = delta
+	b.mutations.StakeToken[hash] = token
+	return true
+}
+
+// SetNewWithdraw moves value from the stake of hash to unbonding. Only one
+// deposit or withdraw of the same stake is accepted per block.
+func (b *Block) SetNewWithdraw(hash crypto.Hash, value uint64) bool {
+	if _, ok := b.mutations.DeltaStakes[hash]; ok {
+		return false
+	}
+	delta, okDelta := util.ToDelta(value, true)
+	unbonding, okUnbonding := util.AddUint64(b.mutations.Unbonding[hash], value)
+	if !okDelta || !okUnbonding {
+		return false
+	}
+	b.mutations.DeltaStakes[hash] = delta
+	b.mutations.Unbonding[hash] = unbonding
+	return true
+}
+
+// pool returns the stake delegated to validator and the shares issued by its
+// pool considering the instructions of the block.
+func (b *Block) pool(validator crypto.Hash) (uint64, uint64) {
+	pooled, shares := b.validator.pool(validator)
+	return addDelta(pooled, b.mutations.DeltaPools[validator]), addDelta(shares, b.mutations.DeltaShares[validator])
+}
+
+// Delegated returns the stake of the pool of validator owned by delegator.
+func (b *Block) Delegated(delegator, validator crypto.Token) uint64 {
+	hash := DelegationHash(delegator, validator)
+	shares := addDelta(b.validator.delegation(hash), b.mutations.DeltaDelegations[hash])
+	pooled, issued := b.pool(crypto.HashToken(validator))
+	return shareValue(shares, pooled, issued)
+}
+
+// SetNewDelegation adds value to the pool of validator in exchange for pool
+// shares to delegator. Only one delegation or undelegation of the same pair
+// is accepted per block.
+func (b *Block) SetNewDelegation(delegator, validator crypto.Token, value uint64) bool {
+	hash := DelegationHash(delegator, validator)
+	if _, ok := b.mutations.DeltaDelegations[hash]; ok {
+		return false
+	}
+	pool := crypto.HashToken(validator)
+	pooled, issued := b.pool(pool)
+	shares := sharesFor(value, pooled, issued, false)
+	if shares == 0 {
+		return false
+	}
+	_, okPooled := util.AddUint64(pooled, value)
+	_, okIssued := util.AddUint64(issued, shares)
+	deltaPool, okPool := shiftDelta(b.mutations.DeltaPools, pool, value, false)
+	deltaShares, okShares := shiftDelta(b.mutations.DeltaShares, pool, shares, false)
+	delegation, okDelegation := util.ToDelta(shares, false)
+	if !okPooled || !okIssued || !okPool || !okShares || !okDelegation {
+		return false
+	}
+	b.mutations.DeltaPools[pool] = deltaPool
+	b.mutations.DeltaShares[pool] = deltaShares
+	b.mutations.DeltaDelegations[hash] = delegation
+	b.mutations.StakeToken[pool] = validator
+	return true
+}
+
+// SetNewUndelegation redeems the pool shares of delegator worth value and
+// moves value to the unbonding stake of delegator. Only one delegation or
+// undelegation of the same pair is accepted per block.
+func (b *Block) SetNewUndelegation(delegator, validator crypto.Token, value uint64) bool {
+	hash := DelegationHash(delegator, validator)
+	if _, ok := b.mutations.DeltaDelegations[hash]; ok {
+		return false
+	}
+	pool := crypto.HashToken(validator)
+	pooled, issued := b.pool(pool)
+	shares := sharesFor(value, pooled, issued, true)
+	if value > pooled || shares > b.validator.delegation(hash) {
+		return false
+	}
+	owner := crypto.HashToken(delegator)
+	deltaPool, okPool := shiftDelta(b.mutations.DeltaPools, pool, value, true)
+	deltaShares, okShares := shiftDelta(b.mutations.DeltaShares, pool, shares, true)
+	delegation, okDelegation := util.ToDelta(shares, true)
+	unbonding, okUnbonding := util.AddUint64(b.mutations.Unbonding[owner], value)
+	if !okPool || !okShares || !okDelegation || !okUnbonding {
+		return false
+	}
+	b.mutations.DeltaPools[pool] = deltaPool
+	b.mutations.DeltaShares[pool] = deltaShares
+	b.mutations.DeltaDelegations[hash] = delegation
+	b.mutations.Unbonding[owner] = unbonding
+	return true
+}
+
+// Earnings returns the part of the fees collected and not burned, and of the
+// reward minted by the block, that is credited to the publisher and the part
+// credited to its pool of delegators, in proportion to its own and delegated
+// stake. It returns false if the earnings overflow.
+func (b *Block) Earnings() (uint64, uint64, bool) {
+	earnings, ok := util.AddUint64(b.FeesCollected-b.Burned(), b.Minted)
+	if !ok {
+		return 0, 0, false
+	}
+	if b.validator == nil {
+		return earnings, 0, true
+	}
+	publisher := crypto.HashToken(b.Publisher)
+	pooled, _ := b.validator.pool(publisher)
+	own, delegated := splitEarnings(earnings, b.validator.stake(publisher), pooled)
+	return own, delegated, true
+}
+
+// Stake returns the stake of hash considering the instructions of the block.
+func (b *Block) Stake(hash crypto.Hash) uint64 {
+	return addDelta(b.validator.stake(hash), b.mutations.DeltaStake(hash))
+}
+
+// Holdings returns the number of powers of attorney granted and stages owned
+// by the member hash considering the instructions of the block.
+func (b *Block) Holdings(hash crypto.Hash) uint64 {
+	return addDelta(b.validator.holdings(hash), b.mutations.DeltaHoldings[hash])
+}
+
 func (b *Block) PowerOfAttorney(hash crypto.Hash) bool {
 	return b.validator.powerOfAttorney(hash)
 }
 
+func (b *Block) AttorneyScope(hash crypto.Hash) *store.AttorneyScope {
+	return b.validator.attorneyScope(hash)
+}
+
 func (b *Block) SponsorshipOffer(hash crypto.Hash) uint64 {
 	return b.validator.sponsorshipOffer(hash)
 }
@@ -177,7 +752,70 @@ func (b *Block) HasCaption(hash crypto.Hash) bool {
 	return b.validator.hasCaption(hash)
 }
 
+func (b *Block) CaptionOwner(hash crypto.Hash) (bool, crypto.Token) {
+	return b.validator.captionOwner(hash)
+}
+
+func (b *Block) MemberCaption(hash crypto.Hash) (bool, crypto.Hash) {
+	return b.validator.memberCaption(hash)
+}
+
+func (b *Block) Guardians(hash crypto.Hash) (bool, crypto.Hash) {
+	return b.validator.guardians(hash)
+}
+
+// Escrow returns the terms of the escrow hash, or nil if there is none.
+func (b *Block) Escrow(hash crypto.Hash) *store.Escrow {
+	if release, ok := b.mutations.EscrowRelease[hash]; ok {
+		if release == 0 {
+			return nil
+		}
+		escrow := b.mutations.NewEscrow[hash]
+		return &escrow
+	}
+	return b.validator.escrow(hash)
+}
+
+// Slashed checks if the validator hash was punished for misbehaviour, either
+// by the block or before it.
+func (b *Block) Slashed(hash crypto.Hash) bool {
+	if _, ok := b.mutations.Slashed[hash]; ok {
+		return true
+	}
+	return b.validator.slashed(hash)
+}
+
+// EscrowRelease returns the next release epoch of the escrow hash, or zero if
+// there is none.
+func (b *Block) EscrowRelease(hash crypto.Hash) uint64 {
+	if release, ok := b.mutations.EscrowRelease[hash]; ok {
+		return release
+	}
+	return b.validator.escrowRelease(hash)
+}
+
+func (b *Block) Multisig(hash crypto.Hash) (bool, crypto.Hash) {
+	if ok, policy := b.mutations.GetMultisig(hash); ok {
+		return true, policy
+	}
+	return b.validator.multisig(hash)
+}
+
+func (b *Block) Recovery(member, to crypto.Token) uint64 {
+	return b.validator.recovery(RecoveryHash(member, to))
+}
+
+func (b *Block) StageOwner(hash crypto.Hash) (bool, crypto.Token) {
+	return b.validator.stageOwner(hash)
+}
+
 func (b *Block) HasGrantedSponser(hash crypto.Hash) (bool, crypto.Hash) {
+	if ok, contentHash := b.mutations.HasGrantedSponsorship(hash); ok {
+		return true, contentHash
+	}
+	if b.mutations.HasPublishedSponsorship(hash) {
+		return false, crypto.Hash{}
+	}
 	return b.validator.hasGrantedSponser(hash)
 }
 
@@ -205,6 +843,69 @@ func (b *Block) Sign(token crypto.PrivateKey) {
 	b.Signature = token.Sign(b.serializeWithoutSignature())
 }
 
+// Reward returns the new aero minted to the publisher according to the reward
+// schedule of the state the block is built upon.
+func (b *Block) Reward() uint64 {
+	if b.rewards == nil {
+		return 0
+	}
+	return b.rewards.Reward(b.epoch, b.FeesCollected)
+}
+
+// Burned returns the part of the fees collected that is burned according to
+// the reward schedule of the state the block is built upon.
+func (b *Block) Burned() uint64 {
+	if b.rewards == nil {
+		return 0
+	}
+	return b.rewards.Burned(b.FeesCollected)
+}
+
+// Seal computes the minted reward, the instructions root and the block hash
+// and signs the block. No instruction should be incorporated after the block
+// is sealed.
+func (b *Block) Seal(token crypto.PrivateKey) {
+	b.Minted = b.Reward()
+	b.InstructionsRoot = crypto.MerkleRoot(b.Instructions)
+	b.Hash = crypto.Hasher(b.serializeHeader())
+	b.Sign(token)
+}
+
+// Header returns every field of the block other than the instructions
+// themselves, which are committed by the instructions root and count.
+func (b *Block) Header() *instructions.BlockHeader {
+	return &instructions.BlockHeader{
+		Epoch:            b.epoch,
+		Parent:           b.Parent,
+		CheckPoint:       b.CheckPoint,
+		Publisher:        b.Publisher,
+		PublishedAt:      b.PublishedAt,
+		Count:            uint16(len(b.Instructions)),
+		InstructionsRoot: b.InstructionsRoot,
+		FeesCollected:    b.FeesCollected,
+		Minted:           b.Minted,
+		StateRoot:        b.StateRoot,
+	}
+}
+
+// serializeHeader serializes the header of the block. The block hash is the
+// hash of the header.
+func (b *Block) serializeHeader() []byte {
+	return b.Header().Serialize()
+}
+
+// InstructionProof returns the Merkle proof of inclusion of the n-th
+// instruction of the block.
+func (b *Block) InstructionProof(n int) []crypto.Hash {
+	return crypto.MerkleProof(b.Instructions, n)
+}
+
+// VerifyInstruction checks that instruction is the n-th of count instructions
+// of a block with the given instructions root.
+func VerifyInstruction(instructionsRoot crypto.Hash, instruction []byte, n, count int, proof []crypto.Hash) bool {
+	return crypto.VerifyMerkleProof(instructionsRoot, instruction, n, count, proof)
+}
+
 func (b *Block) Serialize() []byte {
 	bytes := b.serializeWithoutSignature()
 	util.PutSignature(b.Signature, &bytes)
@@ -216,7 +917,7 @@ func (b *Block) serializeWithoutSignature() []byte {
 	util.PutUint64(b.epoch, &bytes)
 	util.PutByteArray(b.Parent[:], &bytes)
 	util.PutUint64(b.CheckPoint, &bytes)
-	util.PutByteArray(b.Publisher[:], &bytes)
+	util.PutToken(b.Publisher, &bytes)
 	util.PutTime(b.PublishedAt, &bytes)
 	util.PutUint16(uint16(len(b.Instructions)), &bytes)
 	for _, instruction := range b.Instructions {
@@ -224,10 +925,15 @@ func (b *Block) serializeWithoutSignature() []byte {
 	}
 	util.PutByteArray(b.Hash[:], &bytes)
 	util.PutUint64(b.FeesCollected, &bytes)
+	util.PutByteArray(b.StateRoot[:], &bytes)
+	util.PutByteArray(b.InstructionsRoot[:], &bytes)
+	util.PutUint64(b.Minted, &bytes)
 	return bytes
 }
 
-func ParseBlock(data []byte) *Block {
+// ParseBlock parses a serialized block and checks its signature, instructions
+// root and hash.
+func ParseBlock(data []byte) (*Block, error) {
 	position := 0
 	block := Block{}
 	block.epoch, position = util.ParseUint64(data, position)
@@ -238,18 +944,30 @@ func ParseBlock(data []byte) *Block {
 	block.Instructions, position = util.ParseByteArrayArray(data, position)
 	block.Hash, position = util.ParseHash(data, position)
 	block.FeesCollected, position = util.ParseUint64(data, position)
+	block.StateRoot, position = util.ParseHash(data, position)
+	block.InstructionsRoot, position = util.ParseHash(data, position)
+	block.Minted, position = util.ParseUint64(data, position)
 	msg := data[0:position]
 	block.Signature, _ = util.ParseSignature(data, position)
 	if !block.Publisher.Verify(msg, block.Signature) {
-		fmt.Println("wrong signature")
-		return nil
+		return nil, ErrInvalidBlockSignature
+	}
+	if block.InstructionsRoot != crypto.MerkleRoot(block.Instructions) {
+		return nil, ErrInstructionsRootMismatch
+	}
+	if block.Hash != crypto.Hasher(block.serializeHeader()) {
+		return nil, ErrBlockHashMismatch
 	}
 	block.mutations = NewMutation()
-	return &block
+	block.spent = make(map[crypto.Hash]uint64)
+	return &block, nil
 }
 
 func (b *Block) SetValidator(validator *MutatingState) {
 	b.validator = validator
+	if validator != nil && validator.State != nil {
+		b.rewards = validator.State.Rewards
+	}
 }
 
 func GetBlockEpoch(data []byte) uint64 {
@@ -268,8 +986,11 @@ func (b *Block) JSONSimple() string {
 	bulk.PutHex("publisher", b.Publisher[:])
 	bulk.PutTime("publishedAt", b.PublishedAt)
 	bulk.PutUint64("instructionsCount", uint64(len(b.Instructions)))
-	bulk.PutHex("hash", b.Parent[:])
+	bulk.PutHex("hash", b.Hash[:])
 	bulk.PutUint64("feesCollectes", b.FeesCollected)
+	bulk.PutUint64("minted", b.Minted)
+	bulk.PutHex("stateRoot", b.StateRoot[:])
+	bulk.PutHex("instructionsRoot", b.InstructionsRoot[:])
 	bulk.PutBase64("signature", b.Signature[:])
 	return bulk.ToString()
 }
@@ -0,0 +1,107 @@
+package chain
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/instructions"
+)
+
+func TestBlockHash(t *testing.T) {
+	state, token := NewGenesisState()
+	_, publisher := crypto.RandomAsymetricKey()
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), &MutatingState{State: state})
+	for n := 0; n < 5; n++ {
+		receiver, _ := crypto.RandomAsymetricKey()
+		if block.Incorporate(instructions.NewSingleReciepientTransfer(token, receiver, "", 10, 1, 1)) != nil {
+			t.Fatal("could not transfer")
+		}
+	}
+	block.Seal(publisher)
+	if block.Hash == (crypto.Hash{}) || block.InstructionsRoot != crypto.MerkleRoot(block.Instructions) {
+		t.Fatal("block not sealed")
+	}
+	parsed, err := ParseBlock(block.Serialize())
+	if err != nil || parsed.Hash != block.Hash {
+		t.Fatal("could not parse sealed block")
+	}
+	for n, instruction := range block.Instructions {
+		proof := block.InstructionProof(n)
+		if !VerifyInstruction(parsed.InstructionsRoot, instruction, n, len(block.Instructions), proof) {
+			t.Fatalf("could not prove inclusion of instruction %v", n)
+		}
+	}
+
+	block.Hash = crypto.Hasher([]byte("wrong"))
+	block.Sign(publisher)
+	if _, err := ParseBlock(block.Serialize()); err != ErrBlockHashMismatch {
+		t.Errorf("expected block hash mismatch, got %v", err)
+	}
+	block.Seal(publisher)
+	block.Instructions = block.Instructions[1:]
+	block.Sign(publisher)
+	if _, err := ParseBlock(block.Serialize()); err != ErrInstructionsRootMismatch {
+		t.Errorf("expected instructions root mismatch, got %v", err)
+	}
+	block.Signature[0]++
+	if _, err := ParseBlock(block.Serialize()); err != ErrInvalidBlockSignature {
+		t.Errorf("expected invalid block signature, got %v", err)
+	}
+}
+
+func TestIncorporateErrors(t *testing.T) {
+	state, token := NewGenesisState()
+	_, publisher := crypto.RandomAsymetricKey()
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), &MutatingState{State: state})
+	_, poor := crypto.RandomAsymetricKey()
+	receiver, _ := crypto.RandomAsymetricKey()
+	if err := block.Incorporate(instructions.NewSingleReciepientTransfer(poor, receiver, "", 10, 1, 1)); err != instructions.ErrInsufficientFunds {
+		t.Errorf("expected insufficient funds, got %v", err)
+	}
+	eve := &instructions.Author{PrivateKey: token}
+	if err := block.Incorporate(eve.NewGrantPowerOfAttorney(receiver, 1, 1)); err != instructions.ErrAttorneyNotMember {
+		t.Errorf("expected attorney not member, got %v", err)
+	}
+	if err := block.Incorporate(eve.NewJoinNetworkThirdParty(receiver, "Aereum Network Genesis", `{}`, 1, 1)); err != instructions.ErrCaptionTaken {
+		t.Errorf("expected caption taken, got %v", err)
+	}
+	if err := block.Incorporate(instructions.NewSingleReciepientTransfer(token, receiver, "", math.MaxUint64, 1, 1)); err != instructions.ErrOverflow {
+		t.Errorf("expected overflow, got %v", err)
+	}
+	if len(block.Instructions) != 0 {
+		t.Error("invalid instructions incorporated")
+	}
+}
+
+func TestReplayProtection(t *testing.T) {
+	state, token := NewGenesisState()
+	_, publisher := crypto.RandomAsymetricKey()
+	receiver, _ := crypto.RandomAsymetricKey()
+	transfer := instructions.NewSingleReciepientTransfer(token, receiver, "", 10, 1, 1)
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(transfer); err != nil {
+		t.Fatalf("could not transfer: %v", err)
+	}
+	if err := block.Incorporate(transfer); err != instructions.ErrDuplicateInstruction {
+		t.Errorf("expected duplicate instruction in block, got %v", err)
+	}
+	if err := block.Incorporate(instructions.NewSingleReciepientTransfer(token, receiver, "", 10, 2, 1)); err != instructions.ErrFutureEpoch {
+		t.Errorf("expected future epoch, got %v", err)
+	}
+	if _, err := state.IncorporateBlock(block); err != nil {
+		t.Fatalf("could not incorporate block: %v", err)
+	}
+	epoch := uint64(MaxInstructionAge + 1)
+	next := NewBlock(block.Hash, 0, 2, publisher.PublicKey(), &MutatingState{State: state})
+	if err := next.Incorporate(transfer); err != instructions.ErrDuplicateInstruction {
+		t.Errorf("expected duplicate instruction in state, got %v", err)
+	}
+	late := NewBlock(block.Hash, 0, epoch+1, publisher.PublicKey(), &MutatingState{State: state})
+	if err := late.Incorporate(transfer); err != instructions.ErrTooOld {
+		t.Errorf("expected too old instruction, got %v", err)
+	}
+	if err := late.Incorporate(instructions.NewSingleReciepientTransfer(token, receiver, "", 10, epoch, 1)); err != nil {
+		t.Errorf("could not incorporate instruction within window: %v", err)
+	}
+}
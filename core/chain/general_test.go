@@ -28,7 +28,7 @@ func TestGeneral(t *testing.T) {
 	// Starting block
 	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, blockFormationToken.PublicKey(), validator)
 	eve := &instructions.Author{PrivateKey: token}
-	eveBalance := 1e6
+	eveBalance := GenesisSupply - GenesisStake
 	joinFee := 10
 	count := 0
 
@@ -40,7 +40,7 @@ func TestGeneral(t *testing.T) {
 
 	// Join Network sent by eve (pq nao posso usar join network normal?)
 	join := eve.NewJoinNetworkThirdParty(pubKey1, "member1", jsonString1, 1, uint64(joinFee))
-	if block.Incorporate(join) != true {
+	if block.Incorporate(join) != nil {
 		t.Error("could not add new member")
 	}
 	eveBalance = eveBalance - float64(joinFee)
@@ -71,7 +71,7 @@ func TestGeneral(t *testing.T) {
 
 	// Transfer from eve to first member
 	transfer := instructions.NewSingleReciepientTransfer(eve.PrivateKey, firstAuthor.Wallet.PublicKey(), "first transfer", 100, 2, uint64(joinFee))
-	if !block.Incorporate(transfer) {
+	if block.Incorporate(transfer) != nil {
 		t.Error("could not add transfer")
 	}
 	firstBalance = firstBalance + 100
@@ -84,7 +84,7 @@ func TestGeneral(t *testing.T) {
 	secondAuthor := &instructions.Author{PrivateKey: prvKey2, Wallet: prvWal2}
 	secondBalance := 0
 	join2 := eve.NewJoinNetworkThirdParty(pubKey2, "member2", jsonString1, 2, uint64(joinFee))
-	if block.Incorporate(join2) != true {
+	if block.Incorporate(join2) != nil {
 		t.Error("could not add member2")
 	}
 	eveBalance = eveBalance - float64(joinFee)
@@ -96,7 +96,7 @@ func TestGeneral(t *testing.T) {
 	thirdAuthor := &instructions.Author{PrivateKey: prvKey3, Wallet: prvWal3}
 	thirdBalance := 0
 	join3 := eve.NewJoinNetworkThirdParty(pubKey3, "member3", jsonString1, 2, uint64(joinFee))
-	if block.Incorporate(join3) != true {
+	if block.Incorporate(join3) != nil {
 		t.Error("could not add member3")
 	}
 	eveBalance = eveBalance - float64(joinFee)
@@ -104,7 +104,7 @@ func TestGeneral(t *testing.T) {
 
 	// First author update info
 	update := eve.NewUpdateInfo(jsonString1_new, 2, uint64(joinFee))
-	if !block.Incorporate(update) {
+	if block.Incorporate(update) != nil {
 		t.Error("could not add update")
 	}
 	eveBalance = eveBalance - float64(joinFee)
@@ -141,7 +141,7 @@ func TestGeneral(t *testing.T) {
 
 	// Transfer from eve to second member
 	transfer = instructions.NewSingleReciepientTransfer(eve.PrivateKey, secondAuthor.Wallet.PublicKey(), "second transfer", 100, 3, uint64(joinFee))
-	if !block.Incorporate(transfer) {
+	if block.Incorporate(transfer) != nil {
 		t.Error("could not add second transfer")
 	}
 	secondBalance = secondBalance + 100
@@ -150,7 +150,7 @@ func TestGeneral(t *testing.T) {
 
 	// Transfer from eve to third member
 	transfer = instructions.NewSingleReciepientTransfer(eve.PrivateKey, thirdAuthor.Wallet.PublicKey(), "third transfer", 100, 3, uint64(joinFee))
-	if !block.Incorporate(transfer) {
+	if block.Incorporate(transfer) != nil {
 		t.Error("could not add third transfer")
 	}
 	thirdBalance = thirdBalance + 100
@@ -158,9 +158,9 @@ func TestGeneral(t *testing.T) {
 	count = count + 1
 
 	// Create audience
-	audienceTest := instructions.NewStage(0, "teste")
+	audienceTest := instructions.NewStage(instructions.EncryptedStage, "teste")
 	createAudience := firstAuthor.NewCreateAudience(audienceTest, 3, uint64(joinFee))
-	if !block.Incorporate(createAudience) {
+	if block.Incorporate(createAudience) != nil {
 		t.Error("could not add create audience")
 	}
 	count = count + 1
@@ -168,7 +168,7 @@ func TestGeneral(t *testing.T) {
 
 	// Power of attorney sent by first author with third author as attorney
 	poa := firstAuthor.NewGrantPowerOfAttorney(thirdAuthor.PrivateKey.PublicKey(), 3, uint64(joinFee))
-	if !block.Incorporate(poa) {
+	if block.Incorporate(poa) != nil {
 		t.Error("could not add poa")
 	}
 	firstAuthor.Attorney = thirdAuthor.PrivateKey
@@ -210,8 +210,8 @@ func TestGeneral(t *testing.T) {
 	block = NewBlock(crypto.Hasher([]byte{}), 3, 4, blockFormationToken.PublicKey(), validator)
 
 	// Join audience sent by second member
-	joinAudience := secondAuthor.NewJoinAudience(audienceTest.PrivateKey.PublicKey(), "first audience member", 4, uint64(joinFee))
-	if !block.Incorporate(joinAudience) {
+	joinAudience := secondAuthor.NewJoinAudience(audienceTest.PrivateKey.PublicKey(), audienceTest.PrivateKey.PublicKey(), "first audience member", 4, uint64(joinFee))
+	if block.Incorporate(joinAudience) != nil {
 		t.Error("could not send join audience instruction")
 	}
 	secondBalance = secondBalance - joinFee
@@ -219,7 +219,7 @@ func TestGeneral(t *testing.T) {
 
 	// Content
 	content := firstAuthor.NewContent(audienceTest, "text", []byte("first content"), true, true, 4, uint64(joinFee))
-	if !block.Incorporate(content) {
+	if block.Incorporate(content) != nil {
 		t.Error("could not publish content to audience")
 	}
 	firstBalance = firstBalance - joinFee
@@ -227,7 +227,7 @@ func TestGeneral(t *testing.T) {
 
 	// Sponsorship Offer
 	sponsorOffer := thirdAuthor.NewSponsorshipOffer(audienceTest, "txt", []byte("sponsor"), 20, 20, 4, uint64(joinFee))
-	if !block.Incorporate(sponsorOffer) {
+	if block.Incorporate(sponsorOffer) != nil {
 		t.Error("could not publish sponsor offer to audience")
 	}
 	thirdBalance = thirdBalance - joinFee
@@ -259,7 +259,7 @@ func TestGeneral(t *testing.T) {
 
 	// Accept join audience
 	acceptJoin := firstAuthor.NewAcceptJoinAudience(audienceTest, secondAuthor.PrivateKey.PublicKey(), secondAuthor.PrivateKey.PublicKey(), 2, 5, uint64(joinFee))
-	if !block.Incorporate(acceptJoin) {
+	if block.Incorporate(acceptJoin) != nil {
 		t.Error("could not accept join request to audience")
 	}
 	firstBalance = firstBalance - joinFee
@@ -267,7 +267,7 @@ func TestGeneral(t *testing.T) {
 
 	// Accept sponsor offer
 	sponsordAccept := firstAuthor.NewSponsorshipAcceptance(audienceTest, sponsorOffer, 5, uint64(joinFee))
-	if !block.Incorporate(sponsordAccept) {
+	if block.Incorporate(sponsordAccept) != nil {
 		t.Error("could not accept sponsorship acceptance")
 	}
 	firstBalance = firstBalance - joinFee
@@ -301,7 +301,7 @@ func TestGeneral(t *testing.T) {
 
 	// React to content sent by member2
 	react := secondAuthor.NewReact([]byte("teste"), 1, 6, uint64(joinFee))
-	if !block.Incorporate(react) {
+	if block.Incorporate(react) != nil {
 		t.Error("could not accept react to content")
 	}
 	secondBalance = secondBalance - joinFee
@@ -314,7 +314,7 @@ func TestGeneral(t *testing.T) {
 		readers[token] = token
 	}
 	updateAudience := firstAuthor.NewUpdateAudience(audienceTest, readers, readers, readers, 1, "removing member2 from audience", 6, uint64(joinFee))
-	if !block.Incorporate(updateAudience) {
+	if block.Incorporate(updateAudience) != nil {
 		t.Error("could not accept update audience instruction")
 	}
 	firstBalance = firstBalance - joinFee
@@ -324,7 +324,7 @@ func TestGeneral(t *testing.T) {
 	pubEph, prvEph := crypto.RandomAsymetricKey()
 	ephemeralAuthor := &instructions.Author{PrivateKey: prvEph, Wallet: token} // ephemeral token using eve wallet
 	ephemeral := secondAuthor.NewCreateEphemeral(pubEph, 20, 6, uint64(joinFee))
-	if !block.Incorporate(ephemeral) {
+	if block.Incorporate(ephemeral) != nil {
 		t.Error("could not accept create ephemeral token instruction")
 	}
 	secondBalance = secondBalance - joinFee
@@ -356,7 +356,7 @@ func TestGeneral(t *testing.T) {
 
 	// Secure Channel by member 2
 	secure := ephemeralAuthor.NewSecureChannel([]byte("teste"), uint64(1), []byte("encryptedNonce"), []byte("content"), 7, uint64(joinFee))
-	if !block.Incorporate(secure) {
+	if block.Incorporate(secure) != nil {
 		t.Error("could not accept secure channel instruction")
 	}
 	eveBalance = eveBalance - float64(joinFee)
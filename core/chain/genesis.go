@@ -0,0 +1,241 @@
+// Copyright 2021 The Aereum Authors
+// This file is part of the aereum library.
+//
+// The aereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aereum library. If not, see <http://www.gnu.org/licenses/>.
+package chain
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/store"
+)
+
+var (
+	ErrInvalidGenesis = errors.New("invalid genesis specification")
+)
+
+// GenesisWallet is an initial allocation of oxygens to a wallet token.
+type GenesisWallet struct {
+	Token   string `json:"token"`
+	Balance uint64 `json:"balance"`
+}
+
+// GenesisMember is a member registered at genesis with its caption.
+type GenesisMember struct {
+	Token   string `json:"token"`
+	Caption string `json:"caption"`
+}
+
+// GenesisStage is an audience registered at genesis with its keys and,
+// optionally, the token of the member owning it.
+type GenesisStage struct {
+	Token    string `json:"token"`
+	Moderate string `json:"moderate"`
+	Submit   string `json:"submit"`
+	Flag     byte   `json:"flag"`
+	Owner    string `json:"owner,omitempty"`
+}
+
+// GenesisValidator is a member of the initial validator set with its stake.
+type GenesisValidator struct {
+	Token string `json:"token"`
+	Stake uint64 `json:"stake"`
+}
+
+// GenesisSpec is the JSON specification of the genesis of a network. Tokens
+// are hex encoded, optionally prefixed by 0x. The epoch duration is given in
+// the format of time.ParseDuration, as in "1s".
+type GenesisSpec struct {
+	GenesisTime   time.Time          `json:"genesisTime"`
+	EpochDuration string             `json:"epochDuration"`
+	Wallets       []GenesisWallet    `json:"wallets"`
+	Members       []GenesisMember    `json:"members"`
+	Stages        []GenesisStage     `json:"stages"`
+	Validators    []GenesisValidator `json:"validators"`
+}
+
+func genesisError(format string, a ...interface{}) error {
+	return fmt.Errorf("%w: %v", ErrInvalidGenesis, fmt.Sprintf(format, a...))
+}
+
+func parseGenesisToken(s string) (crypto.Token, error) {
+	var token crypto.Token
+	bytes, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil || len(bytes) != crypto.TokenSize {
+		return token, genesisError("invalid token %q", s)
+	}
+	copy(token[:], bytes)
+	return token, nil
+}
+
+// ParseGenesisSpec parses and checks a JSON genesis specification.
+func ParseGenesisSpec(data []byte) (*GenesisSpec, error) {
+	var spec GenesisSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, genesisError("%v", err)
+	}
+	if err := spec.check(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// LoadGenesisSpec reads the JSON genesis specification on the file at path.
+func LoadGenesisSpec(path string) (*GenesisSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGenesisSpec(data)
+}
+
+func (g *GenesisSpec) check() error {
+	if g.GenesisTime.IsZero() {
+		return genesisError("missing genesis time")
+	}
+	if duration, err := g.Duration(); err != nil || duration <= 0 {
+		return genesisError("invalid epoch duration %q", g.EpochDuration)
+	}
+	supply := uint64(0)
+	for _, wallet := range g.Wallets {
+		if _, err := parseGenesisToken(wallet.Token); err != nil {
+			return err
+		}
+		if supply+wallet.Balance < supply {
+			return genesisError("total supply overflow")
+		}
+		supply += wallet.Balance
+	}
+	members := make(map[string]struct{})
+	captions := make(map[string]struct{})
+	for _, member := range g.Members {
+		if _, err := parseGenesisToken(member.Token); err != nil {
+			return err
+		}
+		if _, ok := members[member.Token]; ok {
+			return genesisError("duplicate member %v", member.Token)
+		}
+		if _, ok := captions[member.Caption]; ok || member.Caption == "" {
+			return genesisError("invalid or duplicate caption %q", member.Caption)
+		}
+		members[member.Token] = struct{}{}
+		captions[member.Caption] = struct{}{}
+	}
+	stages := make(map[string]struct{})
+	for _, stage := range g.Stages {
+		for _, token := range []string{stage.Token, stage.Moderate, stage.Submit} {
+			if _, err := parseGenesisToken(token); err != nil {
+				return err
+			}
+		}
+		if _, ok := stages[stage.Token]; ok {
+			return genesisError("duplicate stage %v", stage.Token)
+		}
+		if _, ok := members[stage.Owner]; stage.Owner != "" && !ok {
+			return genesisError("stage owner %v is not a member", stage.Owner)
+		}
+		stages[stage.Token] = struct{}{}
+	}
+	if len(g.Validators) == 0 {
+		return genesisError("no validators")
+	}
+	for _, validator := range g.Validators {
+		if _, err := parseGenesisToken(validator.Token); err != nil {
+			return err
+		}
+		if supply+validator.Stake < supply {
+			return genesisError("total supply overflow")
+		}
+		supply += validator.Stake
+	}
+	return nil
+}
+
+// Duration returns the duration of an epoch.
+func (g *GenesisSpec) Duration() (time.Duration, error) {
+	return time.ParseDuration(g.EpochDuration)
+}
+
+// ValidatorStakes returns the stake of each validator of the initial set.
+func (g *GenesisSpec) ValidatorStakes() map[crypto.Token]uint64 {
+	stakes := make(map[crypto.Token]uint64)
+	for _, validator := range g.Validators {
+		token, _ := parseGenesisToken(validator.Token)
+		stakes[token] += validator.Stake
+	}
+	return stakes
+}
+
+// NewGenesisStateFromSpec creates the genesis state declared by spec. If dir
+// is empty the state is kept in memory, otherwise it is persisted on dir.
+func NewGenesisStateFromSpec(spec *GenesisSpec, dir string) (*State, error) {
+	if err := spec.check(); err != nil {
+		return nil, err
+	}
+	var state *State
+	if dir == "" {
+		state = newMemoryState()
+	} else {
+		var err error
+		if state, err = newFileState(dir); err != nil {
+			return nil, err
+		}
+	}
+	for _, wallet := range spec.Wallets {
+		token, _ := parseGenesisToken(wallet.Token)
+		state.Wallets.Credit(token, wallet.Balance)
+		state.TotalSupply += wallet.Balance
+	}
+	for _, member := range spec.Members {
+		token, _ := parseGenesisToken(member.Token)
+		state.Members.InsertToken(token)
+		state.setCaption(token, crypto.Hasher([]byte(member.Caption)))
+	}
+	for _, validator := range spec.Validators {
+		token, _ := parseGenesisToken(validator.Token)
+		state.Stakes.Credit(token, validator.Stake)
+		state.StakeTokens.Set(crypto.HashToken(token), crypto.Hash(token))
+		state.TotalSupply += validator.Stake
+	}
+	for _, stage := range spec.Stages {
+		keys := store.StageKeys{Flag: stage.Flag}
+		keys.Stage, _ = parseGenesisToken(stage.Token)
+		keys.Moderate, _ = parseGenesisToken(stage.Moderate)
+		keys.Submit, _ = parseGenesisToken(stage.Submit)
+		state.Stages.SetKeys(crypto.HashToken(keys.Stage), &keys)
+		if owner, err := parseGenesisToken(stage.Owner); err == nil {
+			state.StageOwners.Set(crypto.HashToken(keys.Stage), crypto.Hash(owner))
+			state.Holdings.Credit(owner, 1)
+		}
+	}
+	state.commitEpoch(0)
+	return state, nil
+}
+
+// StateHash returns the root of the genesis state declared by spec.
+func (g *GenesisSpec) StateHash() (crypto.Hash, error) {
+	state, err := NewGenesisStateFromSpec(g, "")
+	if err != nil {
+		return crypto.Hash{}, err
+	}
+	defer state.Close()
+	return state.Root(), nil
+}
@@ -0,0 +1,61 @@
+package chain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Aereum/aereum/core/crypto"
+)
+
+func TestGenesisSpec(t *testing.T) {
+	spec, err := LoadGenesisSpec("testdata/genesis.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := spec.StateHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := NewGenesisStateFromSpec(spec, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+	if state.Root() != hash {
+		t.Error("genesis state hash is not reproducible")
+	}
+	alice := crypto.Hasher([]byte("alice"))
+	var token crypto.Token
+	copy(token[:], alice[:])
+	if _, balance := state.Wallets.Balance(token); balance != 1000000 {
+		t.Errorf("wrong genesis allocation: %v", balance)
+	}
+	if !state.Members.ExistsToken(token) || !state.Captions.ExistsHash(crypto.Hasher([]byte("alice"))) {
+		t.Error("genesis member not registered")
+	}
+	stage, _ := parseGenesisToken(spec.Stages[0].Token)
+	if ok, owner := state.StageOwner(crypto.HashToken(stage)); !ok || owner != token {
+		t.Error("genesis stage owner not registered")
+	}
+	if _, stake := state.Stakes.Balance(token); stake != 700000 {
+		t.Errorf("wrong genesis stake: %v", stake)
+	}
+	if state.TotalSupply != 2500000 || state.Audit() != nil {
+		t.Errorf("wrong genesis supply: %v", state.TotalSupply)
+	}
+	if stakes := spec.ValidatorStakes(); stakes[token] != 700000 || len(stakes) != 2 {
+		t.Error("wrong validator stakes")
+	}
+
+	invalid := []string{
+		`{"epochDuration": "1s", "validators": [{"token": "00", "stake": 1}]}`,
+		`{"genesisTime": "2021-11-18T00:00:00Z", "epochDuration": "0s"}`,
+		`{"genesisTime": "2021-11-18T00:00:00Z", "epochDuration": "1s"}`,
+		`{"genesisTime": "2021-11-18T00:00:00Z", "epochDuration": "1s", "validators": [{"token": "00", "stake": 1}]}`,
+	}
+	for _, data := range invalid {
+		if _, err := ParseGenesisSpec([]byte(data)); !errors.Is(err, ErrInvalidGenesis) {
+			t.Errorf("invalid genesis accepted: %v", data)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2021 The Aereum Authors
+// This file is part of the aereum library.
+//
+// The aereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aereum library. If not, see <http://www.gnu.org/licenses/>.
+package chain
+
+import (
+	"math"
+	"math/bits"
+)
+
+// RewardSchedule determines the amount of new oxygens minted to the publisher
+// of the block of an epoch and the part of the fees collected by the block
+// that is burned instead of paid to the publisher. Both must be deterministic
+// functions of the epoch and of the fees collected by the block. The part of
+// the reward that grows with the fees must not exceed the fees burned,
+// otherwise a publisher could mint aero by paying fees to itself.
+type RewardSchedule interface {
+	Reward(epoch, fees uint64) uint64
+	Burned(fees uint64) uint64
+}
+
+// DecayingReward mints a base reward that halves every HalfLife epochs, but
+// never goes below Floor, plus an issuance indexed to the growth of network
+// activity as measured by the fees burned by the block.
+type DecayingReward struct {
+	Initial  uint64 // base reward at genesis
+	HalfLife uint64 // epochs for the base reward to halve, zero for no decay
+	Floor    uint64 // minimum base reward
+	FeeBurn  uint64 // oxygens burned per million oxygens collected in fees
+	FeeIndex uint64 // oxygens minted per million oxygens burned, at most a million
+}
+
+// DefaultRewardSchedule mints one aero per epoch halving every four years of
+// one second epochs. One fifth of the fees collected is burned and half of it
+// is minted back.
+func DefaultRewardSchedule() *DecayingReward {
+	return &DecayingReward{
+		Initial:  1000000,
+		HalfLife: 4 * 365 * 24 * 60 * 60,
+		Floor:    1000,
+		FeeBurn:  200000,
+		FeeIndex: 500000,
+	}
+}
+
+// perMillion returns value * rate / 1000000, or math.MaxUint64 if it
+// overflows.
+func perMillion(value, rate uint64) uint64 {
+	hi, lo := bits.Mul64(value, rate)
+	if hi >= 1000000 {
+		return math.MaxUint64
+	}
+	result, _ := bits.Div64(hi, lo, 1000000)
+	return result
+}
+
+func (d *DecayingReward) Burned(fees uint64) uint64 {
+	if burned := perMillion(fees, d.FeeBurn); burned < fees {
+		return burned
+	}
+	return fees
+}
+
+func (d *DecayingReward) Reward(epoch, fees uint64) uint64 {
+	base := d.Initial
+	if d.HalfLife > 0 {
+		if halvings := epoch / d.HalfLife; halvings < 64 {
+			base = base >> halvings
+		} else {
+			base = 0
+		}
+	}
+	if base < d.Floor {
+		base = d.Floor
+	}
+	growth := perMillion(d.Burned(fees), d.FeeIndex)
+	if reward, carry := bits.Add64(base, growth, 0); carry == 0 {
+		return reward
+	}
+	return math.MaxUint64
+}
@@ -0,0 +1,64 @@
+package chain
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/instructions"
+)
+
+func TestDecayingReward(t *testing.T) {
+	schedule := &DecayingReward{Initial: 1000, HalfLife: 10, Floor: 100, FeeBurn: 500000, FeeIndex: 500000}
+	if reward := schedule.Reward(9, 0); reward != 1000 {
+		t.Errorf("wrong initial reward: %v", reward)
+	}
+	if reward := schedule.Reward(25, 0); reward != 250 {
+		t.Errorf("wrong decayed reward: %v", reward)
+	}
+	if reward := schedule.Reward(1000, 0); reward != 100 {
+		t.Errorf("reward below floor: %v", reward)
+	}
+	if reward := schedule.Reward(0, 40); reward != 1010 || schedule.Burned(40) != 20 {
+		t.Errorf("wrong fee indexed reward: %v", reward)
+	}
+	if burned := (&DecayingReward{FeeBurn: 2000000}).Burned(40); burned != 40 {
+		t.Errorf("burned more than the fees: %v", burned)
+	}
+	if reward := (&DecayingReward{Initial: 1, FeeBurn: 1000000, FeeIndex: 1000000}).Reward(0, math.MaxUint64); reward != math.MaxUint64 {
+		t.Errorf("reward overflow: %v", reward)
+	}
+	// fees paid to itself by the publisher never mint more than they burn
+	defaults := DefaultRewardSchedule()
+	for _, fees := range []uint64{1, 10, 12345, 1e9} {
+		if growth := defaults.Reward(0, fees) - defaults.Reward(0, 0); growth > defaults.Burned(fees) {
+			t.Errorf("issuance of %v exceeds the %v burned", growth, defaults.Burned(fees))
+		}
+	}
+}
+
+func TestMintReward(t *testing.T) {
+	state, token := NewGenesisState()
+	state.Rewards = &DecayingReward{Initial: 1000, FeeBurn: 500000, FeeIndex: 1000000}
+	_, publisher := crypto.RandomAsymetricKey()
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), &MutatingState{State: state})
+	receiver, _ := crypto.RandomAsymetricKey()
+	if block.Incorporate(instructions.NewSingleReciepientTransfer(token, receiver, "", 100, 1, 10)) != nil {
+		t.Fatal("could not transfer")
+	}
+	undo, _ := state.IncorporateBlock(block)
+	block.Seal(publisher)
+	if block.Minted != 1005 {
+		t.Errorf("wrong minted reward on block: %v", block.Minted)
+	}
+	if _, balance := state.Wallets.Balance(publisher.PublicKey()); balance != 1010 {
+		t.Errorf("publisher did not receive fees and reward: %v", balance)
+	}
+	if state.TotalSupply != GenesisSupply+1000 || state.Audit() != nil {
+		t.Errorf("wrong total supply: %v", state.TotalSupply)
+	}
+	state.RevertBlock(undo)
+	if state.TotalSupply != GenesisSupply || state.Audit() != nil {
+		t.Errorf("total supply not reverted: %v", state.TotalSupply)
+	}
+}
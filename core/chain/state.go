@@ -17,106 +17,807 @@ package chain
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
 
 	"github.com/Aereum/aereum/core/crypto"
 	"github.com/Aereum/aereum/core/store"
+	"github.com/Aereum/aereum/core/util"
 )
 
 var (
 	ErrNotSubsequentBlock = errors.New("cannot incorporate a non-subsequent block")
 	ErrIncorporationError = errors.New("could not incorporate block")
+	ErrNoState            = errors.New("no state found on directory")
+	ErrCorruptedState     = errors.New("state files are not at the same epoch")
+	ErrIncompleteState    = errors.New("state was left incomplete by an interrupted block")
+	ErrStateRootMismatch  = errors.New("state root does not match block state root")
+	ErrSupplyMismatch     = errors.New("total supply does not match wallet balances and stakes")
+	ErrNotConserved       = errors.New("block mutations do not conserve supply")
+	ErrOverflow           = errors.New("balances overflow")
 )
 
+// file names of the vaults of a state persisted on a directory
+const (
+	membersFile         = "members.dat"
+	captionsFile        = "captions.dat"
+	walletsFile         = "wallets.dat"
+	stagesFile          = "stages.dat"
+	sponsorOffersFile   = "sponsoroffers.dat"
+	sponsorGrantedFile  = "sponsorgranted.dat"
+	powerOfAttorneyFile = "poa.dat"
+	ephemeralFile       = "ephemeral.dat"
+	includedFile        = "included.dat"
+	stakesFile          = "stakes.dat"
+	unbondingFile       = "unbonding.dat"
+	releasesFile        = "releases.dat"
+	poolsFile           = "pools.dat"
+	poolSharesFile      = "poolshares.dat"
+	delegationsFile     = "delegations.dat"
+	memberCaptionsFile  = "membercaptions.dat"
+	stageOwnersFile     = "stageowners.dat"
+	guardiansFile       = "guardians.dat"
+	recoveriesFile      = "recoveries.dat"
+	multisigsFile       = "multisigs.dat"
+	escrowsFile         = "escrows.dat"
+	escrowedFile        = "escrowed.dat"
+	escrowReleasesFile  = "escrowreleases.dat"
+	slashedFile         = "slashed.dat"
+	holdingsFile        = "holdings.dat"
+	stakeTokensFile     = "staketokens.dat"
+	// present on the directory while the vaults are being written
+	incompleteFile = "incomplete"
+)
+
+// stateFiles are the files of the vaults of a state persisted on a directory.
+var stateFiles = []string{
+	membersFile, captionsFile, walletsFile, stagesFile, sponsorOffersFile,
+	sponsorGrantedFile, powerOfAttorneyFile, ephemeralFile, includedFile,
+	stakesFile, unbondingFile, releasesFile, poolsFile, poolSharesFile,
+	delegationsFile, memberCaptionsFile, stageOwnersFile, guardiansFile,
+	recoveriesFile, multisigsFile, escrowsFile, escrowedFile,
+	escrowReleasesFile, slashedFile, holdingsFile, stakeTokensFile,
+}
+
 type State struct {
 	Epoch           uint64
+	LastHash        crypto.Hash // hash of the last incorporated block, zero at genesis
 	Members         *store.HashVault
-	Captions        *store.HashVault
+	Captions        *store.Registry // caption -> owner token
 	Wallets         *store.Wallet
 	Stages          *store.Stage
 	SponsorOffers   *store.HashExpireVault
 	SponsorGranted  *store.Sponsor
-	PowerOfAttorney *store.HashVault
+	PowerOfAttorney *store.AttorneyVault
 	EphemeralTokens *store.HashExpireVault
-	SponsorExpire   map[uint64]crypto.Hash
-	EphemeralExpire map[uint64]crypto.Hash
+	Included        *store.HashExpireVault // recently included instruction hashes
+	Stakes          *store.Wallet
+	Unbonding       *store.Wallet            // withdrawn stake not yet released
+	Releases        *store.HashExpireVault   // release epoch of unbonding stake
+	Pools           *store.Wallet            // stake delegated to each validator
+	PoolShares      *store.Wallet            // shares issued by each validator pool
+	Delegations     *store.Wallet            // shares of each delegation
+	MemberCaptions  *store.Registry          // member -> caption
+	StageOwners     *store.Registry          // stage -> owner token
+	Guardians       *store.Registry          // member -> hash of guardians and threshold
+	Recoveries      *store.HashExpireVault   // pending recovery -> epoch it can be completed
+	Multisigs       *store.Registry          // wallet -> hash of signers and threshold
+	Escrows         *store.EscrowVault       // terms of escrowed transfers
+	Escrowed        *store.Wallet            // aero of escrowed transfers not yet released
+	EscrowReleases  *store.HashExpireVault   // next release epoch of escrowed transfers
+	Slashed         *store.HashVault         // validators punished for misbehaviour
+	Holdings        *store.Wallet            // member -> powers of attorney granted and stages owned
+	StakeTokens     *store.Registry          // validator -> token, of every stake or pool
+	SponsorExpire   map[uint64][]crypto.Hash // expire epoch -> sponsorship offers
+	EphemeralExpire map[uint64][]crypto.Hash // expire epoch -> ephemeral tokens
+	IncludedExpire  map[uint64][]crypto.Hash // expire epoch -> instruction hashes
+	ReleaseExpire   map[uint64][]crypto.Hash // release epoch -> unbonding stakes
+	EscrowExpire    map[uint64][]crypto.Hash // release epoch -> escrowed transfers
+	Rewards         RewardSchedule           // nil if no aero is minted
+	TotalSupply     uint64
+	dir             string // directory the state is persisted on, empty if on memory
 }
 
-func NewGenesisState() (*State, crypto.PrivateKey) {
-	pubKey, prvKey := crypto.RandomAsymetricKey()
-	state := State{
+func newMemoryState() *State {
+	return &State{
 		Epoch:           0,
 		Members:         store.NewHashVault("members", 0, 8),
-		Captions:        store.NewHashVault("captions", 0, 8),
+		Captions:        store.NewRegistry("captions", 0, 8),
 		Wallets:         store.NewMemoryWalletStore(0, 8),
 		Stages:          store.NewMemoryAudienceStore(0, 8),
 		SponsorOffers:   store.NewExpireHashVault("sponsoroffer", 0, 8),
 		SponsorGranted:  store.NewSponsorShipOfferStore(0, 8),
-		PowerOfAttorney: store.NewHashVault("poa", 0, 8),
+		PowerOfAttorney: store.NewAttorneyVault("poa", 0, 8),
 		EphemeralTokens: store.NewExpireHashVault("ephemeral", 0, 8),
-		SponsorExpire:   make(map[uint64]crypto.Hash),
-		EphemeralExpire: make(map[uint64]crypto.Hash),
+		Included:        store.NewExpireHashVault("included", 0, 8),
+		Stakes:          store.NewMemoryWalletStore(0, 8),
+		Unbonding:       store.NewMemoryWalletStore(0, 8),
+		Releases:        store.NewExpireHashVault("releases", 0, 8),
+		Pools:           store.NewMemoryWalletStore(0, 8),
+		PoolShares:      store.NewMemoryWalletStore(0, 8),
+		Delegations:     store.NewMemoryWalletStore(0, 8),
+		MemberCaptions:  store.NewRegistry("membercaptions", 0, 8),
+		StageOwners:     store.NewRegistry("stageowners", 0, 8),
+		Guardians:       store.NewRegistry("guardians", 0, 8),
+		Recoveries:      store.NewExpireHashVault("recoveries", 0, 8),
+		Multisigs:       store.NewRegistry("multisigs", 0, 8),
+		Escrows:         store.NewEscrowVault("escrows", 0, 8),
+		Escrowed:        store.NewMemoryWalletStore(0, 8),
+		EscrowReleases:  store.NewExpireHashVault("escrowreleases", 0, 8),
+		Slashed:         store.NewHashVault("slashed", 0, 8),
+		Holdings:        store.NewMemoryWalletStore(0, 8),
+		StakeTokens:     store.NewRegistry("staketokens", 0, 8),
+		SponsorExpire:   make(map[uint64][]crypto.Hash),
+		EphemeralExpire: make(map[uint64][]crypto.Hash),
+		IncludedExpire:  make(map[uint64][]crypto.Hash),
+		ReleaseExpire:   make(map[uint64][]crypto.Hash),
+		EscrowExpire:    make(map[uint64][]crypto.Hash),
 	}
-	state.Members.InsertToken(pubKey)
-	state.Captions.InsertHash(crypto.Hasher([]byte("Aereum Network Genesis")))
-	state.Wallets.Credit(pubKey, 1e6)
-	return &state, prvKey
 }
 
-func NewGenesisStateWithToken(token crypto.PrivateKey) *State {
-	hash := crypto.HashToken(token.PublicKey())
-	state := State{
+// newFileState creates the vaults of a new state on dir. The state is marked
+// incomplete until the caller commits the genesis epoch.
+func newFileState(dir string) (*State, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	path := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+	markIncomplete(dir)
+	return &State{
 		Epoch:           0,
-		Members:         store.NewHashVault("members", 0, 8),
-		Captions:        store.NewHashVault("captions", 0, 8),
-		Wallets:         store.NewMemoryWalletStore(0, 8),
-		Stages:          store.NewMemoryAudienceStore(0, 8),
-		SponsorOffers:   store.NewExpireHashVault("sponsoroffer", 0, 8),
-		SponsorGranted:  store.NewSponsorShipOfferStore(0, 8),
-		PowerOfAttorney: store.NewHashVault("poa", 0, 8),
-		EphemeralTokens: store.NewExpireHashVault("ephemeral", 0, 8),
-		SponsorExpire:   make(map[uint64]crypto.Hash),
-		EphemeralExpire: make(map[uint64]crypto.Hash),
+		Members:         store.NewFileHashVault(path(membersFile), 0, 8),
+		Captions:        store.NewFileRegistry(path(captionsFile), 0, 8),
+		Wallets:         store.NewFileWalletStore(path(walletsFile), 0, 8),
+		Stages:          store.NewFileAudienceStore(path(stagesFile), 0, 8),
+		SponsorOffers:   store.NewFileExpireHashVault(path(sponsorOffersFile), 0, 8),
+		SponsorGranted:  store.NewFileSponsorShipOfferStore(path(sponsorGrantedFile), 0, 8),
+		PowerOfAttorney: store.NewFileAttorneyVault(path(powerOfAttorneyFile), 0, 8),
+		EphemeralTokens: store.NewFileExpireHashVault(path(ephemeralFile), 0, 8),
+		Included:        store.NewFileExpireHashVault(path(includedFile), 0, 8),
+		Stakes:          store.NewFileWalletStore(path(stakesFile), 0, 8),
+		Unbonding:       store.NewFileWalletStore(path(unbondingFile), 0, 8),
+		Releases:        store.NewFileExpireHashVault(path(releasesFile), 0, 8),
+		Pools:           store.NewFileWalletStore(path(poolsFile), 0, 8),
+		PoolShares:      store.NewFileWalletStore(path(poolSharesFile), 0, 8),
+		Delegations:     store.NewFileWalletStore(path(delegationsFile), 0, 8),
+		MemberCaptions:  store.NewFileRegistry(path(memberCaptionsFile), 0, 8),
+		StageOwners:     store.NewFileRegistry(path(stageOwnersFile), 0, 8),
+		Guardians:       store.NewFileRegistry(path(guardiansFile), 0, 8),
+		Recoveries:      store.NewFileExpireHashVault(path(recoveriesFile), 0, 8),
+		Multisigs:       store.NewFileRegistry(path(multisigsFile), 0, 8),
+		Escrows:         store.NewFileEscrowVault(path(escrowsFile), 0, 8),
+		Escrowed:        store.NewFileWalletStore(path(escrowedFile), 0, 8),
+		EscrowReleases:  store.NewFileExpireHashVault(path(escrowReleasesFile), 0, 8),
+		Slashed:         store.NewFileHashVault(path(slashedFile), 0, 8),
+		Holdings:        store.NewFileWalletStore(path(holdingsFile), 0, 8),
+		StakeTokens:     store.NewFileRegistry(path(stakeTokensFile), 0, 8),
+		SponsorExpire:   make(map[uint64][]crypto.Hash),
+		EphemeralExpire: make(map[uint64][]crypto.Hash),
+		IncludedExpire:  make(map[uint64][]crypto.Hash),
+		ReleaseExpire:   make(map[uint64][]crypto.Hash),
+		EscrowExpire:    make(map[uint64][]crypto.Hash),
+		dir:             dir,
+	}, nil
+}
+
+// OpenState reopens the state persisted on dir, either by a previous call to
+// Close or by the incorporation of blocks before the process exited. It
+// returns ErrNoState if dir does not contain a state, ErrIncompleteState if
+// the process exited while a block was being incorporated or reverted, and
+// store.ErrStoreVersion if the state was written by another file format.
+func OpenState(dir string) (*State, error) {
+	path := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+	if _, err := os.Stat(path(membersFile)); os.IsNotExist(err) {
+		return nil, ErrNoState
+	}
+	if _, err := os.Stat(path(incompleteFile)); !os.IsNotExist(err) {
+		return nil, ErrIncompleteState
+	}
+	for _, name := range stateFiles {
+		// a vault missing from an existing state was added by a later format
+		if err := store.CheckFileVersion(path(name)); err == store.ErrStoreVersion || os.IsNotExist(err) {
+			return nil, store.ErrStoreVersion
+		}
+	}
+	state := &State{
+		Members:         store.OpenFileHashVault(path(membersFile)),
+		Captions:        store.OpenFileRegistry(path(captionsFile)),
+		Wallets:         store.OpenFileWalletStore(path(walletsFile)),
+		Stages:          store.OpenFileAudienceStore(path(stagesFile)),
+		SponsorOffers:   store.OpenFileExpireHashVault(path(sponsorOffersFile)),
+		SponsorGranted:  store.OpenFileSponsorShipOfferStore(path(sponsorGrantedFile)),
+		PowerOfAttorney: store.OpenFileAttorneyVault(path(powerOfAttorneyFile)),
+		EphemeralTokens: store.OpenFileExpireHashVault(path(ephemeralFile)),
+		Included:        store.OpenFileExpireHashVault(path(includedFile)),
+		Stakes:          store.OpenFileWalletStore(path(stakesFile)),
+		Unbonding:       store.OpenFileWalletStore(path(unbondingFile)),
+		Releases:        store.OpenFileExpireHashVault(path(releasesFile)),
+		Pools:           store.OpenFileWalletStore(path(poolsFile)),
+		PoolShares:      store.OpenFileWalletStore(path(poolSharesFile)),
+		Delegations:     store.OpenFileWalletStore(path(delegationsFile)),
+		MemberCaptions:  store.OpenFileRegistry(path(memberCaptionsFile)),
+		StageOwners:     store.OpenFileRegistry(path(stageOwnersFile)),
+		Guardians:       store.OpenFileRegistry(path(guardiansFile)),
+		Recoveries:      store.OpenFileExpireHashVault(path(recoveriesFile)),
+		Multisigs:       store.OpenFileRegistry(path(multisigsFile)),
+		Escrows:         store.OpenFileEscrowVault(path(escrowsFile)),
+		Escrowed:        store.OpenFileWalletStore(path(escrowedFile)),
+		EscrowReleases:  store.OpenFileExpireHashVault(path(escrowReleasesFile)),
+		Slashed:         store.OpenFileHashVault(path(slashedFile)),
+		Holdings:        store.OpenFileWalletStore(path(holdingsFile)),
+		StakeTokens:     store.OpenFileRegistry(path(stakeTokensFile)),
+		SponsorExpire:   make(map[uint64][]crypto.Hash),
+		EphemeralExpire: make(map[uint64][]crypto.Hash),
+		IncludedExpire:  make(map[uint64][]crypto.Hash),
+		ReleaseExpire:   make(map[uint64][]crypto.Hash),
+		EscrowExpire:    make(map[uint64][]crypto.Hash),
+		dir:             dir,
+	}
+	if state.Members == nil || state.Captions == nil || state.Wallets == nil ||
+		state.Stages == nil || state.SponsorOffers == nil || state.SponsorGranted == nil ||
+		state.PowerOfAttorney == nil || state.EphemeralTokens == nil || state.Included == nil ||
+		state.Stakes == nil || state.Unbonding == nil || state.Releases == nil ||
+		state.Pools == nil || state.PoolShares == nil || state.Delegations == nil ||
+		state.MemberCaptions == nil || state.StageOwners == nil || state.Guardians == nil ||
+		state.Recoveries == nil || state.Multisigs == nil || state.Escrows == nil ||
+		state.Escrowed == nil || state.EscrowReleases == nil || state.Slashed == nil ||
+		state.Holdings == nil || state.StakeTokens == nil {
+		state.Close()
+		return nil, ErrNoState
+	}
+	state.Epoch = state.Members.Epoch()
+	state.LastHash = state.Members.Head()
+	for _, epoch := range state.vaultEpochs() {
+		if epoch != state.Epoch {
+			state.Close()
+			return nil, ErrCorruptedState
+		}
+	}
+	state.indexExpire()
+	state.TotalSupply = state.balancesTotal()
+	return state, nil
+}
+
+func (s *State) vaultEpochs() []uint64 {
+	return []uint64{
+		s.Members.Epoch(),
+		s.Captions.Epoch(),
+		s.Wallets.Epoch(),
+		s.Stages.Epoch(),
+		s.SponsorOffers.Epoch(),
+		s.SponsorGranted.Epoch(),
+		s.PowerOfAttorney.Epoch(),
+		s.EphemeralTokens.Epoch(),
+		s.Included.Epoch(),
+		s.Stakes.Epoch(),
+		s.Unbonding.Epoch(),
+		s.Releases.Epoch(),
+		s.Pools.Epoch(),
+		s.PoolShares.Epoch(),
+		s.Delegations.Epoch(),
+		s.MemberCaptions.Epoch(),
+		s.StageOwners.Epoch(),
+		s.Guardians.Epoch(),
+		s.Recoveries.Epoch(),
+		s.Multisigs.Epoch(),
+		s.Escrows.Epoch(),
+		s.Escrowed.Epoch(),
+		s.EscrowReleases.Epoch(),
+		s.Slashed.Epoch(),
+		s.Holdings.Epoch(),
+		s.StakeTokens.Epoch(),
+	}
+}
+
+// Root returns a commitment to the entire state. It combines the epoch with
+// the hashes of every vault in a fixed order.
+func (s *State) Root() crypto.Hash {
+	return stateRoot(s.Epoch, s.vaultHashes())
+}
+
+// vaultHashes returns the hashes of the vaults in the order of the vault
+// indexes.
+func (s *State) vaultHashes() []crypto.Hash {
+	return []crypto.Hash{
+		s.Members.Hash(),
+		s.Captions.Hash(),
+		s.Wallets.Hash(),
+		s.Stages.Hash(),
+		s.SponsorOffers.Hash(),
+		s.SponsorGranted.Hash(),
+		s.PowerOfAttorney.Hash(),
+		s.EphemeralTokens.Hash(),
+		s.Included.Hash(),
+		s.Stakes.Hash(),
+		s.Unbonding.Hash(),
+		s.Releases.Hash(),
+		s.Pools.Hash(),
+		s.PoolShares.Hash(),
+		s.Delegations.Hash(),
+		s.MemberCaptions.Hash(),
+		s.StageOwners.Hash(),
+		s.Guardians.Hash(),
+		s.Recoveries.Hash(),
+		s.Multisigs.Hash(),
+		s.Escrows.Hash(),
+		s.Escrowed.Hash(),
+		s.EscrowReleases.Hash(),
+		s.Slashed.Hash(),
+		s.Holdings.Hash(),
+		s.StakeTokens.Hash(),
+	}
+}
+
+func stateRoot(epoch uint64, vaults []crypto.Hash) crypto.Hash {
+	bytes := make([]byte, 0)
+	util.PutUint64(epoch, &bytes)
+	for _, hash := range vaults {
+		bytes = append(bytes, hash[:]...)
+	}
+	return crypto.Hasher(bytes)
+}
+
+// CheckStateRoot verifies that the state, after incorporating b, reached the
+// same state root announced by the publisher of b.
+func (s *State) CheckStateRoot(b *Block) error {
+	if s.Epoch != b.Epoch() || s.Root() != b.StateRoot {
+		return ErrStateRootMismatch
+	}
+	return nil
+}
+
+// setEpoch updates the state epoch and records it on every vault. The hash of
+// the last incorporated block is recorded next to it on the members vault.
+func (s *State) setEpoch(epoch uint64) {
+	s.Epoch = epoch
+	s.Members.SetHead(s.LastHash)
+	s.Members.SetEpoch(epoch)
+	s.Captions.SetEpoch(epoch)
+	s.Wallets.SetEpoch(epoch)
+	s.Stages.SetEpoch(epoch)
+	s.SponsorOffers.SetEpoch(epoch)
+	s.SponsorGranted.SetEpoch(epoch)
+	s.PowerOfAttorney.SetEpoch(epoch)
+	s.EphemeralTokens.SetEpoch(epoch)
+	s.Included.SetEpoch(epoch)
+	s.Stakes.SetEpoch(epoch)
+	s.Unbonding.SetEpoch(epoch)
+	s.Releases.SetEpoch(epoch)
+	s.Pools.SetEpoch(epoch)
+	s.PoolShares.SetEpoch(epoch)
+	s.Delegations.SetEpoch(epoch)
+	s.MemberCaptions.SetEpoch(epoch)
+	s.StageOwners.SetEpoch(epoch)
+	s.Guardians.SetEpoch(epoch)
+	s.Recoveries.SetEpoch(epoch)
+	s.Multisigs.SetEpoch(epoch)
+	s.Escrows.SetEpoch(epoch)
+	s.Escrowed.SetEpoch(epoch)
+	s.EscrowReleases.SetEpoch(epoch)
+	s.Slashed.SetEpoch(epoch)
+	s.Holdings.SetEpoch(epoch)
+	s.StakeTokens.SetEpoch(epoch)
+}
+
+// MemoryCopy returns a copy of the state kept on memory. Blocks can be
+// incorporated into the copy without changing s, even if s is persisted on
+// disk. The copy must be closed when no longer needed.
+func (s *State) MemoryCopy() *State {
+	state := &State{
+		Epoch:           s.Epoch,
+		LastHash:        s.LastHash,
+		Members:         s.Members.MemoryCopy(),
+		Captions:        s.Captions.MemoryCopy(),
+		Wallets:         s.Wallets.MemoryCopy(),
+		Stages:          s.Stages.MemoryCopy(),
+		SponsorOffers:   s.SponsorOffers.MemoryCopy(),
+		SponsorGranted:  s.SponsorGranted.MemoryCopy(),
+		PowerOfAttorney: s.PowerOfAttorney.MemoryCopy(),
+		EphemeralTokens: s.EphemeralTokens.MemoryCopy(),
+		Included:        s.Included.MemoryCopy(),
+		Stakes:          s.Stakes.MemoryCopy(),
+		Unbonding:       s.Unbonding.MemoryCopy(),
+		Releases:        s.Releases.MemoryCopy(),
+		Pools:           s.Pools.MemoryCopy(),
+		PoolShares:      s.PoolShares.MemoryCopy(),
+		Delegations:     s.Delegations.MemoryCopy(),
+		MemberCaptions:  s.MemberCaptions.MemoryCopy(),
+		StageOwners:     s.StageOwners.MemoryCopy(),
+		Guardians:       s.Guardians.MemoryCopy(),
+		Recoveries:      s.Recoveries.MemoryCopy(),
+		Multisigs:       s.Multisigs.MemoryCopy(),
+		Escrows:         s.Escrows.MemoryCopy(),
+		Escrowed:        s.Escrowed.MemoryCopy(),
+		EscrowReleases:  s.EscrowReleases.MemoryCopy(),
+		Slashed:         s.Slashed.MemoryCopy(),
+		Holdings:        s.Holdings.MemoryCopy(),
+		StakeTokens:     s.StakeTokens.MemoryCopy(),
+		Rewards:         s.Rewards,
+		TotalSupply:     s.TotalSupply,
+	}
+	state.indexExpire()
+	return state
+}
+
+// Dir returns the directory the state is persisted on, empty if the state is
+// kept on memory.
+func (s *State) Dir() string {
+	return s.dir
+}
+
+// beginWrite marks the state persisted on disk as incomplete before its vaults
+// are written.
+func (s *State) beginWrite() {
+	if s.dir != "" {
+		markIncomplete(s.dir)
+	}
+}
+
+// markIncomplete durably creates the incomplete marker on dir. Like the vault
+// stores, it panics on IO errors.
+func markIncomplete(dir string) {
+	file, err := os.Create(filepath.Join(dir, incompleteFile))
+	if err != nil {
+		panic(err)
+	}
+	if err := file.Sync(); err != nil {
+		panic(err)
 	}
-	state.Members.InsertHash(hash)
-	state.Captions.InsertHash(crypto.Hasher([]byte("Aereum Network Genesis")))
-	state.Wallets.CreditHash(hash, 1e6)
-	return &state
+	file.Close()
+	syncDir(dir)
 }
 
-func (s *State) IncorporateBlock(b *Block) {
-	for hash := range b.mutations.NewCaption {
-		s.Captions.InsertHash(hash)
+// commitEpoch records epoch on every vault. If the state is persisted on disk,
+// the vault data is synced before the epoch headers are written and synced,
+// and only then the state is no longer marked incomplete.
+func (s *State) commitEpoch(epoch uint64) {
+	if s.dir == "" {
+		s.setEpoch(epoch)
+		return
+	}
+	s.sync()
+	s.setEpoch(epoch)
+	s.sync()
+	if err := os.Remove(filepath.Join(s.dir, incompleteFile)); err != nil {
+		panic(err)
+	}
+	syncDir(s.dir)
+}
+
+// sync flushes every vault of the state to durable storage.
+func (s *State) sync() {
+	s.Members.Sync()
+	s.Captions.Sync()
+	s.Wallets.Sync()
+	s.Stages.Sync()
+	s.SponsorOffers.Sync()
+	s.SponsorGranted.Sync()
+	s.PowerOfAttorney.Sync()
+	s.EphemeralTokens.Sync()
+	s.Included.Sync()
+	s.Stakes.Sync()
+	s.Unbonding.Sync()
+	s.Releases.Sync()
+	s.Pools.Sync()
+	s.PoolShares.Sync()
+	s.Delegations.Sync()
+	s.MemberCaptions.Sync()
+	s.StageOwners.Sync()
+	s.Guardians.Sync()
+	s.Recoveries.Sync()
+	s.Multisigs.Sync()
+	s.Escrows.Sync()
+	s.Escrowed.Sync()
+	s.EscrowReleases.Sync()
+	s.Slashed.Sync()
+	s.Holdings.Sync()
+	s.StakeTokens.Sync()
+}
+
+// syncDir flushes the entries of dir, so that created and removed files
+// survive a crash.
+func syncDir(dir string) {
+	file, err := os.Open(dir)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+	if err := file.Sync(); err != nil {
+		panic(err)
+	}
+}
+
+// Close stops every vault of the state, releasing the underlying files if the
+// state is persisted on disk.
+func (s *State) Close() {
+	if s.Members != nil {
+		s.Members.Close()
+	}
+	if s.Captions != nil {
+		s.Captions.Close()
+	}
+	if s.Wallets != nil {
+		s.Wallets.Close()
+	}
+	if s.Stages != nil {
+		s.Stages.Close()
+	}
+	if s.SponsorOffers != nil {
+		s.SponsorOffers.Close()
+	}
+	if s.SponsorGranted != nil {
+		s.SponsorGranted.Close()
 	}
+	if s.PowerOfAttorney != nil {
+		s.PowerOfAttorney.Close()
+	}
+	if s.EphemeralTokens != nil {
+		s.EphemeralTokens.Close()
+	}
+	if s.Included != nil {
+		s.Included.Close()
+	}
+	if s.Stakes != nil {
+		s.Stakes.Close()
+	}
+	if s.Unbonding != nil {
+		s.Unbonding.Close()
+	}
+	if s.Releases != nil {
+		s.Releases.Close()
+	}
+	if s.Pools != nil {
+		s.Pools.Close()
+	}
+	if s.PoolShares != nil {
+		s.PoolShares.Close()
+	}
+	if s.Delegations != nil {
+		s.Delegations.Close()
+	}
+	if s.MemberCaptions != nil {
+		s.MemberCaptions.Close()
+	}
+	if s.StageOwners != nil {
+		s.StageOwners.Close()
+	}
+	if s.Guardians != nil {
+		s.Guardians.Close()
+	}
+	if s.Recoveries != nil {
+		s.Recoveries.Close()
+	}
+	if s.Multisigs != nil {
+		s.Multisigs.Close()
+	}
+	if s.Escrows != nil {
+		s.Escrows.Close()
+	}
+	if s.Escrowed != nil {
+		s.Escrowed.Close()
+	}
+	if s.EscrowReleases != nil {
+		s.EscrowReleases.Close()
+	}
+	if s.Slashed != nil {
+		s.Slashed.Close()
+	}
+	if s.Holdings != nil {
+		s.Holdings.Close()
+	}
+	if s.StakeTokens != nil {
+		s.StakeTokens.Close()
+	}
+}
+
+// GenesisSupply is the aero created for the token of a genesis state without
+// specification. GenesisStake of it is staked, so that the token validates
+// blocks, and the rest is credited to its wallet.
+const (
+	GenesisSupply = 1e6
+	GenesisStake  = GenesisSupply / 2
+)
+
+func (s *State) genesis(token crypto.Token) {
+	hash := crypto.HashToken(token)
+	s.Members.InsertHash(hash)
+	s.setCaption(token, crypto.Hasher([]byte("Aereum Network Genesis")))
+	s.Wallets.CreditHash(hash, GenesisSupply-GenesisStake)
+	s.Stakes.CreditHash(hash, GenesisStake)
+	s.StakeTokens.Set(hash, crypto.Hash(token))
+	s.TotalSupply = GenesisSupply
+}
+
+// reward returns the new aero minted to the publisher of b.
+func (s *State) reward(b *Block) uint64 {
+	if s.Rewards == nil {
+		return 0
+	}
+	return s.Rewards.Reward(b.Epoch(), b.FeesCollected)
+}
+
+// burned returns the part of the fees collected by b that is burned.
+func (s *State) burned(b *Block) uint64 {
+	if s.Rewards == nil {
+		return 0
+	}
+	return s.Rewards.Burned(b.FeesCollected)
+}
+
+// balancesTotal returns the sum of every wallet balance, stake, delegated
+// stake, unbonding stake and escrowed transfer.
+func (s *State) balancesTotal() uint64 {
+	return s.Wallets.Total() + s.Stakes.Total() + s.Pools.Total() + s.Unbonding.Total() + s.Escrowed.Total()
+}
+
+// Audit checks that the total supply matches the sum of the balances of every
+// wallet, stake, delegated stake, unbonding stake and escrowed transfer.
+func (s *State) Audit() error {
+	if s.balancesTotal() != s.TotalSupply {
+		return ErrSupplyMismatch
+	}
+	return nil
+}
+
+func NewGenesisState() (*State, crypto.PrivateKey) {
+	pubKey, prvKey := crypto.RandomAsymetricKey()
+	state := newMemoryState()
+	state.genesis(pubKey)
+	return state, prvKey
+}
+
+func NewGenesisStateWithToken(token crypto.PrivateKey) *State {
+	state := newMemoryState()
+	state.genesis(token.PublicKey())
+	return state
+}
+
+// NewFileGenesisStateWithToken creates on dir a new genesis state persisted
+// on disk. Any state previously persisted on dir is overwritten.
+func NewFileGenesisStateWithToken(dir string, token crypto.PrivateKey) (*State, error) {
+	state, err := newFileState(dir)
+	if err != nil {
+		return nil, err
+	}
+	state.genesis(token.PublicKey())
+	state.commitEpoch(0)
+	return state, nil
+}
+
+// IncorporateBlock applies the mutations of b to the state. It returns an undo
+// record that can be used by RevertBlock to restore the previous state. The
+// mutations of b must conserve the supply, otherwise ErrNotConserved is
+// returned. If a balance cannot be credited or debited the state is left
+// unchanged and ErrIncorporationError is returned. A state persisted on disk is
+// marked incomplete until the block is durable, see OpenState.
+func (s *State) IncorporateBlock(b *Block) (*Undo, error) {
+	if !b.mutations.conserves(b.FeesCollected) {
+		return nil, ErrNotConserved
+	}
+	minted := s.reward(b)
+	burned := s.burned(b)
+	earnings, okEarnings := util.AddUint64(b.FeesCollected-burned, minted)
+	supply, okSupply := util.AddUint64(s.TotalSupply, minted)
+	destroyed, okDestroyed := util.AddUint64(b.mutations.Burned, burned)
+	if !okEarnings || !okSupply || !okDestroyed || supply < destroyed {
+		return nil, ErrOverflow
+	}
+	supply -= destroyed
+	s.beginWrite()
+	undo := newUndo(s.Epoch, s.LastHash, s.TotalSupply)
+	ok := s.sweepExpired(b.Epoch(), undo)
+	publisher := crypto.HashToken(b.Publisher)
+	_, stake := s.Stakes.BalanceHash(publisher)
+	_, pooled := s.Pools.BalanceHash(publisher)
+	s.applyCaptions(b.mutations, undo)
 	for hash := range b.mutations.NewMembers {
-		s.Members.InsertHash(hash)
+		if s.Members.InsertHash(hash) {
+			undo.insertedMembers = append(undo.insertedMembers, hash)
+		}
 	}
-	for acc, delta := range b.mutations.DeltaWallets {
-		if delta > 0 {
-			s.Wallets.CreditHash(acc, uint64(delta))
-		} else if delta < 0 {
-			s.Wallets.DebitHash(acc, uint64(-delta))
+	for hash := range b.mutations.RemovedMembers {
+		if s.Members.RemoveHash(hash) {
+			undo.removedMembers = append(undo.removedMembers, hash)
 		}
 	}
-	for hash := range b.mutations.GrantPower {
-		s.PowerOfAttorney.InsertHash(hash)
+	for stage, owner := range b.mutations.StageOwner {
+		undo.keepEntry(stage, s.StageOwners)
+		s.StageOwners.Set(stage, crypto.Hash(owner))
+	}
+	s.applyRecoveries(b.mutations, undo)
+	for wallet, policy := range b.mutations.NewMultisig {
+		undo.keepEntry(wallet, s.Multisigs)
+		s.Multisigs.Set(wallet, policy)
+	}
+	for hash, token := range b.mutations.StakeToken {
+		undo.keepEntry(hash, s.StakeTokens)
+		s.StakeTokens.Set(hash, crypto.Hash(token))
+	}
+	s.applyEscrows(b.mutations, undo)
+	for hash := range b.mutations.Slashed {
+		if s.Slashed.InsertHash(hash) {
+			undo.slashed = append(undo.slashed, hash)
+		}
+	}
+	ok = applyDeltas(s.Wallets, b.mutations.DeltaWallets, undo) && ok
+	ok = applyDeltas(s.Stakes, b.mutations.DeltaStakes, undo) && ok
+	ok = applyDeltas(s.Pools, b.mutations.DeltaPools, undo) && ok
+	ok = applyDeltas(s.PoolShares, b.mutations.DeltaShares, undo) && ok
+	ok = applyDeltas(s.Delegations, b.mutations.DeltaDelegations, undo) && ok
+	ok = applyDeltas(s.Escrowed, b.mutations.DeltaEscrowed, undo) && ok
+	ok = applyDeltas(s.Holdings, b.mutations.DeltaHoldings, undo) && ok
+	for acc, value := range b.mutations.Unbonding {
+		ok = s.unbond(acc, value, b.Epoch()+UnbondingPeriod, undo) && ok
+	}
+	for hash, scope := range b.mutations.GrantPower {
+		undo.keepPower(hash, s.PowerOfAttorney)
+		s.PowerOfAttorney.Set(hash, scope)
 	}
 	for hash := range b.mutations.RevokePower {
+		undo.keepPower(hash, s.PowerOfAttorney)
 		s.PowerOfAttorney.RemoveHash(hash)
 	}
 	for hash := range b.mutations.PublishSpn {
-		s.SponsorGranted.RemoveContentHash(hash)
+		if ok, contentHash := s.SponsorGranted.GetContentHash(hash); ok {
+			s.SponsorGranted.RemoveContentHash(hash)
+			undo.removedSponsor[hash] = contentHash
+		}
 	}
 	for token, contentHash := range b.mutations.GrantSponsor {
-		s.SponsorGranted.SetContentHash(token, contentHash[:])
+		if s.SponsorGranted.SetContentHash(token, contentHash[:]) {
+			undo.insertedSponsor = append(undo.insertedSponsor, token)
+		}
 	}
 	for hash, expire := range b.mutations.NewSpnOffer {
-		s.SponsorOffers.Insert(hash, expire)
+		if s.SponsorOffers.Insert(hash, expire) {
+			addExpire(s.SponsorExpire, expire, hash)
+			undo.insertedOffers[hash] = expire
+		}
+	}
+	for hash := range b.mutations.UseSpnOffer {
+		if expire := s.SponsorOffers.Exists(hash); expire > 0 && s.SponsorOffers.Remove(hash) {
+			removeExpire(s.SponsorExpire, expire, hash)
+			undo.removedOffers[hash] = expire
+		}
+	}
+	for hash, expire := range b.mutations.NewEphemeral {
+		if s.EphemeralTokens.Insert(hash, expire) {
+			addExpire(s.EphemeralExpire, expire, hash)
+			undo.insertedEphemeral[hash] = expire
+		}
+	}
+	for hash, epoch := range b.mutations.Included {
+		expire := epoch + MaxInstructionAge
+		if s.Included.Insert(hash, expire) {
+			addExpire(s.IncludedExpire, expire, hash)
+			undo.insertedIncluded[hash] = expire
+		}
 	}
 	for hash, keys := range b.mutations.NewStages {
+		undo.keepStage(hash, s.Stages)
 		s.Stages.SetKeys(hash, &keys)
 	}
 	for hash, keys := range b.mutations.StageUpdate {
+		undo.keepStage(hash, s.Stages)
 		s.Stages.SetKeys(hash, &keys)
 	}
-	s.Wallets.CreditHash(crypto.HashToken(b.Publisher), b.FeesCollected)
+	own, delegated := splitEarnings(earnings, stake, pooled)
+	undo.keepBalance(publisher, s.Wallets)
+	ok = applyDelta(s.Wallets, publisher, own, false) && ok
+	if delegated > 0 {
+		undo.keepBalance(publisher, s.Pools)
+		ok = applyDelta(s.Pools, publisher, delegated, false) && ok
+	}
+	s.TotalSupply = supply
+	if !ok {
+		s.RevertBlock(undo)
+		return nil, ErrIncorporationError
+	}
+	s.LastHash = b.Hash
+	s.commitEpoch(b.Epoch())
+	return undo, nil
 }
@@ -0,0 +1,82 @@
+// Copyright 2021 The Aereum Authors
+// This file is part of the aereum library.
+//
+// The aereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aereum library. If not, see <http://www.gnu.org/licenses/>.
+package chain
+
+import (
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/store"
+)
+
+// CaptionOwner returns the token of the member owning the caption hash.
+func (s *State) CaptionOwner(hash crypto.Hash) (bool, crypto.Token) {
+	ok, owner := s.Captions.Get(hash)
+	return ok, crypto.Token(owner)
+}
+
+// ResolveCaption returns the token of the member owning caption.
+func (s *State) ResolveCaption(caption string) (bool, crypto.Token) {
+	return s.CaptionOwner(crypto.Hasher([]byte(caption)))
+}
+
+// MemberCaption returns the caption hash of the member hash.
+func (s *State) MemberCaption(hash crypto.Hash) (bool, crypto.Hash) {
+	return s.MemberCaptions.Get(hash)
+}
+
+// StageOwner returns the token of the member owning the stage hash.
+func (s *State) StageOwner(hash crypto.Hash) (bool, crypto.Token) {
+	ok, owner := s.StageOwners.Get(hash)
+	return ok, crypto.Token(owner)
+}
+
+// setCaption registers caption to token on both directions.
+func (s *State) setCaption(token crypto.Token, caption crypto.Hash) {
+	s.Captions.Set(caption, crypto.Hash(token))
+	s.MemberCaptions.Set(crypto.HashToken(token), caption)
+}
+
+// applyCaptions sets the owners of captions and the captions of members
+// changed by m. A zero owner releases the caption and a zero caption leaves
+// the member without one.
+func (s *State) applyCaptions(m *Mutation, undo *Undo) {
+	for caption, owner := range m.NewCaption {
+		undo.keepEntry(caption, s.Captions)
+		if owner == crypto.ZeroToken {
+			s.Captions.RemoveHash(caption)
+		} else {
+			s.Captions.Set(caption, crypto.Hash(owner))
+		}
+	}
+	for member, caption := range m.NewMemberCaption {
+		undo.keepEntry(member, s.MemberCaptions)
+		if caption == (crypto.Hash{}) {
+			s.MemberCaptions.RemoveHash(member)
+		} else {
+			s.MemberCaptions.Set(member, caption)
+		}
+	}
+}
+
+// restoreEntries sets back every value of registry recorded on entries.
+func restoreEntries(registry *store.Registry, entries map[crypto.Hash]*crypto.Hash) {
+	for hash, value := range entries {
+		if value == nil {
+			registry.RemoveHash(hash)
+		} else {
+			registry.Set(hash, *value)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2021 The Aereum Authors
+// This file is part of the aereum library.
+//
+// The aereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aereum library. If not, see <http://www.gnu.org/licenses/>.
+package chain
+
+import (
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/store"
+	"github.com/Aereum/aereum/core/util"
+)
+
+// setEscrowRelease replaces the release epoch of the escrow hash. Zero
+// removes it.
+func (s *State) setEscrowRelease(hash crypto.Hash, release uint64) {
+	if previous := s.EscrowReleases.Exists(hash); previous > 0 {
+		s.EscrowReleases.Remove(hash)
+		removeExpire(s.EscrowExpire, previous, hash)
+	}
+	if release > 0 {
+		s.EscrowReleases.Insert(hash, release)
+		addExpire(s.EscrowExpire, release, hash)
+	}
+}
+
+// applyEscrows stores the escrows created by m and removes the ones
+// cancelled. Escrowed aero is moved by the deltas of m.
+func (s *State) applyEscrows(m *Mutation, undo *Undo) {
+	for hash, release := range m.EscrowRelease {
+		undo.keepEscrow(hash, s.Escrows)
+		undo.keepEscrowRelease(hash, s.EscrowReleases.Exists(hash))
+		if release == 0 {
+			s.Escrows.RemoveHash(hash)
+		} else {
+			s.Escrows.Set(hash, m.NewEscrow[hash])
+		}
+		s.setEscrowRelease(hash, release)
+	}
+}
+
+// escrowInstallments returns the aero released at epoch by an escrow holding
+// escrowed with an installment due at release, and the epoch of its next
+// installment, zero once fully released. It returns false if the next
+// installment overflows.
+func escrowInstallments(escrow *store.Escrow, release, epoch, escrowed uint64) (uint64, uint64, bool) {
+	if escrow.Period > 0 && escrow.Value > 0 {
+		installments := (epoch-release)/escrow.Period + 1
+		if installments < escrowed/escrow.Value {
+			delay, okDelay := util.MulUint64(installments, escrow.Period)
+			next, okNext := util.AddUint64(release, delay)
+			if !okDelay || !okNext {
+				return 0, 0, false
+			}
+			return installments * escrow.Value, next, true
+		}
+	}
+	return escrowed, 0, true
+}
+
+// releaseEscrows credits to their recipients the installments of escrowed
+// transfers due up to and including epoch. Escrows are rescheduled for their
+// next installment, or removed once fully released. It returns false if a
+// wallet or the schedule of an escrow overflows.
+func (s *State) releaseEscrows(epoch uint64, undo *Undo) bool {
+	ok := true
+	released := make(map[crypto.Hash]uint64)
+	sweepExpire(s.EscrowExpire, epoch, s.EscrowReleases, released)
+	for hash, release := range released {
+		undo.keepEscrowRelease(hash, release)
+		escrow := s.Escrows.Get(hash)
+		if escrow == nil {
+			ok = false
+			continue
+		}
+		_, escrowed := s.Escrowed.BalanceHash(hash)
+		value, next, okNext := escrowInstallments(escrow, release, epoch, escrowed)
+		if !okNext {
+			ok = false
+			continue
+		}
+		recipient := crypto.HashToken(escrow.Recipient)
+		undo.keepBalance(hash, s.Escrowed)
+		undo.keepBalance(recipient, s.Wallets)
+		ok = applyDelta(s.Escrowed, hash, value, true) && applyDelta(s.Wallets, recipient, value, false) && ok
+		if next > 0 {
+			s.setEscrowRelease(hash, next)
+		} else {
+			undo.keepEscrow(hash, s.Escrows)
+			s.Escrows.RemoveHash(hash)
+		}
+	}
+	return ok
+}
+
+// releaseMutationEscrows models on m the installments State.releaseEscrows
+// would release at epoch after the incorporation of m. It returns false if a
+// delta overflows.
+func (s *State) releaseMutationEscrows(m *Mutation, epoch uint64) bool {
+	due := make(map[crypto.Hash]uint64)
+	for _, expire := range dueExpire(s.EscrowExpire, epoch) {
+		for _, hash := range s.EscrowExpire[expire] {
+			if _, ok := m.EscrowRelease[hash]; !ok {
+				due[hash] = expire
+			}
+		}
+	}
+	for hash, release := range m.EscrowRelease {
+		if release > 0 && release <= epoch {
+			due[hash] = release
+		}
+	}
+	view := &MutatingState{State: s, Mutations: m}
+	for hash, release := range due {
+		escrow := view.escrow(hash)
+		if escrow == nil {
+			return false
+		}
+		value, next, ok := escrowInstallments(escrow, release, epoch, view.escrowed(hash))
+		if !ok {
+			return false
+		}
+		recipient := crypto.HashToken(escrow.Recipient)
+		escrowed, okEscrowed := shiftDelta(m.DeltaEscrowed, hash, value, true)
+		credited, okCredited := shiftDelta(m.DeltaWallets, recipient, value, false)
+		if !okEscrowed || !okCredited {
+			return false
+		}
+		m.DeltaEscrowed[hash], m.DeltaWallets[recipient] = escrowed, credited
+		m.EscrowRelease[hash] = next
+		if next > 0 {
+			m.NewEscrow[hash] = *escrow
+		} else {
+			delete(m.NewEscrow, hash)
+		}
+	}
+	return true
+}
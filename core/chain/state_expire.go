@@ -0,0 +1,159 @@
+// Copyright 2021 The Aereum Authors
+// This file is part of the aereum library.
+//
+// The aereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aereum library. If not, see <http://www.gnu.org/licenses/>.
+package chain
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/store"
+)
+
+// addExpire indexes hash under its expire epoch.
+func addExpire(index map[uint64][]crypto.Hash, expire uint64, hash crypto.Hash) {
+	index[expire] = append(index[expire], hash)
+}
+
+// removeExpire removes hash from the index of its expire epoch.
+func removeExpire(index map[uint64][]crypto.Hash, expire uint64, hash crypto.Hash) {
+	hashes := index[expire]
+	for n, indexed := range hashes {
+		if indexed == hash {
+			hashes = append(hashes[:n], hashes[n+1:]...)
+			break
+		}
+	}
+	if len(hashes) == 0 {
+		delete(index, expire)
+	} else {
+		index[expire] = hashes
+	}
+}
+
+// dueExpire returns the epochs of the index up to and including last, in
+// ascending order.
+func dueExpire(index map[uint64][]crypto.Hash, last uint64) []uint64 {
+	epochs := make([]uint64, 0)
+	for epoch := range index {
+		if epoch <= last {
+			epochs = append(epochs, epoch)
+		}
+	}
+	sort.Slice(epochs, func(i, j int) bool { return epochs[i] < epochs[j] })
+	return epochs
+}
+
+// sweepExpire removes from vault every entry of the index expiring up to and
+// including last. Entries are removed in ascending order of epoch and hash,
+// and recorded on removed together with their expire epoch.
+func sweepExpire(index map[uint64][]crypto.Hash, last uint64, vault *store.HashExpireVault, removed map[crypto.Hash]uint64) {
+	for _, epoch := range dueExpire(index, last) {
+		hashes := index[epoch]
+		sort.Slice(hashes, func(i, j int) bool { return bytes.Compare(hashes[i][:], hashes[j][:]) < 0 })
+		for _, hash := range hashes {
+			if vault.Remove(hash) {
+				removed[hash] = epoch
+			}
+		}
+		delete(index, epoch)
+	}
+}
+
+// sweepExpired removes the sponsorship offers, ephemeral tokens and included
+// instruction hashes that are no longer valid at epoch. Sponsorship offers
+// can be accepted and instructions are accepted up to their expire epoch,
+// ephemeral tokens are valid only before their expire epoch. Unbonding stake
+// and escrowed transfers due at epoch are released. It returns false if
+// released aero cannot be credited.
+func (s *State) sweepExpired(epoch uint64, undo *Undo) bool {
+	if epoch > 0 {
+		sweepExpire(s.SponsorExpire, epoch-1, s.SponsorOffers, undo.removedOffers)
+		sweepExpire(s.IncludedExpire, epoch-1, s.Included, undo.removedIncluded)
+	}
+	sweepExpire(s.EphemeralExpire, epoch, s.EphemeralTokens, undo.removedEphemeral)
+	ok := s.releaseUnbonding(epoch, undo)
+	return s.releaseEscrows(epoch, undo) && ok
+}
+
+// sweepMutation models on m the sweep State.sweepExpired would perform at
+// epoch after the incorporation of m, so that m can be validated against as if
+// it had been incorporated block by block. Unbonding stake is tracked on
+// releases. It returns false if a delta overflows.
+func (s *State) sweepMutation(m *Mutation, epoch uint64, releases map[crypto.Hash]*pendingRelease) bool {
+	if epoch > 0 {
+		for _, expire := range dueExpire(s.SponsorExpire, epoch-1) {
+			for _, hash := range s.SponsorExpire[expire] {
+				m.UseSpnOffer[hash] = struct{}{}
+			}
+		}
+		for hash, expire := range m.NewSpnOffer {
+			if expire < epoch {
+				delete(m.NewSpnOffer, hash)
+			}
+		}
+		for _, expire := range dueExpire(s.IncludedExpire, epoch-1) {
+			for _, hash := range s.IncludedExpire[expire] {
+				m.Expired[hash] = struct{}{}
+			}
+		}
+		for hash, included := range m.Included {
+			if included+MaxInstructionAge < epoch {
+				delete(m.Included, hash)
+			}
+		}
+	}
+	for _, expire := range dueExpire(s.EphemeralExpire, epoch) {
+		for _, hash := range s.EphemeralExpire[expire] {
+			m.Expired[hash] = struct{}{}
+		}
+	}
+	for hash, expire := range m.NewEphemeral {
+		if expire <= epoch {
+			delete(m.NewEphemeral, hash)
+		}
+	}
+	return s.releaseMutationUnbonding(m, epoch, releases) && s.releaseMutationEscrows(m, epoch)
+}
+
+// indexExpire rebuilds the expire indexes from the content of the vaults.
+func (s *State) indexExpire() {
+	s.SponsorExpire = make(map[uint64][]crypto.Hash)
+	hashes, expires := s.SponsorOffers.All()
+	for n, hash := range hashes {
+		addExpire(s.SponsorExpire, expires[n], hash)
+	}
+	s.EphemeralExpire = make(map[uint64][]crypto.Hash)
+	hashes, expires = s.EphemeralTokens.All()
+	for n, hash := range hashes {
+		addExpire(s.EphemeralExpire, expires[n], hash)
+	}
+	s.IncludedExpire = make(map[uint64][]crypto.Hash)
+	hashes, expires = s.Included.All()
+	for n, hash := range hashes {
+		addExpire(s.IncludedExpire, expires[n], hash)
+	}
+	s.ReleaseExpire = make(map[uint64][]crypto.Hash)
+	hashes, expires = s.Releases.All()
+	for n, hash := range hashes {
+		addExpire(s.ReleaseExpire, expires[n], hash)
+	}
+	s.EscrowExpire = make(map[uint64][]crypto.Hash)
+	hashes, expires = s.EscrowReleases.All()
+	for n, hash := range hashes {
+		addExpire(s.EscrowExpire, expires[n], hash)
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright 2021 The Aereum Authors
+// This file is part of the aereum library.
+//
+// The aereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aereum library. If not, see <http://www.gnu.org/licenses/>.
+package chain
+
+import (
+	"github.com/Aereum/aereum/core/crypto"
+)
+
+// Multisig returns the commitment to the signers and threshold controlling
+// the wallet hash, if it is a multisig wallet.
+func (s *State) Multisig(hash crypto.Hash) (bool, crypto.Hash) {
+	return s.Multisigs.Get(hash)
+}
@@ -18,135 +18,589 @@ package chain
 import (
 	"github.com/Aereum/aereum/core/crypto"
 	"github.com/Aereum/aereum/core/store"
+	"github.com/Aereum/aereum/core/util"
 )
 
-type mutation struct {
-	DeltaWallets map[crypto.Hash]int
-	GrantPower   map[crypto.Hash]struct{}
-	RevokePower  map[crypto.Hash]struct{}
-	UseSpnOffer  map[crypto.Hash]struct{}
-	GrantSponsor map[crypto.Hash]crypto.Hash // hash of sponsor token + audience -> content hash
-	PublishSpn   map[crypto.Hash]struct{}
-	NewSpnOffer  map[crypto.Hash]uint64
-	NewMembers   map[crypto.Hash]struct{}
-	NewCaption   map[crypto.Hash]struct{}
-	NewStages    map[crypto.Hash]store.StageKeys
-	StageUpdate  map[crypto.Hash]store.StageKeys
-	NewEphemeral map[crypto.Hash]uint64
-}
-
-func NewMutation() *mutation {
-	return &mutation{
-		DeltaWallets: make(map[crypto.Hash]int),
-		GrantPower:   make(map[crypto.Hash]struct{}),
-		RevokePower:  make(map[crypto.Hash]struct{}),
-		UseSpnOffer:  make(map[crypto.Hash]struct{}),
-		GrantSponsor: make(map[crypto.Hash]crypto.Hash),
-		PublishSpn:   make(map[crypto.Hash]struct{}),
-		NewSpnOffer:  make(map[crypto.Hash]uint64),
-		NewMembers:   make(map[crypto.Hash]struct{}),
-		NewCaption:   make(map[crypto.Hash]struct{}),
-		NewStages:    make(map[crypto.Hash]store.StageKeys),
-		StageUpdate:  make(map[crypto.Hash]store.StageKeys),
-		NewEphemeral: make(map[crypto.Hash]uint64),
-	}
-}
-
-func (m *mutation) DeltaBalance(hash crypto.Hash) int {
+type Mutation struct {
+	DeltaWallets     map[crypto.Hash]int64
+	DeltaStakes      map[crypto.Hash]int64
+	Unbonding        map[crypto.Hash]uint64 // stake withdrawn
+	DeltaPools       map[crypto.Hash]int64  // validator -> delegated stake
+	DeltaShares      map[crypto.Hash]int64  // validator -> pool shares
+	DeltaDelegations map[crypto.Hash]int64  // delegation -> shares
+	DeltaEscrowed    map[crypto.Hash]int64  // escrow -> aero escrowed
+	DeltaHoldings    map[crypto.Hash]int64  // member -> powers of attorney granted and stages owned
+	GrantPower       map[crypto.Hash]store.AttorneyScope
+	RevokePower      map[crypto.Hash]struct{}
+	UseSpnOffer      map[crypto.Hash]struct{}
+	GrantSponsor     map[crypto.Hash]crypto.Hash // hash of sponsor token + audience -> content hash
+	PublishSpn       map[crypto.Hash]struct{}
+	NewSpnOffer      map[crypto.Hash]uint64
+	NewMembers       map[crypto.Hash]struct{}
+	RemovedMembers   map[crypto.Hash]struct{}     // members rotated to a new token
+	NewCaption       map[crypto.Hash]crypto.Token // caption -> owner, zero if released
+	NewMemberCaption map[crypto.Hash]crypto.Hash  // member -> caption, zero if none
+	NewStages        map[crypto.Hash]store.StageKeys
+	StageUpdate      map[crypto.Hash]store.StageKeys
+	StageOwner       map[crypto.Hash]crypto.Token // stage -> owner
+	NewGuardians     map[crypto.Hash]crypto.Hash  // member -> guardians, zero if removed
+	NewRecovery      map[crypto.Hash]uint64       // recovery -> epoch, zero if removed
+	NewMultisig      map[crypto.Hash]crypto.Hash  // wallet -> signers and threshold
+	StakeToken       map[crypto.Hash]crypto.Token // validator -> token, on deposits and delegations
+	NewEscrow        map[crypto.Hash]store.Escrow
+	EscrowRelease    map[crypto.Hash]uint64 // escrow -> release epoch, zero if cancelled
+	NewEphemeral     map[crypto.Hash]uint64
+	Included         map[crypto.Hash]uint64   // instruction hash -> instruction epoch
+	Slashed          map[crypto.Hash]struct{} // validators punished for misbehaviour
+	Burned           uint64                   // slashed stake removed from the supply
+	// Sweeps of the state modelled by GroupBlockMutations. Blocks never set
+	// them, State.IncorporateBlock performs its own sweeps.
+	Expired  map[crypto.Hash]struct{} // ephemeral tokens and included hashes swept from the state
+	Released map[crypto.Hash]uint64   // unbonding stake credited back to wallets
+}
+
+func NewMutation() *Mutation {
+	return &Mutation{
+		DeltaWallets:     make(map[crypto.Hash]int64),
+		DeltaStakes:      make(map[crypto.Hash]int64),
+		Unbonding:        make(map[crypto.Hash]uint64),
+		DeltaPools:       make(map[crypto.Hash]int64),
+		DeltaShares:      make(map[crypto.Hash]int64),
+		DeltaDelegations: make(map[crypto.Hash]int64),
+		DeltaEscrowed:    make(map[crypto.Hash]int64),
+		DeltaHoldings:    make(map[crypto.Hash]int64),
+		GrantPower:       make(map[crypto.Hash]store.AttorneyScope),
+		RevokePower:      make(map[crypto.Hash]struct{}),
+		UseSpnOffer:      make(map[crypto.Hash]struct{}),
+		GrantSponsor:     make(map[crypto.Hash]crypto.Hash),
+		PublishSpn:       make(map[crypto.Hash]struct{}),
+		NewSpnOffer:      make(map[crypto.Hash]uint64),
+		NewMembers:       make(map[crypto.Hash]struct{}),
+		RemovedMembers:   make(map[crypto.Hash]struct{}),
+		NewCaption:       make(map[crypto.Hash]crypto.Token),
+		NewMemberCaption: make(map[crypto.Hash]crypto.Hash),
+		NewStages:        make(map[crypto.Hash]store.StageKeys),
+		StageUpdate:      make(map[crypto.Hash]store.StageKeys),
+		StageOwner:       make(map[crypto.Hash]crypto.Token),
+		NewGuardians:     make(map[crypto.Hash]crypto.Hash),
+		NewRecovery:      make(map[crypto.Hash]uint64),
+		NewMultisig:      make(map[crypto.Hash]crypto.Hash),
+		StakeToken:       make(map[crypto.Hash]crypto.Token),
+		NewEscrow:        make(map[crypto.Hash]store.Escrow),
+		EscrowRelease:    make(map[crypto.Hash]uint64),
+		NewEphemeral:     make(map[crypto.Hash]uint64),
+		Included:         make(map[crypto.Hash]uint64),
+		Slashed:          make(map[crypto.Hash]struct{}),
+		Expired:          make(map[crypto.Hash]struct{}),
+		Released:         make(map[crypto.Hash]uint64),
+	}
+}
+
+func (m *Mutation) DeltaBalance(hash crypto.Hash) int64 {
 	balance := m.DeltaWallets[hash]
 	return balance
 }
 
-func (m *mutation) HasGrantedSponsorship(hash crypto.Hash) (bool, crypto.Hash) {
-	if _, ok := m.PublishSpn[hash]; ok {
-		return false, crypto.Hasher([]byte{})
+func (m *Mutation) DeltaStake(hash crypto.Hash) int64 {
+	return m.DeltaStakes[hash]
+}
+
+// shiftDelta returns the delta of acc on deltas after crediting value, or
+// debiting value if debit is set. It returns false on overflow.
+func shiftDelta(deltas map[crypto.Hash]int64, acc crypto.Hash, value uint64, debit bool) (int64, bool) {
+	delta, ok := util.ToDelta(value, debit)
+	if !ok {
+		return 0, false
+	}
+	return util.AddInt64(deltas[acc], delta)
+}
+
+// mergeDeltas adds every delta of src to dst. It returns false on overflow.
+func mergeDeltas(dst, src map[crypto.Hash]int64) bool {
+	for acc, delta := range src {
+		sum, ok := util.AddInt64(dst[acc], delta)
+		if !ok {
+			return false
+		}
+		dst[acc] = sum
+	}
+	return true
+}
+
+// conserves checks that the deltas of aero balances, together with the fees
+// collected, the burned stake and the released unbonding stake, sum to zero,
+// so that the mutations neither create nor destroy aero other than by
+// slashing.
+func (m *Mutation) conserves(fees uint64) bool {
+	total, ok := util.ToDelta(fees, false)
+	if !ok {
+		return false
 	}
+	burned, ok := util.ToDelta(m.Burned, false)
+	if !ok {
+		return false
+	}
+	if total, ok = util.AddInt64(total, burned); !ok {
+		return false
+	}
+	for _, deltas := range []map[crypto.Hash]int64{m.DeltaWallets, m.DeltaStakes, m.DeltaPools, m.DeltaEscrowed} {
+		for _, delta := range deltas {
+			if total, ok = util.AddInt64(total, delta); !ok {
+				return false
+			}
+		}
+	}
+	for _, value := range m.Unbonding {
+		delta, ok := util.ToDelta(value, false)
+		if !ok {
+			return false
+		}
+		if total, ok = util.AddInt64(total, delta); !ok {
+			return false
+		}
+	}
+	for _, value := range m.Released {
+		delta, ok := util.ToDelta(value, true)
+		if !ok {
+			return false
+		}
+		if total, ok = util.AddInt64(total, delta); !ok {
+			return false
+		}
+	}
+	return total == 0
+}
+
+// HasGrantedSponsorship returns the content hash of a sponsorship granted by
+// the mutation.
+func (m *Mutation) HasGrantedSponsorship(hash crypto.Hash) (bool, crypto.Hash) {
 	contentHash, ok := m.GrantSponsor[hash]
 	return ok, contentHash
 }
 
-func (m *mutation) HasGrantPower(hash crypto.Hash) bool {
+// HasPublishedSponsorship checks if the sponsorship has been consumed by the
+// mutation.
+func (m *Mutation) HasPublishedSponsorship(hash crypto.Hash) bool {
+	_, ok := m.PublishSpn[hash]
+	return ok
+}
+
+func (m *Mutation) HasGrantPower(hash crypto.Hash) bool {
 	_, ok := m.GrantPower[hash]
 	return ok
 }
 
-func (m *mutation) HasRevokePower(hash crypto.Hash) bool {
+// GetGrantPower returns the scope of a power of attorney granted by the
+// mutation.
+func (m *Mutation) GetGrantPower(hash crypto.Hash) (bool, store.AttorneyScope) {
+	scope, ok := m.GrantPower[hash]
+	return ok, scope
+}
+
+func (m *Mutation) HasRevokePower(hash crypto.Hash) bool {
 	_, ok := m.RevokePower[hash]
 	return ok
 }
 
-func (m *mutation) HasUsedSponsorOffer(hash crypto.Hash) bool {
+func (m *Mutation) HasUsedSponsorOffer(hash crypto.Hash) bool {
 	_, ok := m.UseSpnOffer[hash]
 	return ok
 }
 
-func (m *mutation) GetSponsorOffer(hash crypto.Hash) bool {
-	_, ok := m.NewSpnOffer[hash]
-	return ok
+// GetSponsorOffer returns the existence and the expire epoch of an offer
+// made by the mutation.
+func (m *Mutation) GetSponsorOffer(hash crypto.Hash) (bool, uint64) {
+	expire, ok := m.NewSpnOffer[hash]
+	return ok, expire
 }
 
-func (m *mutation) HasMember(hash crypto.Hash) bool {
+func (m *Mutation) HasMember(hash crypto.Hash) bool {
 	_, ok := m.NewMembers[hash]
 	return ok
 }
 
-func (m *mutation) HasCaption(hash crypto.Hash) bool {
-	_, ok := m.NewCaption[hash]
+// HasRemovedMember checks if the mutation removes the member hash.
+func (m *Mutation) HasRemovedMember(hash crypto.Hash) bool {
+	_, ok := m.RemovedMembers[hash]
 	return ok
 }
 
-func (m *mutation) GetAudience(hash crypto.Hash) *store.StageKeys {
+// GetGuardians returns the guardians commitment of a member set by the
+// mutation.
+func (m *Mutation) GetGuardians(hash crypto.Hash) (bool, crypto.Hash) {
+	guardians, ok := m.NewGuardians[hash]
+	return ok, guardians
+}
+
+// GetMultisig returns the multisig policy of a wallet set by the mutation.
+func (m *Mutation) GetMultisig(hash crypto.Hash) (bool, crypto.Hash) {
+	policy, ok := m.NewMultisig[hash]
+	return ok, policy
+}
+
+// GetEscrowRelease returns the release epoch of an escrow set by the
+// mutation, zero if cancelled.
+func (m *Mutation) GetEscrowRelease(hash crypto.Hash) (bool, uint64) {
+	release, ok := m.EscrowRelease[hash]
+	return ok, release
+}
+
+// GetRecovery returns the epoch of a recovery set by the mutation.
+func (m *Mutation) GetRecovery(hash crypto.Hash) (bool, uint64) {
+	epoch, ok := m.NewRecovery[hash]
+	return ok, epoch
+}
+
+// GetStageOwner returns the owner of a stage set by the mutation.
+func (m *Mutation) GetStageOwner(hash crypto.Hash) (bool, crypto.Token) {
+	owner, ok := m.StageOwner[hash]
+	return ok, owner
+}
+
+// GetCaptionOwner returns whether the mutation changes the owner of the
+// caption hash, and the new owner. A zero owner means the caption is released.
+func (m *Mutation) GetCaptionOwner(hash crypto.Hash) (bool, crypto.Token) {
+	owner, ok := m.NewCaption[hash]
+	return ok, owner
+}
+
+// GetMemberCaption returns whether the mutation changes the caption of the
+// member hash, and the new caption. A zero caption means the member has none.
+func (m *Mutation) GetMemberCaption(hash crypto.Hash) (bool, crypto.Hash) {
+	caption, ok := m.NewMemberCaption[hash]
+	return ok, caption
+}
+
+// GetAudience returns the keys of a stage created or updated by the mutation.
+// It returns nil if the mutation does not touch the stage.
+func (m *Mutation) GetAudience(hash crypto.Hash) *store.StageKeys {
 	if audience, ok := m.StageUpdate[hash]; ok {
 		return &audience
 	}
-	audience := m.NewStages[hash]
-	return &audience
+	if audience, ok := m.NewStages[hash]; ok {
+		return &audience
+	}
+	return nil
 }
 
-func (m *mutation) HasEphemeral(hash crypto.Hash) (bool, uint64) {
+func (m *Mutation) HasEphemeral(hash crypto.Hash) (bool, uint64) {
 	expire, ok := m.NewEphemeral[hash]
 	return ok, expire
 }
 
-func GroupBlockMutations(blocks []*Block) *mutation {
-	grouped := NewMutation()
-	for _, block := range blocks {
-		for acc, balance := range block.mutations.DeltaWallets {
-			if oldBalance, ok := grouped.DeltaWallets[acc]; ok {
-				grouped.DeltaWallets[acc] = oldBalance + balance
-			} else {
-				grouped.DeltaWallets[acc] = balance
-			}
+func (m *Mutation) HasIncluded(hash crypto.Hash) bool {
+	_, ok := m.Included[hash]
+	return ok
+}
+
+// HasExpired checks if the mutation sweeps the ephemeral token or included
+// instruction hash from the state.
+func (m *Mutation) HasExpired(hash crypto.Hash) bool {
+	_, ok := m.Expired[hash]
+	return ok
+}
+
+// Merge incorporates the mutations of other, taken to happen after those of
+// m, into m. Deltas, unbonding and released stake are added. For everything
+// else the mutation of other prevails, in the same order as they are applied
+// by State.IncorporateBlock, with the sweeps of other before its changes. It
+// returns false if a delta overflows, in which case m is left partially
+// merged and must be discarded.
+func (m *Mutation) Merge(other *Mutation) bool {
+	if !mergeDeltas(m.DeltaWallets, other.DeltaWallets) ||
+		!mergeDeltas(m.DeltaStakes, other.DeltaStakes) ||
+		!mergeDeltas(m.DeltaPools, other.DeltaPools) ||
+		!mergeDeltas(m.DeltaShares, other.DeltaShares) ||
+		!mergeDeltas(m.DeltaDelegations, other.DeltaDelegations) ||
+		!mergeDeltas(m.DeltaEscrowed, other.DeltaEscrowed) ||
+		!mergeDeltas(m.DeltaHoldings, other.DeltaHoldings) {
+		return false
+	}
+	for acc, value := range other.Unbonding {
+		sum, ok := util.AddUint64(m.Unbonding[acc], value)
+		if !ok {
+			return false
+		}
+		m.Unbonding[acc] = sum
+	}
+	for acc, value := range other.Released {
+		sum, ok := util.AddUint64(m.Released[acc], value)
+		if !ok {
+			return false
+		}
+		m.Released[acc] = sum
+	}
+	burned, ok := util.AddUint64(m.Burned, other.Burned)
+	if !ok {
+		return false
+	}
+	m.Burned = burned
+	for hash := range other.Slashed {
+		m.Slashed[hash] = struct{}{}
+	}
+	for hash, scope := range other.GrantPower {
+		m.GrantPower[hash] = scope
+		delete(m.RevokePower, hash)
+	}
+	for hash := range other.RevokePower {
+		m.RevokePower[hash] = struct{}{}
+		delete(m.GrantPower, hash)
+	}
+	for hash := range other.PublishSpn {
+		m.PublishSpn[hash] = struct{}{}
+		delete(m.GrantSponsor, hash)
+	}
+	for hash, contentHash := range other.GrantSponsor {
+		m.GrantSponsor[hash] = contentHash
+	}
+	for hash, expire := range other.NewSpnOffer {
+		m.NewSpnOffer[hash] = expire
+		delete(m.UseSpnOffer, hash)
+	}
+	for hash := range other.UseSpnOffer {
+		m.UseSpnOffer[hash] = struct{}{}
+		delete(m.NewSpnOffer, hash)
+	}
+	for hash := range other.NewMembers {
+		m.NewMembers[hash] = struct{}{}
+		delete(m.RemovedMembers, hash)
+	}
+	for hash := range other.RemovedMembers {
+		m.RemovedMembers[hash] = struct{}{}
+		delete(m.NewMembers, hash)
+	}
+	for hash, owner := range other.NewCaption {
+		m.NewCaption[hash] = owner
+	}
+	for hash, caption := range other.NewMemberCaption {
+		m.NewMemberCaption[hash] = caption
+	}
+	for hash, keys := range other.NewStages {
+		m.NewStages[hash] = keys
+		delete(m.StageUpdate, hash)
+	}
+	for hash, keys := range other.StageUpdate {
+		m.StageUpdate[hash] = keys
+	}
+	for hash, owner := range other.StageOwner {
+		m.StageOwner[hash] = owner
+	}
+	for hash, guardians := range other.NewGuardians {
+		m.NewGuardians[hash] = guardians
+	}
+	for hash, epoch := range other.NewRecovery {
+		m.NewRecovery[hash] = epoch
+	}
+	for hash, policy := range other.NewMultisig {
+		m.NewMultisig[hash] = policy
+	}
+	for hash, token := range other.StakeToken {
+		m.StakeToken[hash] = token
+	}
+	for hash, escrow := range other.NewEscrow {
+		m.NewEscrow[hash] = escrow
+	}
+	for hash, release := range other.EscrowRelease {
+		m.EscrowRelease[hash] = release
+		if release == 0 {
+			delete(m.NewEscrow, hash)
 		}
-		for hash := range block.mutations.GrantPower {
-			grouped.GrantPower[hash] = struct{}{}
+	}
+	for hash := range other.Expired {
+		m.Expired[hash] = struct{}{}
+		delete(m.NewEphemeral, hash)
+		delete(m.Included, hash)
+	}
+	for hash, expire := range other.NewEphemeral {
+		m.NewEphemeral[hash] = expire
+	}
+	for hash, epoch := range other.Included {
+		m.Included[hash] = epoch
+	}
+	return true
+}
+
+// Equal checks if m and other produce the same changes. Zero deltas are
+// taken as absent.
+func (m *Mutation) Equal(other *Mutation) bool {
+	if !equalDeltas(m.DeltaWallets, other.DeltaWallets) ||
+		!equalDeltas(m.DeltaStakes, other.DeltaStakes) ||
+		!equalDeltas(m.DeltaPools, other.DeltaPools) ||
+		!equalDeltas(m.DeltaShares, other.DeltaShares) ||
+		!equalDeltas(m.DeltaDelegations, other.DeltaDelegations) ||
+		!equalDeltas(m.DeltaEscrowed, other.DeltaEscrowed) ||
+		!equalDeltas(m.DeltaHoldings, other.DeltaHoldings) {
+		return false
+	}
+	if !equalValues(m.Unbonding, other.Unbonding) || !equalValues(m.Released, other.Released) ||
+		!equalValues(m.NewSpnOffer, other.NewSpnOffer) ||
+		!equalValues(m.NewEphemeral, other.NewEphemeral) ||
+		!equalValues(m.Included, other.Included) ||
+		!equalValues(m.NewRecovery, other.NewRecovery) ||
+		!equalValues(m.EscrowRelease, other.EscrowRelease) {
+		return false
+	}
+	if !equalScopes(m.GrantPower, other.GrantPower) || !equalEscrows(m.NewEscrow, other.NewEscrow) ||
+		!equalSets(m.RevokePower, other.RevokePower) ||
+		!equalSets(m.UseSpnOffer, other.UseSpnOffer) ||
+		!equalSets(m.PublishSpn, other.PublishSpn) ||
+		!equalSets(m.NewMembers, other.NewMembers) ||
+		!equalSets(m.RemovedMembers, other.RemovedMembers) ||
+		!equalSets(m.Slashed, other.Slashed) || m.Burned != other.Burned ||
+		!equalSets(m.Expired, other.Expired) {
+		return false
+	}
+	if !equalHashes(m.GrantSponsor, other.GrantSponsor) ||
+		!equalHashes(m.NewMemberCaption, other.NewMemberCaption) ||
+		!equalHashes(m.NewGuardians, other.NewGuardians) ||
+		!equalHashes(m.NewMultisig, other.NewMultisig) {
+		return false
+	}
+	if !equalTokens(m.NewCaption, other.NewCaption) || !equalTokens(m.StageOwner, other.StageOwner) ||
+		!equalTokens(m.StakeToken, other.StakeToken) {
+		return false
+	}
+	if len(m.NewStages) != len(other.NewStages) || len(m.StageUpdate) != len(other.StageUpdate) {
+		return false
+	}
+	for hash, keys := range m.NewStages {
+		if otherKeys, ok := other.NewStages[hash]; !ok || otherKeys != keys {
+			return false
 		}
-		for hash := range block.mutations.RevokePower {
-			grouped.RevokePower[hash] = struct{}{}
-			delete(grouped.GrantPower, hash)
+	}
+	for hash, keys := range m.StageUpdate {
+		if otherKeys, ok := other.StageUpdate[hash]; !ok || otherKeys != keys {
+			return false
 		}
-		for hash := range block.mutations.UseSpnOffer {
-			grouped.UseSpnOffer[hash] = struct{}{}
-			delete(grouped.NewSpnOffer, hash)
+	}
+	return true
+}
+
+// equalDeltas checks if a and b have the same non-zero deltas.
+func equalDeltas(a, b map[crypto.Hash]int64) bool {
+	for acc, delta := range a {
+		if b[acc] != delta {
+			return false
 		}
-		for hash, offer := range block.mutations.NewSpnOffer {
-			grouped.NewSpnOffer[hash] = offer
+	}
+	for acc, delta := range b {
+		if a[acc] != delta {
+			return false
 		}
-		for hash := range block.mutations.NewMembers {
-			grouped.NewMembers[hash] = struct{}{}
+	}
+	return true
+}
+
+// equalValues checks if a and b have the same keys with the same values.
+func equalValues(a, b map[crypto.Hash]uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for hash, value := range a {
+		if other, ok := b[hash]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
+
+// equalHashes checks if a and b have the same keys with the same hashes.
+func equalHashes(a, b map[crypto.Hash]crypto.Hash) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for hash, value := range a {
+		if other, ok := b[hash]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
+
+// equalTokens checks if a and b have the same keys with the same tokens.
+func equalTokens(a, b map[crypto.Hash]crypto.Token) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for hash, token := range a {
+		if other, ok := b[hash]; !ok || other != token {
+			return false
+		}
+	}
+	return true
+}
+
+// equalScopes checks if a and b have the same keys with the same scopes.
+func equalScopes(a, b map[crypto.Hash]store.AttorneyScope) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for hash, scope := range a {
+		if other, ok := b[hash]; !ok || other != scope {
+			return false
+		}
+	}
+	return true
+}
+
+// equalEscrows checks if a and b have the same escrows.
+func equalEscrows(a, b map[crypto.Hash]store.Escrow) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for hash, escrow := range a {
+		if other, ok := b[hash]; !ok || other != escrow {
+			return false
 		}
-		for hash := range block.mutations.NewCaption {
-			grouped.NewCaption[hash] = struct{}{}
+	}
+	return true
+}
+
+// equalSets checks if a and b have the same keys.
+func equalSets(a, b map[crypto.Hash]struct{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for hash := range a {
+		if _, ok := b[hash]; !ok {
+			return false
 		}
-		for hash, keys := range block.mutations.NewStages {
-			grouped.NewStages[hash] = keys
+	}
+	return true
+}
+
+// GroupBlockMutations combines the mutations of sequential blocks extending
+// state, including the earnings of their publishers and the sweeps of expired
+// entries and due releases at the epoch of every block. It returns ErrOverflow
+// if the combined balances overflow.
+func GroupBlockMutations(state *State, blocks []*Block) (*Mutation, error) {
+	grouped := NewMutation()
+	releases := make(map[crypto.Hash]*pendingRelease)
+	for _, block := range blocks {
+		if !state.sweepMutation(grouped, block.Epoch(), releases) ||
+			!grouped.Merge(block.mutations) ||
+			!state.unbondMutation(block.mutations, block.Epoch(), releases) {
+			return nil, ErrOverflow
+		}
+		// incorporate fees and minted reward to block publisher and its pool
+		own, delegated, ok := block.Earnings()
+		if !ok {
+			return nil, ErrOverflow
+		}
+		publisher := crypto.HashToken(block.Publisher)
+		earnings := NewMutation()
+		earnings.DeltaWallets[publisher], ok = util.ToDelta(own, false)
+		if !ok {
+			return nil, ErrOverflow
+		}
+		if delegated > 0 {
+			if earnings.DeltaPools[publisher], ok = util.ToDelta(delegated, false); !ok {
+				return nil, ErrOverflow
+			}
 		}
-		// incorporate fees to block publisher
-		if balance, ok := grouped.DeltaWallets[crypto.HashToken(block.Publisher)]; ok {
-			grouped.DeltaWallets[crypto.HashToken(block.Publisher)] = balance + int(block.FeesCollected)
-		} else {
-			grouped.DeltaWallets[crypto.HashToken(block.Publisher)] = int(block.FeesCollected)
+		if !grouped.Merge(earnings) {
+			return nil, ErrOverflow
 		}
 	}
-	return grouped
+	return grouped, nil
 }
@@ -0,0 +1,312 @@
+package chain
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/instructions"
+	"github.com/Aereum/aereum/core/store"
+)
+
+// mutationModel generates random instructions over a small set of members,
+// audiences, offers and escrows, and keeps every hash it may affect.
+type mutationModel struct {
+	rnd          *rand.Rand
+	keys         []crypto.PrivateKey
+	stages       []*instructions.Stage
+	offers       []*instructions.SponsorshipOffer
+	offerStages  []*instructions.Stage
+	offerAuthors []crypto.PrivateKey
+	escrows      []*instructions.EscrowTransfer
+	escrowPayers []crypto.PrivateKey
+	ephemeral    []crypto.Hash
+	instructions []instructions.Instruction
+}
+
+func newMutationModel(token crypto.PrivateKey, seed int64) *mutationModel {
+	model := &mutationModel{rnd: rand.New(rand.NewSource(seed)), keys: []crypto.PrivateKey{token}}
+	for n := 0; n < 3; n++ {
+		_, key := crypto.RandomAsymetricKey()
+		model.keys = append(model.keys, key)
+	}
+	return model
+}
+
+func (m *mutationModel) key() crypto.PrivateKey {
+	return m.keys[m.rnd.Intn(len(m.keys))]
+}
+
+func (m *mutationModel) value() uint64 {
+	return uint64(1 + m.rnd.Intn(1000))
+}
+
+// caption returns one of the captions used by the model.
+func (m *mutationModel) caption() string {
+	if m.rnd.Intn(2) == 0 {
+		return fmt.Sprintf("member %v", m.rnd.Intn(len(m.keys)))
+	}
+	return fmt.Sprintf("caption %v", m.rnd.Intn(len(m.keys)))
+}
+
+// next returns a random instruction for epoch, or nil if none could be made.
+func (m *mutationModel) next(epoch uint64) instructions.Instruction {
+	author := &instructions.Author{PrivateKey: m.key()}
+	validator := m.keys[0].PublicKey()
+	switch m.rnd.Intn(22) {
+	case 0:
+		return instructions.NewSingleReciepientTransfer(m.keys[0], m.key().PublicKey(), "", m.value(), epoch, 1)
+	case 1:
+		return instructions.NewSingleReciepientTransfer(m.key(), m.key().PublicKey(), "", m.value(), epoch, 1)
+	case 2:
+		n := m.rnd.Intn(len(m.keys))
+		eve := &instructions.Author{PrivateKey: m.keys[0]}
+		return eve.NewJoinNetworkThirdParty(m.keys[n].PublicKey(), fmt.Sprintf("member %v", n), `{}`, epoch, 1)
+	case 3:
+		if m.rnd.Intn(2) == 0 {
+			scope := store.AttorneyScope{Kinds: instructions.KindsMask(instructions.IReact), Expire: epoch + 2, Limit: m.value()}
+			return author.NewScopedPowerOfAttorney(m.key().PublicKey(), scope, epoch, 1)
+		}
+		return author.NewGrantPowerOfAttorney(m.key().PublicKey(), epoch, 1)
+	case 4:
+		return author.NewRevokePowerOfAttorney(m.key().PublicKey(), epoch, 1)
+	case 5:
+		stage := instructions.NewStage(0, "stage")
+		m.stages = append(m.stages, stage)
+		return author.NewCreateAudience(stage, epoch, 1)
+	case 6:
+		if len(m.stages) == 0 {
+			return nil
+		}
+		stage := m.stages[m.rnd.Intn(len(m.stages))]
+		none := make(map[crypto.Token]crypto.Token)
+		return author.NewUpdateAudience(stage, none, none, none, byte(m.rnd.Intn(4)), "update", epoch, 1)
+	case 7:
+		return instructions.NewDeposit(m.key(), m.value(), epoch, 1)
+	case 8:
+		return instructions.NewWithdraw(m.key(), m.value(), epoch, 1)
+	case 9:
+		return instructions.NewDelegate(m.key(), validator, m.value(), epoch, 1)
+	case 10:
+		return instructions.NewUndelegate(m.key(), validator, m.value(), epoch, 1)
+	case 11:
+		ephemeral, _ := crypto.RandomAsymetricKey()
+		m.ephemeral = append(m.ephemeral, crypto.HashToken(ephemeral))
+		return author.NewCreateEphemeral(ephemeral, epoch+50, epoch, 1)
+	case 12:
+		if len(m.stages) == 0 {
+			return nil
+		}
+		stage := m.stages[m.rnd.Intn(len(m.stages))]
+		offer := author.NewSponsorshipOffer(stage, "text", []byte(fmt.Sprint(m.rnd.Int())), epoch+50, m.value(), epoch, 1)
+		m.offers = append(m.offers, offer)
+		m.offerStages = append(m.offerStages, stage)
+		m.offerAuthors = append(m.offerAuthors, author.PrivateKey)
+		return offer
+	case 13:
+		return author.NewChangeCaption(m.caption(), epoch, 1)
+	case 14:
+		n := m.rnd.Intn(len(m.keys))
+		owner := &instructions.Author{PrivateKey: m.keys[n]}
+		caption := fmt.Sprintf("member %v", n)
+		if m.rnd.Intn(2) == 0 {
+			caption = m.caption()
+		}
+		return owner.NewTransferCaption(caption, m.key().PublicKey(), epoch, 1)
+	case 15:
+		_, next := crypto.RandomAsymetricKey()
+		m.keys = append(m.keys, next)
+		attorneys := []crypto.Token{m.key().PublicKey()}
+		stages := make([]crypto.Token, 0)
+		if len(m.stages) > 0 && m.rnd.Intn(2) == 0 {
+			stages = append(stages, m.stages[m.rnd.Intn(len(m.stages))].PrivateKey.PublicKey())
+		}
+		return instructions.NewRotateIdentity(m.keys[1+m.rnd.Intn(len(m.keys)-2)], next, attorneys[:m.rnd.Intn(2)], stages, epoch, 0)
+	case 16:
+		guardians := []crypto.Token{m.key().PublicKey(), m.key().PublicKey()}
+		return author.NewSetGuardians(guardians[:m.rnd.Intn(3)], byte(m.rnd.Intn(2)), epoch, 1)
+	case 17:
+		payer := m.key()
+		installments, period := uint64(1+m.rnd.Intn(3)), uint64(1+m.rnd.Intn(60))
+		escrow := instructions.NewEscrowTransfer(payer, m.key().PublicKey(), "", m.value(), installments, period, epoch+uint64(1+m.rnd.Intn(100)), epoch, 1)
+		m.escrows = append(m.escrows, escrow)
+		m.escrowPayers = append(m.escrowPayers, payer)
+		return escrow
+	case 18:
+		if len(m.escrows) == 0 {
+			return nil
+		}
+		n := m.rnd.Intn(len(m.escrows))
+		return instructions.NewCancelEscrow(m.escrowPayers[n], m.escrows[n].Hash(), epoch, 1)
+	case 19:
+		if len(m.offers) == 0 {
+			return nil
+		}
+		n := m.rnd.Intn(len(m.offers))
+		sponsor := &instructions.Author{PrivateKey: m.offerAuthors[n]}
+		return sponsor.NewSponsoredContent(m.offers[n], epoch, 1)
+	case 20:
+		signers := []crypto.Token{m.key().PublicKey(), m.key().PublicKey()}
+		return instructions.NewCreateMultisig(m.keys[1+m.rnd.Intn(len(m.keys)-1)], signers, byte(1+m.rnd.Intn(2)), epoch, 1)
+	default:
+		if len(m.offers) == 0 {
+			return nil
+		}
+		n := m.rnd.Intn(len(m.offers))
+		return author.NewSponsorshipAcceptance(m.offerStages[n], m.offers[n], epoch, 1)
+	}
+}
+
+// view returns every value of v the model may have affected, through every
+// query of the validator.
+func (m *mutationModel) view(v *MutatingState) []string {
+	values := make([]string, 0)
+	add := func(name string, value ...interface{}) {
+		values = append(values, fmt.Sprintf("%v: %v", name, value))
+	}
+	for n, key := range m.keys {
+		hash := crypto.HashToken(key.PublicKey())
+		pooled, shares := v.pool(hash)
+		add(fmt.Sprintf("key %v", n), v.balance(hash), v.stake(hash), pooled, shares, v.hasMember(hash), v.slashed(hash))
+		ok, policy := v.multisig(hash)
+		add(fmt.Sprintf("key %v multisig", n), ok, policy)
+		for _, caption := range []string{"member %v", "caption %v"} {
+			ok, owner := v.captionOwner(crypto.Hasher([]byte(fmt.Sprintf(caption, n))))
+			add(fmt.Sprintf(caption, n), ok, owner)
+		}
+		ok, caption := v.memberCaption(hash)
+		add(fmt.Sprintf("key %v caption", n), ok, caption)
+		ok, guardians := v.guardians(hash)
+		add(fmt.Sprintf("key %v guardians", n), ok, guardians)
+		for _, other := range m.keys {
+			add("delegation", v.delegation(DelegationHash(key.PublicKey(), other.PublicKey())))
+			author, attorney := key.PublicKey(), other.PublicKey()
+			power := crypto.Hasher(append(author[:], attorney[:]...))
+			if scope := v.attorneyScope(power); scope != nil {
+				add("attorney", v.powerOfAttorney(power), *scope)
+			} else {
+				add("attorney", v.powerOfAttorney(power), nil)
+			}
+			add("recovery", v.recovery(RecoveryHash(author, attorney)))
+		}
+	}
+	for _, stage := range m.stages {
+		ok, owner := v.stageOwner(crypto.HashToken(stage.PrivateKey.PublicKey()))
+		add("stage owner", ok, owner)
+		if keys := v.getAudienceKeys(crypto.HashToken(stage.PrivateKey.PublicKey())); keys != nil {
+			add("stage", *keys)
+		} else {
+			add("stage", nil)
+		}
+	}
+	for _, hash := range m.ephemeral {
+		ok, expire := v.getEphemeralExpire(hash)
+		add("ephemeral", ok, expire)
+	}
+	for _, offer := range m.offers {
+		add("offer", v.sponsorshipOffer(crypto.Hasher(offer.Serialize())))
+		ok, contentHash := v.hasGrantedSponser(crypto.Hasher(append(offer.Authored.Author[:], offer.Stage[:]...)))
+		add("sponsorship", ok, contentHash)
+	}
+	for _, escrow := range m.escrows {
+		hash := escrow.Hash()
+		if terms := v.escrow(hash); terms != nil {
+			add("escrow", *terms, v.escrowRelease(hash), v.escrowed(hash))
+		} else {
+			add("escrow", nil, v.escrowRelease(hash), v.escrowed(hash))
+		}
+	}
+	for _, instruction := range m.instructions {
+		add("included", v.included(crypto.Hasher(instruction.Serialize())))
+	}
+	return values
+}
+
+// rawMutations merges the mutations of blocks, without earnings or sweeps.
+func rawMutations(blocks []*Block) *Mutation {
+	merged := NewMutation()
+	for _, block := range blocks {
+		merged.Merge(block.mutations)
+	}
+	return merged
+}
+
+func TestMutatingStateModel(t *testing.T) {
+	// epochs past the expiry of offers, ephemeral tokens and included hashes,
+	// and past the release of unbonding stake and escrows.
+	epochs := []uint64{1, 2, 3, 4, 5, 60, 61, 120, UnbondingPeriod + 2, UnbondingPeriod + 3, UnbondingPeriod + 70}
+	// blocks incorporated before the remaining ones are grouped.
+	const committed = 5
+	for seed := int64(0); seed < 10; seed++ {
+		state, token := NewGenesisState()
+		state.Rewards = DefaultRewardSchedule()
+		model := newMutationModel(token, seed)
+		_, other := crypto.RandomAsymetricKey()
+		publishers := []crypto.PrivateKey{token, other}
+
+		blocks := make([]*Block, 0)
+		included := make([]instructions.Instruction, 0)
+		for n, epoch := range epochs {
+			if n == committed {
+				for _, block := range blocks {
+					if _, err := state.IncorporateBlock(block); err != nil {
+						t.Fatalf("could not incorporate block %v: %v", block.Epoch(), err)
+					}
+				}
+				blocks = blocks[:0]
+			}
+			grouped, err := GroupBlockMutations(state, blocks)
+			if err != nil {
+				t.Fatalf("could not group mutations: %v", err)
+			}
+			publisher := publishers[model.rnd.Intn(len(publishers))]
+			block := NewBlock(crypto.Hasher([]byte{}), 0, epoch, publisher.PublicKey(), &MutatingState{State: state, Mutations: grouped})
+			for n := 0; n < 50; n++ {
+				if len(included) > 0 && model.rnd.Intn(10) == 0 {
+					replay := included[model.rnd.Intn(len(included))]
+					if block.Incorporate(replay) == nil {
+						t.Fatal("replayed instruction incorporated")
+					}
+					continue
+				}
+				if instruction := model.next(epoch); instruction != nil {
+					model.instructions = append(model.instructions, instruction)
+					if block.Incorporate(instruction) == nil {
+						included = append(included, instruction)
+					}
+				}
+			}
+			block.Seal(publisher)
+			blocks = append(blocks, block)
+		}
+
+		left := rawMutations(blocks[:2])
+		if !left.Merge(rawMutations(blocks[2:])) || !left.Equal(rawMutations(blocks)) {
+			t.Fatal("merge of mutations not associative")
+		}
+		grouped, err := GroupBlockMutations(state, blocks)
+		if err != nil {
+			t.Fatalf("could not group mutations: %v", err)
+		}
+		if grouped.Equal(NewMutation()) {
+			t.Fatal("non-empty mutation equal to empty mutation")
+		}
+		expected := model.view(&MutatingState{State: state, Mutations: grouped})
+
+		for _, block := range blocks {
+			if _, err := state.IncorporateBlock(block); err != nil {
+				t.Fatalf("could not incorporate block %v: %v", block.Epoch(), err)
+			}
+		}
+		if err := state.Audit(); err != nil {
+			t.Fatalf("state audit failed: %v", err)
+		}
+		got := model.view(&MutatingState{State: state})
+		for n := range expected {
+			if got[n] != expected[n] {
+				t.Errorf("seed %v: sequential %v, grouped %v", seed, got[n], expected[n])
+			}
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2021 The Aereum Authors
+// This file is part of the aereum library.
+//
+// The aereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aereum library. If not, see <http://www.gnu.org/licenses/>.
+package chain
+
+import (
+	"encoding/binary"
+
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/store"
+	"github.com/Aereum/aereum/core/util"
+)
+
+// indexes of the vaults within the state root
+const (
+	MembersVault byte = iota
+	CaptionsVault
+	WalletsVault
+	StagesVault
+	SponsorOffersVault
+	SponsorGrantedVault
+	PowerOfAttorneyVault
+	EphemeralTokensVault
+	IncludedVault
+	StakesVault
+	UnbondingVault
+	ReleasesVault
+	PoolsVault
+	PoolSharesVault
+	DelegationsVault
+	MemberCaptionsVault
+	StageOwnersVault
+	GuardiansVault
+	RecoveriesVault
+	MultisigsVault
+	EscrowsVault
+	EscrowedVault
+	EscrowReleasesVault
+	SlashedVault
+	HoldingsVault
+	StakeTokensVault
+	vaultsCount
+)
+
+// StateProof proves the presence or absence of a hash on one of the vaults of
+// the state committed by a state root.
+type StateProof struct {
+	Epoch  uint64
+	Vaults []crypto.Hash // hashes of every vault of the state
+	Vault  byte
+	Proof  *store.Proof
+}
+
+func (s *State) newStateProof(vault byte, proof *store.Proof) *StateProof {
+	return &StateProof{
+		Epoch:  s.Epoch,
+		Vaults: s.vaultHashes(),
+		Vault:  vault,
+		Proof:  proof,
+	}
+}
+
+// ProveMember returns a proof of membership of the token hash.
+func (s *State) ProveMember(hash crypto.Hash) *StateProof {
+	return s.newStateProof(MembersVault, s.Members.Prove(hash))
+}
+
+// ProveCaption returns a proof of the owner of the caption hash.
+func (s *State) ProveCaption(hash crypto.Hash) *StateProof {
+	return s.newStateProof(CaptionsVault, s.Captions.Prove(hash))
+}
+
+// ProveWallet returns a proof of the balance of the wallet hash.
+func (s *State) ProveWallet(hash crypto.Hash) *StateProof {
+	return s.newStateProof(WalletsVault, s.Wallets.Prove(hash))
+}
+
+// ProveStage returns a proof of the keys of the stage hash.
+func (s *State) ProveStage(hash crypto.Hash) *StateProof {
+	return s.newStateProof(StagesVault, s.Stages.Prove(hash))
+}
+
+// ProveStake returns a proof of the stake of the token hash.
+func (s *State) ProveStake(hash crypto.Hash) *StateProof {
+	return s.newStateProof(StakesVault, s.Stakes.Prove(hash))
+}
+
+// ProveDelegation returns a proof of the shares delegated by delegator to the
+// pool of validator.
+func (s *State) ProveDelegation(delegator, validator crypto.Token) *StateProof {
+	return s.newStateProof(DelegationsVault, s.Delegations.Prove(DelegationHash(delegator, validator)))
+}
+
+// ProveMemberCaption returns a proof of the caption of the member hash.
+func (s *State) ProveMemberCaption(hash crypto.Hash) *StateProof {
+	return s.newStateProof(MemberCaptionsVault, s.MemberCaptions.Prove(hash))
+}
+
+// ProveStageOwner returns a proof of the owner of the stage hash.
+func (s *State) ProveStageOwner(hash crypto.Hash) *StateProof {
+	return s.newStateProof(StageOwnersVault, s.StageOwners.Prove(hash))
+}
+
+// ProveGuardians returns a proof of the guardians commitment of the member
+// hash.
+func (s *State) ProveGuardians(hash crypto.Hash) *StateProof {
+	return s.newStateProof(GuardiansVault, s.Guardians.Prove(hash))
+}
+
+// ProveMultisig returns a proof of the multisig policy of the wallet hash.
+func (s *State) ProveMultisig(hash crypto.Hash) *StateProof {
+	return s.newStateProof(MultisigsVault, s.Multisigs.Prove(hash))
+}
+
+// ProveEscrow returns a proof of the terms of the escrow hash.
+func (s *State) ProveEscrow(hash crypto.Hash) *StateProof {
+	return s.newStateProof(EscrowsVault, s.Escrows.Prove(hash))
+}
+
+// ProveSlashed returns a proof that the validator hash was slashed.
+func (s *State) ProveSlashed(hash crypto.Hash) *StateProof {
+	return s.newStateProof(SlashedVault, s.Slashed.Prove(hash))
+}
+
+// ProvePowerOfAttorney returns a proof of the power of attorney hash.
+func (s *State) ProvePowerOfAttorney(hash crypto.Hash) *StateProof {
+	return s.newStateProof(PowerOfAttorneyVault, s.PowerOfAttorney.Prove(hash))
+}
+
+// VerifyStateProof checks proof against the state root of the block header.
+// It returns whether hash is present on the vault together with the data
+// associated to it. The last return value is false if the proof is not valid
+// for the header.
+func VerifyStateProof(header *Block, vault byte, hash crypto.Hash, proof *StateProof) (bool, []byte, bool) {
+	if proof == nil || proof.Proof == nil || proof.Vault != vault || len(proof.Vaults) != int(vaultsCount) {
+		return false, nil, false
+	}
+	if proof.Epoch != header.Epoch() || stateRoot(proof.Epoch, proof.Vaults) != header.StateRoot {
+		return false, nil, false
+	}
+	return proof.Proof.Verify(proof.Vaults[vault], hash)
+}
+
+// VerifyBalance checks a wallet proof against the state root of the block
+// header and returns the proven balance of the wallet hash.
+func VerifyBalance(header *Block, hash crypto.Hash, proof *StateProof) (uint64, bool) {
+	found, data, ok := VerifyStateProof(header, WalletsVault, hash, proof)
+	if !ok {
+		return 0, false
+	}
+	if !found {
+		return 0, true
+	}
+	if len(data) < 8 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint64(data), true
+}
+
+func (p *StateProof) Serialize() []byte {
+	bytes := make([]byte, 0)
+	util.PutUint64(p.Epoch, &bytes)
+	util.PutUint16(uint16(len(p.Vaults)), &bytes)
+	for _, hash := range p.Vaults {
+		util.PutByteArray(hash[:], &bytes)
+	}
+	util.PutByte(p.Vault, &bytes)
+	bytes = append(bytes, p.Proof.Serialize()...)
+	return bytes
+}
+
+func ParseStateProof(data []byte) *StateProof {
+	position := 0
+	proof := StateProof{}
+	var count uint16
+	proof.Epoch, position = util.ParseUint64(data, position)
+	count, position = util.ParseUint16(data, position)
+	proof.Vaults = make([]crypto.Hash, int(count))
+	for n := range proof.Vaults {
+		proof.Vaults[n], position = util.ParseHash(data, position)
+	}
+	proof.Vault, position = util.ParseByte(data, position)
+	proof.Proof, position = store.ParseProof(data, position)
+	if position != len(data) {
+		return nil
+	}
+	return &proof
+}
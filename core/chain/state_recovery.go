@@ -0,0 +1,67 @@
+// Copyright 2021 The Aereum Authors
+// This file is part of the aereum library.
+//
+// The aereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aereum library. If not, see <http://www.gnu.org/licenses/>.
+package chain
+
+import (
+	"github.com/Aereum/aereum/core/crypto"
+)
+
+// RecoveryDelay is the number of epochs a recovery by guardians must wait
+// before it can be completed, during which the member can cancel it.
+const RecoveryDelay = 3 * 24 * 60 * 60
+
+// RecoveryHash returns the hash under which the recovery of member to the
+// token is kept.
+func RecoveryHash(member, token crypto.Token) crypto.Hash {
+	return crypto.Hasher(append(member[:], token[:]...))
+}
+
+// MemberGuardians returns the commitment to the guardians and threshold of
+// the member hash.
+func (s *State) MemberGuardians(hash crypto.Hash) (bool, crypto.Hash) {
+	return s.Guardians.Get(hash)
+}
+
+// Recovery returns the epoch from which the recovery hash can be completed,
+// or zero if there is no such pending recovery.
+func (s *State) Recovery(hash crypto.Hash) uint64 {
+	return s.Recoveries.Exists(hash)
+}
+
+// setRecovery replaces the epoch of the recovery hash. Zero removes it.
+func (s *State) setRecovery(hash crypto.Hash, epoch uint64) {
+	s.Recoveries.Remove(hash)
+	if epoch > 0 {
+		s.Recoveries.Insert(hash, epoch)
+	}
+}
+
+// applyRecoveries sets the guardians and pending recoveries changed by m. A
+// zero commitment removes the guardians and a zero epoch the recovery.
+func (s *State) applyRecoveries(m *Mutation, undo *Undo) {
+	for member, guardians := range m.NewGuardians {
+		undo.keepEntry(member, s.Guardians)
+		if guardians == (crypto.Hash{}) {
+			s.Guardians.RemoveHash(member)
+		} else {
+			s.Guardians.Set(member, guardians)
+		}
+	}
+	for hash, epoch := range m.NewRecovery {
+		undo.keepRecovery(hash, s.Recoveries)
+		s.setRecovery(hash, epoch)
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2021 The Aereum Authors
+// This file is part of the aereum library.
+//
+// The aereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aereum library. If not, see <http://www.gnu.org/licenses/>.
+package chain
+
+import (
+	"math/bits"
+
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/store"
+	"github.com/Aereum/aereum/core/util"
+)
+
+// UnbondingPeriod is the number of epochs withdrawn stake remains unbonding
+// before it is credited back to the wallet of its token.
+const UnbondingPeriod = 24 * 60 * 60
+
+// SlashPercentage is the percentage of the own stake of a validator burned
+// when it is proven to have misbehaved.
+const SlashPercentage = 50
+
+// unbond moves value withdrawn from the stake of acc to unbonding, to be
+// released at release. Stake already unbonding for acc is released together
+// with the new withdraw.
+// It returns false if the unbonding stake overflows.
+func (s *State) unbond(acc crypto.Hash, value, release uint64, undo *Undo) bool {
+	undo.keepBalance(acc, s.Unbonding)
+	if !applyDelta(s.Unbonding, acc, value, false) {
+		return false
+	}
+	if previous := s.Releases.Exists(acc); previous > 0 {
+		s.Releases.Remove(acc)
+		removeExpire(s.ReleaseExpire, previous, acc)
+		undo.removedReleases[acc] = previous
+	}
+	s.Releases.Insert(acc, release)
+	addExpire(s.ReleaseExpire, release, acc)
+	undo.insertedReleases[acc] = release
+	return true
+}
+
+// releaseUnbonding credits back to their wallets the unbonding stakes released
+// up to and including epoch. It returns false if a wallet overflows.
+func (s *State) releaseUnbonding(epoch uint64, undo *Undo) bool {
+	ok := true
+	released := make(map[crypto.Hash]uint64)
+	sweepExpire(s.ReleaseExpire, epoch, s.Releases, released)
+	for acc, release := range released {
+		undo.removedReleases[acc] = release
+		undo.keepBalance(acc, s.Unbonding)
+		undo.keepBalance(acc, s.Wallets)
+		if _, value := s.Unbonding.BalanceHash(acc); value > 0 {
+			ok = applyDelta(s.Unbonding, acc, value, true) && applyDelta(s.Wallets, acc, value, false) && ok
+		}
+	}
+	return ok
+}
+
+// applyDelta credits value to the balance of acc on wallets, or debits it if
+// debit is set. It returns false if the balance would be negative or
+// overflow, leaving it unchanged.
+func applyDelta(wallets *store.Wallet, acc crypto.Hash, value uint64, debit bool) bool {
+	if value == 0 {
+		return true
+	}
+	if debit {
+		return wallets.DebitHash(acc, value)
+	}
+	return wallets.CreditHash(acc, value)
+}
+
+// applyDeltas credits or debits every delta to wallets. It returns false if
+// any balance would be negative or overflow.
+func applyDeltas(wallets *store.Wallet, deltas map[crypto.Hash]int64, undo *Undo) bool {
+	ok := true
+	for acc, delta := range deltas {
+		undo.keepBalance(acc, wallets)
+		if delta < 0 {
+			ok = applyDelta(wallets, acc, uint64(-(delta+1))+1, true) && ok
+		} else {
+			ok = applyDelta(wallets, acc, uint64(delta), false) && ok
+		}
+	}
+	return ok
+}
+
+// TotalStake returns the own and delegated stake of every validator that was
+// not slashed, the total weight of the validators.
+func (s *State) TotalStake() uint64 {
+	return s.Stakes.TotalExcept(s.Slashed) + s.Pools.TotalExcept(s.Slashed)
+}
+
+// ValidatorWeights returns the weight of every validator holding stake or
+// stake delegated to its pool. Slashed validators are left out.
+func (s *State) ValidatorWeights() map[crypto.Token]uint64 {
+	weights := make(map[crypto.Token]uint64)
+	for _, value := range s.StakeTokens.Values() {
+		token := crypto.Token(value)
+		if weight := s.Weight(token); weight > 0 {
+			weights[token] = weight
+		}
+	}
+	return weights
+}
+
+// Weight returns the stake of token plus the stake delegated to its pool.
+// Slashed validators weigh nothing.
+func (s *State) Weight(token crypto.Token) uint64 {
+	hash := crypto.HashToken(token)
+	if s.Slashed.ExistsHash(hash) {
+		return 0
+	}
+	_, stake := s.Stakes.BalanceHash(hash)
+	_, pooled := s.Pools.BalanceHash(hash)
+	return stake + pooled
+}
+
+// DelegationHash returns the key of the shares delegated by delegator to the
+// pool of validator.
+func DelegationHash(delegator, validator crypto.Token) crypto.Hash {
+	return crypto.Hasher(append(delegator[:], validator[:]...))
+}
+
+// mulDiv returns a*b/c rounded down, or rounded up if ceil is set. It returns
+// false if the result does not fit in an uint64.
+func mulDiv(a, b, c uint64, ceil bool) (uint64, bool) {
+	hi, lo := bits.Mul64(a, b)
+	if hi >= c {
+		return 0, false
+	}
+	quo, rem := bits.Div64(hi, lo, c)
+	if ceil && rem > 0 {
+		if quo == ^uint64(0) {
+			return 0, false
+		}
+		quo++
+	}
+	return quo, true
+}
+
+// shareValue returns the stake owned by shares of a pool with pooled stake
+// and issued shares.
+func shareValue(shares, pooled, issued uint64) uint64 {
+	if issued == 0 {
+		return 0
+	}
+	value, _ := mulDiv(shares, pooled, issued, false)
+	return value
+}
+
+// sharesFor returns the shares of a pool with pooled stake and issued shares
+// worth value. Shares are issued one to one by empty pools. It returns zero
+// if the shares do not fit in an uint64.
+func sharesFor(value, pooled, issued uint64, ceil bool) uint64 {
+	if issued == 0 || pooled == 0 {
+		return value
+	}
+	shares, ok := mulDiv(value, issued, pooled, ceil)
+	if !ok {
+		return 0
+	}
+	return shares
+}
+
+// splitEarnings divides the earnings of a validator between itself and its
+// pool of delegators in proportion to its own stake and the pooled stake.
+// Rounding favours the validator.
+func splitEarnings(earnings, stake, pooled uint64) (uint64, uint64) {
+	if pooled == 0 {
+		return earnings, 0
+	}
+	delegated, _ := mulDiv(earnings, pooled, stake+pooled, false)
+	return earnings - delegated, delegated
+}
+
+// pendingRelease is the unbonding stake of an account and the epoch of its
+// release, as changed by mutations not yet incorporated. A zero epoch means
+// there is nothing left to release.
+type pendingRelease struct {
+	epoch uint64
+	value uint64
+}
+
+// stateRelease returns the unbonding stake of acc on the state.
+func (s *State) stateRelease(acc crypto.Hash) *pendingRelease {
+	_, value := s.Unbonding.BalanceHash(acc)
+	return &pendingRelease{epoch: s.Releases.Exists(acc), value: value}
+}
+
+// unbondMutation records on releases the stake withdrawn by m at epoch, as
+// State.unbond would. It returns false if the unbonding stake overflows.
+func (s *State) unbondMutation(m *Mutation, epoch uint64, releases map[crypto.Hash]*pendingRelease) bool {
+	for acc, value := range m.Unbonding {
+		release, ok := releases[acc]
+		if !ok {
+			release = s.stateRelease(acc)
+			releases[acc] = release
+		}
+		if release.value, ok = util.AddUint64(release.value, value); !ok {
+			return false
+		}
+		release.epoch = epoch + UnbondingPeriod
+	}
+	return true
+}
+
+// releaseMutationUnbonding models on m the unbonding stake
+// State.releaseUnbonding would release at epoch after the incorporation of m.
+// Releases of the state are tracked on releases together with the changes of
+// m. It returns false if a delta overflows.
+func (s *State) releaseMutationUnbonding(m *Mutation, epoch uint64, releases map[crypto.Hash]*pendingRelease) bool {
+	for _, expire := range dueExpire(s.ReleaseExpire, epoch) {
+		for _, acc := range s.ReleaseExpire[expire] {
+			if _, ok := releases[acc]; !ok {
+				releases[acc] = s.stateRelease(acc)
+			}
+		}
+	}
+	for acc, release := range releases {
+		if release.epoch == 0 || release.epoch > epoch {
+			continue
+		}
+		if release.value > 0 {
+			credited, okCredited := shiftDelta(m.DeltaWallets, acc, release.value, false)
+			released, okReleased := util.AddUint64(m.Released[acc], release.value)
+			if !okCredited || !okReleased {
+				return false
+			}
+			m.DeltaWallets[acc], m.Released[acc] = credited, released
+		}
+		*release = pendingRelease{}
+	}
+	return true
+}
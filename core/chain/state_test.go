@@ -0,0 +1,1187 @@
+package chain
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/instructions"
+	"github.com/Aereum/aereum/core/store"
+)
+
+func TestOpenState(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := OpenState(dir); err != ErrNoState {
+		t.Fatalf("expected no state on empty directory, got %v", err)
+	}
+	_, token := crypto.RandomAsymetricKey()
+	state, err := NewFileGenesisStateWithToken(dir, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, publisher := crypto.RandomAsymetricKey()
+	validator := &MutatingState{State: state}
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), validator)
+	eve := &instructions.Author{PrivateKey: token}
+	member, _ := crypto.RandomAsymetricKey()
+	join := eve.NewJoinNetworkThirdParty(member, "member", `{}`, 1, 10)
+	if block.Incorporate(join) != nil {
+		t.Fatal("could not add new member")
+	}
+	block.Seal(publisher)
+	state.IncorporateBlock(block)
+	state.Close()
+
+	state, err = OpenState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+	if state.Epoch != 1 {
+		t.Errorf("epoch not persisted: %v", state.Epoch)
+	}
+	if state.LastHash != block.Hash {
+		t.Error("last block hash not persisted")
+	}
+	if !state.Members.ExistsToken(member) || !state.Captions.ExistsHash(crypto.Hasher([]byte("member"))) {
+		t.Error("new member not persisted")
+	}
+	if _, balance := state.Wallets.Balance(token.PublicKey()); balance != GenesisSupply-GenesisStake-10 {
+		t.Errorf("genesis wallet not persisted: %v", balance)
+	}
+	if _, balance := state.Wallets.Balance(publisher.PublicKey()); balance != 10 {
+		t.Errorf("publisher fees not persisted: %v", balance)
+	}
+}
+
+func TestIncompleteState(t *testing.T) {
+	dir := t.TempDir()
+	_, token := crypto.RandomAsymetricKey()
+	state, err := NewFileGenesisStateWithToken(dir, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, publisher := crypto.RandomAsymetricKey()
+	if _, err := state.IncorporateBlock(NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), &MutatingState{State: state})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, incompleteFile)); !os.IsNotExist(err) {
+		t.Fatal("state marked incomplete after block was incorporated")
+	}
+	// the process exits after the vaults of the next block started to be written
+	state.beginWrite()
+	state.Members.InsertHash(crypto.Hasher([]byte("partial")))
+	state.Close()
+	if _, err := OpenState(dir); err != ErrIncompleteState {
+		t.Fatalf("expected incomplete state, got %v", err)
+	}
+}
+
+func TestStateVersion(t *testing.T) {
+	dir := t.TempDir()
+	_, token := crypto.RandomAsymetricKey()
+	state, err := NewFileGenesisStateWithToken(dir, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.Close()
+	// a vault written before the header recorded the format version
+	file, err := os.OpenFile(filepath.Join(dir, sponsorGrantedFile), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteAt(make([]byte, 8), 8)
+	file.Close()
+	if _, err := OpenState(dir); err != store.ErrStoreVersion {
+		t.Fatalf("expected unsupported version, got %v", err)
+	}
+	// a state written before a vault was added
+	if err := os.Remove(filepath.Join(dir, holdingsFile)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenState(dir); err != store.ErrStoreVersion {
+		t.Fatalf("expected unsupported version for a missing vault, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, holdingsFile)); !os.IsNotExist(err) {
+		t.Error("missing vault recreated")
+	}
+}
+
+func TestStateRoot(t *testing.T) {
+	_, token := crypto.RandomAsymetricKey()
+	state := NewGenesisStateWithToken(token)
+	other := NewGenesisStateWithToken(token)
+	if state.Root() != other.Root() {
+		t.Fatal("equal states with different roots")
+	}
+	_, publisher := crypto.RandomAsymetricKey()
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), &MutatingState{State: state})
+	receiver, _ := crypto.RandomAsymetricKey()
+	if block.Incorporate(instructions.NewSingleReciepientTransfer(token, receiver, "", 100, 1, 10)) != nil {
+		t.Fatal("could not transfer")
+	}
+	state.IncorporateBlock(block)
+	block.StateRoot = state.Root()
+	block.Seal(publisher)
+	if block.StateRoot == other.Root() {
+		t.Fatal("state root does not change with state")
+	}
+
+	parsed, err := ParseBlock(block.Serialize())
+	if err != nil || parsed.StateRoot != block.StateRoot {
+		t.Fatal("state root not covered by block serialization")
+	}
+	replay := NewBlock(parsed.Parent, parsed.CheckPoint, parsed.Epoch(), parsed.Publisher, &MutatingState{State: other})
+	for _, data := range parsed.Instructions {
+		if replay.Incorporate(instructions.ParseInstruction(data)) != nil {
+			t.Fatal("could not replay block")
+		}
+	}
+	other.IncorporateBlock(replay)
+	if err := other.CheckStateRoot(parsed); err != nil {
+		t.Fatal(err)
+	}
+	parsed.StateRoot = crypto.ZeroHash
+	if err := other.CheckStateRoot(parsed); err != ErrStateRootMismatch {
+		t.Fatal("state root mismatch not detected")
+	}
+}
+
+func TestRevertBlock(t *testing.T) {
+	state, token := NewGenesisState()
+	_, publisher := crypto.RandomAsymetricKey()
+	eve := &instructions.Author{PrivateKey: token}
+	member, _ := crypto.RandomAsymetricKey()
+
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), &MutatingState{State: state})
+	if block.Incorporate(eve.NewJoinNetworkThirdParty(member, "member", `{}`, 1, 10)) != nil {
+		t.Fatal("could not add new member")
+	}
+	first, _ := state.IncorporateBlock(block)
+	root := state.Root()
+
+	block = NewBlock(crypto.Hasher([]byte{}), 1, 2, publisher.PublicKey(), &MutatingState{State: state})
+	if block.Incorporate(instructions.NewSingleReciepientTransfer(token, member, "", 100, 2, 10)) != nil {
+		t.Fatal("could not transfer")
+	}
+	if block.Incorporate(eve.NewGrantPowerOfAttorney(member, 2, 10)) != nil {
+		t.Fatal("could not grant power of attorney")
+	}
+	stage := instructions.NewStage(instructions.EncryptedStage, "stage")
+	if block.Incorporate(eve.NewCreateAudience(stage, 2, 10)) != nil {
+		t.Fatal("could not create stage")
+	}
+	undo, _ := state.IncorporateBlock(block)
+	if state.Root() == root {
+		t.Fatal("state root unchanged by block")
+	}
+	state.RevertBlock(undo)
+	if state.Epoch != 1 || state.Root() != root {
+		t.Fatal("state not reverted")
+	}
+	if _, balance := state.Wallets.Balance(member); balance != 0 {
+		t.Errorf("transfer not reverted: %v", balance)
+	}
+	if state.Stages.Exists(crypto.HashToken(stage.PrivateKey.PublicKey())) {
+		t.Error("stage creation not reverted")
+	}
+
+	state.RevertBlock(first)
+	genesis := NewGenesisStateWithToken(token)
+	if state.Epoch != 0 || state.Root() != genesis.Root() {
+		t.Fatal("state not reverted to genesis")
+	}
+}
+
+func TestConservation(t *testing.T) {
+	state, token := NewGenesisState()
+	_, publisher := crypto.RandomAsymetricKey()
+	receiver, _ := crypto.RandomAsymetricKey()
+	root := state.Root()
+
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), &MutatingState{State: state})
+	if block.Incorporate(instructions.NewSingleReciepientTransfer(token, receiver, "", 100, 1, 10)) != nil {
+		t.Fatal("could not transfer")
+	}
+	block.FeesCollected += 1
+	if _, err := state.IncorporateBlock(block); err != ErrNotConserved {
+		t.Fatalf("expected not conserved, got %v", err)
+	}
+	if state.Epoch != 0 || state.Root() != root {
+		t.Fatal("state changed by rejected block")
+	}
+	block.FeesCollected -= 1
+	if _, err := state.IncorporateBlock(block); err != nil {
+		t.Fatalf("could not incorporate block: %v", err)
+	}
+	if state.Audit() != nil {
+		t.Error("supply not conserved")
+	}
+}
+
+func TestExpire(t *testing.T) {
+	dir := t.TempDir()
+	_, token := crypto.RandomAsymetricKey()
+	state, err := NewFileGenesisStateWithToken(dir, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, publisher := crypto.RandomAsymetricKey()
+	eve := &instructions.Author{PrivateKey: token}
+	ephemeral, _ := crypto.RandomAsymetricKey()
+	hash := crypto.HashToken(ephemeral)
+
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), &MutatingState{State: state})
+	if block.Incorporate(eve.NewCreateEphemeral(ephemeral, 3, 1, 10)) != nil {
+		t.Fatal("could not create ephemeral token")
+	}
+	state.IncorporateBlock(block)
+	if state.EphemeralTokens.Exists(hash) != 3 || len(state.EphemeralExpire[3]) != 1 {
+		t.Fatal("ephemeral token not incorporated")
+	}
+	state.Close()
+	if state, err = OpenState(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+	if len(state.EphemeralExpire[3]) != 1 {
+		t.Fatal("expire index not rebuilt")
+	}
+
+	state.IncorporateBlock(NewBlock(crypto.Hasher([]byte{}), 1, 2, publisher.PublicKey(), &MutatingState{State: state}))
+	if state.EphemeralTokens.Exists(hash) != 3 {
+		t.Fatal("ephemeral token removed before expire")
+	}
+	root := state.Root()
+	undo, _ := state.IncorporateBlock(NewBlock(crypto.Hasher([]byte{}), 2, 3, publisher.PublicKey(), &MutatingState{State: state}))
+	if state.EphemeralTokens.Exists(hash) != 0 || len(state.EphemeralExpire) != 0 {
+		t.Fatal("expired ephemeral token not removed")
+	}
+	state.RevertBlock(undo)
+	if state.Root() != root || len(state.EphemeralExpire[3]) != 1 {
+		t.Fatal("expire sweep not reverted")
+	}
+}
+
+func TestStateProof(t *testing.T) {
+	state, token := NewGenesisState()
+	_, publisher := crypto.RandomAsymetricKey()
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), &MutatingState{State: state})
+	receiver, _ := crypto.RandomAsymetricKey()
+	if block.Incorporate(instructions.NewSingleReciepientTransfer(token, receiver, "", 100, 1, 10)) != nil {
+		t.Fatal("could not transfer")
+	}
+	state.IncorporateBlock(block)
+	block.StateRoot = state.Root()
+	block.Seal(publisher)
+	header, _ := ParseBlock(block.Serialize())
+
+	proof := ParseStateProof(state.ProveWallet(crypto.HashToken(receiver)).Serialize())
+	if balance, ok := VerifyBalance(header, crypto.HashToken(receiver), proof); !ok || balance != 100 {
+		t.Fatalf("could not prove balance: %v", balance)
+	}
+	member := crypto.HashToken(token.PublicKey())
+	if found, _, ok := VerifyStateProof(header, MembersVault, member, state.ProveMember(member)); !found || !ok {
+		t.Fatal("could not prove membership")
+	}
+	if found, _, ok := VerifyStateProof(header, MembersVault, crypto.HashToken(receiver), state.ProveMember(crypto.HashToken(receiver))); found || !ok {
+		t.Fatal("could not prove absence of membership")
+	}
+	if _, _, ok := VerifyStateProof(header, WalletsVault, member, state.ProveMember(member)); ok {
+		t.Fatal("proof accepted for another vault")
+	}
+	state.Wallets.CreditHash(member, 1)
+	if _, ok := VerifyBalance(header, member, state.ProveWallet(member)); ok {
+		t.Fatal("proof of another state accepted")
+	}
+}
+
+func TestStake(t *testing.T) {
+	state, token := NewGenesisState()
+	_, publisher := crypto.RandomAsymetricKey()
+	hash := crypto.HashToken(token.PublicKey())
+
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(instructions.NewDeposit(token, 100, 1, 10)); err != nil {
+		t.Fatalf("could not deposit: %v", err)
+	}
+	if err := block.Incorporate(instructions.NewWithdraw(token, 10, 1, 10)); err != instructions.ErrConflictingInstruction {
+		t.Errorf("expected conflicting instruction, got %v", err)
+	}
+	state.IncorporateBlock(block)
+	if _, stake := state.Stakes.BalanceHash(hash); stake != GenesisStake+100 {
+		t.Errorf("wrong stake after deposit: %v", stake)
+	}
+	if _, balance := state.Wallets.BalanceHash(hash); balance != GenesisSupply-GenesisStake-110 {
+		t.Errorf("wrong balance after deposit: %v", balance)
+	}
+
+	block = NewBlock(crypto.Hasher([]byte{}), 1, 2, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(instructions.NewWithdraw(token, 2e6, 2, 10)); err != instructions.ErrInsufficientStake {
+		t.Errorf("expected insufficient stake, got %v", err)
+	}
+	if err := block.Incorporate(instructions.NewWithdraw(token, 500, 2, 10)); err != nil {
+		t.Fatalf("could not withdraw: %v", err)
+	}
+	state.IncorporateBlock(block)
+	if _, unbonding := state.Unbonding.BalanceHash(hash); unbonding != 500 {
+		t.Errorf("withdraw not unbonding: %v", unbonding)
+	}
+	if state.Audit() != nil {
+		t.Error("supply not conserved while unbonding")
+	}
+	root := state.Root()
+
+	release := uint64(2 + UnbondingPeriod)
+	undo, _ := state.IncorporateBlock(NewBlock(crypto.Hasher([]byte{}), 2, release-1, publisher.PublicKey(), &MutatingState{State: state}))
+	if _, balance := state.Wallets.BalanceHash(hash); balance != GenesisSupply-GenesisStake-120 {
+		t.Errorf("stake released before unbonding period: %v", balance)
+	}
+	state.RevertBlock(undo)
+	undo, _ = state.IncorporateBlock(NewBlock(crypto.Hasher([]byte{}), 2, release, publisher.PublicKey(), &MutatingState{State: state}))
+	if _, balance := state.Wallets.BalanceHash(hash); balance != GenesisSupply-GenesisStake-120+500 {
+		t.Errorf("stake not released: %v", balance)
+	}
+	if _, unbonding := state.Unbonding.BalanceHash(hash); unbonding != 0 || state.Audit() != nil {
+		t.Errorf("unbonding not cleared: %v", unbonding)
+	}
+	state.RevertBlock(undo)
+	if state.Root() != root {
+		t.Error("release not reverted")
+	}
+}
+
+func TestDelegation(t *testing.T) {
+	state, token := NewGenesisState()
+	validator := token.PublicKey()
+	delegator, key := crypto.RandomAsymetricKey()
+
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, validator, &MutatingState{State: state})
+	if err := block.Incorporate(instructions.NewSingleReciepientTransfer(token, delegator, "", 200000, 1, 0)); err != nil {
+		t.Fatalf("could not transfer: %v", err)
+	}
+	state.IncorporateBlock(block)
+
+	block = NewBlock(crypto.Hasher([]byte{}), 1, 2, validator, &MutatingState{State: state})
+	if err := block.Incorporate(instructions.NewDelegate(key, delegator, 100, 2, 0)); err != instructions.ErrNotValidator {
+		t.Errorf("expected not validator, got %v", err)
+	}
+	if err := block.Incorporate(instructions.NewDelegate(key, validator, 50000, 2, 0)); err != nil {
+		t.Fatalf("could not delegate: %v", err)
+	}
+	state.IncorporateBlock(block)
+	if weight := state.Weight(validator); weight != GenesisStake+50000 {
+		t.Errorf("delegation not counted on validator weight: %v", weight)
+	}
+
+	block = NewBlock(crypto.Hasher([]byte{}), 2, 3, validator, &MutatingState{State: state})
+	if err := block.Incorporate(instructions.NewSingleReciepientTransfer(key, validator, "", 0, 3, 1100)); err != nil {
+		t.Fatalf("could not transfer: %v", err)
+	}
+	if own, delegated, ok := block.Earnings(); !ok || own != 1000 || delegated != 100 {
+		t.Errorf("wrong earnings split: %v %v", own, delegated)
+	}
+	state.IncorporateBlock(block)
+	if state.Audit() != nil {
+		t.Error("supply not conserved by distribution")
+	}
+
+	block = NewBlock(crypto.Hasher([]byte{}), 3, 4, validator, &MutatingState{State: state})
+	if delegated := block.Delegated(delegator, validator); delegated != 50100 {
+		t.Fatalf("distribution not credited to delegator: %v", delegated)
+	}
+	if err := block.Incorporate(instructions.NewUndelegate(key, validator, 50101, 4, 0)); err != instructions.ErrInsufficientDelegation {
+		t.Errorf("expected insufficient delegation, got %v", err)
+	}
+	if err := block.Incorporate(instructions.NewUndelegate(key, validator, 50100, 4, 0)); err != nil {
+		t.Fatalf("could not undelegate: %v", err)
+	}
+	undo, _ := state.IncorporateBlock(block)
+	if _, unbonding := state.Unbonding.BalanceHash(crypto.HashToken(delegator)); unbonding != 50100 {
+		t.Errorf("undelegated stake not unbonding: %v", unbonding)
+	}
+	if _, shares := state.PoolShares.BalanceHash(crypto.HashToken(validator)); shares != 0 || state.Weight(validator) != GenesisStake {
+		t.Errorf("pool not emptied: %v", shares)
+	}
+	if state.Audit() != nil {
+		t.Error("supply not conserved by undelegation")
+	}
+	state.RevertBlock(undo)
+	if _, shares := state.Delegations.BalanceHash(DelegationHash(delegator, validator)); shares != 50000 {
+		t.Errorf("undelegation not reverted: %v", shares)
+	}
+}
+
+func TestValidatorWeights(t *testing.T) {
+	state, token := NewGenesisState()
+	validator := token.PublicKey()
+	alice, aliceKey := crypto.RandomAsymetricKey()
+	if weights := state.ValidatorWeights(); len(weights) != 1 || weights[validator] != GenesisStake {
+		t.Fatalf("genesis validator not weighted: %v", weights)
+	}
+
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, validator, &MutatingState{State: state})
+	if err := block.Incorporate(instructions.NewSingleReciepientTransfer(token, alice, "", 1000, 1, 0)); err != nil {
+		t.Fatalf("could not transfer: %v", err)
+	}
+	state.IncorporateBlock(block)
+
+	block = NewBlock(crypto.Hasher([]byte{}), 1, 2, validator, &MutatingState{State: state})
+	if err := block.Incorporate(instructions.NewDeposit(aliceKey, 500, 2, 0)); err != nil {
+		t.Fatalf("could not deposit: %v", err)
+	}
+	undo, _ := state.IncorporateBlock(block)
+	if weights := state.ValidatorWeights(); len(weights) != 2 || weights[alice] != 500 {
+		t.Errorf("depositor not in the validator set: %v", weights)
+	}
+	state.RevertBlock(undo)
+	if weights := state.ValidatorWeights(); len(weights) != 1 {
+		t.Errorf("deposit not reverted from the validator set: %v", weights)
+	}
+}
+
+func TestCaptions(t *testing.T) {
+	state, token := NewGenesisState()
+	_, publisher := crypto.RandomAsymetricKey()
+	eve := &instructions.Author{PrivateKey: token}
+	alice, aliceKey := crypto.RandomAsymetricKey()
+	bob, bobKey := crypto.RandomAsymetricKey()
+	if ok, owner := state.ResolveCaption("Aereum Network Genesis"); !ok || owner != token.PublicKey() {
+		t.Fatal("genesis caption not registered")
+	}
+
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), &MutatingState{State: state})
+	if block.Incorporate(eve.NewJoinNetworkThirdParty(alice, "alice", `{}`, 1, 10)) != nil ||
+		block.Incorporate(eve.NewJoinNetworkThirdParty(bob, "bob", `{}`, 1, 10)) != nil {
+		t.Fatal("could not add new members")
+	}
+	if _, err := state.IncorporateBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	if ok, owner := state.ResolveCaption("alice"); !ok || owner != alice {
+		t.Fatal("caption not resolved to member")
+	}
+
+	block = NewBlock(crypto.Hasher([]byte{}), 1, 2, publisher.PublicKey(), &MutatingState{State: state})
+	aliceAuthor := &instructions.Author{PrivateKey: aliceKey, Wallet: token}
+	bobAuthor := &instructions.Author{PrivateKey: bobKey, Wallet: token}
+	if err := block.Incorporate(bobAuthor.NewTransferCaption("alice", alice, 2, 1)); err != instructions.ErrNotCaptionOwner {
+		t.Errorf("expected not caption owner, got %v", err)
+	}
+	if err := block.Incorporate(aliceAuthor.NewChangeCaption("bob", 2, 1)); err != instructions.ErrCaptionTaken {
+		t.Errorf("expected caption taken, got %v", err)
+	}
+	if err := block.Incorporate(aliceAuthor.NewChangeCaption("alice in chains", 2, 1)); err != nil {
+		t.Fatalf("could not change caption: %v", err)
+	}
+	if err := block.Incorporate(aliceAuthor.NewChangeCaption("alice in wonderland", 2, 2)); err != instructions.ErrConflictingInstruction {
+		t.Errorf("expected conflicting instruction, got %v", err)
+	}
+	if _, err := state.IncorporateBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	if state.Captions.ExistsHash(crypto.Hasher([]byte("alice"))) {
+		t.Error("previous caption not released")
+	}
+	if ok, caption := state.MemberCaption(crypto.HashToken(alice)); !ok || caption != crypto.Hasher([]byte("alice in chains")) {
+		t.Error("member caption not changed")
+	}
+
+	root := state.Root()
+	block = NewBlock(crypto.Hasher([]byte{}), 2, 3, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(aliceAuthor.NewTransferCaption("alice in chains", bob, 3, 1)); err != nil {
+		t.Fatalf("could not transfer caption: %v", err)
+	}
+	undo, err := state.IncorporateBlock(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, owner := state.ResolveCaption("alice in chains"); !ok || owner != bob {
+		t.Error("caption not transferred")
+	}
+	if state.Captions.ExistsHash(crypto.Hasher([]byte("bob"))) {
+		t.Error("previous caption of recipient not released")
+	}
+	if ok, _ := state.MemberCaption(crypto.HashToken(alice)); ok {
+		t.Error("sender kept a caption")
+	}
+	if ok, caption := state.MemberCaption(crypto.HashToken(bob)); !ok || caption != crypto.Hasher([]byte("alice in chains")) {
+		t.Error("recipient caption not changed")
+	}
+	state.RevertBlock(undo)
+	if ok, owner := state.ResolveCaption("bob"); !ok || owner != bob || state.Root() != root {
+		t.Error("caption transfer not reverted")
+	}
+
+	block = NewBlock(crypto.Hasher([]byte{}), 2, 3, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(eve.NewJoinNetworkThirdParty(alice, "alice", `{}`, 3, 10)); err != instructions.ErrAlreadyMember {
+		t.Errorf("expected already member, got %v", err)
+	}
+	_, carol := crypto.RandomAsymetricKey()
+	if err := block.Incorporate(eve.NewJoinNetworkThirdParty(carol.PublicKey(), "alice", `{}`, 3, 10)); err != nil {
+		t.Errorf("released caption not available: %v", err)
+	}
+	state.IncorporateBlock(block)
+	if ok, owner := state.ResolveCaption("alice"); !ok || owner != carol.PublicKey() {
+		t.Error("released caption not taken by new member")
+	}
+}
+
+func TestRotateIdentity(t *testing.T) {
+	state, token := NewGenesisState()
+	_, publisher := crypto.RandomAsymetricKey()
+	eve := &instructions.Author{PrivateKey: token}
+	alice, aliceKey := crypto.RandomAsymetricKey()
+	bob, _ := crypto.RandomAsymetricKey()
+	_, next := crypto.RandomAsymetricKey()
+	aliceAuthor := &instructions.Author{PrivateKey: aliceKey, Wallet: token}
+	stage := instructions.NewStage(0, "stage")
+	stageHash := crypto.HashToken(stage.PrivateKey.PublicKey())
+
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), &MutatingState{State: state})
+	if block.Incorporate(eve.NewJoinNetworkThirdParty(alice, "alice", `{}`, 1, 10)) != nil ||
+		block.Incorporate(eve.NewJoinNetworkThirdParty(bob, "bob", `{}`, 1, 10)) != nil ||
+		block.Incorporate(instructions.NewSingleReciepientTransfer(token, alice, "", 1000, 1, 10)) != nil {
+		t.Fatal("could not add new members")
+	}
+	state.IncorporateBlock(block)
+	block = NewBlock(crypto.Hasher([]byte{}), 1, 2, publisher.PublicKey(), &MutatingState{State: state})
+	if block.Incorporate(aliceAuthor.NewGrantPowerOfAttorney(bob, 2, 10)) != nil ||
+		block.Incorporate(aliceAuthor.NewCreateAudience(stage, 2, 10)) != nil {
+		t.Fatal("could not grant power of attorney and create stage")
+	}
+	state.IncorporateBlock(block)
+	if ok, owner := state.StageOwner(stageHash); !ok || owner != alice {
+		t.Fatal("stage owner not registered")
+	}
+
+	root := state.Root()
+	block = NewBlock(crypto.Hasher([]byte{}), 2, 3, publisher.PublicKey(), &MutatingState{State: state})
+	stages := []crypto.Token{stage.PrivateKey.PublicKey()}
+	if err := block.Incorporate(instructions.NewRotateIdentity(aliceKey, token, nil, nil, 3, 10)); err != instructions.ErrAlreadyMember {
+		t.Errorf("expected already member, got %v", err)
+	}
+	if err := block.Incorporate(instructions.NewRotateIdentity(aliceKey, next, []crypto.Token{alice}, nil, 3, 10)); err != instructions.ErrNoPowerOfAttorney {
+		t.Errorf("expected no power of attorney, got %v", err)
+	}
+	if err := block.Incorporate(instructions.NewRotateIdentity(aliceKey, next, []crypto.Token{bob}, nil, 3, 10)); err != instructions.ErrIncompleteRotation {
+		t.Errorf("expected incomplete rotation without the stage, got %v", err)
+	}
+	if err := block.Incorporate(instructions.NewRotateIdentity(aliceKey, next, []crypto.Token{bob, bob}, nil, 3, 10)); err != instructions.ErrIncompleteRotation {
+		t.Errorf("expected incomplete rotation with a repeated attorney, got %v", err)
+	}
+	if err := block.Incorporate(instructions.NewRotateIdentity(aliceKey, next, nil, stages, 3, 10)); err != instructions.ErrIncompleteRotation {
+		t.Errorf("expected incomplete rotation without the attorney, got %v", err)
+	}
+	if err := block.Incorporate(instructions.NewRotateIdentity(aliceKey, next, []crypto.Token{bob}, stages, 3, 10)); err != nil {
+		t.Fatalf("could not rotate identity: %v", err)
+	}
+	undo, err := state.IncorporateBlock(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newToken := next.PublicKey()
+	newHash := crypto.HashToken(newToken)
+	if state.Members.ExistsHash(crypto.HashToken(alice)) || !state.Members.ExistsHash(newHash) {
+		t.Error("membership not moved")
+	}
+	if ok, owner := state.ResolveCaption("alice"); !ok || owner != newToken {
+		t.Error("caption not moved")
+	}
+	if ok, _ := state.MemberCaption(crypto.HashToken(alice)); ok {
+		t.Error("old token kept a caption")
+	}
+	if _, balance := state.Wallets.BalanceHash(newHash); balance != 990 {
+		t.Errorf("expected balance 990, got %v", balance)
+	}
+	if _, balance := state.Wallets.BalanceHash(crypto.HashToken(alice)); balance != 0 {
+		t.Errorf("old token kept balance %v", balance)
+	}
+	if state.PowerOfAttorney.ExistsHash(crypto.Hasher(append(alice[:], bob[:]...))) ||
+		!state.PowerOfAttorney.ExistsHash(crypto.Hasher(append(newToken[:], bob[:]...))) {
+		t.Error("power of attorney not moved")
+	}
+	if ok, owner := state.StageOwner(stageHash); !ok || owner != newToken {
+		t.Error("stage ownership not moved")
+	}
+	if _, holdings := state.Holdings.BalanceHash(newHash); holdings != 2 {
+		t.Errorf("holdings not moved: %v", holdings)
+	}
+
+	block = NewBlock(crypto.Hasher([]byte{}), 3, 4, publisher.PublicKey(), &MutatingState{State: state})
+	none := make(map[crypto.Token]crypto.Token)
+	if err := block.Incorporate(aliceAuthor.NewUpdateAudience(stage, none, none, none, 0, "update", 4, 10)); err != instructions.ErrNotMember {
+		t.Errorf("expected not member, got %v", err)
+	}
+	nextAuthor := &instructions.Author{PrivateKey: next, Wallet: token}
+	if err := block.Incorporate(nextAuthor.NewUpdateAudience(stage, none, none, none, 0, "update", 4, 10)); err != nil {
+		t.Errorf("new token could not update stage: %v", err)
+	}
+
+	state.RevertBlock(undo)
+	if state.Root() != root {
+		t.Error("rotation not reverted")
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	state, token := NewGenesisState()
+	_, publisher := crypto.RandomAsymetricKey()
+	eve := &instructions.Author{PrivateKey: token}
+	alice, aliceKey := crypto.RandomAsymetricKey()
+	bob, bobKey := crypto.RandomAsymetricKey()
+	carol, carolKey := crypto.RandomAsymetricKey()
+	_, next := crypto.RandomAsymetricKey()
+	aliceAuthor := &instructions.Author{PrivateKey: aliceKey, Wallet: token}
+	guardians := []crypto.Token{bob, carol}
+
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), &MutatingState{State: state})
+	if block.Incorporate(eve.NewJoinNetworkThirdParty(alice, "alice", `{}`, 1, 10)) != nil ||
+		block.Incorporate(instructions.NewSingleReciepientTransfer(token, alice, "", 1000, 1, 10)) != nil {
+		t.Fatal("could not add new member")
+	}
+	if err := block.Incorporate(aliceAuthor.NewSetGuardians([]crypto.Token{bob, alice}, 1, 1, 10)); err != instructions.ErrNotMember {
+		t.Errorf("expected not member, got %v", err)
+	}
+	state.IncorporateBlock(block)
+
+	block = NewBlock(crypto.Hasher([]byte{}), 1, 2, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(aliceAuthor.NewSetGuardians([]crypto.Token{bob, alice}, 1, 2, 10)); err != instructions.ErrInvalidGuardians {
+		t.Errorf("expected invalid guardians, got %v", err)
+	}
+	if err := block.Incorporate(aliceAuthor.NewSetGuardians(guardians, 2, 2, 10)); err != nil {
+		t.Fatalf("could not set guardians: %v", err)
+	}
+	state.IncorporateBlock(block)
+
+	block = NewBlock(crypto.Hasher([]byte{}), 2, 3, publisher.PublicKey(), &MutatingState{State: state})
+	recovery := instructions.NewRecoverIdentity(alice, next.PublicKey(), guardians, 2, 3, 10)
+	recovery.Sign(bobKey)
+	if err := block.Incorporate(recovery); err != instructions.ErrInsufficientGuardians {
+		t.Errorf("expected insufficient guardians, got %v", err)
+	}
+	recovery.Sign(carolKey)
+	if err := block.Incorporate(recovery); err != nil {
+		t.Fatalf("could not start recovery: %v", err)
+	}
+	state.IncorporateBlock(block)
+	release := state.Recovery(RecoveryHash(alice, next.PublicKey()))
+	if release != 3+RecoveryDelay {
+		t.Fatalf("expected recovery at %v, got %v", 3+RecoveryDelay, release)
+	}
+
+	root := state.Root()
+	block = NewBlock(crypto.Hasher([]byte{}), 3, 4, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(instructions.NewCompleteRecovery(alice, next, nil, nil, 4, 10)); err != instructions.ErrRecoveryDelay {
+		t.Errorf("expected recovery delay, got %v", err)
+	}
+	if err := block.Incorporate(aliceAuthor.NewCancelRecovery(next.PublicKey(), 4, 10)); err != nil {
+		t.Fatalf("could not cancel recovery: %v", err)
+	}
+	undo, _ := state.IncorporateBlock(block)
+	if state.Recovery(RecoveryHash(alice, next.PublicKey())) != 0 {
+		t.Error("recovery not cancelled")
+	}
+	state.RevertBlock(undo)
+	if state.Root() != root {
+		t.Fatal("cancel not reverted")
+	}
+
+	block = NewBlock(crypto.Hasher([]byte{}), 3, release, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(instructions.NewCompleteRecovery(alice, next, nil, nil, release, 10)); err != nil {
+		t.Fatalf("could not complete recovery: %v", err)
+	}
+	if _, err := state.IncorporateBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	newHash := crypto.HashToken(next.PublicKey())
+	if !state.Members.ExistsHash(newHash) || state.Members.ExistsHash(crypto.HashToken(alice)) {
+		t.Error("membership not recovered")
+	}
+	if ok, owner := state.ResolveCaption("alice"); !ok || owner != next.PublicKey() {
+		t.Error("caption not recovered")
+	}
+	if _, balance := state.Wallets.BalanceHash(newHash); balance != 980 {
+		t.Errorf("expected balance 980, got %v", balance)
+	}
+	if ok, commitment := state.MemberGuardians(newHash); !ok || commitment != instructions.GuardiansHash(guardians, 2) {
+		t.Error("guardians not moved to the new token")
+	}
+	if state.Recovery(RecoveryHash(alice, next.PublicKey())) != 0 {
+		t.Error("completed recovery still pending")
+	}
+}
+
+func TestRecoveryReplay(t *testing.T) {
+	state, token := NewGenesisState()
+	_, publisher := crypto.RandomAsymetricKey()
+	eve := &instructions.Author{PrivateKey: token}
+	alice, aliceKey := crypto.RandomAsymetricKey()
+	_, next := crypto.RandomAsymetricKey()
+	aliceAuthor := &instructions.Author{PrivateKey: aliceKey, Wallet: token}
+	guardians := make([]crypto.Token, 3)
+	keys := make([]crypto.PrivateKey, 3)
+	for n := range guardians {
+		guardians[n], keys[n] = crypto.RandomAsymetricKey()
+	}
+
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), &MutatingState{State: state})
+	if block.Incorporate(eve.NewJoinNetworkThirdParty(alice, "alice", `{}`, 1, 10)) != nil ||
+		block.Incorporate(instructions.NewSingleReciepientTransfer(token, alice, "", 1000, 1, 10)) != nil {
+		t.Fatal("could not add new member")
+	}
+	state.IncorporateBlock(block)
+	block = NewBlock(crypto.Hasher([]byte{}), 1, 2, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(aliceAuthor.NewSetGuardians(guardians, 2, 2, 10)); err != nil {
+		t.Fatalf("could not set guardians: %v", err)
+	}
+	state.IncorporateBlock(block)
+
+	recovery := instructions.NewRecoverIdentity(alice, next.PublicKey(), guardians, 2, 3, 10)
+	for _, key := range keys {
+		recovery.Sign(key)
+	}
+	block = NewBlock(crypto.Hasher([]byte{}), 2, 3, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(recovery); err != nil {
+		t.Fatalf("could not start recovery: %v", err)
+	}
+	state.IncorporateBlock(block)
+	block = NewBlock(crypto.Hasher([]byte{}), 3, 4, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(aliceAuthor.NewCancelRecovery(next.PublicKey(), 4, 10)); err != nil {
+		t.Fatalf("could not cancel recovery: %v", err)
+	}
+	state.IncorporateBlock(block)
+
+	// a variant with one signature stripped is still above the threshold
+	stripped := *recovery
+	stripped.Signatures = append([]crypto.Signature{}, recovery.Signatures...)
+	stripped.Signatures[0] = crypto.Signature{}
+	block = NewBlock(crypto.Hasher([]byte{}), 4, 5, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(&stripped); err != instructions.ErrDuplicateInstruction {
+		t.Errorf("expected cancelled recovery not to be replayed, got %v", err)
+	}
+}
+
+func TestAttorneyScope(t *testing.T) {
+	state, token := NewGenesisState()
+	_, publisher := crypto.RandomAsymetricKey()
+	eve := &instructions.Author{PrivateKey: token}
+	alice, aliceKey := crypto.RandomAsymetricKey()
+	bob, bobKey := crypto.RandomAsymetricKey()
+	carol, carolKey := crypto.RandomAsymetricKey()
+	stage, _ := crypto.RandomAsymetricKey()
+	aliceAuthor := &instructions.Author{PrivateKey: aliceKey, Wallet: token}
+	bobAttorney := &instructions.Author{PrivateKey: aliceKey, Attorney: bobKey}
+	carolAttorney := &instructions.Author{PrivateKey: aliceKey, Attorney: carolKey}
+
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), &MutatingState{State: state})
+	for n, member := range []crypto.Token{alice, bob, carol} {
+		if block.Incorporate(eve.NewJoinNetworkThirdParty(member, fmt.Sprintf("member %v", n), `{}`, 1, 10)) != nil ||
+			block.Incorporate(instructions.NewSingleReciepientTransfer(token, member, "", 1000, 1, 10)) != nil {
+			t.Fatal("could not add new members")
+		}
+	}
+	state.IncorporateBlock(block)
+
+	block = NewBlock(crypto.Hasher([]byte{}), 1, 2, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(bobAttorney.NewReact([]byte("content"), 1, 2, 10)); err != instructions.ErrNoPowerOfAttorney {
+		t.Errorf("expected no power of attorney, got %v", err)
+	}
+	scope := store.AttorneyScope{Kinds: instructions.KindsMask(instructions.IReact, instructions.IChangeCaption), Expire: 4, Limit: 25}
+	if block.Incorporate(aliceAuthor.NewScopedPowerOfAttorney(bob, scope, 2, 10)) != nil ||
+		block.Incorporate(aliceAuthor.NewScopedPowerOfAttorney(carol, store.AttorneyScope{Kinds: instructions.AllKinds, Audience: stage}, 2, 10)) != nil {
+		t.Fatal("could not grant power of attorney")
+	}
+	state.IncorporateBlock(block)
+
+	block = NewBlock(crypto.Hasher([]byte{}), 2, 3, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(carolAttorney.NewGrantPowerOfAttorney(token.PublicKey(), 3, 10)); err != instructions.ErrAttorneyNotAllowed {
+		t.Errorf("expected attorney not allowed to grant powers, got %v", err)
+	}
+	if err := block.Incorporate(bobAttorney.NewUpdateInfo(`{}`, 3, 10)); err != instructions.ErrAttorneyScope {
+		t.Errorf("expected attorney scope, got %v", err)
+	}
+	if block.Incorporate(bobAttorney.NewChangeCaption("alice", 3, 10)) != nil ||
+		block.Incorporate(bobAttorney.NewReact([]byte("first"), 1, 3, 10)) != nil {
+		t.Fatal("could not sign within scope")
+	}
+	if err := block.Incorporate(bobAttorney.NewReact([]byte("second"), 1, 3, 10)); err != instructions.ErrAttorneyLimit {
+		t.Errorf("expected attorney limit, got %v", err)
+	}
+	if err := block.Incorporate(bobAttorney.NewReact([]byte("third"), 1, 3, 5)); err != nil {
+		t.Errorf("rejected spending counted against the cap: %v", err)
+	}
+	if err := block.Incorporate(carolAttorney.NewReact([]byte("first"), 1, 3, 10)); err != instructions.ErrAttorneyScope {
+		t.Errorf("expected attorney scope outside audience, got %v", err)
+	}
+	if err := block.Incorporate(carolAttorney.NewJoinAudience(stage, stage, "", 3, 10)); err != instructions.ErrUnknownStage {
+		t.Errorf("expected unknown stage within audience, got %v", err)
+	}
+	state.IncorporateBlock(block)
+
+	block = NewBlock(crypto.Hasher([]byte{}), 3, 4, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(bobAttorney.NewReact([]byte("second"), 1, 4, 10)); err != nil {
+		t.Errorf("spending cap not renewed on a new epoch: %v", err)
+	}
+	state.IncorporateBlock(block)
+
+	block = NewBlock(crypto.Hasher([]byte{}), 4, 5, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(bobAttorney.NewReact([]byte("third"), 1, 5, 10)); err != instructions.ErrAttorneyExpired {
+		t.Errorf("expected attorney expired, got %v", err)
+	}
+}
+
+func TestSponsoredContent(t *testing.T) {
+	state, token := NewGenesisState()
+	_, publisher := crypto.RandomAsymetricKey()
+	eve := &instructions.Author{PrivateKey: token}
+	sponsor, sponsorKey := crypto.RandomAsymetricKey()
+	owner, ownerKey := crypto.RandomAsymetricKey()
+	sponsorAuthor := &instructions.Author{PrivateKey: sponsorKey, Wallet: token}
+	ownerAuthor := &instructions.Author{PrivateKey: ownerKey, Wallet: token}
+	stage := instructions.NewStage(0, "stage")
+
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), &MutatingState{State: state})
+	if block.Incorporate(eve.NewJoinNetworkThirdParty(sponsor, "sponsor", `{}`, 1, 10)) != nil ||
+		block.Incorporate(eve.NewJoinNetworkThirdParty(owner, "owner", `{}`, 1, 10)) != nil {
+		t.Fatal("could not add new members")
+	}
+	state.IncorporateBlock(block)
+
+	block = NewBlock(crypto.Hasher([]byte{}), 1, 2, publisher.PublicKey(), &MutatingState{State: state})
+	if block.Incorporate(ownerAuthor.NewCreateAudience(stage, 2, 10)) != nil {
+		t.Fatal("could not create stage")
+	}
+	state.IncorporateBlock(block)
+
+	offer := sponsorAuthor.NewSponsorshipOffer(stage, "text", []byte("sponsored"), 20, 50, 3, 10)
+	block = NewBlock(crypto.Hasher([]byte{}), 2, 3, publisher.PublicKey(), &MutatingState{State: state})
+	if block.Incorporate(offer) != nil {
+		t.Fatal("could not offer sponsorship")
+	}
+	state.IncorporateBlock(block)
+
+	accept := NewBlock(crypto.Hasher([]byte{}), 3, 4, publisher.PublicKey(), &MutatingState{State: state})
+	if err := accept.Incorporate(ownerAuthor.NewSponsorshipAcceptance(stage, offer, 4, 10)); err != nil {
+		t.Fatalf("could not accept sponsorship: %v", err)
+	}
+
+	publish := func(validator *MutatingState) {
+		t.Helper()
+		block := NewBlock(crypto.Hasher([]byte{}), 4, 5, publisher.PublicKey(), validator)
+		forged := sponsorAuthor.NewSponsoredContent(offer, 5, 10)
+		forged.Content = []byte("forged")
+		if err := block.Incorporate(forged); err != instructions.ErrSponsoredContent {
+			t.Errorf("expected sponsored content mismatch, got %v", err)
+		}
+		if err := block.Incorporate(sponsorAuthor.NewSponsoredContent(offer, 5, 10)); err != nil {
+			t.Fatalf("could not publish sponsored content: %v", err)
+		}
+		if err := block.Incorporate(sponsorAuthor.NewSponsoredContent(offer, 5, 11)); err != instructions.ErrNoSponsorship {
+			t.Errorf("expected sponsorship consumed, got %v", err)
+		}
+	}
+
+	grouped, err := GroupBlockMutations(state, []*Block{accept})
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(&MutatingState{State: state, Mutations: grouped})
+
+	state.IncorporateBlock(accept)
+	audience := stage.PrivateKey.PublicKey()
+	grant := crypto.Hasher(append(sponsor[:], audience[:]...))
+	if ok, contentHash := state.SponsorGranted.GetContentHash(grant); !ok || !crypto.Hasher([]byte("sponsored")).Equals(contentHash) {
+		t.Fatal("sponsorship not granted on state")
+	}
+	publish(&MutatingState{State: state})
+}
+
+func TestMultisig(t *testing.T) {
+	state, token := NewGenesisState()
+	_, publisher := crypto.RandomAsymetricKey()
+	wallet, walletKey := crypto.RandomAsymetricKey()
+	first, firstKey := crypto.RandomAsymetricKey()
+	second, secondKey := crypto.RandomAsymetricKey()
+	signers := []crypto.Token{first, second}
+	payee, _ := crypto.RandomAsymetricKey()
+
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), &MutatingState{State: state})
+	if block.Incorporate(instructions.NewSingleReciepientTransfer(token, wallet, "", 1000, 1, 10)) != nil {
+		t.Fatal("could not fund wallet")
+	}
+	if err := block.Incorporate(instructions.NewCreateMultisig(walletKey, signers, 3, 1, 10)); err != instructions.ErrInvalidSigners {
+		t.Errorf("expected invalid signers, got %v", err)
+	}
+	if block.Incorporate(instructions.NewCreateMultisig(walletKey, signers, 2, 1, 10)) != nil {
+		t.Fatal("could not create multisig wallet")
+	}
+	if err := block.Incorporate(instructions.NewSingleReciepientTransfer(walletKey, payee, "", 100, 1, 10)); err != instructions.ErrMultisigWallet {
+		t.Errorf("expected multisig wallet, got %v", err)
+	}
+	root := state.Root()
+	undo, _ := state.IncorporateBlock(block)
+	if ok, _ := state.Multisig(crypto.HashToken(wallet)); !ok {
+		t.Fatal("multisig policy not stored")
+	}
+
+	block = NewBlock(crypto.Hasher([]byte{}), 1, 2, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(instructions.NewCreateMultisig(walletKey, signers[:1], 1, 2, 10)); err != instructions.ErrMultisigWallet {
+		t.Errorf("expected multisig wallet, got %v", err)
+	}
+	to := []instructions.Recipient{{Token: payee, Value: 100}}
+	transfer := instructions.NewMultisigTransfer(wallet, signers, 2, to, "", 2, 10)
+	transfer.Sign(firstKey)
+	if err := block.Incorporate(transfer); err != instructions.ErrInsufficientSignatures {
+		t.Errorf("expected insufficient signatures, got %v", err)
+	}
+	other := instructions.NewMultisigTransfer(wallet, signers, 1, to, "", 2, 10)
+	other.Sign(firstKey)
+	if err := block.Incorporate(other); err != instructions.ErrMultisigMismatch {
+		t.Errorf("expected multisig mismatch, got %v", err)
+	}
+	transfer.Sign(secondKey)
+	if err := block.Incorporate(transfer); err != nil {
+		t.Fatalf("could not transfer from multisig wallet: %v", err)
+	}
+	last, _ := state.IncorporateBlock(block)
+	if _, balance := state.Wallets.Balance(wallet); balance != 880 {
+		t.Errorf("wrong multisig wallet balance: %v", balance)
+	}
+	if _, balance := state.Wallets.Balance(payee); balance != 100 {
+		t.Errorf("wrong payee balance: %v", balance)
+	}
+
+	state.RevertBlock(last)
+	state.RevertBlock(undo)
+	if state.Root() != root {
+		t.Error("multisig wallet not reverted")
+	}
+}
+
+func TestMultisigReplay(t *testing.T) {
+	state, token := NewGenesisState()
+	_, publisher := crypto.RandomAsymetricKey()
+	wallet, walletKey := crypto.RandomAsymetricKey()
+	signers := make([]crypto.Token, 3)
+	keys := make([]crypto.PrivateKey, 3)
+	for n := range signers {
+		signers[n], keys[n] = crypto.RandomAsymetricKey()
+	}
+	payee, _ := crypto.RandomAsymetricKey()
+
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), &MutatingState{State: state})
+	if block.Incorporate(instructions.NewSingleReciepientTransfer(token, wallet, "", 1000, 1, 10)) != nil {
+		t.Fatal("could not fund wallet")
+	}
+	if block.Incorporate(instructions.NewCreateMultisig(walletKey, signers, 2, 1, 10)) != nil {
+		t.Fatal("could not create multisig wallet")
+	}
+	state.IncorporateBlock(block)
+
+	to := []instructions.Recipient{{Token: payee, Value: 100}}
+	transfer := instructions.NewMultisigTransfer(wallet, signers, 2, to, "", 2, 10)
+	for _, key := range keys {
+		transfer.Sign(key)
+	}
+	// a variant with one signature stripped is still above the threshold
+	stripped := *transfer
+	stripped.Signatures = append([]crypto.Signature{}, transfer.Signatures...)
+	stripped.Signatures[2] = crypto.Signature{}
+	block = NewBlock(crypto.Hasher([]byte{}), 1, 2, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(transfer); err != nil {
+		t.Fatalf("could not transfer from multisig wallet: %v", err)
+	}
+	if err := block.Incorporate(&stripped); err != instructions.ErrDuplicateInstruction {
+		t.Errorf("expected duplicate instruction on the same block, got %v", err)
+	}
+	state.IncorporateBlock(block)
+
+	block = NewBlock(crypto.Hasher([]byte{}), 2, 3, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(&stripped); err != instructions.ErrDuplicateInstruction {
+		t.Errorf("expected duplicate instruction on a later block, got %v", err)
+	}
+	if _, balance := state.Wallets.Balance(payee); balance != 100 {
+		t.Errorf("transfer paid more than once: %v", balance)
+	}
+}
+
+func TestEscrowTransfer(t *testing.T) {
+	state, token := NewGenesisState()
+	_, publisher := crypto.RandomAsymetricKey()
+	payer := token.PublicKey()
+	recipient, _ := crypto.RandomAsymetricKey()
+	_, otherKey := crypto.RandomAsymetricKey()
+	balance := func(token crypto.Token) uint64 {
+		_, balance := state.Wallets.Balance(token)
+		return balance
+	}
+	initial := balance(payer)
+
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(instructions.NewEscrowTransfer(token, recipient, "", 50, 2, 0, 2, 1, 10)); err != instructions.ErrInvalidSchedule {
+		t.Errorf("expected invalid schedule, got %v", err)
+	}
+	if err := block.Incorporate(instructions.NewEscrowTransfer(token, recipient, "", 50, 3, math.MaxUint64/2, 2, 1, 10)); err != instructions.ErrInvalidSchedule {
+		t.Errorf("expected overflowing schedule to be invalid, got %v", err)
+	}
+	if _, _, ok := escrowInstallments(&store.Escrow{Value: 50, Period: 10}, math.MaxUint64-1, math.MaxUint64-1, 150); ok {
+		t.Error("overflowing installment rescheduled")
+	}
+	if err := block.Incorporate(instructions.NewEscrowTransfer(token, recipient, "", 50, 1, 0, 1, 1, 10)); err != instructions.ErrExpired {
+		t.Errorf("expected expired release, got %v", err)
+	}
+	locked := instructions.NewEscrowTransfer(token, recipient, "", 100, 1, 0, 3, 1, 10)
+	periodic := instructions.NewEscrowTransfer(token, recipient, "", 50, 4, 2, 2, 1, 10)
+	if block.Incorporate(locked) != nil || block.Incorporate(periodic) != nil {
+		t.Fatal("could not escrow transfers")
+	}
+	if err := block.Incorporate(instructions.NewCancelEscrow(token, locked.Hash(), 1, 10)); err != instructions.ErrConflictingInstruction {
+		t.Errorf("expected conflicting cancel, got %v", err)
+	}
+	state.IncorporateBlock(block)
+	if balance(payer) != initial-320 || state.Escrowed.Total() != 300 || state.Audit() != nil {
+		t.Fatalf("wrong escrowed balances: %v, %v", balance(payer), state.Escrowed.Total())
+	}
+
+	block = NewBlock(crypto.Hasher([]byte{}), 1, 2, publisher.PublicKey(), &MutatingState{State: state})
+	state.IncorporateBlock(block)
+	if balance(recipient) != 50 {
+		t.Errorf("first installment not released: %v", balance(recipient))
+	}
+
+	block = NewBlock(crypto.Hasher([]byte{}), 2, 3, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(instructions.NewCancelEscrow(token, locked.Hash(), 3, 10)); err != instructions.ErrEscrowReleased {
+		t.Errorf("expected escrow released, got %v", err)
+	}
+	if err := block.Incorporate(instructions.NewCancelEscrow(otherKey, periodic.Hash(), 3, 0)); err != instructions.ErrNotEscrowPayer {
+		t.Errorf("expected not escrow payer, got %v", err)
+	}
+	state.IncorporateBlock(block)
+	if balance(recipient) != 150 || state.Escrows.ExistsHash(locked.Hash()) {
+		t.Errorf("time locked transfer not released: %v", balance(recipient))
+	}
+
+	block = NewBlock(crypto.Hasher([]byte{}), 3, 6, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(instructions.NewCancelEscrow(token, periodic.Hash(), 6, 10)); err != instructions.ErrEscrowReleased {
+		t.Errorf("expected escrow released, got %v", err)
+	}
+	state.IncorporateBlock(block)
+	if balance(recipient) != 250 || state.EscrowReleases.Exists(periodic.Hash()) != 8 {
+		t.Errorf("missed installments not released: %v", balance(recipient))
+	}
+	root := state.Root()
+	before := balance(payer)
+
+	block = NewBlock(crypto.Hasher([]byte{}), 6, 7, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(instructions.NewCancelEscrow(token, periodic.Hash(), 7, 10)); err != nil {
+		t.Fatalf("could not cancel escrow: %v", err)
+	}
+	undo, _ := state.IncorporateBlock(block)
+	if balance(payer) != before+40 || state.Escrowed.Total() != 0 || state.Audit() != nil {
+		t.Errorf("escrow not returned to payer: %v", balance(payer)-before)
+	}
+	state.RevertBlock(undo)
+	if state.Root() != root {
+		t.Error("escrow cancel not reverted")
+	}
+}
+
+func TestSlash(t *testing.T) {
+	state, token := NewGenesisState()
+	_, publisher := crypto.RandomAsymetricKey()
+	offender := token.PublicKey()
+	reporter, reporterKey := crypto.RandomAsymetricKey()
+	hash := crypto.HashToken(offender)
+
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 1, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(instructions.NewSingleReciepientTransfer(token, reporter, "", 1000, 1, 0)); err != nil {
+		t.Fatalf("could not transfer: %v", err)
+	}
+	state.IncorporateBlock(block)
+	root, supply := state.Root(), state.TotalSupply
+
+	first, second := crypto.Hasher([]byte("first")), crypto.Hasher([]byte("second"))
+	conflict := instructions.NewConflict(token, instructions.VoteMessage, 1, first, second)
+	block = NewBlock(crypto.Hasher([]byte{}), 1, 2, publisher.PublicKey(), &MutatingState{State: state})
+	same := instructions.NewConflict(token, instructions.VoteMessage, 1, first, first)
+	if err := block.Incorporate(instructions.NewDenounceCheckpoint(reporterKey, offender, same, 2, 10)); err != instructions.ErrInvalidEvidence {
+		t.Errorf("expected invalid evidence, got %v", err)
+	}
+	if err := block.Incorporate(instructions.NewDenounceChecksum(reporterKey, offender, conflict, 2, 10)); err != instructions.ErrInvalidEvidence {
+		t.Errorf("expected invalid evidence for vote as checksum, got %v", err)
+	}
+	own := instructions.NewConflict(reporterKey, instructions.VoteMessage, 1, first, second)
+	if err := block.Incorporate(instructions.NewDenounceCheckpoint(reporterKey, reporter, own, 2, 10)); err != instructions.ErrNotValidator {
+		t.Errorf("expected not validator, got %v", err)
+	}
+	if err := block.Incorporate(instructions.NewDenounceCheckpoint(reporterKey, offender, conflict, 2, 10)); err != nil {
+		t.Fatalf("could not denounce: %v", err)
+	}
+	checksums := instructions.NewConflict(token, instructions.ChecksumMessage, 1, first, second)
+	if err := block.Incorporate(instructions.NewDenounceChecksum(reporterKey, offender, checksums, 2, 10)); err != instructions.ErrAlreadySlashed {
+		t.Errorf("expected already slashed in block, got %v", err)
+	}
+	undo, err := state.IncorporateBlock(block)
+	if err != nil {
+		t.Fatalf("could not incorporate slash: %v", err)
+	}
+	if _, stake := state.Stakes.BalanceHash(hash); stake != GenesisStake*(100-SlashPercentage)/100 {
+		t.Errorf("wrong stake after slash: %v", stake)
+	}
+	if state.Weight(offender) != 0 || !state.Slashed.ExistsHash(hash) {
+		t.Error("slashed validator still weighs")
+	}
+	_, stake := state.Stakes.BalanceHash(hash)
+	if _, pooled := state.Pools.BalanceHash(hash); state.TotalStake() != state.Stakes.Total()+state.Pools.Total()-stake-pooled {
+		t.Errorf("slashed stake counted on total stake: %v", state.TotalStake())
+	}
+	if state.TotalSupply != supply-GenesisStake*SlashPercentage/100 || state.Audit() != nil {
+		t.Errorf("burned stake not removed from supply: %v", state.TotalSupply)
+	}
+	state.RevertBlock(undo)
+	if state.Root() != root || state.TotalSupply != supply {
+		t.Fatal("slash not reverted")
+	}
+	state.IncorporateBlock(block)
+
+	block = NewBlock(crypto.Hasher([]byte{}), 2, 3, publisher.PublicKey(), &MutatingState{State: state})
+	if err := block.Incorporate(instructions.NewDenounceChecksum(reporterKey, offender, checksums, 3, 10)); err != instructions.ErrAlreadySlashed {
+		t.Errorf("expected already slashed, got %v", err)
+	}
+}
+
+func TestDenounceInstruction(t *testing.T) {
+	state, token := NewGenesisState()
+	_, publisher := crypto.RandomAsymetricKey()
+	offender := token.PublicKey()
+	_, reporterKey := crypto.RandomAsymetricKey()
+
+	valid := instructions.NewSingleReciepientTransfer(token, publisher.PublicKey(), "", 10, 1, 0).Serialize()
+	published := NewBlock(crypto.Hasher([]byte{}), 0, 1, offender, &MutatingState{State: state})
+	published.Instructions = append(published.Instructions, valid, []byte{0, 255, 1})
+	published.Seal(token)
+	header := published.Header().Serialize()
+	if crypto.Hasher(header) != published.Hash {
+		t.Fatal("header does not hash to block hash")
+	}
+	vote := token.Sign(instructions.VoteMessage(1, published.Hash))
+
+	block := NewBlock(crypto.Hasher([]byte{}), 0, 2, publisher.PublicKey(), &MutatingState{State: state})
+	denounce := func(n int) error {
+		evidence := instructions.NewDenounceInstruction(reporterKey, offender, header, vote, published.Instructions[n], uint16(n), published.InstructionProof(n), 2, 0)
+		return block.Incorporate(evidence)
+	}
+	if err := denounce(0); err != instructions.ErrInvalidEvidence {
+		t.Errorf("expected invalid evidence for valid instruction, got %v", err)
+	}
+	if err := denounce(1); err != nil {
+		t.Fatalf("could not denounce invalid instruction: %v", err)
+	}
+	if !block.Slashed(crypto.HashToken(offender)) {
+		t.Error("offender not slashed")
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2021 The Aereum Authors
+// This file is part of the aereum library.
+//
+// The aereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aereum library. If not, see <http://www.gnu.org/licenses/>.
+package chain
+
+import (
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/store"
+)
+
+// Undo records the changes made to the state by the incorporation of a block,
+// so that they can be reverted if the block is discarded.
+type Undo struct {
+	epoch             uint64
+	lastHash          crypto.Hash
+	supply            uint64
+	entries           map[*store.Registry]map[crypto.Hash]*crypto.Hash // values prior to the block, nil if absent
+	insertedMembers   []crypto.Hash
+	removedMembers    []crypto.Hash
+	balances          map[*store.Wallet]map[crypto.Hash]uint64 // balances prior to the block
+	insertedReleases  map[crypto.Hash]uint64
+	removedReleases   map[crypto.Hash]uint64
+	powers            map[crypto.Hash]*store.AttorneyScope // nil if not granted
+	removedSponsor    map[crypto.Hash][]byte               // content hash prior to the block
+	insertedSponsor   []crypto.Hash
+	insertedOffers    map[crypto.Hash]uint64 // expire epoch of new offers
+	removedOffers     map[crypto.Hash]uint64 // expire epoch of swept offers
+	insertedEphemeral map[crypto.Hash]uint64
+	removedEphemeral  map[crypto.Hash]uint64
+	insertedIncluded  map[crypto.Hash]uint64
+	removedIncluded   map[crypto.Hash]uint64
+	stages            map[crypto.Hash]*store.StageKeys // nil if stage did not exist
+	recoveries        map[crypto.Hash]uint64           // epoch prior to the block, 0 if absent
+	escrows           map[crypto.Hash]*store.Escrow    // terms prior to the block, nil if absent
+	escrowReleases    map[crypto.Hash]uint64           // release epoch prior to the block, 0 if absent
+	slashed           []crypto.Hash
+}
+
+func newUndo(epoch uint64, lastHash crypto.Hash, supply uint64) *Undo {
+	return &Undo{
+		epoch:             epoch,
+		lastHash:          lastHash,
+		supply:            supply,
+		entries:           make(map[*store.Registry]map[crypto.Hash]*crypto.Hash),
+		insertedMembers:   make([]crypto.Hash, 0),
+		removedMembers:    make([]crypto.Hash, 0),
+		balances:          make(map[*store.Wallet]map[crypto.Hash]uint64),
+		insertedReleases:  make(map[crypto.Hash]uint64),
+		removedReleases:   make(map[crypto.Hash]uint64),
+		powers:            make(map[crypto.Hash]*store.AttorneyScope),
+		removedSponsor:    make(map[crypto.Hash][]byte),
+		insertedSponsor:   make([]crypto.Hash, 0),
+		insertedOffers:    make(map[crypto.Hash]uint64),
+		removedOffers:     make(map[crypto.Hash]uint64),
+		insertedEphemeral: make(map[crypto.Hash]uint64),
+		removedEphemeral:  make(map[crypto.Hash]uint64),
+		insertedIncluded:  make(map[crypto.Hash]uint64),
+		removedIncluded:   make(map[crypto.Hash]uint64),
+		stages:            make(map[crypto.Hash]*store.StageKeys),
+		recoveries:        make(map[crypto.Hash]uint64),
+		escrows:           make(map[crypto.Hash]*store.Escrow),
+		escrowReleases:    make(map[crypto.Hash]uint64),
+		slashed:           make([]crypto.Hash, 0),
+	}
+}
+
+// Epoch returns the epoch of the state prior to the incorporation of the
+// block.
+func (u *Undo) Epoch() uint64 {
+	return u.epoch
+}
+
+// keepBalance records the balance of acc on wallets if it was not recorded
+// before.
+func (u *Undo) keepBalance(acc crypto.Hash, wallets *store.Wallet) {
+	balances, ok := u.balances[wallets]
+	if !ok {
+		balances = make(map[crypto.Hash]uint64)
+		u.balances[wallets] = balances
+	}
+	if _, ok := balances[acc]; ok {
+		return
+	}
+	_, balance := wallets.BalanceHash(acc)
+	balances[acc] = balance
+}
+
+// restoreBalances sets back every balance of wallets recorded on balances.
+func restoreBalances(wallets *store.Wallet, balances map[crypto.Hash]uint64) {
+	for acc, balance := range balances {
+		_, current := wallets.BalanceHash(acc)
+		if current > balance {
+			wallets.DebitHash(acc, current-balance)
+		} else if current < balance {
+			wallets.CreditHash(acc, balance-current)
+		}
+	}
+}
+
+// keepEntry records the value of hash on registry if it was not recorded
+// before.
+func (u *Undo) keepEntry(hash crypto.Hash, registry *store.Registry) {
+	entries, ok := u.entries[registry]
+	if !ok {
+		entries = make(map[crypto.Hash]*crypto.Hash)
+		u.entries[registry] = entries
+	}
+	if _, ok := entries[hash]; ok {
+		return
+	}
+	if ok, value := registry.Get(hash); ok {
+		entries[hash] = &value
+	} else {
+		entries[hash] = nil
+	}
+}
+
+// keepStage records the keys of the stage if they were not recorded before.
+func (u *Undo) keepStage(hash crypto.Hash, stages *store.Stage) {
+	if _, ok := u.stages[hash]; ok {
+		return
+	}
+	u.stages[hash] = stages.GetKeys(hash)
+}
+
+// keepPower records the scope of the power of attorney hash if it was not
+// recorded before.
+func (u *Undo) keepPower(hash crypto.Hash, powers *store.AttorneyVault) {
+	if _, ok := u.powers[hash]; ok {
+		return
+	}
+	u.powers[hash] = powers.Get(hash)
+}
+
+// keepEscrow records the terms of the escrow hash if they were not recorded
+// before.
+func (u *Undo) keepEscrow(hash crypto.Hash, escrows *store.EscrowVault) {
+	if _, ok := u.escrows[hash]; ok {
+		return
+	}
+	u.escrows[hash] = escrows.Get(hash)
+}
+
+// keepEscrowRelease records release as the release epoch of the escrow hash
+// if it was not recorded before.
+func (u *Undo) keepEscrowRelease(hash crypto.Hash, release uint64) {
+	if _, ok := u.escrowReleases[hash]; ok {
+		return
+	}
+	u.escrowReleases[hash] = release
+}
+
+// keepRecovery records the epoch of the pending recovery hash if it was not
+// recorded before.
+func (u *Undo) keepRecovery(hash crypto.Hash, recoveries *store.HashExpireVault) {
+	if _, ok := u.recoveries[hash]; ok {
+		return
+	}
+	u.recoveries[hash] = recoveries.Exists(hash)
+}
+
+// RevertBlock restores the state to the one prior to the incorporation of the
+// block that produced undo. Blocks must be reverted in the reverse order of
+// their incorporation.
+func (s *State) RevertBlock(undo *Undo) {
+	s.beginWrite()
+	for hash, keys := range undo.stages {
+		if keys == nil {
+			s.Stages.RemoveKeys(hash)
+		} else {
+			s.Stages.SetKeys(hash, keys)
+		}
+	}
+	for hash, epoch := range undo.recoveries {
+		s.setRecovery(hash, epoch)
+	}
+	for hash, release := range undo.escrowReleases {
+		s.setEscrowRelease(hash, release)
+	}
+	for hash, escrow := range undo.escrows {
+		if escrow == nil {
+			s.Escrows.RemoveHash(hash)
+		} else {
+			s.Escrows.Set(hash, *escrow)
+		}
+	}
+	for hash, release := range undo.insertedReleases {
+		s.Releases.Remove(hash)
+		removeExpire(s.ReleaseExpire, release, hash)
+	}
+	for hash, expire := range undo.insertedIncluded {
+		s.Included.Remove(hash)
+		removeExpire(s.IncludedExpire, expire, hash)
+	}
+	for hash, expire := range undo.insertedEphemeral {
+		s.EphemeralTokens.Remove(hash)
+		removeExpire(s.EphemeralExpire, expire, hash)
+	}
+	for hash, expire := range undo.insertedOffers {
+		s.SponsorOffers.Remove(hash)
+		removeExpire(s.SponsorExpire, expire, hash)
+	}
+	for _, hash := range undo.insertedSponsor {
+		s.SponsorGranted.RemoveContentHash(hash)
+	}
+	for hash, contentHash := range undo.removedSponsor {
+		s.SponsorGranted.SetContentHash(hash, contentHash)
+	}
+	for hash, scope := range undo.powers {
+		if scope == nil {
+			s.PowerOfAttorney.RemoveHash(hash)
+		} else {
+			s.PowerOfAttorney.Set(hash, *scope)
+		}
+	}
+	for wallets, balances := range undo.balances {
+		restoreBalances(wallets, balances)
+	}
+	for _, hash := range undo.insertedMembers {
+		s.Members.RemoveHash(hash)
+	}
+	for _, hash := range undo.removedMembers {
+		s.Members.InsertHash(hash)
+	}
+	for _, hash := range undo.slashed {
+		s.Slashed.RemoveHash(hash)
+	}
+	for registry, entries := range undo.entries {
+		restoreEntries(registry, entries)
+	}
+	for hash, expire := range undo.removedEphemeral {
+		s.EphemeralTokens.Insert(hash, expire)
+		addExpire(s.EphemeralExpire, expire, hash)
+	}
+	for hash, expire := range undo.removedIncluded {
+		s.Included.Insert(hash, expire)
+		addExpire(s.IncludedExpire, expire, hash)
+	}
+	for hash, release := range undo.removedReleases {
+		s.Releases.Insert(hash, release)
+		addExpire(s.ReleaseExpire, release, hash)
+	}
+	for hash, expire := range undo.removedOffers {
+		s.SponsorOffers.Insert(hash, expire)
+		addExpire(s.SponsorExpire, expire, hash)
+	}
+	s.TotalSupply = undo.supply
+	s.LastHash = undo.lastHash
+	s.commitEpoch(undo.epoch)
+}
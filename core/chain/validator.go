@@ -18,6 +18,7 @@ package chain
 import (
 	"github.com/Aereum/aereum/core/crypto"
 	"github.com/Aereum/aereum/core/store"
+	"github.com/Aereum/aereum/core/util"
 )
 
 // Validator consists of a state and permanent mutations not incorporated into
@@ -26,7 +27,7 @@ import (
 // incorporating.
 type MutatingState struct {
 	State     *State
-	Mutations *mutation
+	Mutations *Mutation
 }
 
 // Balance returns the balance of fungible tokens associated to the hash.
@@ -36,26 +37,66 @@ func (c *MutatingState) balance(hash crypto.Hash) uint64 {
 	if c.Mutations == nil {
 		return balance
 	}
-	delta := c.Mutations.DeltaBalance(hash)
-	if delta < 0 {
-		balance = balance - uint64(-delta)
-	} else {
-		balance = balance + uint64(delta)
+	return addDelta(balance, c.Mutations.DeltaBalance(hash))
+}
+
+// stake returns the stake associated to the hash. It returns zero if the hash
+// is not found.
+func (c *MutatingState) stake(hash crypto.Hash) uint64 {
+	_, stake := c.State.Stakes.BalanceHash(hash)
+	if c.Mutations == nil {
+		return stake
+	}
+	return addDelta(stake, c.Mutations.DeltaStake(hash))
+}
+
+// pool returns the stake delegated to the validator hash and the shares
+// issued by its pool.
+func (c *MutatingState) pool(hash crypto.Hash) (uint64, uint64) {
+	_, pooled := c.State.Pools.BalanceHash(hash)
+	_, shares := c.State.PoolShares.BalanceHash(hash)
+	if c.Mutations == nil {
+		return pooled, shares
+	}
+	return addDelta(pooled, c.Mutations.DeltaPools[hash]), addDelta(shares, c.Mutations.DeltaShares[hash])
+}
+
+// delegation returns the pool shares of the delegation hash.
+func (c *MutatingState) delegation(hash crypto.Hash) uint64 {
+	_, shares := c.State.Delegations.BalanceHash(hash)
+	if c.Mutations == nil {
+		return shares
 	}
-	return balance
+	return addDelta(shares, c.Mutations.DeltaDelegations[hash])
+}
+
+// holdings returns the number of powers of attorney granted and stages owned
+// by the member hash.
+func (c *MutatingState) holdings(hash crypto.Hash) uint64 {
+	_, holdings := c.State.Holdings.BalanceHash(hash)
+	if c.Mutations == nil {
+		return holdings
+	}
+	return addDelta(holdings, c.Mutations.DeltaHoldings[hash])
 }
 
 // PowerOfAttorney checks if an attorney can sign on behalf of an author.
 func (c *MutatingState) powerOfAttorney(hash crypto.Hash) bool {
+	return c.attorneyScope(hash) != nil
+}
+
+// attorneyScope returns the scope of the power of attorney hash, or nil if it
+// is not granted.
+func (c *MutatingState) attorneyScope(hash crypto.Hash) *store.AttorneyScope {
 	if c.Mutations != nil {
 		if c.Mutations.HasRevokePower(hash) {
-			return false
+			return nil
 		}
-		if c.Mutations.HasGrantPower(hash) {
-			return true
+		if ok, scope := c.Mutations.GetGrantPower(hash); ok {
+			return &scope
 		}
 	}
-	return c.State.PowerOfAttorney.ExistsHash(hash)
+	return c.State.PowerOfAttorney.Get(hash)
 }
 
 // SponsorshipOffer returns the expire epoch of an SponsorshipOffer. It returns
@@ -65,8 +106,8 @@ func (c *MutatingState) sponsorshipOffer(hash crypto.Hash) uint64 {
 		if c.Mutations.HasUsedSponsorOffer(hash) {
 			return 0
 		}
-		if offer := c.Mutations.GetSponsorOffer(hash); !offer {
-			return 0
+		if ok, expire := c.Mutations.GetSponsorOffer(hash); ok {
+			return expire
 		}
 	}
 	expire := c.State.SponsorOffers.Exists(hash)
@@ -75,30 +116,145 @@ func (c *MutatingState) sponsorshipOffer(hash crypto.Hash) uint64 {
 
 // HasMeber returns the existance of a member.
 func (c *MutatingState) hasMember(hash crypto.Hash) bool {
-	if c.Mutations != nil && c.Mutations.HasMember(hash) {
-		return true
+	if c.Mutations != nil {
+		if c.Mutations.HasMember(hash) {
+			return true
+		}
+		if c.Mutations.HasRemovedMember(hash) {
+			return false
+		}
 	}
 	return c.State.Members.ExistsHash(hash)
 }
 
-// HasGrantedSponsor returns the existence and the hash of the grantee +
-// audience.
+// slashed checks if the validator hash was punished for misbehaviour.
+func (c *MutatingState) slashed(hash crypto.Hash) bool {
+	if c.Mutations != nil {
+		if _, ok := c.Mutations.Slashed[hash]; ok {
+			return true
+		}
+	}
+	return c.State.Slashed.ExistsHash(hash)
+}
+
+// guardians returns the guardians commitment of the member hash.
+func (c *MutatingState) guardians(hash crypto.Hash) (bool, crypto.Hash) {
+	if c.Mutations != nil {
+		if ok, guardians := c.Mutations.GetGuardians(hash); ok {
+			return guardians != crypto.Hash{}, guardians
+		}
+	}
+	return c.State.MemberGuardians(hash)
+}
+
+// escrow returns the terms of the escrow hash, or nil if there is none.
+func (c *MutatingState) escrow(hash crypto.Hash) *store.Escrow {
+	if c.Mutations != nil {
+		if ok, release := c.Mutations.GetEscrowRelease(hash); ok {
+			if release == 0 {
+				return nil
+			}
+			escrow := c.Mutations.NewEscrow[hash]
+			return &escrow
+		}
+	}
+	return c.State.Escrows.Get(hash)
+}
+
+// escrowRelease returns the next release epoch of the escrow hash, or zero if
+// there is none.
+func (c *MutatingState) escrowRelease(hash crypto.Hash) uint64 {
+	if c.Mutations != nil {
+		if ok, release := c.Mutations.GetEscrowRelease(hash); ok {
+			return release
+		}
+	}
+	return c.State.EscrowReleases.Exists(hash)
+}
+
+// escrowed returns the aero of the escrow hash not yet released.
+func (c *MutatingState) escrowed(hash crypto.Hash) uint64 {
+	_, escrowed := c.State.Escrowed.BalanceHash(hash)
+	if c.Mutations == nil {
+		return escrowed
+	}
+	return addDelta(escrowed, c.Mutations.DeltaEscrowed[hash])
+}
+
+// multisig returns the multisig policy of the wallet hash.
+func (c *MutatingState) multisig(hash crypto.Hash) (bool, crypto.Hash) {
+	if c.Mutations != nil {
+		if ok, policy := c.Mutations.GetMultisig(hash); ok {
+			return true, policy
+		}
+	}
+	return c.State.Multisig(hash)
+}
+
+// recovery returns the epoch from which the recovery hash can be completed,
+// or zero if there is no such pending recovery.
+func (c *MutatingState) recovery(hash crypto.Hash) uint64 {
+	if c.Mutations != nil {
+		if ok, epoch := c.Mutations.GetRecovery(hash); ok {
+			return epoch
+		}
+	}
+	return c.State.Recovery(hash)
+}
+
+// stageOwner returns the token of the member owning the stage hash.
+func (c *MutatingState) stageOwner(hash crypto.Hash) (bool, crypto.Token) {
+	if c.Mutations != nil {
+		if ok, owner := c.Mutations.GetStageOwner(hash); ok {
+			return true, owner
+		}
+	}
+	return c.State.StageOwner(hash)
+}
+
+// HasGrantedSponsor returns the existence of a sponsorship granted to the hash
+// of sponsor token + audience and the hash of the sponsored content.
 func (c *MutatingState) hasGrantedSponser(hash crypto.Hash) (bool, crypto.Hash) {
 	if c.Mutations != nil {
 		if ok, contentHash := c.Mutations.HasGrantedSponsorship(hash); ok {
 			return true, contentHash
 		}
+		if c.Mutations.HasPublishedSponsorship(hash) {
+			return false, crypto.Hash{}
+		}
 	}
-	ok, contentHash := c.State.SponsorGranted.GetContentHash(hash)
-	return ok, crypto.Hasher(contentHash)
+	var contentHash crypto.Hash
+	ok, stored := c.State.SponsorGranted.GetContentHash(hash)
+	if ok {
+		copy(contentHash[:], stored)
+	}
+	return ok, contentHash
 }
 
 // HasCaption returns the existence of the caption
 func (c *MutatingState) hasCaption(hash crypto.Hash) bool {
-	if c.Mutations != nil && c.Mutations.HasCaption(hash) {
-		return true
+	ok, _ := c.captionOwner(hash)
+	return ok
+}
+
+// captionOwner returns the token of the member owning the caption hash.
+func (c *MutatingState) captionOwner(hash crypto.Hash) (bool, crypto.Token) {
+	if c.Mutations != nil {
+		if ok, owner := c.Mutations.GetCaptionOwner(hash); ok {
+			return owner != crypto.ZeroToken, owner
+		}
 	}
-	return c.State.Captions.ExistsHash(hash)
+	return c.State.CaptionOwner(hash)
+}
+
+// memberCaption returns the caption hash of the member hash.
+func (c *MutatingState) memberCaption(hash crypto.Hash) (bool, crypto.Hash) {
+	if c.Mutations != nil {
+		if ok, caption := c.Mutations.GetMemberCaption(hash); ok {
+			return caption != crypto.Hash{}, caption
+		}
+	}
+	return c.State.MemberCaption(hash)
 }
 
 // GetAudienceKeys returns the audience keys
@@ -119,7 +275,33 @@ func (c *MutatingState) getEphemeralExpire(hash crypto.Hash) (bool, uint64) {
 		if ok, expire := c.Mutations.HasEphemeral(hash); ok {
 			return true, expire
 		}
+		if c.Mutations.HasExpired(hash) {
+			return false, 0
+		}
 	}
 	expire := c.State.EphemeralTokens.Exists(hash)
 	return expire > 0, expire
 }
+
+// included returns the existence of the instruction hash among the recently
+// included instructions.
+func (c *MutatingState) included(hash crypto.Hash) bool {
+	if c.Mutations != nil {
+		if c.Mutations.HasIncluded(hash) {
+			return true
+		}
+		if c.Mutations.HasExpired(hash) {
+			return false
+		}
+	}
+	return c.State.Included.Exists(hash) > 0
+}
+
+// addDelta returns value + delta, or zero if the result is negative or
+// overflows. Mutations are checked on incorporation so that neither happens.
+func addDelta(value uint64, delta int64) uint64 {
+	if result, ok := util.ApplyDelta(value, delta); ok {
+		return result
+	}
+	return 0
+}
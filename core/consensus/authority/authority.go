@@ -24,6 +24,8 @@ func NewProofOfAtuhority(chain *consensus.BlockChain, token crypto.PrivateKey) *
 				// do nothing
 			case sync := <-comm.Synchronization:
 				sync.Ok <- false
+			case reset := <-comm.Reset:
+				reset.Ok <- false
 			case hashedInst := <-comm.Instructions:
 				pool.Queue(hashedInst.Instruction, hashedInst.Hash)
 			case validate := <-comm.ValidateConn:
@@ -36,11 +38,23 @@ func NewProofOfAtuhority(chain *consensus.BlockChain, token crypto.PrivateKey) *
 		epoch := chain.Epoch + 1
 		for {
 			fmt.Println(epoch)
-			nextBlock := time.Now().Add(consensus.IntervalToNewEpoch(epoch, chain.GenesisTime))
+			nextBlock := time.Now().Add(chain.IntervalToNewEpoch(epoch))
 			//fmt.Println(nextBlock)
-			newBlock := <-consensus.BlockBuilder(chain.GetLastCheckpoint(), epoch, token, nextBlock, pool)
-			newBlock.Sign(token)
-			chain.CurrentState.IncorporateBlock(newBlock)
+			checkpoint, err := chain.GetLastCheckpoint()
+			if err != nil {
+				fmt.Println(err)
+				epoch += 1
+				continue
+			}
+			newBlock := <-consensus.BlockBuilder(checkpoint, epoch, token, nextBlock, pool)
+			if _, err := chain.CurrentState.IncorporateBlock(newBlock); err != nil {
+				fmt.Println(err)
+				epoch += 1
+				continue
+			}
+			chain.UpdateStakes()
+			newBlock.StateRoot = chain.CurrentState.Root()
+			newBlock.Seal(token)
 			comm.Checkpoint <- &consensus.SignedBlock{Block: newBlock, Signatures: make([]consensus.Signature, 0)}
 			epoch += 1
 		}
@@ -9,51 +9,102 @@ import (
 
 type BlockChain struct {
 	GenesisTime     time.Time
-	TotalStake      uint64
+	EpochDuration   time.Duration
+	Validators      map[crypto.Token]uint64 // own and delegated stake of each validator
+	TotalStake      uint64                  // own and delegated stake of validators not slashed
 	Epoch           uint64
+	LastHash        crypto.Hash // hash of the last block incorporated into the current state
 	CurrentState    *chain.State
-	RecentBlocks    SignedBlocks
-	CandidateBlocks map[uint64]SignedBlocks
+	RecentBlocks    SignedBlocks            // final blocks not yet incorporated
+	CandidateBlocks map[uint64]SignedBlocks // blocks of each epoch awaiting finality
 }
 
-func (b *BlockChain) GetLastCheckpoint() *Checkpoint {
-	starting := b.CurrentState.Epoch
-	if len(b.RecentBlocks) == 0 || b.RecentBlocks[0].Block.Epoch() != starting+1 {
-		return &Checkpoint{
-			Validator: &chain.MutatingState{
-				State:     b.CurrentState,
-				Mutations: chain.NewMutation(),
-			},
-			CheckpointEpoch: b.CurrentState.Epoch,
-		}
-	}
-	sequential := make([]*chain.Block, 0)
-	for _, block := range b.RecentBlocks {
-		if block.Block.Epoch() != starting+1 {
-			break
-		}
-		starting += 1
-		sequential = append(sequential, block.Block)
-	}
-	return &Checkpoint{
-		Validator: &chain.MutatingState{
-			State:     b.CurrentState,
-			Mutations: chain.GroupBlockMutations(sequential),
-		},
-		CheckpointEpoch: sequential[len(sequential)-1].Epoch(),
-		CheckpointHash:  sequential[len(sequential)-1].Hash,
-	}
+// UpdateStakes rebuilds the validator set from the current state, with the
+// stake of every validator including the stake delegated to its pool, and
+// refreshes the total stake. Tokens that deposit or receive delegated stake
+// join the set, slashed validators and validators without stake leave it. It
+// must be called after blocks are incorporated into the current state.
+func (b *BlockChain) UpdateStakes() {
+	b.Validators = b.CurrentState.ValidatorWeights()
+	b.TotalStake = b.CurrentState.TotalStake()
+}
+
+// GetLastCheckpoint returns a validator over the current state and the
+// sequence of recent and candidate blocks that lead to the head. It returns
+// an error if the mutations of those blocks cannot be grouped.
+func (b *BlockChain) GetLastCheckpoint() (*Checkpoint, error) {
+	return b.CheckpointAt(b.Head())
 }
 
 func NewGenesisBlockChain(token crypto.PrivateKey) *BlockChain {
 	state := chain.NewGenesisStateWithToken(token)
+	state.Rewards = chain.DefaultRewardSchedule()
 	chain := BlockChain{
 		GenesisTime:     time.Now(),
-		TotalStake:      1000000,
+		EpochDuration:   DefaultEpochDuration,
 		Epoch:           0,
 		CurrentState:    state,
 		RecentBlocks:    make(SignedBlocks, 0),
 		CandidateBlocks: make(map[uint64]SignedBlocks),
 	}
+	chain.UpdateStakes()
 	return &chain
 }
+
+// OpenBlockChain resumes the block chain from the state persisted on dir,
+// starting after the last incorporated block. If dir holds no state, a new
+// genesis state is created there with token. Since epochs are counted from
+// genesisTime it must be the same on every restart.
+func OpenBlockChain(dir string, token crypto.PrivateKey, genesisTime time.Time) (*BlockChain, error) {
+	state, err := chain.OpenState(dir)
+	if err == chain.ErrNoState {
+		state, err = chain.NewFileGenesisStateWithToken(dir, token)
+	}
+	if err != nil {
+		return nil, err
+	}
+	state.Rewards = chain.DefaultRewardSchedule()
+	blockchain := BlockChain{
+		GenesisTime:     genesisTime,
+		EpochDuration:   DefaultEpochDuration,
+		Epoch:           state.Epoch,
+		LastHash:        state.LastHash,
+		CurrentState:    state,
+		RecentBlocks:    make(SignedBlocks, 0),
+		CandidateBlocks: make(map[uint64]SignedBlocks),
+	}
+	blockchain.UpdateStakes()
+	return &blockchain, nil
+}
+
+// OpenBlockChainFromGenesis resumes the block chain from the state persisted
+// on dir, or creates there the genesis state declared by spec if dir holds no
+// state. If dir is empty the state is kept in memory.
+func OpenBlockChainFromGenesis(dir string, spec *chain.GenesisSpec) (*BlockChain, error) {
+	duration, err := spec.Duration()
+	if err != nil {
+		return nil, err
+	}
+	var state *chain.State
+	if dir != "" {
+		state, err = chain.OpenState(dir)
+	}
+	if dir == "" || err == chain.ErrNoState {
+		state, err = chain.NewGenesisStateFromSpec(spec, dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	state.Rewards = chain.DefaultRewardSchedule()
+	blockchain := BlockChain{
+		GenesisTime:     spec.GenesisTime,
+		EpochDuration:   duration,
+		Epoch:           state.Epoch,
+		LastHash:        state.LastHash,
+		CurrentState:    state,
+		RecentBlocks:    make(SignedBlocks, 0),
+		CandidateBlocks: make(map[uint64]SignedBlocks),
+	}
+	blockchain.UpdateStakes()
+	return &blockchain, nil
+}
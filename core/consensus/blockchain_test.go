@@ -0,0 +1,70 @@
+package consensus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Aereum/aereum/core/chain"
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/instructions"
+)
+
+func TestUpdateStakes(t *testing.T) {
+	_, token := crypto.RandomAsymetricKey()
+	blockchain := NewGenesisBlockChain(token)
+	alice, aliceKey := crypto.RandomAsymetricKey()
+	checkpoint, err := blockchain.CheckpointAt(0, crypto.Hash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := chain.NewBlock(crypto.Hash{}, 0, 1, token.PublicKey(), checkpoint.Validator)
+	if err := block.Incorporate(instructions.NewSingleReciepientTransfer(token, alice, "", 1000, 1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := block.Incorporate(instructions.NewDeposit(aliceKey, 500, 1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := blockchain.SealCandidate(block, token); err != nil {
+		t.Fatal(err)
+	}
+	blockchain.Finalize(blockchain.AddCandidate(block))
+	if _, err := blockchain.IncorporateNext(); err != nil {
+		t.Fatal(err)
+	}
+	if blockchain.Validators[alice] != 500 || blockchain.Validators[token.PublicKey()] != chain.GenesisStake {
+		t.Errorf("validator set not rebuilt from the state: %v", blockchain.Validators)
+	}
+	if blockchain.TotalStake != chain.GenesisStake+500 {
+		t.Errorf("wrong total stake: %v", blockchain.TotalStake)
+	}
+}
+
+func TestReopenBlockChain(t *testing.T) {
+	dir := t.TempDir()
+	_, token := crypto.RandomAsymetricKey()
+	genesis := time.Now()
+	blockchain, err := OpenBlockChain(dir, token, genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := newCandidate(t, blockchain, 1, 0, crypto.Hash{}, token)
+	blockchain.Finalize(first)
+	if incorporated, err := blockchain.IncorporateNext(); err != nil || incorporated != first {
+		t.Fatal("first block not incorporated")
+	}
+	blockchain.CurrentState.Close()
+
+	blockchain, err = OpenBlockChain(dir, token, genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.CurrentState.Close()
+	if blockchain.Epoch != 1 || blockchain.LastHash != first.Block.Hash {
+		t.Fatal("last incorporated block not restored")
+	}
+	second := newCandidate(t, blockchain, 2, 1, first.Block.Hash, token)
+	blockchain.Finalize(second)
+	if incorporated, err := blockchain.IncorporateNext(); err != nil || incorporated != second {
+		t.Fatal("block not incorporated after reopening the chain")
+	}
+}
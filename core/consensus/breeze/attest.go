@@ -0,0 +1,182 @@
+package breeze
+
+import (
+	"github.com/Aereum/aereum/core/consensus"
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/instructions"
+)
+
+// attest signs and publishes the state checksum of the node at the start of a
+// checksum window and checks it against the checksums of the validators.
+func (b *Breeze) attest() {
+	epoch := b.chain.CurrentState.Epoch
+	own := consensus.SignChecksum(epoch, b.chain.CurrentState.Root(), b.token)
+	b.checksumEpoch, b.checksum = epoch, own.Checksum
+	for previous := range b.checksums {
+		if previous < epoch {
+			delete(b.checksums, previous)
+		}
+	}
+	if b.schedule.Stake(own.Token) > 0 {
+		b.record(own)
+		select {
+		case b.comm.Attestation <- &own:
+		default:
+		}
+	}
+	b.tally()
+}
+
+// receiveChecksum keeps the checksum of a validator, for the current window or
+// for the next windows the node has not reached yet, and checks it against the
+// majority.
+func (b *Breeze) receiveChecksum(checksum *consensus.Checksum) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if checksum.Epoch < b.checksumEpoch || checksum.Epoch > b.chain.CurrentState.Epoch+ChecksumWindows {
+		return
+	}
+	if b.schedule.Stake(checksum.Token) == 0 || !checksum.Verify() {
+		return
+	}
+	b.record(*checksum)
+	if checksum.Epoch == b.checksumEpoch {
+		b.tally()
+	}
+}
+
+// record keeps the first checksum of each validator for an epoch and
+// denounces validators that sign different checksums of the same epoch.
+func (b *Breeze) record(checksum consensus.Checksum) {
+	checksums, ok := b.checksums[checksum.Epoch]
+	if !ok {
+		checksums = make(map[crypto.Token]consensus.Checksum)
+		b.checksums[checksum.Epoch] = checksums
+	}
+	kept, ok := checksums[checksum.Token]
+	if !ok {
+		checksums[checksum.Token] = checksum
+		return
+	}
+	if kept.Conflicts(checksum) {
+		if _, denounced := b.denounced[checksum.Token]; !denounced {
+			b.denounced[checksum.Token] = struct{}{}
+			evidence := instructions.NewDenounceChecksum(b.token, checksum.Token, kept.Denounce(checksum), b.chain.CurrentState.Epoch, 0)
+			b.pool.Queue(evidence, crypto.Hasher(evidence.Serialize()))
+		}
+	}
+}
+
+// majority returns the checksum of the current window signed by more than half
+// of the stake of the validators, if any.
+func (b *Breeze) majority() (crypto.Hash, bool) {
+	total := uint64(0)
+	for _, stake := range b.schedule.stakes {
+		total += stake
+	}
+	weights := make(map[crypto.Hash]uint64)
+	for token, checksum := range b.checksums[b.checksumEpoch] {
+		weights[checksum.Checksum] += b.schedule.Stake(token)
+		// more than half of total without overflow
+		if weights[checksum.Checksum] > total/2 {
+			return checksum.Checksum, true
+		}
+	}
+	return crypto.Hash{}, false
+}
+
+// tally flags the validators whose checksum of the current window disagrees
+// with the stake weighted majority. If the node itself disagrees its state has
+// diverged, and it halts block production until it is resynchronized. Every
+// change of the halt state is reported on the Halted channel.
+func (b *Breeze) tally() {
+	if b.checksumEpoch == 0 {
+		return
+	}
+	majority, ok := b.majority()
+	if !ok {
+		return
+	}
+	for token, checksum := range b.checksums[b.checksumEpoch] {
+		if checksum.Checksum == majority {
+			continue
+		}
+		if epoch, flagged := b.flagged[token]; flagged && epoch == b.checksumEpoch {
+			continue
+		}
+		b.flagged[token] = b.checksumEpoch
+		dissent := checksum
+		select {
+		case b.comm.Dissent <- &dissent:
+		default:
+		}
+	}
+	if halted := b.checksum != majority; halted != b.halted {
+		b.halted = halted
+		b.reportHalt(majority)
+	}
+}
+
+// reset replaces the chain of the node and resumes block production. The
+// chain is rejected if its state is at the epoch of the current checksum window
+// and disagrees with the stake weighted majority. A chain at the start of a
+// window seeds the schedule as on NewBreeze; otherwise the schedule of the
+// window is seeded again by the majority checksum. Candidate blocks and orphan
+// signatures of the replaced chain are discarded, but the last vote of the node
+// is kept, and persisted with the new chain, so that it does not sign
+// conflicting blocks after the reset.
+func (b *Breeze) reset(chain *consensus.BlockChain) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	epoch, root := chain.CurrentState.Epoch, chain.CurrentState.Root()
+	majority, ok := b.majority()
+	if ok && epoch == b.checksumEpoch && root != majority {
+		return false
+	}
+	votedEpoch, voted, err := chain.LastVote()
+	if err == nil && votedEpoch < b.votedEpoch {
+		err = chain.SaveVote(b.votedEpoch, b.voted)
+	}
+	if err != nil {
+		b.reportError(err)
+		return false
+	}
+	if votedEpoch > b.votedEpoch {
+		b.voted, b.votedEpoch = voted, votedEpoch
+	}
+	b.chain = chain
+	b.orphans = make(map[crypto.Hash]*orphan)
+	switch window := epoch / ChecksumWindows; {
+	case epoch == b.checksumEpoch:
+		b.schedule = NewSchedule(root, chain.Validators)
+		b.checksum = root
+	case window > b.window:
+		b.window = window
+		b.schedule = NewSchedule(root, chain.Validators)
+	case ok:
+		b.schedule = NewSchedule(majority, b.schedule.stakes)
+	}
+	if ok {
+		b.checksum = majority
+	}
+	if b.halted {
+		b.halted = false
+		b.reportHalt(majority)
+	}
+	b.advance()
+	return true
+}
+
+// reportHalt publishes the halt state of the node without blocking.
+func (b *Breeze) reportHalt(majority crypto.Hash) {
+	halt := consensus.Halt{
+		Epoch:    b.checksumEpoch,
+		Halted:   b.halted,
+		Checksum: b.checksum,
+		Majority: majority,
+	}
+	select {
+	case b.comm.Halted <- &halt:
+	default:
+	}
+}
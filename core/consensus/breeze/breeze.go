@@ -0,0 +1,436 @@
+package breeze
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Aereum/aereum/core/chain"
+	"github.com/Aereum/aereum/core/consensus"
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/instructions"
+)
+
+var _ consensus.ConsensusEngine = NewBreeze
+
+var (
+	ErrAlreadyVoted = errors.New("node already signed a block of the epoch or a conflicting block")
+)
+
+// orphanEpochs is the number of epochs signatures of unknown blocks are kept
+// waiting for their block.
+const orphanEpochs = 2
+
+// lockEpochs is the number of epochs a node only signs blocks that extend the
+// last block it signed. The lock is released if that block does not become
+// final in time, so that validators split between conflicting blocks can
+// agree again.
+const lockEpochs = 2
+
+type orphan struct {
+	received   uint64
+	signatures []consensus.Signature
+}
+
+// Breeze is a proof-of-stake engine. The leader of each epoch builds the
+// block and the committee of the epoch validates and signs it. A block is
+// final, and incorporated, once signed by more than 2/3 of the committee
+// stake. At the start of every checksum window validators exchange signed
+// state checksums, and a node whose checksum disagrees with the stake weighted
+// majority stops building and signing blocks until the operator resets it to
+// a chain that agrees with the majority.
+type Breeze struct {
+	mu         sync.Mutex
+	chain      *consensus.BlockChain
+	token      crypto.PrivateKey
+	comm       *consensus.Communication
+	pool       *consensus.InstructionPool
+	schedule   *Schedule
+	window     uint64
+	orphans    map[crypto.Hash]*orphan
+	advanced   chan struct{}
+	voted      crypto.Hash                          // last block signed by the node
+	votedEpoch uint64                               // epoch of the last block signed by the node
+	seen       map[crypto.Token]consensus.Signature // last signature of each validator
+	denounced  map[crypto.Token]struct{}
+	// state checksums exchanged at the start of every checksum window
+	checksums     map[uint64]map[crypto.Token]consensus.Checksum
+	checksumEpoch uint64                  // epoch of the last checksum of the node
+	checksum      crypto.Hash             // last checksum of the node
+	flagged       map[crypto.Token]uint64 // validator -> epoch of its dissenting checksum
+	halted        bool                    // checksum of the node disagrees with the majority
+}
+
+// NewBreeze starts the breeze engine over chain for the validator holding
+// token. Slots are seeded by the state root of chain until the next checksum
+// window, so every node must start from the same state. The last vote of the
+// node is restored from chain; it panics if the vote cannot be read, since the
+// node could otherwise sign conflicting blocks.
+func NewBreeze(chain *consensus.BlockChain, token crypto.PrivateKey) *consensus.Communication {
+	votedEpoch, voted, err := chain.LastVote()
+	if err != nil {
+		panic(err)
+	}
+	b := &Breeze{
+		chain:      chain,
+		token:      token,
+		comm:       consensus.NewCommunication(),
+		pool:       consensus.NewInstructionPool(),
+		schedule:   NewSchedule(chain.CurrentState.Root(), chain.Validators),
+		window:     chain.CurrentState.Epoch / ChecksumWindows,
+		orphans:    make(map[crypto.Hash]*orphan),
+		advanced:   make(chan struct{}, 1),
+		voted:      voted,
+		votedEpoch: votedEpoch,
+		seen:       make(map[crypto.Token]consensus.Signature),
+		denounced:  make(map[crypto.Token]struct{}),
+		checksums:  make(map[uint64]map[crypto.Token]consensus.Checksum),
+		flagged:    make(map[crypto.Token]uint64),
+	}
+	go b.listen()
+	go b.lead(chain.CurrentState.Epoch + 1)
+	return b.comm
+}
+
+func (b *Breeze) listen() {
+	for {
+		select {
+		case peer := <-b.comm.PeerRequest:
+			peer.Response <- false
+		case block := <-b.comm.NewBlock:
+			b.receive(block)
+		case signature := <-b.comm.BlockSignature:
+			b.sign(signature)
+		case checksum := <-b.comm.Checksum:
+			b.receiveChecksum(checksum)
+		case sync := <-b.comm.Synchronization:
+			sync.Ok <- false
+		case reset := <-b.comm.Reset:
+			reset.Ok <- b.reset(reset.Chain)
+		case hashedInst := <-b.comm.Instructions:
+			b.pool.Queue(hashedInst.Instruction, hashedInst.Hash)
+		case validate := <-b.comm.ValidateConn:
+			validate.Ok <- true
+		}
+	}
+}
+
+// lead builds, from epoch on, the block of every epoch for which the node
+// is the leader.
+func (b *Breeze) lead(epoch uint64) {
+	for {
+		b.mu.Lock()
+		interval, duration := b.chain.IntervalToNewEpoch(epoch), b.chain.EpochDuration
+		leader := b.schedule.Leader(epoch) == b.token.PublicKey() && !b.halted
+		b.mu.Unlock()
+		if interval <= 0 {
+			epoch += 1
+			continue
+		}
+		finish := time.Now().Add(interval)
+		if !leader {
+			time.Sleep(time.Until(finish))
+			epoch += 1
+			continue
+		}
+		b.awaitHead(epoch, finish.Add(-duration/2))
+		b.mu.Lock()
+		checkpoint, err := b.chain.CheckpointAt(b.base(epoch))
+		b.mu.Unlock()
+		if err != nil || !time.Now().Before(finish) {
+			if err != nil {
+				b.reportError(err)
+			}
+			time.Sleep(time.Until(finish))
+			epoch += 1
+			continue
+		}
+		block := <-consensus.BlockBuilder(checkpoint, epoch, b.token, finish, b.pool)
+		if err := b.propose(block); err != nil {
+			b.reportError(err)
+		}
+		epoch += 1
+	}
+}
+
+// awaitHead waits until deadline for the block of the epoch preceding epoch
+// to be known, so that the block of epoch extends it.
+func (b *Breeze) awaitHead(epoch uint64, deadline time.Time) {
+	for {
+		b.mu.Lock()
+		head, _ := b.chain.Head()
+		b.mu.Unlock()
+		if head+1 >= epoch || !time.Now().Before(deadline) {
+			return
+		}
+		select {
+		case <-b.advanced:
+		case <-time.After(time.Until(deadline)):
+		}
+	}
+}
+
+// locked returns the last block signed by the node if it still binds the
+// votes of the node at epoch.
+func (b *Breeze) locked(epoch uint64) *consensus.SignedBlock {
+	voted := b.chain.SignedBlock(b.voted)
+	if voted == nil || voted.Block.Epoch()+lockEpochs < epoch {
+		return nil
+	}
+	return voted
+}
+
+// base returns the block the block of epoch extends: the head, unless it
+// does not extend the block locking the votes of the node.
+func (b *Breeze) base(epoch uint64) (uint64, crypto.Hash) {
+	head, hash := b.chain.Head()
+	voted := b.locked(epoch)
+	if voted == nil {
+		return head, hash
+	}
+	if block := b.chain.SignedBlock(hash); block != nil && b.chain.Descends(block.Block, voted.Block.Epoch(), voted.Block.Hash) {
+		return head, hash
+	}
+	return voted.Block.Epoch(), voted.Block.Hash
+}
+
+// canVote checks if block is of an epoch after the last block signed by the
+// node and extends the block locking its votes, so that the node does not sign
+// conflicting blocks.
+func (b *Breeze) canVote(block *chain.Block) bool {
+	if block.Epoch() <= b.votedEpoch {
+		return false
+	}
+	voted := b.locked(block.Epoch())
+	return voted == nil || b.chain.Descends(block, voted.Block.Epoch(), voted.Block.Hash)
+}
+
+// vote signs block and locks the votes of the node on it. The vote is
+// persisted before the block is signed, so that the node never signs another
+// block of the epoch after a restart. No signature is returned if the vote
+// cannot be persisted.
+func (b *Breeze) vote(signed *consensus.SignedBlock) (consensus.Signature, error) {
+	if err := b.chain.SaveVote(signed.Block.Epoch(), signed.Block.Hash); err != nil {
+		return consensus.Signature{}, err
+	}
+	signature := consensus.SignBlock(signed.Block, b.token)
+	signed.AppendSignature(signature)
+	b.voted, b.votedEpoch = signed.Block.Hash, signed.Block.Epoch()
+	return signature, nil
+}
+
+// witness keeps the last signature of each validator and denounces validators
+// that sign different blocks of the same epoch. The evidence is queued on the
+// pool to be included in the next block built by the node.
+func (b *Breeze) witness(signature consensus.Signature) {
+	seen, ok := b.seen[signature.Token]
+	if ok && seen.Conflicts(signature) {
+		if _, denounced := b.denounced[signature.Token]; !denounced {
+			b.denounced[signature.Token] = struct{}{}
+			evidence := instructions.NewDenounceCheckpoint(b.token, signature.Token, seen.Denounce(signature), b.chain.CurrentState.Epoch, 0)
+			b.pool.Queue(evidence, crypto.Hasher(evidence.Serialize()))
+		}
+	}
+	if !ok || signature.Epoch > seen.Epoch {
+		b.seen[signature.Token] = signature
+	}
+}
+
+// advance signals that the head may have moved.
+func (b *Breeze) advance() {
+	select {
+	case b.advanced <- struct{}{}:
+	default:
+	}
+}
+
+// propose seals a block built by the node over the head and publishes it
+// together with its own signature.
+func (b *Breeze) propose(block *chain.Block) error {
+	b.mu.Lock()
+	if err := b.chain.SealCandidate(block, b.token); err != nil {
+		b.mu.Unlock()
+		return err
+	}
+	if !b.canVote(block) {
+		b.mu.Unlock()
+		return ErrAlreadyVoted
+	}
+	signed := b.chain.AddCandidate(block)
+	signature, err := b.vote(signed)
+	if err != nil {
+		b.mu.Unlock()
+		return err
+	}
+	final, err := b.finalize(signed)
+	b.mu.Unlock()
+	b.publish(block, &signature)
+	b.checkpoint(final)
+	return err
+}
+
+// receive validates a block published by the leader of its epoch and keeps it
+// as a candidate. Committee members sign the block if it does not conflict
+// with the blocks they signed before.
+func (b *Breeze) receive(block *chain.Block) {
+	b.mu.Lock()
+	epoch := block.Epoch()
+	if epoch <= b.chain.CurrentState.Epoch || block.Publisher != b.schedule.Leader(epoch) || b.chain.SignedBlock(block.Hash) != nil {
+		b.mu.Unlock()
+		return
+	}
+	validated, err := b.chain.ValidateCandidate(block)
+	if err != nil {
+		b.mu.Unlock()
+		b.reportError(err)
+		return
+	}
+	signed := b.chain.AddCandidate(validated)
+	if orphans, ok := b.orphans[validated.Hash]; ok {
+		delete(b.orphans, validated.Hash)
+		for _, signature := range orphans.signatures {
+			b.appendSignature(signed, signature)
+		}
+	}
+	var signature *consensus.Signature
+	if b.schedule.IsMember(epoch, b.token.PublicKey()) && !b.halted && b.canVote(validated) {
+		if own, err := b.vote(signed); err != nil {
+			b.reportError(err)
+		} else {
+			signature = &own
+		}
+	}
+	final, err := b.finalize(signed)
+	b.mu.Unlock()
+	b.advance()
+	if err != nil {
+		b.reportError(err)
+	}
+	b.publish(nil, signature)
+	b.checkpoint(final)
+}
+
+// sign aggregates a signature gossiped by a committee member. Signatures of
+// validators for blocks not yet received are kept for orphanEpochs.
+func (b *Breeze) sign(signature *consensus.Signature) {
+	b.mu.Lock()
+	if b.schedule.Stake(signature.Token) == 0 || !signature.Verify() {
+		b.mu.Unlock()
+		return
+	}
+	b.witness(*signature)
+	signed := b.chain.SignedBlock(signature.Hash)
+	if signed == nil {
+		kept, ok := b.orphans[signature.Hash]
+		if !ok {
+			kept = &orphan{received: b.chain.CurrentState.Epoch}
+			b.orphans[signature.Hash] = kept
+		}
+		kept.signatures = append(kept.signatures, *signature)
+		b.mu.Unlock()
+		return
+	}
+	if !b.appendSignature(signed, *signature) {
+		b.mu.Unlock()
+		return
+	}
+	final, err := b.finalize(signed)
+	b.mu.Unlock()
+	if err != nil {
+		b.reportError(err)
+	}
+	b.checkpoint(final)
+}
+
+// appendSignature appends a signature of a committee member of the epoch of
+// the block.
+func (b *Breeze) appendSignature(signed *consensus.SignedBlock, signature consensus.Signature) bool {
+	if !b.schedule.IsMember(signed.Block.Epoch(), signature.Token) {
+		return false
+	}
+	return signed.AppendSignature(signature)
+}
+
+// isFinal checks if the block is signed by more than 2/3 of the stake of the
+// committee of its epoch.
+func (b *Breeze) isFinal(signed *consensus.SignedBlock) bool {
+	epoch := signed.Block.Epoch()
+	total := uint64(0)
+	for _, member := range b.schedule.Committee(epoch) {
+		total += b.schedule.Stake(member)
+	}
+	stake := uint64(0)
+	for _, signature := range signed.Signatures {
+		if b.schedule.IsMember(epoch, signature.Token) {
+			stake += b.schedule.Stake(signature.Token)
+		}
+	}
+	// more than 2/3 of total without overflow
+	return total > 0 && stake > 2*(total/3)+2*(total%3)/3
+}
+
+// finalize moves a final block to the recent blocks and incorporates every
+// recent block that extends the current state. It returns the incorporated
+// blocks.
+func (b *Breeze) finalize(signed *consensus.SignedBlock) ([]*consensus.SignedBlock, error) {
+	if !b.isFinal(signed) {
+		return nil, nil
+	}
+	b.chain.Finalize(signed)
+	final := make([]*consensus.SignedBlock, 0)
+	for {
+		block, err := b.chain.IncorporateNext()
+		if err != nil || block == nil {
+			return final, err
+		}
+		final = append(final, block)
+		b.incorporated()
+	}
+}
+
+// incorporated reseeds the schedule and attests the state checksum once the
+// state enters a new checksum window, and discards stale orphan signatures.
+func (b *Breeze) incorporated() {
+	epoch := b.chain.CurrentState.Epoch
+	if window := epoch / ChecksumWindows; window > b.window {
+		b.window = window
+		b.schedule = NewSchedule(b.chain.CurrentState.Root(), b.chain.Validators)
+		b.attest()
+	}
+	for hash, orphan := range b.orphans {
+		if orphan.received+orphanEpochs < epoch {
+			delete(b.orphans, hash)
+		}
+	}
+}
+
+// checkpoint publishes final blocks to observers.
+func (b *Breeze) checkpoint(final []*consensus.SignedBlock) {
+	for _, signed := range final {
+		b.comm.Checkpoint <- signed
+	}
+}
+
+// publish gossips a block and a signature of the node without blocking.
+func (b *Breeze) publish(block *chain.Block, signature *consensus.Signature) {
+	if block != nil {
+		select {
+		case b.comm.Proposal <- block:
+		default:
+		}
+	}
+	if signature != nil {
+		select {
+		case b.comm.Vote <- signature:
+		default:
+		}
+	}
+}
+
+// reportError publishes a failure of the engine without blocking.
+func (b *Breeze) reportError(err error) {
+	select {
+	case b.comm.Errors <- err:
+	default:
+	}
+}
@@ -0,0 +1,441 @@
+package breeze
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Aereum/aereum/core/chain"
+	"github.com/Aereum/aereum/core/consensus"
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/instructions"
+	"github.com/Aereum/aereum/core/network"
+)
+
+func TestSchedule(t *testing.T) {
+	validators := make(map[crypto.Token]uint64)
+	for n := 0; n < 2*ValidatorsCount; n++ {
+		token, _ := crypto.RandomAsymetricKey()
+		validators[token] = uint64(n+1) * StakeRounding
+	}
+	poor, _ := crypto.RandomAsymetricKey()
+	validators[poor] = StakeRounding - 1
+	checksum := crypto.Hasher([]byte("checksum"))
+	schedule := NewSchedule(checksum, validators)
+	other := NewSchedule(checksum, validators)
+	reseeded := NewSchedule(crypto.Hasher([]byte("other")), validators)
+	leaders := make(map[crypto.Token]struct{})
+	differs := false
+	for epoch := uint64(1); epoch < 50; epoch++ {
+		committee := schedule.Committee(epoch)
+		if len(committee) != ValidatorsCount {
+			t.Fatalf("wrong committee size: %v", len(committee))
+		}
+		if other.Leader(epoch) != schedule.Leader(epoch) {
+			t.Fatal("schedule is not deterministic")
+		}
+		if committee[0] != schedule.Leader(epoch) {
+			t.Fatal("leader is not first in committee")
+		}
+		if schedule.IsMember(epoch, poor) {
+			t.Fatal("validator without slots in committee")
+		}
+		if reseeded.Leader(epoch) != schedule.Leader(epoch) {
+			differs = true
+		}
+		leaders[schedule.Leader(epoch)] = struct{}{}
+	}
+	if !differs {
+		t.Fatal("schedule does not depend on checksum")
+	}
+	if len(leaders) < 2 {
+		t.Fatal("leader does not rotate")
+	}
+}
+
+func TestBreeze(t *testing.T) {
+	_, token := crypto.RandomAsymetricKey()
+	_, observer := crypto.RandomAsymetricKey()
+	leaderChain := consensus.NewGenesisBlockChain(token)
+	leaderChain.EpochDuration = 50 * time.Millisecond
+	observerChain := consensus.NewGenesisBlockChain(token)
+	if observerChain.CurrentState.Root() != leaderChain.CurrentState.Root() {
+		t.Fatal("genesis states differ")
+	}
+	leader := NewBreeze(leaderChain, token)
+	signed := <-leader.Checkpoint
+	if signed.Block.Publisher != token.PublicKey() || len(signed.Signatures) != 1 || !signed.Signatures[0].Verify() {
+		t.Fatal("invalid leader checkpoint")
+	}
+	proposal := <-leader.Proposal
+	if proposal.Hash != signed.Block.Hash {
+		t.Fatal("proposal not published")
+	}
+	vote := <-leader.Vote
+	if vote.Hash != signed.Block.Hash {
+		t.Fatal("vote not published")
+	}
+
+	observerChain.EpochDuration = time.Hour
+	follower := NewBreeze(observerChain, observer)
+	follower.NewBlock <- proposal
+	select {
+	case <-follower.Checkpoint:
+		t.Fatal("block incorporated without quorum")
+	case <-time.After(20 * time.Millisecond):
+	}
+	follower.BlockSignature <- vote
+	accepted := <-follower.Checkpoint
+	if accepted.Block.Hash != proposal.Hash || len(accepted.Signatures) != 1 {
+		t.Fatal("observer did not accept leader block")
+	}
+	if observerChain.CurrentState.Root() != proposal.StateRoot {
+		t.Fatal("observer state diverged")
+	}
+	// a block published by a validator that is not the leader is dropped
+	forged := <-leader.Checkpoint
+	forged.Block.Publisher = observer.PublicKey()
+	forged.Block.Seal(observer)
+	follower.NewBlock <- forged.Block
+	select {
+	case <-follower.Checkpoint:
+		t.Fatal("block from non leader accepted")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func relay(from, to *consensus.Communication) {
+	for {
+		select {
+		case block := <-from.Proposal:
+			to.NewBlock <- block
+		case signature := <-from.Vote:
+			to.BlockSignature <- signature
+		}
+	}
+}
+
+func collect(comm *consensus.Communication, count int) chan []*consensus.SignedBlock {
+	collected := make(chan []*consensus.SignedBlock)
+	go func() {
+		blocks := make([]*consensus.SignedBlock, 0, count)
+		for len(blocks) < count {
+			blocks = append(blocks, <-comm.Checkpoint)
+		}
+		collected <- blocks
+	}()
+	return collected
+}
+
+func TestBreezeQuorum(t *testing.T) {
+	testQuorum(t, func(firstKey, secondKey crypto.PrivateKey, firstComm, secondComm *consensus.Communication) {
+		go relay(firstComm, secondComm)
+		go relay(secondComm, firstComm)
+	})
+}
+
+func TestGossipQuorum(t *testing.T) {
+	testQuorum(t, func(firstKey, secondKey crypto.PrivateKey, firstComm, secondComm *consensus.Communication) {
+		firstConn, secondConn := connect(t, firstKey, secondKey, secondComm.ValidateConn)
+		network.NewGossip(firstKey, firstComm).AddPeer(firstConn)
+		network.NewGossip(secondKey, secondComm).AddPeer(secondConn)
+	})
+}
+
+// testQuorum checks that two validators with the same stake, linked by link,
+// finalize the same blocks signed by both.
+func testQuorum(t *testing.T, link func(firstKey, secondKey crypto.PrivateKey, firstComm, secondComm *consensus.Communication)) {
+	first, firstKey := crypto.RandomAsymetricKey()
+	second, secondKey := crypto.RandomAsymetricKey()
+	spec := &chain.GenesisSpec{
+		GenesisTime:   time.Now(),
+		EpochDuration: "100ms",
+		Validators: []chain.GenesisValidator{
+			{Token: first.Hex(), Stake: 500000},
+			{Token: second.Hex(), Stake: 500000},
+		},
+	}
+	firstChain, err := consensus.OpenBlockChainFromGenesis("", spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondChain, err := consensus.OpenBlockChainFromGenesis("", spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstComm := NewBreeze(firstChain, firstKey)
+	secondComm := NewBreeze(secondChain, secondKey)
+	link(firstKey, secondKey, firstComm, secondComm)
+	firstBlocks, secondBlocks := collect(firstComm, 4), collect(secondComm, 4)
+	var firstFinal, secondFinal []*consensus.SignedBlock
+	for firstFinal == nil || secondFinal == nil {
+		select {
+		case firstFinal = <-firstBlocks:
+		case secondFinal = <-secondBlocks:
+		case <-time.After(5 * time.Second):
+			t.Fatal("blocks not finalized")
+		}
+	}
+	for n := range firstFinal {
+		if firstFinal[n].Block.Hash != secondFinal[n].Block.Hash {
+			t.Fatal("validators finalized different blocks")
+		}
+		if len(firstFinal[n].Signatures) != 2 || len(secondFinal[n].Signatures) != 2 {
+			t.Fatal("block final without quorum")
+		}
+	}
+}
+
+func TestDenounceDoubleVote(t *testing.T) {
+	_, token := crypto.RandomAsymetricKey()
+	offender, offenderKey := crypto.RandomAsymetricKey()
+	b := &Breeze{
+		chain:     consensus.NewGenesisBlockChain(token),
+		token:     token,
+		pool:      consensus.NewInstructionPool(),
+		seen:      make(map[crypto.Token]consensus.Signature),
+		denounced: make(map[crypto.Token]struct{}),
+	}
+	vote := func(epoch uint64, data string) consensus.Signature {
+		block := chain.NewBlock(crypto.Hasher([]byte{}), 0, epoch, offender, nil)
+		block.Hash = crypto.Hasher([]byte(data))
+		return consensus.SignBlock(block, offenderKey)
+	}
+	b.witness(vote(1, "first"))
+	b.witness(vote(2, "second"))
+	if instruction, _ := b.pool.Unqueue(); instruction != nil {
+		t.Fatal("votes of different epochs denounced")
+	}
+	b.witness(vote(2, "conflicting"))
+	b.witness(vote(2, "third"))
+	instruction, _ := b.pool.Unqueue()
+	denounce, ok := instruction.(*instructions.DenounceCheckpoint)
+	if !ok || denounce.Offender != offender {
+		t.Fatal("double vote not denounced")
+	}
+	if again, _ := b.pool.Unqueue(); again != nil {
+		t.Fatal("offender denounced twice")
+	}
+}
+
+func TestChecksumTally(t *testing.T) {
+	keys := make([]crypto.PrivateKey, 3)
+	stakes := make(map[crypto.Token]uint64)
+	for n := range keys {
+		var token crypto.Token
+		token, keys[n] = crypto.RandomAsymetricKey()
+		stakes[token] = 1000
+	}
+	agreed, diverged := crypto.Hasher([]byte("agreed")), crypto.Hasher([]byte("diverged"))
+	node := func(checksum crypto.Hash) *Breeze {
+		b := &Breeze{
+			chain:         consensus.NewGenesisBlockChain(keys[0]),
+			token:         keys[0],
+			comm:          consensus.NewCommunication(),
+			pool:          consensus.NewInstructionPool(),
+			schedule:      NewSchedule(checksum, stakes),
+			denounced:     make(map[crypto.Token]struct{}),
+			checksums:     make(map[uint64]map[crypto.Token]consensus.Checksum),
+			flagged:       make(map[crypto.Token]uint64),
+			checksumEpoch: 5,
+			checksum:      checksum,
+		}
+		b.record(consensus.SignChecksum(5, checksum, keys[0]))
+		return b
+	}
+
+	b := node(agreed)
+	dissent := consensus.SignChecksum(5, diverged, keys[2])
+	b.receiveChecksum(&dissent)
+	select {
+	case <-b.comm.Dissent:
+		t.Fatal("dissent flagged without majority")
+	default:
+	}
+	agreement := consensus.SignChecksum(5, agreed, keys[1])
+	b.receiveChecksum(&agreement)
+	if flagged := <-b.comm.Dissent; flagged.Token != keys[2].PublicKey() || b.halted {
+		t.Fatal("dissenting validator not flagged")
+	}
+	select {
+	case <-b.comm.Halted:
+		t.Fatal("halt reported by node agreeing with majority")
+	default:
+	}
+	conflicting := consensus.SignChecksum(5, crypto.Hasher([]byte("other")), keys[2])
+	b.receiveChecksum(&conflicting)
+	if instruction, _ := b.pool.Unqueue(); instruction == nil || instruction.Kind() != instructions.IDenounceChecksum {
+		t.Fatal("conflicting checksums not denounced")
+	}
+
+	b = node(diverged)
+	for _, key := range keys[1:] {
+		checksum := consensus.SignChecksum(5, agreed, key)
+		b.receiveChecksum(&checksum)
+	}
+	if !b.halted {
+		t.Fatal("node with diverged state not halted")
+	}
+	select {
+	case halt := <-b.comm.Halted:
+		if !halt.Halted || halt.Epoch != 5 || halt.Checksum != diverged || halt.Majority != agreed {
+			t.Fatal("wrong halt report")
+		}
+	default:
+		t.Fatal("halt not reported")
+	}
+}
+
+func TestResetHalted(t *testing.T) {
+	keys := make([]crypto.PrivateKey, 3)
+	stakes := make(map[crypto.Token]uint64)
+	for n := range keys {
+		var token crypto.Token
+		token, keys[n] = crypto.RandomAsymetricKey()
+		stakes[token] = 1000
+	}
+	atEpoch := func(token crypto.PrivateKey) *consensus.BlockChain {
+		chain := consensus.NewGenesisBlockChain(token)
+		chain.CurrentState.Epoch = 5
+		return chain
+	}
+	agreed, diverged, other := atEpoch(keys[1]), atEpoch(keys[0]), atEpoch(keys[2])
+	b := &Breeze{
+		chain:         diverged,
+		token:         keys[0],
+		comm:          consensus.NewCommunication(),
+		pool:          consensus.NewInstructionPool(),
+		schedule:      NewSchedule(diverged.CurrentState.Root(), stakes),
+		orphans:       make(map[crypto.Hash]*orphan),
+		advanced:      make(chan struct{}, 1),
+		denounced:     make(map[crypto.Token]struct{}),
+		checksums:     make(map[uint64]map[crypto.Token]consensus.Checksum),
+		flagged:       make(map[crypto.Token]uint64),
+		checksumEpoch: 5,
+		checksum:      diverged.CurrentState.Root(),
+	}
+	b.record(consensus.SignChecksum(5, b.checksum, keys[0]))
+	go b.listen()
+	for _, key := range keys[1:] {
+		checksum := consensus.SignChecksum(5, agreed.CurrentState.Root(), key)
+		b.comm.Checksum <- &checksum
+	}
+	if halt := <-b.comm.Halted; !halt.Halted {
+		t.Fatal("node with diverged state not halted")
+	}
+
+	reset := func(chain *consensus.BlockChain) bool {
+		ok := make(chan bool)
+		b.comm.Reset <- consensus.ResetRequest{Chain: chain, Ok: ok}
+		return <-ok
+	}
+	if reset(other) {
+		t.Fatal("reset to a state disagreeing with the majority accepted")
+	}
+	if !reset(agreed) {
+		t.Fatal("reset to the majority state rejected")
+	}
+	if halt := <-b.comm.Halted; halt.Halted || halt.Epoch != 5 {
+		t.Fatal("resume not reported")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.halted || b.chain != agreed || b.schedule.Checksum != agreed.CurrentState.Root() {
+		t.Fatal("node not reset to the majority state")
+	}
+}
+
+// connect returns both ends of a secure connection from the node holding
+// client to the node holding server.
+func connect(t *testing.T, client, server crypto.PrivateKey, validator chan consensus.ValidatedConnection) (*network.SecureConnection, *network.SecureConnection) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	accepted := make(chan *network.SecureConnection)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			accepted <- nil
+			return
+		}
+		secure, err := network.PerformServerHandShake(conn, server, validator)
+		if err != nil {
+			accepted <- nil
+			return
+		}
+		accepted <- secure
+	}()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	secure, err := network.PerformClientHandShake(conn, client, server.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := <-accepted
+	if remote == nil {
+		t.Fatal("handshake failed")
+	}
+	return secure, remote
+}
+
+func TestGossipDisagreement(t *testing.T) {
+	first, firstKey := crypto.RandomAsymetricKey()
+	second, secondKey := crypto.RandomAsymetricKey()
+	stakes := map[crypto.Token]uint64{first: 2000, second: 1000}
+	engine := func(token crypto.PrivateKey) *Breeze {
+		chain := consensus.NewGenesisBlockChain(token)
+		chain.CurrentState.Epoch = 5
+		b := &Breeze{
+			chain:     chain,
+			token:     token,
+			comm:      consensus.NewCommunication(),
+			pool:      consensus.NewInstructionPool(),
+			schedule:  NewSchedule(chain.CurrentState.Root(), stakes),
+			orphans:   make(map[crypto.Hash]*orphan),
+			advanced:  make(chan struct{}, 1),
+			denounced: make(map[crypto.Token]struct{}),
+			checksums: make(map[uint64]map[crypto.Token]consensus.Checksum),
+			flagged:   make(map[crypto.Token]uint64),
+		}
+		go b.listen()
+		return b
+	}
+	agreed, diverged := engine(firstKey), engine(secondKey)
+	if agreed.chain.CurrentState.Root() == diverged.chain.CurrentState.Root() {
+		t.Fatal("engines start from the same state")
+	}
+	agreedConn, divergedConn := connect(t, firstKey, secondKey, diverged.comm.ValidateConn)
+	network.NewGossip(firstKey, agreed.comm).AddPeer(agreedConn)
+	network.NewGossip(secondKey, diverged.comm).AddPeer(divergedConn)
+	for _, b := range []*Breeze{agreed, diverged} {
+		b.mu.Lock()
+		b.attest()
+		b.mu.Unlock()
+	}
+
+	select {
+	case halt := <-diverged.comm.Halted:
+		if !halt.Halted || halt.Majority != agreed.chain.CurrentState.Root() {
+			t.Fatal("wrong halt report")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("diverged engine not halted")
+	}
+	select {
+	case dissent := <-agreed.comm.Dissent:
+		if dissent.Token != second {
+			t.Fatal("wrong validator flagged")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("diverged checksum not flagged")
+	}
+	select {
+	case <-agreed.comm.Halted:
+		t.Fatal("engine agreeing with the majority halted")
+	default:
+	}
+}
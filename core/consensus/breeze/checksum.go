@@ -1,6 +1,7 @@
 package breeze
 
 import (
+	"bytes"
 	"encoding/binary"
 	"math/big"
 	"sort"
@@ -34,12 +35,35 @@ func (s Slots) Len() int {
 	return len(s)
 }
 
+// NewNodes returns the nodes of validators ordered by token. The stake of
+// each validator must include the stake delegated to its pool.
+func NewNodes(validators map[crypto.Token]uint64) []Node {
+	nodes := make([]Node, 0, len(validators))
+	for token, stake := range validators {
+		nodes = append(nodes, Node{Token: append([]byte{}, token[:]...), Stake: stake})
+	}
+	sort.Slice(nodes, func(i, j int) bool { return bytes.Compare(nodes[i].Token, nodes[j].Token) < 0 })
+	return nodes
+}
+
+// Seed returns the seed of the slots of epoch within the checksum window of
+// checksum.
+func Seed(checksum crypto.Hash, epoch uint64) []byte {
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint64(data, epoch)
+	seed := crypto.Hasher(append(append([]byte{}, checksum[:]...), data...))
+	return seed[:]
+}
+
+// SortSlots returns one slot for every StakeRounding of stake of each node,
+// sorted by the hash of the slot seeded by checksum.
 func SortSlots(checksum []byte, nodes []Node) Slots {
 	slots := make(Slots, 0)
 	for _, node := range nodes {
 		for n := uint64(0); n < node.Stake/StakeRounding; n++ {
-			data := make([]byte, 8)
-			binary.LittleEndian.PutUint64(data, n)
+			data := append([]byte{}, checksum...)
+			data = append(data, make([]byte, 8)...)
+			binary.LittleEndian.PutUint64(data[len(checksum):], n)
 			data = append(data, node.Token...)
 			hash := crypto.Hasher(data)
 			hashBigInt := big.NewInt(0)
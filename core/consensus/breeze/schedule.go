@@ -0,0 +1,78 @@
+package breeze
+
+import (
+	"github.com/Aereum/aereum/core/crypto"
+)
+
+// Schedule elects the leader and the committee of every epoch of a checksum
+// window from the stake of the validators at the start of the window.
+type Schedule struct {
+	Checksum crypto.Hash
+	nodes    []Node
+	stakes   map[crypto.Token]uint64
+	epoch    uint64
+	cached   []crypto.Token
+}
+
+// NewSchedule returns the schedule seeded by checksum over a snapshot of the
+// stake of validators.
+func NewSchedule(checksum crypto.Hash, validators map[crypto.Token]uint64) *Schedule {
+	stakes := make(map[crypto.Token]uint64, len(validators))
+	for token, stake := range validators {
+		stakes[token] = stake
+	}
+	return &Schedule{
+		Checksum: checksum,
+		nodes:    NewNodes(stakes),
+		stakes:   stakes,
+	}
+}
+
+// Committee returns the first ValidatorsCount distinct validators in the slot
+// order of epoch. The leader of the epoch comes first.
+func (s *Schedule) Committee(epoch uint64) []crypto.Token {
+	if s.cached != nil && s.epoch == epoch {
+		return s.cached
+	}
+	committee := make([]crypto.Token, 0, ValidatorsCount)
+	seen := make(map[crypto.Token]struct{})
+	for _, slot := range SortSlots(Seed(s.Checksum, epoch), s.nodes) {
+		var token crypto.Token
+		copy(token[:], slot.Token)
+		if _, ok := seen[token]; ok {
+			continue
+		}
+		seen[token] = struct{}{}
+		committee = append(committee, token)
+		if len(committee) == ValidatorsCount {
+			break
+		}
+	}
+	s.epoch, s.cached = epoch, committee
+	return committee
+}
+
+// Leader returns the validator elected to build the block of epoch, or the
+// zero token if no validator holds a slot.
+func (s *Schedule) Leader(epoch uint64) crypto.Token {
+	committee := s.Committee(epoch)
+	if len(committee) == 0 {
+		return crypto.ZeroToken
+	}
+	return committee[0]
+}
+
+// IsMember checks if token is in the committee of epoch.
+func (s *Schedule) IsMember(epoch uint64, token crypto.Token) bool {
+	for _, member := range s.Committee(epoch) {
+		if member == token {
+			return true
+		}
+	}
+	return false
+}
+
+// Stake returns the stake of token in the snapshot of the schedule.
+func (s *Schedule) Stake(token crypto.Token) uint64 {
+	return s.stakes[token]
+}
@@ -0,0 +1,324 @@
+package consensus
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/Aereum/aereum/core/chain"
+	"github.com/Aereum/aereum/core/crypto"
+)
+
+// extends checks if block is built on the block of epoch with hash.
+func extends(block *SignedBlock, epoch uint64, hash crypto.Hash) bool {
+	return block.Block.CheckPoint == epoch && block.Block.Parent == hash
+}
+
+// SignedBlock returns the recent or candidate block with hash, or nil if the
+// block is not known.
+func (b *BlockChain) SignedBlock(hash crypto.Hash) *SignedBlock {
+	for _, block := range b.RecentBlocks {
+		if block.Block.Hash == hash {
+			return block
+		}
+	}
+	for _, blocks := range b.CandidateBlocks {
+		for _, block := range blocks {
+			if block.Block.Hash == hash {
+				return block
+			}
+		}
+	}
+	return nil
+}
+
+// pending returns the recent and candidate blocks that lead from the current
+// state up to the block of epoch with hash, in incorporation order.
+func (b *BlockChain) pending(epoch uint64, hash crypto.Hash) ([]*chain.Block, bool) {
+	blocks := make([]*chain.Block, 0)
+	for epoch > b.CurrentState.Epoch {
+		block := b.SignedBlock(hash)
+		if block == nil || block.Block.Epoch() != epoch || block.Block.CheckPoint >= epoch {
+			return nil, false
+		}
+		blocks = append(blocks, block.Block)
+		epoch, hash = block.Block.CheckPoint, block.Block.Parent
+	}
+	if epoch != b.CurrentState.Epoch || hash != b.LastHash {
+		return nil, false
+	}
+	for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+		blocks[i], blocks[j] = blocks[j], blocks[i]
+	}
+	return blocks, true
+}
+
+// Descends checks if block is, or extends, the block of epoch with hash. The
+// block of the current state is extended by every block that extends the
+// current state.
+func (b *BlockChain) Descends(block *chain.Block, epoch uint64, hash crypto.Hash) bool {
+	if block.Epoch() == epoch && block.Hash == hash {
+		return true
+	}
+	blocks, ok := b.pending(block.CheckPoint, block.Parent)
+	if !ok {
+		return false
+	}
+	for _, ancestor := range blocks {
+		if ancestor.Epoch() == epoch && ancestor.Hash == hash {
+			return true
+		}
+	}
+	return epoch == b.CurrentState.Epoch && hash == b.LastHash
+}
+
+// CheckpointAt returns a validator over the current state and the blocks that
+// lead from it up to the block of epoch with hash. It returns
+// ErrUnknownParent if there is no such sequence of blocks.
+func (b *BlockChain) CheckpointAt(epoch uint64, hash crypto.Hash) (*Checkpoint, error) {
+	blocks, ok := b.pending(epoch, hash)
+	if !ok {
+		return nil, ErrUnknownParent
+	}
+	mutations := chain.NewMutation()
+	if len(blocks) > 0 {
+		var err error
+		if mutations, err = chain.GroupBlockMutations(b.CurrentState, blocks); err != nil {
+			return nil, err
+		}
+	}
+	return &Checkpoint{
+		Validator: &chain.MutatingState{
+			State:     b.CurrentState,
+			Mutations: mutations,
+		},
+		CheckpointEpoch: epoch,
+		CheckpointHash:  hash,
+	}, nil
+}
+
+// Head returns the epoch and hash of the block new blocks should extend. It
+// walks the tree of recent and candidate blocks from the current state and,
+// at every fork, follows the final block if there is one and otherwise the
+// branch with the heaviest signed stake, tie-broken by the lowest hash.
+// Blocks extend checkpoints of any earlier epoch, so epochs without blocks
+// are skipped.
+func (b *BlockChain) Head() (uint64, crypto.Hash) {
+	tree := b.tree()
+	weights := make(map[crypto.Hash]uint64)
+	epoch, hash := b.CurrentState.Epoch, b.LastHash
+	for {
+		var next *SignedBlock
+		for _, block := range b.RecentBlocks {
+			if extends(block, epoch, hash) {
+				next = block
+				break
+			}
+		}
+		if next == nil {
+			heaviest := uint64(0)
+			for _, block := range tree[hash] {
+				if !extends(block, epoch, hash) {
+					continue
+				}
+				weight := b.branchWeight(block, tree, weights)
+				if next == nil || weight > heaviest || (weight == heaviest && bytes.Compare(block.Block.Hash[:], next.Block.Hash[:]) < 0) {
+					next, heaviest = block, weight
+				}
+			}
+		}
+		if next == nil {
+			return epoch, hash
+		}
+		epoch, hash = next.Block.Epoch(), next.Block.Hash
+	}
+}
+
+// tree returns the candidate blocks that extend each block, indexed by the
+// hash of the extended block.
+func (b *BlockChain) tree() map[crypto.Hash]SignedBlocks {
+	tree := make(map[crypto.Hash]SignedBlocks)
+	for _, blocks := range b.CandidateBlocks {
+		for _, block := range blocks {
+			if block.Block.CheckPoint < block.Block.Epoch() {
+				tree[block.Block.Parent] = append(tree[block.Block.Parent], block)
+			}
+		}
+	}
+	return tree
+}
+
+// Weight returns the stake of the validators that signed block.
+func (b *BlockChain) Weight(block *SignedBlock) uint64 {
+	weight := uint64(0)
+	for _, signature := range block.Signatures {
+		weight += b.Validators[signature.Token]
+	}
+	return weight
+}
+
+// branchWeight returns the signed stake of block and of every candidate that
+// extends it. Weights are memoized on weights.
+func (b *BlockChain) branchWeight(block *SignedBlock, tree map[crypto.Hash]SignedBlocks, weights map[crypto.Hash]uint64) uint64 {
+	if weight, ok := weights[block.Block.Hash]; ok {
+		return weight
+	}
+	weight := b.Weight(block)
+	for _, child := range tree[block.Block.Hash] {
+		if child.Block.CheckPoint == block.Block.Epoch() {
+			weight += b.branchWeight(child, tree, weights)
+		}
+	}
+	weights[block.Block.Hash] = weight
+	return weight
+}
+
+// rootAfter returns the state root after incorporating block over the blocks
+// it extends. Blocks are incorporated into a memory copy of the current state,
+// which is left unchanged.
+func (b *BlockChain) rootAfter(block *chain.Block) (crypto.Hash, error) {
+	blocks, ok := b.pending(block.CheckPoint, block.Parent)
+	if !ok {
+		return crypto.Hash{}, ErrUnknownParent
+	}
+	scratch := b.CurrentState.MemoryCopy()
+	defer scratch.Close()
+	for _, block := range append(blocks, block) {
+		if _, err := scratch.IncorporateBlock(block); err != nil {
+			return crypto.Hash{}, err
+		}
+	}
+	return scratch.Root(), nil
+}
+
+// SealCandidate sets the state root of a block built over a checkpoint of
+// the block chain and seals it with token.
+func (b *BlockChain) SealCandidate(block *chain.Block, token crypto.PrivateKey) error {
+	root, err := b.rootAfter(block)
+	if err != nil {
+		return err
+	}
+	block.StateRoot = root
+	block.Seal(token)
+	return nil
+}
+
+// ValidateCandidate validates block over the blocks it extends and checks the
+// state root announced by its publisher.
+func (b *BlockChain) ValidateCandidate(block *chain.Block) (*chain.Block, error) {
+	checkpoint, err := b.CheckpointAt(block.CheckPoint, block.Parent)
+	if err != nil {
+		return nil, err
+	}
+	validated, err := ValidateBlock(block.Serialize(), *checkpoint.Validator)
+	if err != nil {
+		return nil, err
+	}
+	root, err := b.rootAfter(validated)
+	if err != nil {
+		return nil, err
+	}
+	if root != validated.StateRoot {
+		return nil, chain.ErrStateRootMismatch
+	}
+	return validated, nil
+}
+
+// AddCandidate appends a validated block to the candidates of its epoch and
+// returns its signed block. A known block is returned unchanged.
+func (b *BlockChain) AddCandidate(block *chain.Block) *SignedBlock {
+	if known := b.SignedBlock(block.Hash); known != nil {
+		return known
+	}
+	signed := &SignedBlock{Block: block, Signatures: make([]Signature, 0)}
+	b.CandidateBlocks[block.Epoch()] = append(b.CandidateBlocks[block.Epoch()], signed)
+	return signed
+}
+
+// Finalize moves a candidate block that reached finality to the recent
+// blocks. Recent blocks are incorporated by IncorporateNext.
+func (b *BlockChain) Finalize(signed *SignedBlock) {
+	epoch := signed.Block.Epoch()
+	candidates := b.CandidateBlocks[epoch]
+	for n, block := range candidates {
+		if block == signed {
+			candidates = append(candidates[:n], candidates[n+1:]...)
+			break
+		}
+	}
+	if len(candidates) == 0 {
+		delete(b.CandidateBlocks, epoch)
+	} else {
+		b.CandidateBlocks[epoch] = candidates
+	}
+	for _, block := range b.RecentBlocks {
+		if block == signed {
+			return
+		}
+	}
+	b.RecentBlocks = append(b.RecentBlocks, signed)
+	sort.Sort(b.RecentBlocks)
+}
+
+// IncorporateNext incorporates into the current state the recent block that
+// extends it, if any, and discards the blocks that no longer extend the new
+// state. It returns nil if no recent block extends the current state.
+func (b *BlockChain) IncorporateNext() (*SignedBlock, error) {
+	var next *SignedBlock
+	for n, block := range b.RecentBlocks {
+		if extends(block, b.CurrentState.Epoch, b.LastHash) {
+			next = block
+			b.RecentBlocks = append(b.RecentBlocks[:n], b.RecentBlocks[n+1:]...)
+			break
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+	undo, err := b.CurrentState.IncorporateBlock(next.Block)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.CurrentState.CheckStateRoot(next.Block); err != nil {
+		b.CurrentState.RevertBlock(undo)
+		return nil, err
+	}
+	b.Epoch = b.CurrentState.Epoch
+	b.LastHash = next.Block.Hash
+	b.UpdateStakes()
+	b.prune()
+	return next, nil
+}
+
+// prune discards the recent and candidate blocks that do not extend the
+// current state.
+func (b *BlockChain) prune() {
+	recent := make(SignedBlocks, 0, len(b.RecentBlocks))
+	for _, block := range b.RecentBlocks {
+		if block.Block.Epoch() > b.CurrentState.Epoch {
+			recent = append(recent, block)
+		}
+	}
+	b.RecentBlocks = recent
+	epochs := make([]uint64, 0, len(b.CandidateBlocks))
+	for epoch := range b.CandidateBlocks {
+		epochs = append(epochs, epoch)
+	}
+	sort.Slice(epochs, func(i, j int) bool { return epochs[i] < epochs[j] })
+	for _, epoch := range epochs {
+		if epoch <= b.CurrentState.Epoch {
+			delete(b.CandidateBlocks, epoch)
+			continue
+		}
+		kept := make(SignedBlocks, 0)
+		for _, block := range b.CandidateBlocks[epoch] {
+			if _, ok := b.pending(block.Block.CheckPoint, block.Block.Parent); ok {
+				kept = append(kept, block)
+			}
+		}
+		if len(kept) == 0 {
+			delete(b.CandidateBlocks, epoch)
+		} else {
+			b.CandidateBlocks[epoch] = kept
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package consensus
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Aereum/aereum/core/chain"
+	"github.com/Aereum/aereum/core/crypto"
+)
+
+func newCandidate(t *testing.T, b *BlockChain, epoch, checkpoint uint64, parent crypto.Hash, token crypto.PrivateKey) *SignedBlock {
+	validator, err := b.CheckpointAt(checkpoint, parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := chain.NewBlock(parent, checkpoint, epoch, token.PublicKey(), validator.Validator)
+	if err := b.SealCandidate(block, token); err != nil {
+		t.Fatal(err)
+	}
+	return b.AddCandidate(block)
+}
+
+func TestForkChoice(t *testing.T) {
+	_, token := crypto.RandomAsymetricKey()
+	blockchain := NewGenesisBlockChain(token)
+	keys := make([]crypto.PrivateKey, 3)
+	for n := range keys {
+		_, keys[n] = crypto.RandomAsymetricKey()
+		blockchain.Validators[keys[n].PublicKey()] = uint64(n+1) * 1000
+	}
+	first := newCandidate(t, blockchain, 1, 0, crypto.Hash{}, token)
+	// epoch 2 is missing on the second branch
+	second := newCandidate(t, blockchain, 3, 0, crypto.Hash{}, token)
+
+	lower := first
+	if bytes.Compare(second.Block.Hash[:], first.Block.Hash[:]) < 0 {
+		lower = second
+	}
+	if _, head := blockchain.Head(); head != lower.Block.Hash {
+		t.Fatal("tie not broken by hash")
+	}
+
+	first.AppendSignature(SignBlock(first.Block, keys[1]))
+	second.AppendSignature(SignBlock(second.Block, keys[0]))
+	if _, head := blockchain.Head(); head != first.Block.Hash {
+		t.Fatal("head is not the heaviest block")
+	}
+
+	third := newCandidate(t, blockchain, 4, 3, second.Block.Hash, token)
+	third.AppendSignature(SignBlock(third.Block, keys[2]))
+	epoch, head := blockchain.Head()
+	if epoch != 4 || head != third.Block.Hash {
+		t.Fatal("head did not switch to the heavier branch")
+	}
+	checkpoint, err := blockchain.GetLastCheckpoint()
+	if err != nil || checkpoint.CheckpointEpoch != 4 || checkpoint.CheckpointHash != third.Block.Hash {
+		t.Fatal("checkpoint is not on the head")
+	}
+
+	// a final block prevails over heavier branches
+	blockchain.Finalize(first)
+	if _, head := blockchain.Head(); head != first.Block.Hash {
+		t.Fatal("head does not follow final block")
+	}
+	if incorporated, err := blockchain.IncorporateNext(); err != nil || incorporated != first {
+		t.Fatal("final block not incorporated")
+	}
+	if blockchain.SignedBlock(second.Block.Hash) != nil || blockchain.SignedBlock(third.Block.Hash) != nil {
+		t.Fatal("conflicting branch not pruned")
+	}
+}
@@ -0,0 +1,73 @@
+package consensus
+
+import (
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/instructions"
+	"github.com/Aereum/aereum/core/util"
+)
+
+// Checksum is the signature of a validator over the state checksum it reached
+// at epoch. The checksum is the state root, which commits to the hash of every
+// vault of the state.
+type Checksum struct {
+	Epoch     uint64
+	Checksum  crypto.Hash
+	Token     crypto.Token
+	Signature crypto.Signature
+}
+
+// SignChecksum returns the signature of token over the checksum of epoch.
+func SignChecksum(epoch uint64, checksum crypto.Hash, token crypto.PrivateKey) Checksum {
+	return Checksum{
+		Epoch:     epoch,
+		Checksum:  checksum,
+		Token:     token.PublicKey(),
+		Signature: token.Sign(instructions.ChecksumMessage(epoch, checksum)),
+	}
+}
+
+// Verify checks that the signature is valid for its epoch, checksum and token.
+func (c Checksum) Verify() bool {
+	return c.Token.Verify(instructions.ChecksumMessage(c.Epoch, c.Checksum), c.Signature)
+}
+
+// Conflicts checks if c and other are signatures by the same token of
+// different checksums of the same epoch.
+func (c Checksum) Conflicts(other Checksum) bool {
+	return c.Token == other.Token && c.Epoch == other.Epoch && c.Checksum != other.Checksum
+}
+
+// Denounce returns the evidence that c and other are conflicting checksums.
+func (c Checksum) Denounce(other Checksum) instructions.Conflict {
+	return instructions.Conflict{
+		Epoch:           c.Epoch,
+		First:           c.Checksum,
+		FirstSignature:  c.Signature,
+		Second:          other.Checksum,
+		SecondSignature: other.Signature,
+	}
+}
+
+func (c *Checksum) Serialize() []byte {
+	bytes := make([]byte, 0)
+	util.PutUint64(c.Epoch, &bytes)
+	util.PutByteArray(c.Checksum[:], &bytes)
+	util.PutToken(c.Token, &bytes)
+	util.PutSignature(c.Signature, &bytes)
+	return bytes
+}
+
+// ParseChecksum parses a serialized checksum. It returns nil if the data is
+// not a checksum or its signature is not valid.
+func ParseChecksum(data []byte) *Checksum {
+	c := Checksum{}
+	position := 0
+	c.Epoch, position = util.ParseUint64(data, position)
+	c.Checksum, position = util.ParseHash(data, position)
+	c.Token, position = util.ParseToken(data, position)
+	c.Signature, position = util.ParseSignature(data, position)
+	if position != len(data) || !c.Verify() {
+		return nil
+	}
+	return &c
+}
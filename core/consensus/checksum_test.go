@@ -0,0 +1,47 @@
+package consensus
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Aereum/aereum/core/chain"
+	"github.com/Aereum/aereum/core/crypto"
+)
+
+func TestChecksum(t *testing.T) {
+	_, token := crypto.RandomAsymetricKey()
+	checksum := SignChecksum(10, crypto.Hasher([]byte("state")), token)
+	parsed := ParseChecksum(checksum.Serialize())
+	if parsed == nil || !reflect.DeepEqual(*parsed, checksum) {
+		t.Fatal("Parse and Serialize not working for Checksum")
+	}
+	forged := checksum
+	forged.Epoch = 11
+	if ParseChecksum(forged.Serialize()) != nil {
+		t.Error("checksum parsed with invalid signature")
+	}
+	other := SignChecksum(10, crypto.Hasher([]byte("other")), token)
+	if !checksum.Conflicts(other) {
+		t.Error("conflicting checksums not detected")
+	}
+	conflict := checksum.Denounce(other)
+	if conflict.First != checksum.Checksum || conflict.Second != other.Checksum || conflict.Epoch != 10 {
+		t.Error("evidence does not hold both checksums")
+	}
+}
+
+func TestSignature(t *testing.T) {
+	token, key := crypto.RandomAsymetricKey()
+	block := chain.NewBlock(crypto.Hasher([]byte{}), 0, 10, token, nil)
+	block.Hash = crypto.Hasher([]byte("block"))
+	signature := SignBlock(block, key)
+	parsed := ParseSignature(signature.Serialize())
+	if parsed == nil || !reflect.DeepEqual(*parsed, signature) {
+		t.Fatal("Parse and Serialize not working for Signature")
+	}
+	forged := signature
+	forged.Epoch = 11
+	if ParseSignature(forged.Serialize()) != nil {
+		t.Error("signature parsed with invalid signature")
+	}
+}
@@ -22,18 +22,29 @@ type PeerRequest struct {
 	Response chan bool
 }
 
-type Checksum struct {
-	Token   crypto.Hash
-	Check   []byte
-	Confirm chan bool
-}
-
 type SyncRequest struct {
 	Starting chan uint64
 	Data     chan []byte
 	Ok       chan bool
 }
 
+// Halt reports that a node stopped or resumed building and signing blocks
+// after comparing its state checksum of Epoch with the stake weighted majority.
+type Halt struct {
+	Epoch    uint64
+	Halted   bool
+	Checksum crypto.Hash // checksum of the node
+	Majority crypto.Hash // checksum signed by the majority of the stake
+}
+
+// ResetRequest asks a node to replace its chain, typically reopened from a
+// state copied from a validator that agrees with the majority. Ok reports if
+// the chain was accepted.
+type ResetRequest struct {
+	Chain *BlockChain
+	Ok    chan bool
+}
+
 type ValidatedConnection struct {
 	Token crypto.Hash
 	Ok    chan bool
@@ -44,12 +55,23 @@ type Communication struct {
 	NewBlock        chan *chain.Block // Node publishes to or receives new blocks from the network
 	BlockSignature  chan *Signature   // Node publishes to or receives signatures from the network
 	Checkpoint      chan *SignedBlock // Node publishes new checkpoint to observers network
-	Checksum        chan *Checksum    // Node publishes to or receives checksums from the network
+	Checksum        chan *Checksum    // Node receives state checksums of validators from the network
 	Synchronization chan SyncRequest  // Node receives sync request
 	ValidateConn    chan ValidatedConnection
 	Instructions    chan *instructions.HashInstruction
+	Proposal        chan *chain.Block // Node publishes its own blocks to the network
+	Vote            chan *Signature   // Node publishes its own block signatures to the network
+	Attestation     chan *Checksum    // Node publishes its own state checksums to the network
+	Dissent         chan *Checksum    // Node flags checksums that disagree with the stake weighted majority
+	Halted          chan *Halt        // Node reports halting or resuming block production
+	Errors          chan error        // Node reports failures to propose, validate or finalize blocks
+	Reset           chan ResetRequest // Operator replaces the chain of the node
 }
 
+// gossipBuffer is the capacity of the outbound channels.
+// Engines publish on them without blocking and drop messages once full.
+const gossipBuffer = 64
+
 func NewCommunication() *Communication {
 	return &Communication{
 		PeerRequest:     make(chan *PeerRequest),
@@ -60,15 +82,33 @@ func NewCommunication() *Communication {
 		Synchronization: make(chan SyncRequest),
 		ValidateConn:    make(chan ValidatedConnection),
 		Instructions:    make(chan *instructions.HashInstruction),
+		Proposal:        make(chan *chain.Block, gossipBuffer),
+		Vote:            make(chan *Signature, gossipBuffer),
+		Attestation:     make(chan *Checksum, gossipBuffer),
+		Dissent:         make(chan *Checksum, gossipBuffer),
+		Halted:          make(chan *Halt, gossipBuffer),
+		Errors:          make(chan error, gossipBuffer),
+		Reset:           make(chan ResetRequest),
 	}
 }
 
-type ConsensusEngine func(BlockChain) *Communication
+// ConsensusEngine starts a consensus engine over chain for the node holding
+// token and returns the channels to communicate with it.
+type ConsensusEngine func(chain *BlockChain, token crypto.PrivateKey) *Communication
+
+// DefaultEpochDuration is the duration of an epoch of networks not bootstrapped
+// from a genesis specification.
+const DefaultEpochDuration = time.Second
 
 func IntervalToNewEpoch(epoch uint64, genesis time.Time) time.Duration {
 	return time.Until(genesis.Add(time.Duration(int64(epoch) * 1000000000)))
 }
 
+// IntervalToNewEpoch returns the time remaining until the start of epoch.
+func (b *BlockChain) IntervalToNewEpoch(epoch uint64) time.Duration {
+	return time.Until(b.GenesisTime.Add(time.Duration(epoch) * b.EpochDuration))
+}
+
 /*
 func (c *Consensus) PushNewBlock(block *instructions.Block) {
 	epoch := block.Epoch
@@ -21,9 +21,6 @@ func NewInstructionPool() *InstructionPool {
 }
 
 func (pool *InstructionPool) Unqueue() (instructions.Instruction, crypto.Hash) {
-	if len(pool.queue) == 0 {
-		return nil, crypto.ZeroHash
-	}
 	pool.mu.Lock()
 	defer pool.mu.Unlock()
 	for n, hash := range pool.queue {
@@ -6,19 +6,94 @@ import (
 	"github.com/Aereum/aereum/core/chain"
 	"github.com/Aereum/aereum/core/crypto"
 	"github.com/Aereum/aereum/core/instructions"
+	"github.com/Aereum/aereum/core/util"
 )
 
 type Signature struct {
+	Epoch     uint64
 	Hash      crypto.Hash
 	Token     crypto.Token
 	Signature crypto.Signature
 }
 
+// SignBlock returns the signature of token over the vote message of block.
+// Signatures of two different blocks of the same epoch are evidence of
+// double-signing.
+func SignBlock(block *chain.Block, token crypto.PrivateKey) Signature {
+	return Signature{
+		Epoch:     block.Epoch(),
+		Hash:      block.Hash,
+		Token:     token.PublicKey(),
+		Signature: token.Sign(instructions.VoteMessage(block.Epoch(), block.Hash)),
+	}
+}
+
+// Verify checks that the signature is valid for its epoch, hash and token.
+func (s Signature) Verify() bool {
+	return s.Token.Verify(instructions.VoteMessage(s.Epoch, s.Hash), s.Signature)
+}
+
+// Conflicts checks if s and other are signatures by the same token of
+// different blocks of the same epoch.
+func (s Signature) Conflicts(other Signature) bool {
+	return s.Token == other.Token && s.Epoch == other.Epoch && s.Hash != other.Hash
+}
+
+// Denounce returns the evidence that s and other are conflicting signatures.
+func (s Signature) Denounce(other Signature) instructions.Conflict {
+	return instructions.Conflict{
+		Epoch:           s.Epoch,
+		First:           s.Hash,
+		FirstSignature:  s.Signature,
+		Second:          other.Hash,
+		SecondSignature: other.Signature,
+	}
+}
+
+func (s *Signature) Serialize() []byte {
+	bytes := make([]byte, 0)
+	util.PutUint64(s.Epoch, &bytes)
+	util.PutByteArray(s.Hash[:], &bytes)
+	util.PutToken(s.Token, &bytes)
+	util.PutSignature(s.Signature, &bytes)
+	return bytes
+}
+
+// ParseSignature parses a serialized block signature. It returns nil if the
+// data is not a block signature or its signature is not valid.
+func ParseSignature(data []byte) *Signature {
+	s := Signature{}
+	position := 0
+	s.Epoch, position = util.ParseUint64(data, position)
+	s.Hash, position = util.ParseHash(data, position)
+	s.Token, position = util.ParseToken(data, position)
+	s.Signature, position = util.ParseSignature(data, position)
+	if position != len(data) || !s.Verify() {
+		return nil
+	}
+	return &s
+}
+
 type SignedBlock struct {
 	Block      *chain.Block
 	Signatures []Signature
 }
 
+// AppendSignature appends a valid signature of the block by a token that has
+// not signed it yet. It returns false if the signature is not appended.
+func (s *SignedBlock) AppendSignature(signature Signature) bool {
+	if signature.Hash != s.Block.Hash || signature.Epoch != s.Block.Epoch() || !signature.Verify() {
+		return false
+	}
+	for _, signed := range s.Signatures {
+		if signed.Token == signature.Token {
+			return false
+		}
+	}
+	s.Signatures = append(s.Signatures, signature)
+	return true
+}
+
 type SignedBlocks []*SignedBlock
 
 func (blocks SignedBlocks) Less(i, j int) bool {
@@ -53,55 +128,43 @@ type Checkpoint struct {
 	CheckpointHash  crypto.Hash
 }
 
-type processInstruction struct {
-	instruction instructions.Instruction
-	valid       chan bool
-}
-
 type instructionCache struct {
 	instruction instructions.Instruction
 	hash        crypto.Hash
 }
 
+// builderIdle is the interval BlockBuilder waits for new instructions when the
+// pool is empty.
+const builderIdle = 10 * time.Millisecond
+
+// BlockBuilder builds over checkpoint the block of epoch with the instructions
+// of pool until finish. Instructions rejected by the block are queued back on
+// the pool once the block is finished.
 func BlockBuilder(checkpoint *Checkpoint, epoch uint64, token crypto.PrivateKey, finish time.Time, pool *InstructionPool) chan *chain.Block {
 	block := chain.NewBlock(checkpoint.CheckpointHash, checkpoint.CheckpointEpoch, epoch, token.PublicKey(), checkpoint.Validator)
-	stop := time.NewTicker(time.Until(finish))
-	communication := make(chan processInstruction)
 	finished := make(chan *chain.Block)
-	running := true
-	cache := make([]instructionCache, 0)
 	go func() {
+		stop := time.NewTimer(time.Until(finish))
+		defer stop.Stop()
+		cache := make([]instructionCache, 0)
+		busy := make(chan time.Time)
+		close(busy)
 		for {
+			next := (<-chan time.Time)(busy)
+			newInstruction, newHash := pool.Unqueue()
+			if newInstruction == nil {
+				next = time.After(builderIdle)
+			} else if block.Incorporate(newInstruction) != nil {
+				cache = append(cache, instructionCache{newInstruction, newHash})
+			}
 			select {
 			case <-stop.C:
-				finished <- block
-				running = false
 				for _, cached := range cache {
 					pool.Queue(cached.instruction, cached.hash)
 				}
+				finished <- block
 				return
-			case process := <-communication:
-				process.valid <- block.Incorporate(process.instruction)
-			}
-		}
-	}()
-
-	go func() {
-		valid := make(chan (bool))
-		for {
-			if !running {
-				break
-			}
-			newInstruction, newHash := pool.Unqueue()
-			if newInstruction != nil {
-				cache = append(cache, instructionCache{newInstruction, newHash})
-				communication <- processInstruction{
-					instruction: newInstruction,
-					valid:       valid,
-				}
-				if <-valid {
-					cache = cache[0 : len(cache)-1]
-				}
+			case <-next:
 			}
 		}
 	}()
@@ -1,21 +1,62 @@
 package consensus
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/Aereum/aereum/core/chain"
 	"github.com/Aereum/aereum/core/instructions"
 )
 
-func ValidateBlock(data []byte, validator chain.MutatingState) *chain.Block {
-	block := chain.ParseBlock(data)
+var (
+	ErrFeesMismatch  = errors.New("fees collected do not match block instructions")
+	ErrMintMismatch  = errors.New("minted reward does not match reward schedule")
+	ErrUnknownParent = errors.New("block does not extend a known block")
+)
+
+// InstructionError reports the instruction of a block that failed validation.
+type InstructionError struct {
+	Index int
+	Err   error
+}
+
+func (e *InstructionError) Error() string {
+	return fmt.Sprintf("instruction %v: %v", e.Index, e.Err)
+}
+
+func (e *InstructionError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateBlock parses data and validates every instruction of the block
+// against validator. If an instruction is not valid it returns an
+// *InstructionError with the index of the instruction and the reason.
+func ValidateBlock(data []byte, validator chain.MutatingState) (*chain.Block, error) {
+	block, err := chain.ParseBlock(data)
+	if err != nil {
+		return nil, err
+	}
 	block.SetValidator(&validator)
-	for _, instructionBytes := range block.Instructions {
+	// fees are collected and instructions appended again while they are
+	// replayed
+	fees := block.FeesCollected
+	block.FeesCollected = 0
+	replayed := block.Instructions
+	block.Instructions = make([][]byte, 0, len(replayed))
+	for n, instructionBytes := range replayed {
 		instruction := instructions.ParseInstruction(instructionBytes)
 		if instruction == nil {
-			return nil
+			return nil, &InstructionError{Index: n, Err: instructions.ErrUnknownInstruction}
 		}
-		if !block.Incorporate(instruction) {
-			return nil
+		if err := block.Incorporate(instruction); err != nil {
+			return nil, &InstructionError{Index: n, Err: err}
 		}
 	}
-	return block
+	if block.FeesCollected != fees {
+		return nil, ErrFeesMismatch
+	}
+	if block.Minted != block.Reward() {
+		return nil, ErrMintMismatch
+	}
+	return block, nil
 }
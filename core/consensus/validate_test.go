@@ -0,0 +1,34 @@
+package consensus
+
+import (
+	"testing"
+
+	"github.com/Aereum/aereum/core/chain"
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/instructions"
+)
+
+func TestValidateBlock(t *testing.T) {
+	state, token := chain.NewGenesisState()
+	_, publisher := crypto.RandomAsymetricKey()
+	validator := chain.MutatingState{State: state, Mutations: chain.NewMutation()}
+	block := chain.NewBlock(crypto.Hash{}, 0, 1, publisher.PublicKey(), &validator)
+	for n := 0; n < 3; n++ {
+		receiver, _ := crypto.RandomAsymetricKey()
+		if err := block.Incorporate(instructions.NewSingleReciepientTransfer(token, receiver, "", 100, 1, 10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	block.Seal(publisher)
+
+	validated, err := ValidateBlock(block.Serialize(), chain.MutatingState{State: state, Mutations: chain.NewMutation()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(validated.Instructions) != 3 || crypto.MerkleRoot(validated.Instructions) != block.InstructionsRoot {
+		t.Fatalf("instructions not replayed once: %v", len(validated.Instructions))
+	}
+	if validated.FeesCollected != 30 {
+		t.Errorf("wrong fees collected: %v", validated.FeesCollected)
+	}
+}
@@ -0,0 +1,78 @@
+package consensus
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/util"
+)
+
+// voteFile keeps the last block signed by the node next to the persisted state.
+const voteFile = "vote"
+
+var (
+	ErrCorruptVote = errors.New("persisted vote is corrupted")
+)
+
+// SaveVote durably records epoch and hash of the last block signed by the
+// node, so that a restarted node does not sign another block of the same
+// epoch. It must return before the signature is published. Nothing is
+// recorded if the state is kept on memory.
+func (b *BlockChain) SaveVote(epoch uint64, hash crypto.Hash) error {
+	dir := b.CurrentState.Dir()
+	if dir == "" {
+		return nil
+	}
+	data := make([]byte, 0, 8+crypto.Size)
+	util.PutUint64(epoch, &data)
+	data = append(data, hash[:]...)
+	// the vote replaces the previous one only once written in full
+	temp := filepath.Join(dir, voteFile+".new")
+	file, err := os.Create(temp)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(temp, filepath.Join(dir, voteFile)); err != nil {
+		return err
+	}
+	parent, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer parent.Close()
+	return parent.Sync()
+}
+
+// LastVote returns epoch and hash of the last block signed by the node as
+// recorded by SaveVote, or zero values if the node never signed a block.
+func (b *BlockChain) LastVote() (uint64, crypto.Hash, error) {
+	dir := b.CurrentState.Dir()
+	if dir == "" {
+		return 0, crypto.Hash{}, nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, voteFile))
+	if os.IsNotExist(err) {
+		return 0, crypto.Hash{}, nil
+	}
+	if err != nil {
+		return 0, crypto.Hash{}, err
+	}
+	if len(data) != 8+crypto.Size {
+		return 0, crypto.Hash{}, ErrCorruptVote
+	}
+	epoch, position := util.ParseUint64(data, 0)
+	return epoch, crypto.BytesToHash(data[position:]), nil
+}
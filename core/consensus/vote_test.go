@@ -0,0 +1,51 @@
+package consensus
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Aereum/aereum/core/crypto"
+)
+
+func TestSaveVote(t *testing.T) {
+	dir := t.TempDir()
+	_, token := crypto.RandomAsymetricKey()
+	genesis := time.Now()
+	blockchain, err := OpenBlockChain(dir, token, genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if epoch, _, err := blockchain.LastVote(); err != nil || epoch != 0 {
+		t.Fatalf("unexpected vote before signing: %v %v", epoch, err)
+	}
+	hash := crypto.Hasher([]byte("block"))
+	if err := blockchain.SaveVote(7, hash); err != nil {
+		t.Fatal(err)
+	}
+	blockchain.CurrentState.Close()
+
+	blockchain, err = OpenBlockChain(dir, token, genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.CurrentState.Close()
+	if epoch, voted, err := blockchain.LastVote(); err != nil || epoch != 7 || voted != hash {
+		t.Fatalf("vote not restored: %v %v %v", epoch, voted, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, voteFile), []byte{7}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := blockchain.LastVote(); err != ErrCorruptVote {
+		t.Errorf("expected corrupt vote, got %v", err)
+	}
+
+	memory := NewGenesisBlockChain(token)
+	if err := memory.SaveVote(7, hash); err != nil {
+		t.Error(err)
+	}
+	if epoch, _, err := memory.LastVote(); err != nil || epoch != 0 {
+		t.Error("vote persisted for a chain kept on memory")
+	}
+}
@@ -0,0 +1,104 @@
+package crypto
+
+// Merkle trees hash leaves and inner nodes with different prefixes so that an
+// inner node can never be presented as a leaf. A node without a sibling is
+// promoted unchanged to the next level.
+const (
+	merkleLeaf byte = 0
+	merkleNode byte = 1
+)
+
+func merkleLeafHash(data []byte) Hash {
+	return Hasher(append([]byte{merkleLeaf}, data...))
+}
+
+func merkleNodeHash(left, right Hash) Hash {
+	data := make([]byte, 0, 2*Size+1)
+	data = append(data, merkleNode)
+	data = append(data, left[:]...)
+	data = append(data, right[:]...)
+	return Hasher(data)
+}
+
+func merkleLevel(nodes []Hash) []Hash {
+	next := make([]Hash, 0, (len(nodes)+1)/2)
+	for n := 0; n < len(nodes); n += 2 {
+		if n+1 < len(nodes) {
+			next = append(next, merkleNodeHash(nodes[n], nodes[n+1]))
+		} else {
+			next = append(next, nodes[n])
+		}
+	}
+	return next
+}
+
+func merkleLeaves(leaves [][]byte) []Hash {
+	nodes := make([]Hash, len(leaves))
+	for n, leaf := range leaves {
+		nodes[n] = merkleLeafHash(leaf)
+	}
+	return nodes
+}
+
+// MerkleRoot returns the root of the Merkle tree of leaves. The root of an
+// empty tree is ZeroHash.
+func MerkleRoot(leaves [][]byte) Hash {
+	if len(leaves) == 0 {
+		return ZeroHash
+	}
+	nodes := merkleLeaves(leaves)
+	for len(nodes) > 1 {
+		nodes = merkleLevel(nodes)
+	}
+	return nodes[0]
+}
+
+// MerkleProof returns the sibling hashes from the leaf at index up to the
+// root of the Merkle tree of leaves. It returns nil if index is out of range.
+func MerkleProof(leaves [][]byte, index int) []Hash {
+	if index < 0 || index >= len(leaves) {
+		return nil
+	}
+	proof := make([]Hash, 0)
+	nodes := merkleLeaves(leaves)
+	for len(nodes) > 1 {
+		if sibling := index ^ 1; sibling < len(nodes) {
+			proof = append(proof, nodes[sibling])
+		}
+		nodes = merkleLevel(nodes)
+		index = index / 2
+	}
+	return proof
+}
+
+// MerkleProofRoot returns the root of a Merkle tree with count leaves that
+// has leaf at index, given the sibling hashes of proof. It returns false if
+// proof does not have the expected length.
+func MerkleProofRoot(leaf []byte, index, count int, proof []Hash) (Hash, bool) {
+	if index < 0 || index >= count {
+		return Hash{}, false
+	}
+	hash := merkleLeafHash(leaf)
+	for ; count > 1; count = (count + 1) / 2 {
+		if sibling := index ^ 1; sibling < count {
+			if len(proof) == 0 {
+				return Hash{}, false
+			}
+			if index%2 == 0 {
+				hash = merkleNodeHash(hash, proof[0])
+			} else {
+				hash = merkleNodeHash(proof[0], hash)
+			}
+			proof = proof[1:]
+		}
+		index = index / 2
+	}
+	return hash, len(proof) == 0
+}
+
+// VerifyMerkleProof checks that leaf is the leaf at index of a Merkle tree
+// with count leaves and the given root.
+func VerifyMerkleProof(root Hash, leaf []byte, index, count int, proof []Hash) bool {
+	hash, ok := MerkleProofRoot(leaf, index, count, proof)
+	return ok && hash == root
+}
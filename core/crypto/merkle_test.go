@@ -0,0 +1,25 @@
+package crypto
+
+import "testing"
+
+func TestMerkleProof(t *testing.T) {
+	for count := 1; count < 12; count++ {
+		leaves := make([][]byte, count)
+		for n := range leaves {
+			leaves[n] = []byte{byte(n), byte(count)}
+		}
+		root := MerkleRoot(leaves)
+		for n := range leaves {
+			proof := MerkleProof(leaves, n)
+			if !VerifyMerkleProof(root, leaves[n], n, count, proof) {
+				t.Fatalf("valid proof rejected: leaf %v of %v", n, count)
+			}
+			if VerifyMerkleProof(root, []byte{byte(n + 1), byte(count)}, n, count, proof) {
+				t.Fatalf("invalid leaf accepted: leaf %v of %v", n, count)
+			}
+		}
+	}
+	if MerkleRoot(nil) != ZeroHash {
+		t.Error("wrong root for empty tree")
+	}
+}
@@ -3,6 +3,7 @@ package instructions
 import (
 	"github.com/Aereum/aereum/core/crypto"
 	"github.com/Aereum/aereum/core/crypto/dh"
+	"github.com/Aereum/aereum/core/store"
 	"github.com/Aereum/aereum/core/util"
 )
 
@@ -83,10 +84,69 @@ func (a *Author) NewUpdateInfo(details string, epoch, fee uint64) *UpdateInfo {
 	return nil
 }
 
+func (a *Author) NewChangeCaption(caption string, epoch, fee uint64) *ChangeCaption {
+	change := ChangeCaption{
+		Authored: a.NewAuthored(epoch, fee),
+		Caption:  caption,
+	}
+	bulk := change.serializeBulk()
+	if a.sign(change.Authored, bulk, IChangeCaption) {
+		return &change
+	}
+	return nil
+}
+
+func (a *Author) NewTransferCaption(caption string, recipient crypto.Token, epoch, fee uint64) *TransferCaption {
+	transfer := TransferCaption{
+		Authored:  a.NewAuthored(epoch, fee),
+		Caption:   caption,
+		Recipient: recipient,
+	}
+	bulk := transfer.serializeBulk()
+	if a.sign(transfer.Authored, bulk, ITransferCaption) {
+		return &transfer
+	}
+	return nil
+}
+
+func (a *Author) NewSetGuardians(guardians []crypto.Token, threshold byte, epoch, fee uint64) *SetGuardians {
+	set := SetGuardians{
+		Authored:  a.NewAuthored(epoch, fee),
+		Guardians: guardians,
+		Threshold: threshold,
+	}
+	bulk := set.serializeBulk()
+	if a.sign(set.Authored, bulk, ISetGuardians) {
+		return &set
+	}
+	return nil
+}
+
+func (a *Author) NewCancelRecovery(token crypto.Token, epoch, fee uint64) *CancelRecovery {
+	cancel := CancelRecovery{
+		Authored: a.NewAuthored(epoch, fee),
+		Token:    token,
+	}
+	bulk := cancel.serializeBulk()
+	if a.sign(cancel.Authored, bulk, ICancelRecovery) {
+		return &cancel
+	}
+	return nil
+}
+
+// NewGrantPowerOfAttorney grants attorney power to sign any instruction on
+// behalf of the author without limits.
 func (a *Author) NewGrantPowerOfAttorney(attorney crypto.Token, epoch, fee uint64) *GrantPowerOfAttorney {
+	return a.NewScopedPowerOfAttorney(attorney, store.AttorneyScope{Kinds: AllKinds}, epoch, fee)
+}
+
+// NewScopedPowerOfAttorney grants attorney power to sign instructions on
+// behalf of the author within scope.
+func (a *Author) NewScopedPowerOfAttorney(attorney crypto.Token, scope store.AttorneyScope, epoch, fee uint64) *GrantPowerOfAttorney {
 	grant := GrantPowerOfAttorney{
 		Authored: a.NewAuthored(epoch, fee),
 		Attorney: attorney,
+		Scope:    scope,
 	}
 	bulk := grant.serializeBulk()
 	if a.sign(grant.Authored, bulk, IGrantPowerOfAttorney) {
@@ -314,6 +374,38 @@ func (a *Author) NewContent(audience *Stage, contentType string, message []byte,
 	return content
 }
 
+// NewSponsoredContent publishes the content of an accepted sponsorship offer
+// on its stage. The author must be the author of the offer.
+func (a *Author) NewSponsoredContent(offer *SponsorshipOffer, epoch, fee uint64) *Content {
+	if offer == nil {
+		return nil
+	}
+	content := &Content{
+		epoch:       epoch,
+		Published:   epoch,
+		Author:      a.PrivateKey.PublicKey(),
+		Audience:    offer.Stage,
+		ContentType: offer.ContentType,
+		Content:     offer.Content,
+		Hash:        []byte{},
+		Sponsored:   true,
+		Attorney:    a.Attorney.PublicKey(),
+		Wallet:      a.Wallet.PublicKey(),
+		Fee:         fee,
+	}
+	if a.Attorney != crypto.ZeroPrivateKey {
+		content.Signature = a.Attorney.Sign(content.serializeSignBulk())
+	} else {
+		content.Signature = a.PrivateKey.Sign(content.serializeSignBulk())
+	}
+	if a.Wallet != crypto.ZeroPrivateKey {
+		content.WalletSignature = a.Wallet.Sign(content.serializeWalletBulk())
+	} else {
+		content.WalletSignature = a.PrivateKey.Sign(content.serializeWalletBulk())
+	}
+	return content
+}
+
 func (a *Author) NewReact(hash []byte, reaction byte, epoch, fee uint64) *React {
 	react := React{
 		Authored: a.NewAuthored(epoch, fee),
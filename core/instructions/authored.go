@@ -24,11 +24,48 @@ func (a *AuthoredInstruction) authorHash() crypto.Hash {
 	return crypto.Hasher(a.Author[:])
 }
 
+// validateAttorney checks that the attorney signing on behalf of author, if
+// any, holds a power of attorney whose scope covers an instruction of kind
+// addressed to audience and paying payments. The spending is recorded against
+// the cap of the power of attorney.
+func validateAttorney(v InstructionValidator, author, attorney crypto.Token, kind byte, audience crypto.Token, payments *Payment) error {
+	if attorney == crypto.ZeroToken {
+		return nil
+	}
+	hash := crypto.Hasher(append(author[:], attorney[:]...))
+	scope := v.AttorneyScope(hash)
+	if scope == nil {
+		return ErrNoPowerOfAttorney
+	}
+	if scope.Kinds&KindsMask(kind) == 0 {
+		return ErrAttorneyScope
+	}
+	if scope.Expire != 0 && scope.Expire < v.Epoch() {
+		return ErrAttorneyExpired
+	}
+	if scope.Audience != crypto.ZeroToken && scope.Audience != audience {
+		return ErrAttorneyScope
+	}
+	if scope.Limit != 0 {
+		spent, ok := payments.Debits()
+		if !ok || !v.SetAttorneySpending(hash, spent, scope.Limit) {
+			return ErrAttorneyLimit
+		}
+	}
+	return nil
+}
+
+// validateAttorney checks the power of attorney of instruction addressed to
+// audience, if it is signed by an attorney.
+func (a *AuthoredInstruction) validateAttorney(v InstructionValidator, instruction Instruction, audience crypto.Token) error {
+	return validateAttorney(v, a.Author, a.Attorney, instruction.Kind(), audience, instruction.Payments())
+}
+
 func (a *AuthoredInstruction) payments() *Payment {
-	if len(a.Wallet) > 0 {
+	if a.Wallet != crypto.ZeroToken {
 		return NewPayment(crypto.Hasher(a.Wallet[:]), a.Fee)
 	}
-	if len(a.Attorney) > 0 {
+	if a.Attorney != crypto.ZeroToken {
 		return NewPayment(crypto.Hasher(a.Attorney[:]), a.Fee)
 	}
 	return NewPayment(crypto.Hasher(a.Author[:]), a.Fee)
@@ -36,6 +36,22 @@
 		CreateEphemeral
 		SecureChannel
 		React
+		Delegate
+		Undelegate
+		ChangeCaption
+		TransferCaption
+		RotateIdentity
+		SetGuardians
+		RecoverIdentity
+		CancelRecovery
+		CompleteRecovery
+		CreateMultisig
+		MultisigTransfer
+		EscrowTransfer
+		CancelEscrow
+		DenounceCheckpoint
+		DenounceChecksum
+		DenounceInstruction
 
 	Each instruction has its canonical binary encoding rules implemented.
 
@@ -0,0 +1,64 @@
+package instructions
+
+import "errors"
+
+// Errors returned by the validation of instructions.
+var (
+	ErrInsufficientFunds      = errors.New("insufficient funds")
+	ErrInsufficientStake      = errors.New("insufficient stake")
+	ErrOverflow               = errors.New("payment amounts overflow")
+	ErrNotValidator           = errors.New("token has no stake to validate")
+	ErrInsufficientDelegation = errors.New("insufficient delegated stake")
+	ErrNotMember              = errors.New("author is not a member")
+	ErrAttorneyNotMember      = errors.New("attorney is not a member")
+	ErrAlreadyMember          = errors.New("token is already a member")
+	ErrCaptionTaken           = errors.New("caption is already taken")
+	ErrNotCaptionOwner        = errors.New("author does not own the caption")
+	ErrRecipientNotMember     = errors.New("recipient must be another member")
+	ErrAttorneyNotAllowed     = errors.New("instruction must be signed by the member key")
+	ErrInvalidGuardians       = errors.New("invalid guardians or threshold")
+	ErrGuardiansMismatch      = errors.New("guardians do not match the member guardians")
+	ErrInsufficientGuardians  = errors.New("not enough guardian signatures")
+	ErrRecoveryPending        = errors.New("recovery is already pending")
+	ErrNoRecovery             = errors.New("recovery not found")
+	ErrRecoveryDelay          = errors.New("recovery delay has not elapsed")
+	ErrIncompleteRotation     = errors.New("every power of attorney granted and stage owned must be moved")
+	ErrInvalidSigners         = errors.New("invalid multisig signers or threshold")
+	ErrMultisigExists         = errors.New("wallet is already a multisig wallet")
+	ErrMultisigMismatch       = errors.New("signers do not match the multisig wallet")
+	ErrInsufficientSignatures = errors.New("not enough multisig signatures")
+	ErrMultisigWallet         = errors.New("multisig wallet can only be debited by a multisig transfer")
+	ErrInvalidSchedule        = errors.New("invalid escrow value, installments or period")
+	ErrNoEscrow               = errors.New("escrow not found")
+	ErrNotEscrowPayer         = errors.New("only the payer can cancel the escrow")
+	ErrEscrowReleased         = errors.New("escrow is due for release")
+	ErrInvalidEvidence        = errors.New("evidence does not prove misbehaviour")
+	ErrAlreadySlashed         = errors.New("validator has already been slashed")
+	ErrInvalidDetails         = errors.New("details are not valid json")
+	ErrFutureEpoch            = errors.New("instruction epoch is ahead of block epoch")
+	ErrExpired                = errors.New("expire epoch has already passed")
+	ErrStageExists            = errors.New("stage already exists")
+	ErrUnknownStage           = errors.New("stage not found")
+	ErrNotStageOwner          = errors.New("author does not own the stage")
+	ErrNotModerated           = errors.New("stage has no moderator")
+	ErrInvalidModSignature    = errors.New("invalid moderator signature")
+	ErrInvalidSubSignature    = errors.New("invalid submitter signature")
+	ErrPowerOfAttorneyExists  = errors.New("power of attorney already granted")
+	ErrNoPowerOfAttorney      = errors.New("power of attorney not granted")
+	ErrInvalidScope           = errors.New("power of attorney allows no instruction")
+	ErrAttorneyScope          = errors.New("instruction outside the scope of the power of attorney")
+	ErrAttorneyExpired        = errors.New("power of attorney has expired")
+	ErrAttorneyLimit          = errors.New("power of attorney spending cap reached for the epoch")
+	ErrEphemeralExists        = errors.New("ephemeral token is already active")
+	ErrNoEphemeral            = errors.New("ephemeral token not found or expired")
+	ErrInvalidTokenRange      = errors.New("invalid token range")
+	ErrUnknownSponsorOffer    = errors.New("sponsorship offer not found")
+	ErrNoSponsorship          = errors.New("sponsorship not granted")
+	ErrSponsoredEncrypted     = errors.New("sponsored content cannot be encrypted")
+	ErrSponsoredSigned        = errors.New("sponsored content cannot be signed by stage keys")
+	ErrSponsoredContent       = errors.New("content does not match sponsorship offer")
+	ErrConflictingInstruction = errors.New("conflicting instruction already in block")
+	ErrUnknownInstruction     = errors.New("unknown or malformed instruction")
+	ErrTooOld                 = errors.New("instruction epoch is too old for block epoch")
+	ErrDuplicateInstruction   = errors.New("instruction already included")
+)
@@ -24,27 +24,88 @@ const (
 	ICreateEphemeral
 	ISecureChannel
 	IReact
+	IDelegate
+	IUndelegate
+	IChangeCaption
+	ITransferCaption
+	IRotateIdentity
+	ISetGuardians
+	IRecoverIdentity
+	ICancelRecovery
+	ICompleteRecovery
+	ICreateMultisig
+	IMultisigTransfer
+	IEscrowTransfer
+	ICancelEscrow
+	IDenounceCheckpoint
+	IDenounceChecksum
+	IDenounceInstruction
 	iUnkown
 )
 
+// AllKinds allows every instruction kind on the scope of a power of attorney.
+const AllKinds = ^uint64(0)
+
+// KindsMask returns the bitmask allowing kinds on the scope of a power of
+// attorney.
+func KindsMask(kinds ...byte) uint64 {
+	var mask uint64
+	for _, kind := range kinds {
+		if kind < 64 {
+			mask |= 1 << kind
+		}
+	}
+	return mask
+}
+
 type InstructionValidator interface {
-	SetNewGrantPower(hash crypto.Hash) bool
-	SetNewRevokePower(hash crypto.Hash) bool
+	SetNewGrantPower(hash crypto.Hash, scope store.AttorneyScope, author crypto.Token) bool
+	SetAttorneySpending(hash crypto.Hash, value, limit uint64) bool
+	SetNewRevokePower(hash crypto.Hash, author crypto.Token) bool
 	SetNewUseSpnOffer(hash crypto.Hash) bool
 	SetNewSpnOffer(hash crypto.Hash, expire uint64) bool
+	SetNewGrantSponsor(hash, contentHash crypto.Hash) bool
 	SetPublishSponsor(hash crypto.Hash) bool
 	SetNewEphemeralToken(hash crypto.Hash, expire uint64) bool
-	SetNewMember(tokenHash crypto.Hash, captionHashe crypto.Hash) bool
-	SetNewAudience(hash crypto.Hash, stage store.StageKeys) bool
+	SetNewMember(token crypto.Token, captionHash crypto.Hash) bool
+	SetNewCaption(token crypto.Token, captionHash crypto.Hash) bool
+	SetCaptionTransfer(from, to crypto.Token, captionHash crypto.Hash) bool
+	SetNewRotation(from, to crypto.Token, attorneys []crypto.Token, stages []crypto.Hash) bool
+	SetNewAudience(hash crypto.Hash, stage store.StageKeys, owner crypto.Token) bool
+	SetNewGuardians(member crypto.Token, guardians crypto.Hash) bool
+	SetNewRecovery(member, to crypto.Token) bool
+	SetCancelRecovery(member, to crypto.Token) bool
+	SetCompleteRecovery(member, to crypto.Token, attorneys []crypto.Token, stages []crypto.Hash) bool
+	SetNewMultisig(hash crypto.Hash, policy crypto.Hash) bool
+	SetNewEscrow(hash crypto.Hash, escrow store.Escrow, release, value uint64) bool
+	SetCancelEscrow(hash crypto.Hash) bool
+	SetNewSlash(offender crypto.Token) bool
 	UpdateAudience(hash crypto.Hash, stage store.StageKeys) bool
 	Balance(hash crypto.Hash) uint64
+	Holdings(hash crypto.Hash) uint64
 	PowerOfAttorney(hash crypto.Hash) bool
+	AttorneyScope(hash crypto.Hash) *store.AttorneyScope
 	SponsorshipOffer(hash crypto.Hash) uint64
 	HasMember(hash crypto.Hash) bool
 	HasCaption(hash crypto.Hash) bool
+	CaptionOwner(hash crypto.Hash) (bool, crypto.Token)
+	MemberCaption(hash crypto.Hash) (bool, crypto.Hash)
+	StageOwner(hash crypto.Hash) (bool, crypto.Token)
+	Guardians(hash crypto.Hash) (bool, crypto.Hash)
+	Recovery(member, to crypto.Token) uint64
+	Multisig(hash crypto.Hash) (bool, crypto.Hash)
+	Escrow(hash crypto.Hash) *store.Escrow
+	EscrowRelease(hash crypto.Hash) uint64
+	Slashed(hash crypto.Hash) bool
 	HasGrantedSponser(hash crypto.Hash) (bool, crypto.Hash)
 	GetAudienceKeys(hash crypto.Hash) *store.StageKeys
 	GetEphemeralExpire(hash crypto.Hash) (bool, uint64)
+	Stake(hash crypto.Hash) uint64
+	SetNewDeposit(token crypto.Token, value uint64) bool
+	SetNewWithdraw(hash crypto.Hash, value uint64) bool
+	Delegated(delegator, validator crypto.Token) uint64
+	SetNewDelegation(delegator, validator crypto.Token, value uint64) bool
+	SetNewUndelegation(delegator, validator crypto.Token, value uint64) bool
 	AddFeeCollected(uint64)
 	Epoch() uint64
 }
@@ -60,8 +121,9 @@ type Wallet struct {
 }
 
 type Payment struct {
-	Debit  []Wallet
-	Credit []Wallet
+	Debit    []Wallet
+	Credit   []Wallet
+	overflow bool
 }
 
 func GetEpochFromByteArray(inst []byte) uint64 {
@@ -76,28 +138,57 @@ func NewPayment(debitAcc crypto.Hash, value uint64) *Payment {
 	}
 }
 
-func (p *Payment) NewCredit(account crypto.Hash, value uint64) {
-	for _, credit := range p.Credit {
-		if credit.Account.Equal(account) {
-			credit.FungibleTokens += value
-			return
+// addToWallets adds value to the wallet of account, appending a new wallet if
+// account is not found. It returns false if the value of the wallet overflows.
+func addToWallets(wallets []Wallet, account crypto.Hash, value uint64) ([]Wallet, bool) {
+	for n := range wallets {
+		if wallets[n].Account.Equal(account) {
+			total, ok := util.AddUint64(wallets[n].FungibleTokens, value)
+			if !ok {
+				return wallets, false
+			}
+			wallets[n].FungibleTokens = total
+			return wallets, true
 		}
 	}
-	p.Credit = append(p.Credit, Wallet{Account: account, FungibleTokens: value})
+	return append(wallets, Wallet{Account: account, FungibleTokens: value}), true
+}
+
+func (p *Payment) NewCredit(account crypto.Hash, value uint64) {
+	var ok bool
+	if p.Credit, ok = addToWallets(p.Credit, account, value); !ok {
+		p.overflow = true
+	}
 }
 
 func (p *Payment) NewDebit(account crypto.Hash, value uint64) {
-	for _, debit := range p.Debit {
-		if debit.Account.Equal(account) {
-			debit.FungibleTokens += value
-			return
+	var ok bool
+	if p.Debit, ok = addToWallets(p.Debit, account, value); !ok {
+		p.overflow = true
+	}
+}
+
+// Overflows returns true if the total credited or debited to an account does
+// not fit in an uint64.
+func (p *Payment) Overflows() bool {
+	return p.overflow
+}
+
+// Debits returns the sum of every debit of the payment. It returns false if
+// the sum overflows.
+func (p *Payment) Debits() (uint64, bool) {
+	total := uint64(0)
+	for _, wallet := range p.Debit {
+		var ok bool
+		if total, ok = util.AddUint64(total, wallet.FungibleTokens); !ok {
+			return 0, false
 		}
 	}
-	p.Debit = append(p.Debit, Wallet{Account: account, FungibleTokens: value})
+	return total, true
 }
 
 type Instruction interface {
-	Validate(InstructionValidator) bool
+	Validate(InstructionValidator) error
 	Payments() *Payment
 	Serialize() []byte
 	Epoch() uint64
@@ -106,6 +197,20 @@ type Instruction interface {
 	Authority() crypto.Token
 }
 
+// ReplayHash returns the hash under which the inclusion of instruction is
+// recorded to reject its replay. Instructions signed by any threshold of a set
+// of signers are identified by their content without signatures, so that
+// adding or dropping signatures does not make a new instruction.
+func ReplayHash(instruction Instruction) crypto.Hash {
+	switch v := instruction.(type) {
+	case *MultisigTransfer:
+		return crypto.Hasher(v.serializeWithoutSignatures())
+	case *RecoverIdentity:
+		return crypto.Hasher(v.serializeWithoutSignatures())
+	}
+	return crypto.Hasher(instruction.Serialize())
+}
+
 func ParseInstruction(data []byte) Instruction {
 	if data[0] != 0 {
 		return nil
@@ -145,6 +250,38 @@ func ParseInstruction(data []byte) Instruction {
 		return ParseSecureChannel(data)
 	case IReact:
 		return ParseReact(data)
+	case IDelegate:
+		return ParseDelegate(data)
+	case IUndelegate:
+		return ParseUndelegate(data)
+	case IChangeCaption:
+		return ParseChangeCaption(data)
+	case ITransferCaption:
+		return ParseTransferCaption(data)
+	case IRotateIdentity:
+		return ParseRotateIdentity(data)
+	case ISetGuardians:
+		return ParseSetGuardians(data)
+	case IRecoverIdentity:
+		return ParseRecoverIdentity(data)
+	case ICancelRecovery:
+		return ParseCancelRecovery(data)
+	case ICompleteRecovery:
+		return ParseCompleteRecovery(data)
+	case ICreateMultisig:
+		return ParseCreateMultisig(data)
+	case IMultisigTransfer:
+		return ParseMultisigTransfer(data)
+	case IEscrowTransfer:
+		return ParseEscrowTransfer(data)
+	case ICancelEscrow:
+		return ParseCancelEscrow(data)
+	case IDenounceCheckpoint:
+		return ParseDenounceCheckpoint(data)
+	case IDenounceChecksum:
+		return ParseDenounceChecksum(data)
+	case IDenounceInstruction:
+		return ParseDenounceInstruction(data)
 	}
 	return nil
 }
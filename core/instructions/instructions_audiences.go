@@ -23,22 +23,28 @@ func (a *CreateStage) Epoch() uint64 {
 	return a.Authored.epoch
 }
 
-func (stage *CreateStage) Validate(v InstructionValidator) bool {
+func (stage *CreateStage) Validate(v InstructionValidator) error {
 	if !v.HasMember(stage.Authored.authorHash()) {
-		return false
+		return ErrNotMember
+	}
+	if err := stage.Authored.validateAttorney(v, stage, stage.Audience); err != nil {
+		return err
 	}
 	audienceHash := crypto.HashToken(stage.Audience)
 	if stage := v.GetAudienceKeys(audienceHash); stage != nil {
-		return false
+		return ErrStageExists
 	}
-	v.AddFeeCollected(stage.Authored.Fee)
 	stageKeys := store.StageKeys{
 		Moderate: stage.Moderation,
 		Submit:   stage.Submission,
 		Stage:    stage.Audience,
 		Flag:     stage.Flag,
 	}
-	return v.SetNewAudience(audienceHash, stageKeys)
+	if !v.SetNewAudience(audienceHash, stageKeys, stage.Authored.Author) {
+		return ErrConflictingInstruction
+	}
+	v.AddFeeCollected(stage.Authored.Fee)
+	return nil
 }
 
 func (stage *CreateStage) Payments() *Payment {
@@ -97,15 +103,18 @@ func (a *JoinStage) Epoch() uint64 {
 	return a.Authored.epoch
 }
 
-func (join *JoinStage) Validate(v InstructionValidator) bool {
+func (join *JoinStage) Validate(v InstructionValidator) error {
 	if !v.HasMember(join.Authored.authorHash()) {
-		return false
+		return ErrNotMember
+	}
+	if err := join.Authored.validateAttorney(v, join, join.Audience); err != nil {
+		return err
 	}
 	if keys := v.GetAudienceKeys(crypto.HashToken(join.Audience)); keys == nil {
-		return false
+		return ErrUnknownStage
 	}
 	v.AddFeeCollected(join.Authored.Fee)
-	return true
+	return nil
 }
 
 func (join *JoinStage) Payments() *Payment {
@@ -164,21 +173,27 @@ func (a *AcceptJoinStage) Epoch() uint64 {
 	return a.Authored.epoch
 }
 
-func (accept *AcceptJoinStage) Validate(v InstructionValidator) bool {
+func (accept *AcceptJoinStage) Validate(v InstructionValidator) error {
 	if !v.HasMember(accept.Authored.authorHash()) {
-		return false
+		return ErrNotMember
+	}
+	if err := accept.Authored.validateAttorney(v, accept, accept.Stage); err != nil {
+		return err
 	}
 	keys := v.GetAudienceKeys(crypto.HashToken(accept.Stage))
-	if keys == nil || keys.Moderate == crypto.ZeroToken {
-		return false
+	if keys == nil {
+		return ErrUnknownStage
+	}
+	if keys.Moderate == crypto.ZeroToken {
+		return ErrNotModerated
 	}
 	if !keys.Moderate.Verify(accept.serializeModBulk(), accept.modSignature) {
-		return false
+		return ErrInvalidModSignature
 	}
 	//hashed := crypto.Hasher(accept.Serialize())
 	//if bytes.Equal(keys[0:crypto.Size], hashed[:]) {
 	v.AddFeeCollected(accept.Authored.Fee)
-	return true
+	return nil
 	//}
 	//return false
 }
@@ -311,22 +326,28 @@ func (a *UpdateStage) Epoch() uint64 {
 	return a.Authored.epoch
 }
 
-func (update *UpdateStage) Validate(v InstructionValidator) bool {
+func (update *UpdateStage) Validate(v InstructionValidator) error {
 	if !v.HasMember(update.Authored.authorHash()) {
-		return false
+		return ErrNotMember
+	}
+	if err := update.Authored.validateAttorney(v, update, update.Stage); err != nil {
+		return err
 	}
 	hashed := crypto.HashToken(update.Stage)
+	if ok, owner := v.StageOwner(hashed); !ok || owner != update.Authored.Author {
+		return ErrNotStageOwner
+	}
 	stageKeys := store.StageKeys{
 		Moderate: update.Moderation,
 		Submit:   update.Submission,
 		Stage:    update.Stage,
 		Flag:     update.Flag,
 	}
-	if v.UpdateAudience(hashed, stageKeys) {
-		v.AddFeeCollected(update.Authored.Fee)
-		return true
+	if !v.UpdateAudience(hashed, stageKeys) {
+		return ErrConflictingInstruction
 	}
-	return false
+	v.AddFeeCollected(update.Authored.Fee)
+	return nil
 }
 
 func (update *UpdateStage) Payments() *Payment {
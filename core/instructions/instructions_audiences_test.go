@@ -10,7 +10,7 @@ import (
 )
 
 var (
-	audienceTest *Stage = NewStage(0, "teste")
+	audienceTest *Stage = NewStage(EncryptedStage, "teste")
 )
 
 func TestCreateteAudience(t *testing.T) {
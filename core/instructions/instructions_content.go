@@ -34,46 +34,52 @@ func (a *Content) Epoch() uint64 {
 	return a.epoch
 }
 
-func (content *Content) Validate(v InstructionValidator) bool {
+func (content *Content) Validate(v InstructionValidator) error {
 	if content.epoch > v.Epoch() {
-		return false
+		return ErrFutureEpoch
 	}
 	if !v.HasMember(crypto.HashToken(content.Author)) {
-		return false
+		return ErrNotMember
+	}
+	if err := validateAttorney(v, content.Author, content.Attorney, IContent, content.Audience, content.Payments()); err != nil {
+		return err
 	}
 	audienceHash := crypto.HashToken(content.Audience)
 	stageKeys := v.GetAudienceKeys(audienceHash)
 	if stageKeys == nil {
-		return false
+		return ErrUnknownStage
 	}
 	if content.Sponsored {
 		if content.Encrypted {
-			return false
+			return ErrSponsoredEncrypted
 		}
-		if len(content.SubSignature) != 0 || len(content.ModSignature) != 0 {
-			return false
+		if content.SubSignature != (crypto.Signature{}) || content.ModSignature != (crypto.Signature{}) {
+			return ErrSponsoredSigned
 		}
 		hash := crypto.Hasher(append(content.Author[:], content.Audience[:]...))
 		ok, contentHash := v.HasGrantedSponser(hash)
 		if !ok {
-			return false
+			return ErrNoSponsorship
 		}
 		if !crypto.Hasher(content.Content).Equal(contentHash) {
-			return false
+			return ErrSponsoredContent
+		}
+		if !v.SetPublishSponsor(hash) {
+			return ErrConflictingInstruction
 		}
 		v.AddFeeCollected(content.Fee)
-		return v.SetPublishSponsor(hash)
+		return nil
 	}
 	if !stageKeys.Submit.Verify(content.serializeSubBulk()[10:], content.SubSignature) {
-		return false
+		return ErrInvalidSubSignature
 	}
 	if content.Moderator != crypto.ZeroToken {
 		if !stageKeys.Moderate.Verify(content.serializeModBulk(), content.ModSignature) {
-			return false
+			return ErrInvalidModSignature
 		}
 	}
 	v.AddFeeCollected(content.Fee)
-	return true
+	return nil
 }
 
 func (a *Content) Payments() *Payment {
@@ -193,12 +199,15 @@ func (a *React) Epoch() uint64 {
 	return a.Authored.epoch
 }
 
-func (react *React) Validate(v InstructionValidator) bool {
-	if v.HasMember(react.Authored.authorHash()) {
-		v.AddFeeCollected(react.Authored.Fee)
-		return true
+func (react *React) Validate(v InstructionValidator) error {
+	if !v.HasMember(react.Authored.authorHash()) {
+		return ErrNotMember
 	}
-	return false
+	if err := react.Authored.validateAttorney(v, react, crypto.ZeroToken); err != nil {
+		return err
+	}
+	v.AddFeeCollected(react.Authored.Fee)
+	return nil
 }
 
 func (react *React) Payments() *Payment {
@@ -206,7 +215,7 @@ func (react *React) Payments() *Payment {
 }
 
 func (react *React) Kind() byte {
-	return IContent
+	return IReact
 }
 
 func (react *React) serializeBulk() []byte {
@@ -0,0 +1,451 @@
+package instructions
+
+import (
+	"time"
+
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/util"
+)
+
+// kinds of the messages signed by validators. They start with a one byte, so
+// that they never collide with serialized instructions.
+const (
+	voteMessage byte = iota
+	checksumMessage
+)
+
+// VoteMessage returns the message signed by a validator endorsing the block of
+// epoch with hash.
+func VoteMessage(epoch uint64, hash crypto.Hash) []byte {
+	bytes := []byte{1, voteMessage}
+	util.PutUint64(epoch, &bytes)
+	util.PutByteArray(hash[:], &bytes)
+	return bytes
+}
+
+// ChecksumMessage returns the message signed by a validator attesting the
+// state checksum of epoch.
+func ChecksumMessage(epoch uint64, checksum crypto.Hash) []byte {
+	bytes := []byte{1, checksumMessage}
+	util.PutUint64(epoch, &bytes)
+	util.PutByteArray(checksum[:], &bytes)
+	return bytes
+}
+
+// BlockHeader holds every field of a block other than its instructions, which
+// are committed by the instructions root and count. The hash of a block is the
+// hash of its serialized header.
+type BlockHeader struct {
+	Epoch            uint64
+	Parent           crypto.Hash
+	CheckPoint       uint64
+	Publisher        crypto.Token
+	PublishedAt      time.Time
+	Count            uint16
+	InstructionsRoot crypto.Hash
+	FeesCollected    uint64
+	Minted           uint64
+	StateRoot        crypto.Hash
+}
+
+func (h *BlockHeader) Serialize() []byte {
+	bytes := make([]byte, 0)
+	util.PutUint64(h.Epoch, &bytes)
+	util.PutByteArray(h.Parent[:], &bytes)
+	util.PutUint64(h.CheckPoint, &bytes)
+	util.PutToken(h.Publisher, &bytes)
+	util.PutTime(h.PublishedAt, &bytes)
+	util.PutUint16(h.Count, &bytes)
+	util.PutByteArray(h.InstructionsRoot[:], &bytes)
+	util.PutUint64(h.FeesCollected, &bytes)
+	util.PutUint64(h.Minted, &bytes)
+	util.PutByteArray(h.StateRoot[:], &bytes)
+	return bytes
+}
+
+// ParseBlockHeader parses a serialized block header. It returns nil if data is
+// not exactly a header.
+func ParseBlockHeader(data []byte) *BlockHeader {
+	h := BlockHeader{}
+	position := 0
+	h.Epoch, position = util.ParseUint64(data, position)
+	h.Parent, position = util.ParseHash(data, position)
+	h.CheckPoint, position = util.ParseUint64(data, position)
+	h.Publisher, position = util.ParseToken(data, position)
+	h.PublishedAt, position = util.ParseTime(data, position)
+	h.Count, position = util.ParseUint16(data, position)
+	h.InstructionsRoot, position = util.ParseHash(data, position)
+	h.FeesCollected, position = util.ParseUint64(data, position)
+	h.Minted, position = util.ParseUint64(data, position)
+	h.StateRoot, position = util.ParseHash(data, position)
+	if position != len(data) {
+		return nil
+	}
+	return &h
+}
+
+// Conflict holds the signatures of a token on two different hashes for the
+// same epoch.
+type Conflict struct {
+	Epoch           uint64
+	First           crypto.Hash
+	FirstSignature  crypto.Signature
+	Second          crypto.Hash
+	SecondSignature crypto.Signature
+}
+
+// NewConflict returns the conflict of the messages of epoch for the hashes
+// first and second signed by token.
+func NewConflict(token crypto.PrivateKey, message func(uint64, crypto.Hash) []byte, epoch uint64, first, second crypto.Hash) Conflict {
+	return Conflict{
+		Epoch:           epoch,
+		First:           first,
+		FirstSignature:  token.Sign(message(epoch, first)),
+		Second:          second,
+		SecondSignature: token.Sign(message(epoch, second)),
+	}
+}
+
+// verify checks that offender signed the message of both hashes.
+func (c *Conflict) verify(offender crypto.Token, message func(uint64, crypto.Hash) []byte) bool {
+	return c.First != c.Second &&
+		offender.Verify(message(c.Epoch, c.First), c.FirstSignature) &&
+		offender.Verify(message(c.Epoch, c.Second), c.SecondSignature)
+}
+
+func (c *Conflict) serialize(data *[]byte) {
+	util.PutUint64(c.Epoch, data)
+	util.PutByteArray(c.First[:], data)
+	util.PutSignature(c.FirstSignature, data)
+	util.PutByteArray(c.Second[:], data)
+	util.PutSignature(c.SecondSignature, data)
+}
+
+func parseConflict(data []byte, position int) (Conflict, int) {
+	c := Conflict{}
+	c.Epoch, position = util.ParseUint64(data, position)
+	c.First, position = util.ParseHash(data, position)
+	c.FirstSignature, position = util.ParseSignature(data, position)
+	c.Second, position = util.ParseHash(data, position)
+	c.SecondSignature, position = util.ParseSignature(data, position)
+	return c, position
+}
+
+func putHashes(hashes []crypto.Hash, data *[]byte) {
+	util.PutUint16(uint16(len(hashes)), data)
+	for _, hash := range hashes {
+		util.PutByteArray(hash[:], data)
+	}
+}
+
+func parseHashes(data []byte, position int) ([]crypto.Hash, int) {
+	length, position := util.ParseUint16(data, position)
+	if length == 0 {
+		return nil, position
+	}
+	hashes := make([]crypto.Hash, length)
+	for n := range hashes {
+		hashes[n], position = util.ParseHash(data, position)
+	}
+	return hashes, position
+}
+
+// slash validates the punishment of offender, denounced by an instruction
+// paying fee.
+func slash(v InstructionValidator, offender crypto.Token, fee uint64) error {
+	hash := crypto.HashToken(offender)
+	if v.Slashed(hash) {
+		return ErrAlreadySlashed
+	}
+	if v.Stake(hash) == 0 {
+		return ErrNotValidator
+	}
+	if !v.SetNewSlash(offender) {
+		return ErrConflictingInstruction
+	}
+	v.AddFeeCollected(fee)
+	return nil
+}
+
+func NewDenounceCheckpoint(author crypto.PrivateKey, offender crypto.Token, conflict Conflict, epoch, fee uint64) *DenounceCheckpoint {
+	denounce := &DenounceCheckpoint{
+		epoch:    epoch,
+		Author:   author.PublicKey(),
+		Offender: offender,
+		Conflict: conflict,
+		Fee:      fee,
+	}
+	denounce.Signature = author.Sign(denounce.serializeWithoutSignature())
+	return denounce
+}
+
+// DenounceCheckpoint proves that a validator signed two different blocks of
+// the same epoch.
+type DenounceCheckpoint struct {
+	epoch     uint64
+	Author    crypto.Token
+	Offender  crypto.Token
+	Conflict  Conflict
+	Fee       uint64
+	Signature crypto.Signature
+}
+
+func (d *DenounceCheckpoint) Payments() *Payment {
+	return NewPayment(crypto.HashToken(d.Author), d.Fee)
+}
+
+func (a *DenounceCheckpoint) Authority() crypto.Token {
+	return crypto.ZeroToken
+}
+
+func (d *DenounceCheckpoint) Validate(v InstructionValidator) error {
+	if !d.Conflict.verify(d.Offender, VoteMessage) {
+		return ErrInvalidEvidence
+	}
+	return slash(v, d.Offender, d.Fee)
+}
+
+func (a *DenounceCheckpoint) Kind() byte {
+	return IDenounceCheckpoint
+}
+
+func (a *DenounceCheckpoint) Epoch() uint64 {
+	return a.epoch
+}
+
+func (d *DenounceCheckpoint) serializeWithoutSignature() []byte {
+	bytes := []byte{0, IDenounceCheckpoint}
+	util.PutUint64(d.epoch, &bytes)
+	util.PutToken(d.Author, &bytes)
+	util.PutToken(d.Offender, &bytes)
+	d.Conflict.serialize(&bytes)
+	util.PutUint64(d.Fee, &bytes)
+	return bytes
+}
+
+func (d *DenounceCheckpoint) Serialize() []byte {
+	bytes := d.serializeWithoutSignature()
+	util.PutSignature(d.Signature, &bytes)
+	return bytes
+}
+
+func ParseDenounceCheckpoint(data []byte) *DenounceCheckpoint {
+	if len(data) < 2 || data[1] != IDenounceCheckpoint {
+		return nil
+	}
+	p := DenounceCheckpoint{}
+	position := 2
+	p.epoch, position = util.ParseUint64(data, position)
+	p.Author, position = util.ParseToken(data, position)
+	p.Offender, position = util.ParseToken(data, position)
+	p.Conflict, position = parseConflict(data, position)
+	p.Fee, position = util.ParseUint64(data, position)
+	msgToVerify := data[0:position]
+	p.Signature, _ = util.ParseSignature(data, position)
+	if !p.Author.Verify(msgToVerify, p.Signature) {
+		return nil
+	}
+	return &p
+}
+
+func NewDenounceChecksum(author crypto.PrivateKey, offender crypto.Token, conflict Conflict, epoch, fee uint64) *DenounceChecksum {
+	denounce := &DenounceChecksum{
+		epoch:    epoch,
+		Author:   author.PublicKey(),
+		Offender: offender,
+		Conflict: conflict,
+		Fee:      fee,
+	}
+	denounce.Signature = author.Sign(denounce.serializeWithoutSignature())
+	return denounce
+}
+
+// DenounceChecksum proves that a validator signed two different state
+// checksums of the same epoch.
+type DenounceChecksum struct {
+	epoch     uint64
+	Author    crypto.Token
+	Offender  crypto.Token
+	Conflict  Conflict
+	Fee       uint64
+	Signature crypto.Signature
+}
+
+func (d *DenounceChecksum) Payments() *Payment {
+	return NewPayment(crypto.HashToken(d.Author), d.Fee)
+}
+
+func (a *DenounceChecksum) Authority() crypto.Token {
+	return crypto.ZeroToken
+}
+
+func (d *DenounceChecksum) Validate(v InstructionValidator) error {
+	if !d.Conflict.verify(d.Offender, ChecksumMessage) {
+		return ErrInvalidEvidence
+	}
+	return slash(v, d.Offender, d.Fee)
+}
+
+func (a *DenounceChecksum) Kind() byte {
+	return IDenounceChecksum
+}
+
+func (a *DenounceChecksum) Epoch() uint64 {
+	return a.epoch
+}
+
+func (d *DenounceChecksum) serializeWithoutSignature() []byte {
+	bytes := []byte{0, IDenounceChecksum}
+	util.PutUint64(d.epoch, &bytes)
+	util.PutToken(d.Author, &bytes)
+	util.PutToken(d.Offender, &bytes)
+	d.Conflict.serialize(&bytes)
+	util.PutUint64(d.Fee, &bytes)
+	return bytes
+}
+
+func (d *DenounceChecksum) Serialize() []byte {
+	bytes := d.serializeWithoutSignature()
+	util.PutSignature(d.Signature, &bytes)
+	return bytes
+}
+
+func ParseDenounceChecksum(data []byte) *DenounceChecksum {
+	if len(data) < 2 || data[1] != IDenounceChecksum {
+		return nil
+	}
+	p := DenounceChecksum{}
+	position := 2
+	p.epoch, position = util.ParseUint64(data, position)
+	p.Author, position = util.ParseToken(data, position)
+	p.Offender, position = util.ParseToken(data, position)
+	p.Conflict, position = parseConflict(data, position)
+	p.Fee, position = util.ParseUint64(data, position)
+	msgToVerify := data[0:position]
+	p.Signature, _ = util.ParseSignature(data, position)
+	if !p.Author.Verify(msgToVerify, p.Signature) {
+		return nil
+	}
+	return &p
+}
+
+func NewDenounceInstruction(author crypto.PrivateKey, offender crypto.Token, header []byte, vote crypto.Signature, instruction []byte, index uint16, proof []crypto.Hash, epoch, fee uint64) *DenounceInstruction {
+	denounce := &DenounceInstruction{
+		epoch:       epoch,
+		Author:      author.PublicKey(),
+		Offender:    offender,
+		Header:      header,
+		Vote:        vote,
+		Instruction: instruction,
+		Index:       index,
+		Proof:       proof,
+		Fee:         fee,
+	}
+	denounce.Signature = author.Sign(denounce.serializeWithoutSignature())
+	return denounce
+}
+
+// DenounceInstruction proves that a validator signed a block including an
+// instruction that is not valid on any state: one that cannot be parsed, is
+// not properly signed or is from an epoch after the block epoch. The block
+// is given by its header and the instruction by its Merkle proof of
+// inclusion.
+type DenounceInstruction struct {
+	epoch       uint64
+	Author      crypto.Token
+	Offender    crypto.Token
+	Header      []byte           // serialized header of the block
+	Vote        crypto.Signature // offender signature of the vote message of the block
+	Instruction []byte
+	Index       uint16
+	Proof       []crypto.Hash
+	Fee         uint64
+	Signature   crypto.Signature
+}
+
+func (d *DenounceInstruction) Payments() *Payment {
+	return NewPayment(crypto.HashToken(d.Author), d.Fee)
+}
+
+func (a *DenounceInstruction) Authority() crypto.Token {
+	return crypto.ZeroToken
+}
+
+// invalidOnBlock checks if data is not a valid instruction for a block of
+// epoch regardless of the state.
+func invalidOnBlock(data []byte, epoch uint64) bool {
+	if len(data) < 2 {
+		return true
+	}
+	instruction := ParseInstruction(data)
+	return instruction == nil || instruction.Epoch() > epoch
+}
+
+func (d *DenounceInstruction) Validate(v InstructionValidator) error {
+	header := ParseBlockHeader(d.Header)
+	if header == nil {
+		return ErrInvalidEvidence
+	}
+	hash := crypto.Hasher(d.Header)
+	if !d.Offender.Verify(VoteMessage(header.Epoch, hash), d.Vote) {
+		return ErrInvalidEvidence
+	}
+	if !crypto.VerifyMerkleProof(header.InstructionsRoot, d.Instruction, int(d.Index), int(header.Count), d.Proof) {
+		return ErrInvalidEvidence
+	}
+	if !invalidOnBlock(d.Instruction, header.Epoch) {
+		return ErrInvalidEvidence
+	}
+	return slash(v, d.Offender, d.Fee)
+}
+
+func (a *DenounceInstruction) Kind() byte {
+	return IDenounceInstruction
+}
+
+func (a *DenounceInstruction) Epoch() uint64 {
+	return a.epoch
+}
+
+func (d *DenounceInstruction) serializeWithoutSignature() []byte {
+	bytes := []byte{0, IDenounceInstruction}
+	util.PutUint64(d.epoch, &bytes)
+	util.PutToken(d.Author, &bytes)
+	util.PutToken(d.Offender, &bytes)
+	util.PutByteArray(d.Header, &bytes)
+	util.PutSignature(d.Vote, &bytes)
+	util.PutByteArray(d.Instruction, &bytes)
+	util.PutUint16(d.Index, &bytes)
+	putHashes(d.Proof, &bytes)
+	util.PutUint64(d.Fee, &bytes)
+	return bytes
+}
+
+func (d *DenounceInstruction) Serialize() []byte {
+	bytes := d.serializeWithoutSignature()
+	util.PutSignature(d.Signature, &bytes)
+	return bytes
+}
+
+func ParseDenounceInstruction(data []byte) *DenounceInstruction {
+	if len(data) < 2 || data[1] != IDenounceInstruction {
+		return nil
+	}
+	p := DenounceInstruction{}
+	position := 2
+	p.epoch, position = util.ParseUint64(data, position)
+	p.Author, position = util.ParseToken(data, position)
+	p.Offender, position = util.ParseToken(data, position)
+	p.Header, position = util.ParseByteArray(data, position)
+	p.Vote, position = util.ParseSignature(data, position)
+	p.Instruction, position = util.ParseByteArray(data, position)
+	p.Index, position = util.ParseUint16(data, position)
+	p.Proof, position = parseHashes(data, position)
+	p.Fee, position = util.ParseUint64(data, position)
+	msgToVerify := data[0:position]
+	p.Signature, _ = util.ParseSignature(data, position)
+	if !p.Author.Verify(msgToVerify, p.Signature) {
+		return nil
+	}
+	return &p
+}
@@ -0,0 +1,81 @@
+package instructions
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Aereum/aereum/core/crypto"
+)
+
+func TestDenounceCheckpoint(t *testing.T) {
+	offender, offenderKey := crypto.RandomAsymetricKey()
+	conflict := NewConflict(offenderKey, VoteMessage, 5, crypto.Hasher([]byte("a")), crypto.Hasher([]byte("b")))
+	if !conflict.verify(offender, VoteMessage) || conflict.verify(offender, ChecksumMessage) {
+		t.Error("conflict verification not bound to message kind")
+	}
+	denounce := NewDenounceCheckpoint(token, offender, conflict, 10, 2000)
+	denounce2 := ParseDenounceCheckpoint(denounce.Serialize())
+	if denounce2 == nil {
+		t.Error("could not parse DenounceCheckpoint")
+		return
+	}
+	if !reflect.DeepEqual(denounce, denounce2) {
+		t.Error("Parse and Serialize not working for DenounceCheckpoint")
+	}
+	if !json.Valid([]byte(denounce.JSON())) {
+		t.Error("invalid denounce checkpoint json")
+	}
+}
+
+func TestDenounceChecksum(t *testing.T) {
+	offender, offenderKey := crypto.RandomAsymetricKey()
+	conflict := NewConflict(offenderKey, ChecksumMessage, 5, crypto.Hasher([]byte("a")), crypto.Hasher([]byte("b")))
+	denounce := NewDenounceChecksum(token, offender, conflict, 10, 2000)
+	denounce2 := ParseDenounceChecksum(denounce.Serialize())
+	if denounce2 == nil {
+		t.Error("could not parse DenounceChecksum")
+		return
+	}
+	if !reflect.DeepEqual(denounce, denounce2) {
+		t.Error("Parse and Serialize not working for DenounceChecksum")
+	}
+	if !json.Valid([]byte(denounce.JSON())) {
+		t.Error("invalid denounce checksum json")
+	}
+}
+
+func TestDenounceInstruction(t *testing.T) {
+	offender, offenderKey := crypto.RandomAsymetricKey()
+	leaves := [][]byte{{0, 255}, {1, 2, 3}, {4}}
+	header := BlockHeader{
+		Epoch:            7,
+		Parent:           crypto.Hasher([]byte("parent")),
+		CheckPoint:       6,
+		Publisher:        offender,
+		PublishedAt:      time.Unix(1000, 0),
+		Count:            uint16(len(leaves)),
+		InstructionsRoot: crypto.MerkleRoot(leaves),
+	}
+	data := header.Serialize()
+	if parsed := ParseBlockHeader(data); parsed == nil || !reflect.DeepEqual(*parsed, header) {
+		t.Error("Parse and Serialize not working for BlockHeader")
+	}
+	if ParseBlockHeader(append(data, 0)) != nil {
+		t.Error("block header parsed with trailing data")
+	}
+	vote := offenderKey.Sign(VoteMessage(header.Epoch, crypto.Hasher(data)))
+	denounce := NewDenounceInstruction(token, offender, data, vote, leaves[1], 1, crypto.MerkleProof(leaves, 1), 10, 2000)
+	denounce2 := ParseDenounceInstruction(denounce.Serialize())
+	if denounce2 == nil {
+		t.Error("could not parse DenounceInstruction")
+		return
+	}
+	if !reflect.DeepEqual(denounce, denounce2) {
+		t.Error("Parse and Serialize not working for DenounceInstruction")
+	}
+	if !json.Valid([]byte(denounce.JSON())) {
+		t.Error("invalid denounce instruction json")
+	}
+}
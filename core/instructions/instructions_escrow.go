@@ -0,0 +1,243 @@
+package instructions
+
+import (
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/store"
+	"github.com/Aereum/aereum/core/util"
+)
+
+// NewEscrowTransfer returns a transfer of installments of value each, the
+// first released at the release epoch and the others every period epochs.
+func NewEscrowTransfer(from crypto.PrivateKey, to crypto.Token, reason string, value, installments, period, release, epoch, fee uint64) *EscrowTransfer {
+	transfer := &EscrowTransfer{
+		epoch:        epoch,
+		From:         from.PublicKey(),
+		To:           to,
+		Reason:       reason,
+		Value:        value,
+		Installments: installments,
+		Period:       period,
+		Release:      release,
+		Fee:          fee,
+	}
+	transfer.Signature = from.Sign(transfer.serializeWithoutSignature())
+	return transfer
+}
+
+// EscrowTransfer transfers aero from a wallet to the chain, which releases it
+// to the recipient in installments. The escrow is identified by the hash of
+// the instruction, and can be cancelled by the payer before it is fully
+// released.
+type EscrowTransfer struct {
+	epoch        uint64
+	From         crypto.Token
+	To           crypto.Token
+	Reason       string
+	Value        uint64 // aero per installment
+	Installments uint64
+	Period       uint64 // epochs between installments
+	Release      uint64 // epoch of the first installment
+	Fee          uint64
+	Signature    crypto.Signature
+}
+
+// Hash returns the hash identifying the escrow.
+func (t *EscrowTransfer) Hash() crypto.Hash {
+	return crypto.Hasher(t.Serialize())
+}
+
+// Total returns the aero escrowed by the transfer. It returns false if it
+// overflows.
+func (t *EscrowTransfer) Total() (uint64, bool) {
+	return util.MulUint64(t.Value, t.Installments)
+}
+
+// LastRelease returns the epoch of the last installment. It returns false if
+// it overflows.
+func (t *EscrowTransfer) LastRelease() (uint64, bool) {
+	if t.Installments == 0 {
+		return t.Release, true
+	}
+	delay, ok := util.MulUint64(t.Installments-1, t.Period)
+	if !ok {
+		return 0, false
+	}
+	return util.AddUint64(t.Release, delay)
+}
+
+func (t *EscrowTransfer) Payments() *Payment {
+	from := crypto.HashToken(t.From)
+	payment := NewPayment(from, t.Fee)
+	total, ok := t.Total()
+	if !ok {
+		payment.overflow = true
+		return payment
+	}
+	payment.NewDebit(from, total)
+	return payment
+}
+
+func (a *EscrowTransfer) Authority() crypto.Token {
+	return crypto.ZeroToken
+}
+
+func (t *EscrowTransfer) Validate(v InstructionValidator) error {
+	if t.Value == 0 || t.Installments == 0 || (t.Installments > 1 && t.Period == 0) {
+		return ErrInvalidSchedule
+	}
+	if _, ok := t.LastRelease(); !ok {
+		return ErrInvalidSchedule
+	}
+	if t.Release <= v.Epoch() {
+		return ErrExpired
+	}
+	escrow := store.Escrow{Payer: t.From, Recipient: t.To, Value: t.Value}
+	if t.Installments > 1 {
+		escrow.Period = t.Period
+	}
+	total, _ := t.Total()
+	if !v.SetNewEscrow(t.Hash(), escrow, t.Release, total) {
+		return ErrConflictingInstruction
+	}
+	v.AddFeeCollected(t.Fee)
+	return nil
+}
+
+func (a *EscrowTransfer) Kind() byte {
+	return IEscrowTransfer
+}
+
+func (a *EscrowTransfer) Epoch() uint64 {
+	return a.epoch
+}
+
+func (t *EscrowTransfer) serializeWithoutSignature() []byte {
+	bytes := []byte{0, IEscrowTransfer}
+	util.PutUint64(t.epoch, &bytes)
+	util.PutToken(t.From, &bytes)
+	util.PutToken(t.To, &bytes)
+	util.PutString(t.Reason, &bytes)
+	util.PutUint64(t.Value, &bytes)
+	util.PutUint64(t.Installments, &bytes)
+	util.PutUint64(t.Period, &bytes)
+	util.PutUint64(t.Release, &bytes)
+	util.PutUint64(t.Fee, &bytes)
+	return bytes
+}
+
+func (t *EscrowTransfer) Serialize() []byte {
+	bytes := t.serializeWithoutSignature()
+	util.PutSignature(t.Signature, &bytes)
+	return bytes
+}
+
+func ParseEscrowTransfer(data []byte) *EscrowTransfer {
+	if len(data) < 2 || data[1] != IEscrowTransfer {
+		return nil
+	}
+	p := EscrowTransfer{}
+	position := 2
+	p.epoch, position = util.ParseUint64(data, position)
+	p.From, position = util.ParseToken(data, position)
+	p.To, position = util.ParseToken(data, position)
+	p.Reason, position = util.ParseString(data, position)
+	p.Value, position = util.ParseUint64(data, position)
+	p.Installments, position = util.ParseUint64(data, position)
+	p.Period, position = util.ParseUint64(data, position)
+	p.Release, position = util.ParseUint64(data, position)
+	p.Fee, position = util.ParseUint64(data, position)
+	msg := data[0:position]
+	p.Signature, _ = util.ParseSignature(data, position)
+	if !p.From.Verify(msg, p.Signature) {
+		return nil
+	}
+	return &p
+}
+
+func NewCancelEscrow(from crypto.PrivateKey, escrow crypto.Hash, epoch, fee uint64) *CancelEscrow {
+	cancel := &CancelEscrow{
+		epoch:  epoch,
+		Token:  from.PublicKey(),
+		Escrow: escrow,
+		Fee:    fee,
+	}
+	cancel.Signature = from.Sign(cancel.serializeWithoutSignature())
+	return cancel
+}
+
+// CancelEscrow returns to the payer the aero of an escrow not yet released.
+// It must be signed by the payer before the next release epoch.
+type CancelEscrow struct {
+	epoch     uint64
+	Token     crypto.Token
+	Escrow    crypto.Hash
+	Fee       uint64
+	Signature crypto.Signature
+}
+
+func (c *CancelEscrow) Payments() *Payment {
+	return NewPayment(crypto.HashToken(c.Token), c.Fee)
+}
+
+func (a *CancelEscrow) Authority() crypto.Token {
+	return crypto.ZeroToken
+}
+
+func (c *CancelEscrow) Validate(v InstructionValidator) error {
+	escrow := v.Escrow(c.Escrow)
+	if escrow == nil {
+		return ErrNoEscrow
+	}
+	if escrow.Payer != c.Token {
+		return ErrNotEscrowPayer
+	}
+	if v.EscrowRelease(c.Escrow) <= v.Epoch() {
+		return ErrEscrowReleased
+	}
+	if !v.SetCancelEscrow(c.Escrow) {
+		return ErrConflictingInstruction
+	}
+	v.AddFeeCollected(c.Fee)
+	return nil
+}
+
+func (a *CancelEscrow) Kind() byte {
+	return ICancelEscrow
+}
+
+func (a *CancelEscrow) Epoch() uint64 {
+	return a.epoch
+}
+
+func (c *CancelEscrow) serializeWithoutSignature() []byte {
+	bytes := []byte{0, ICancelEscrow}
+	util.PutUint64(c.epoch, &bytes)
+	util.PutToken(c.Token, &bytes)
+	util.PutByteArray(c.Escrow[:], &bytes)
+	util.PutUint64(c.Fee, &bytes)
+	return bytes
+}
+
+func (c *CancelEscrow) Serialize() []byte {
+	bytes := c.serializeWithoutSignature()
+	util.PutSignature(c.Signature, &bytes)
+	return bytes
+}
+
+func ParseCancelEscrow(data []byte) *CancelEscrow {
+	if len(data) < 2 || data[1] != ICancelEscrow {
+		return nil
+	}
+	p := CancelEscrow{}
+	position := 2
+	p.epoch, position = util.ParseUint64(data, position)
+	p.Token, position = util.ParseToken(data, position)
+	p.Escrow, position = util.ParseHash(data, position)
+	p.Fee, position = util.ParseUint64(data, position)
+	msgToVerify := data[0:position]
+	p.Signature, _ = util.ParseSignature(data, position)
+	if !p.Token.Verify(msgToVerify, p.Signature) {
+		return nil
+	}
+	return &p
+}
@@ -0,0 +1,46 @@
+package instructions
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/Aereum/aereum/core/crypto"
+)
+
+func TestEscrowTransfer(t *testing.T) {
+	to, _ := crypto.RandomAsymetricKey()
+	transfer := NewEscrowTransfer(token, to, "installments", 100, 3, 10, 20, 10, 2000)
+	transfer2 := ParseEscrowTransfer(transfer.Serialize())
+	if transfer2 == nil {
+		t.Error("could not parse EscrowTransfer")
+		return
+	}
+	if !reflect.DeepEqual(transfer, transfer2) {
+		t.Error("Parse and Serialize not working for EscrowTransfer")
+	}
+	if total, ok := transfer.Total(); !ok || total != 300 {
+		t.Errorf("wrong escrow total: %v", total)
+	}
+	if last, ok := transfer.LastRelease(); !ok || last != 40 {
+		t.Errorf("wrong escrow last release: %v", last)
+	}
+	if !json.Valid([]byte(transfer.JSON())) {
+		t.Error("invalid escrow transfer json")
+	}
+}
+
+func TestCancelEscrow(t *testing.T) {
+	cancel := NewCancelEscrow(token, crypto.Hasher([]byte("escrow")), 10, 2000)
+	cancel2 := ParseCancelEscrow(cancel.Serialize())
+	if cancel2 == nil {
+		t.Error("could not parse CancelEscrow")
+		return
+	}
+	if !reflect.DeepEqual(cancel, cancel2) {
+		t.Error("Parse and Serialize not working for CancelEscrow")
+	}
+	if !json.Valid([]byte(cancel.JSON())) {
+		t.Error("invalid cancel escrow json")
+	}
+}
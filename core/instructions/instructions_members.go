@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 
 	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/store"
 	"github.com/Aereum/aereum/core/util"
 )
 
@@ -21,23 +22,26 @@ func (a *JoinNetwork) Epoch() uint64 {
 	return a.Authored.epoch
 }
 
-func (join *JoinNetwork) Validate(v InstructionValidator) bool {
+func (join *JoinNetwork) Validate(v InstructionValidator) error {
 	captionHash := crypto.Hasher([]byte(join.Caption))
 	if v.HasCaption(captionHash) {
-		return false
+		return ErrCaptionTaken
 	}
-	authorHash := crypto.Hasher(join.Authored.Author[:])
+	authorHash := join.Authored.authorHash()
 	if v.HasMember(authorHash) {
-		return false
+		return ErrAlreadyMember
 	}
 	if !json.Valid([]byte(join.Details)) {
-		return false
+		return ErrInvalidDetails
 	}
-	if v.SetNewMember(authorHash, captionHash) {
-		v.AddFeeCollected(join.Authored.Fee)
-		return true
+	if err := join.Authored.validateAttorney(v, join, crypto.ZeroToken); err != nil {
+		return err
 	}
-	return false
+	if !v.SetNewMember(join.Authored.Author, captionHash) {
+		return ErrConflictingInstruction
+	}
+	v.AddFeeCollected(join.Authored.Fee)
+	return nil
 }
 
 func (join *JoinNetwork) Payments() *Payment {
@@ -91,15 +95,18 @@ func (a *UpdateInfo) Epoch() uint64 {
 	return a.Authored.epoch
 }
 
-func (update *UpdateInfo) Validate(v InstructionValidator) bool {
+func (update *UpdateInfo) Validate(v InstructionValidator) error {
 	if !v.HasMember(update.Authored.authorHash()) {
-		return false
+		return ErrNotMember
 	}
-	if json.Valid([]byte(update.Details)) {
-		v.AddFeeCollected(update.Authored.Fee)
-		return true
+	if err := update.Authored.validateAttorney(v, update, crypto.ZeroToken); err != nil {
+		return err
 	}
-	return false
+	if !json.Valid([]byte(update.Details)) {
+		return ErrInvalidDetails
+	}
+	v.AddFeeCollected(update.Authored.Fee)
+	return nil
 }
 
 func (update *UpdateInfo) Payments() *Payment {
@@ -138,9 +145,13 @@ func ParseUpdateInfo(data []byte) *UpdateInfo {
 	return nil
 }
 
+// GrantPowerOfAttorney allows an attorney to sign instructions on behalf of
+// the author within the limits of Scope. It must be signed by the author key,
+// so that an attorney cannot grant powers beyond its own scope.
 type GrantPowerOfAttorney struct {
 	Authored *AuthoredInstruction
 	Attorney crypto.Token
+	Scope    store.AttorneyScope
 }
 
 func (a *GrantPowerOfAttorney) Authority() crypto.Token {
@@ -151,22 +162,31 @@ func (a *GrantPowerOfAttorney) Epoch() uint64 {
 	return a.Authored.epoch
 }
 
-func (grant *GrantPowerOfAttorney) Validate(v InstructionValidator) bool {
+func (grant *GrantPowerOfAttorney) Validate(v InstructionValidator) error {
+	if grant.Authored.Attorney != crypto.ZeroToken {
+		return ErrAttorneyNotAllowed
+	}
 	if !v.HasMember(grant.Authored.authorHash()) {
-		return false
+		return ErrNotMember
 	}
 	if !v.HasMember(crypto.HashToken(grant.Attorney)) {
-		return false
+		return ErrAttorneyNotMember
 	}
 	hash := crypto.Hasher(append(grant.Authored.Author[:], grant.Attorney[:]...))
 	if v.PowerOfAttorney(hash) {
-		return false
+		return ErrPowerOfAttorneyExists
+	}
+	if grant.Scope.Kinds == 0 {
+		return ErrInvalidScope
 	}
-	if v.SetNewGrantPower(hash) {
-		v.AddFeeCollected(grant.Authored.Fee)
-		return true
+	if grant.Scope.Expire != 0 && grant.Scope.Expire < v.Epoch() {
+		return ErrExpired
 	}
-	return false
+	if !v.SetNewGrantPower(hash, grant.Scope, grant.Authored.Author) {
+		return ErrConflictingInstruction
+	}
+	v.AddFeeCollected(grant.Authored.Fee)
+	return nil
 }
 
 func (grant *GrantPowerOfAttorney) Payments() *Payment {
@@ -180,6 +200,10 @@ func (grant *GrantPowerOfAttorney) Kind() byte {
 func (grant *GrantPowerOfAttorney) serializeBulk() []byte {
 	bytes := make([]byte, 0)
 	util.PutToken(grant.Attorney, &bytes)
+	util.PutUint64(grant.Scope.Kinds, &bytes)
+	util.PutUint64(grant.Scope.Expire, &bytes)
+	util.PutUint64(grant.Scope.Limit, &bytes)
+	util.PutToken(grant.Scope.Audience, &bytes)
 	return bytes
 }
 
@@ -196,6 +220,10 @@ func ParseGrantPowerOfAttorney(data []byte) *GrantPowerOfAttorney {
 	}
 	position := grant.Authored.parseHead(data)
 	grant.Attorney, position = util.ParseToken(data, position)
+	grant.Scope.Kinds, position = util.ParseUint64(data, position)
+	grant.Scope.Expire, position = util.ParseUint64(data, position)
+	grant.Scope.Limit, position = util.ParseUint64(data, position)
+	grant.Scope.Audience, position = util.ParseToken(data, position)
 	if grant.Authored.parseTail(data, position) {
 		return &grant
 	}
@@ -215,22 +243,25 @@ func (a *RevokePowerOfAttorney) Epoch() uint64 {
 	return a.Authored.epoch
 }
 
-func (revoke *RevokePowerOfAttorney) Validate(v InstructionValidator) bool {
+func (revoke *RevokePowerOfAttorney) Validate(v InstructionValidator) error {
 	if !v.HasMember(revoke.Authored.authorHash()) {
-		return false
+		return ErrNotMember
+	}
+	if err := revoke.Authored.validateAttorney(v, revoke, crypto.ZeroToken); err != nil {
+		return err
 	}
 	if !v.HasMember(crypto.HashToken(revoke.Attorney)) {
-		return false
+		return ErrAttorneyNotMember
 	}
 	hash := crypto.Hasher(append(revoke.Authored.Author[:], revoke.Attorney[:]...))
 	if !v.PowerOfAttorney(hash) {
-		return false
+		return ErrNoPowerOfAttorney
 	}
-	if v.SetNewRevokePower(hash) {
-		v.AddFeeCollected(revoke.Authored.Fee)
-		return true
+	if !v.SetNewRevokePower(hash, revoke.Authored.Author) {
+		return ErrConflictingInstruction
 	}
-	return false
+	v.AddFeeCollected(revoke.Authored.Fee)
+	return nil
 }
 
 func (revoke *RevokePowerOfAttorney) Payments() *Payment {
@@ -280,22 +311,25 @@ func (a *CreateEphemeral) Epoch() uint64 {
 	return a.Authored.epoch
 }
 
-func (ephemeral *CreateEphemeral) Validate(v InstructionValidator) bool {
+func (ephemeral *CreateEphemeral) Validate(v InstructionValidator) error {
 	if !v.HasMember(ephemeral.Authored.authorHash()) {
-		return false
+		return ErrNotMember
+	}
+	if err := ephemeral.Authored.validateAttorney(v, ephemeral, crypto.ZeroToken); err != nil {
+		return err
 	}
 	if ephemeral.Expiry <= v.Epoch() {
-		return false
+		return ErrExpired
 	}
 	hash := crypto.HashToken(ephemeral.EphemeralToken)
 	if ok, expire := v.GetEphemeralExpire(hash); ok && expire > v.Epoch() {
-		return false
+		return ErrEphemeralExists
 	}
-	if v.SetNewEphemeralToken(hash, ephemeral.Expiry) {
-		v.AddFeeCollected(ephemeral.Authored.Fee)
-		return true
+	if !v.SetNewEphemeralToken(hash, ephemeral.Expiry) {
+		return ErrConflictingInstruction
 	}
-	return false
+	v.AddFeeCollected(ephemeral.Authored.Fee)
+	return nil
 }
 
 func (ephemeral *CreateEphemeral) Payments() *Payment {
@@ -349,16 +383,19 @@ func (a *SecureChannel) Epoch() uint64 {
 	return a.Authored.epoch
 }
 
-func (secure *SecureChannel) Validate(v InstructionValidator) bool {
+func (secure *SecureChannel) Validate(v InstructionValidator) error {
 	authorHash := crypto.Hasher(secure.Authored.Author[:])
 	if _, expire := v.GetEphemeralExpire(authorHash); expire <= v.Epoch() {
-		return false
+		return ErrNoEphemeral
 	}
 	if len(secure.TokenRange) >= crypto.Size {
-		return false
+		return ErrInvalidTokenRange
+	}
+	if err := secure.Authored.validateAttorney(v, secure, crypto.ZeroToken); err != nil {
+		return err
 	}
 	v.AddFeeCollected(secure.Authored.Fee)
-	return true
+	return nil
 }
 
 func (secure *SecureChannel) Payments() *Payment {
@@ -400,3 +437,294 @@ func ParseSecureChannel(data []byte) *SecureChannel {
 	}
 	return nil
 }
+
+type ChangeCaption struct {
+	Authored *AuthoredInstruction
+	Caption  string
+}
+
+func (a *ChangeCaption) Authority() crypto.Token {
+	return a.Authored.Author
+}
+
+func (a *ChangeCaption) Epoch() uint64 {
+	return a.Authored.epoch
+}
+
+func (change *ChangeCaption) Validate(v InstructionValidator) error {
+	if !v.HasMember(change.Authored.authorHash()) {
+		return ErrNotMember
+	}
+	if err := change.Authored.validateAttorney(v, change, crypto.ZeroToken); err != nil {
+		return err
+	}
+	captionHash := crypto.Hasher([]byte(change.Caption))
+	if v.HasCaption(captionHash) {
+		return ErrCaptionTaken
+	}
+	if !v.SetNewCaption(change.Authored.Author, captionHash) {
+		return ErrConflictingInstruction
+	}
+	v.AddFeeCollected(change.Authored.Fee)
+	return nil
+}
+
+func (change *ChangeCaption) Payments() *Payment {
+	return change.Authored.payments()
+}
+
+func (change *ChangeCaption) Kind() byte {
+	return IChangeCaption
+}
+
+func (change *ChangeCaption) serializeBulk() []byte {
+	bytes := make([]byte, 0)
+	util.PutString(change.Caption, &bytes)
+	return bytes
+}
+
+func (change *ChangeCaption) Serialize() []byte {
+	return change.Authored.serialize(IChangeCaption, change.serializeBulk())
+}
+
+func ParseChangeCaption(data []byte) *ChangeCaption {
+	if data[0] != 0 || data[1] != IChangeCaption {
+		return nil
+	}
+	change := ChangeCaption{
+		Authored: &AuthoredInstruction{},
+	}
+	position := change.Authored.parseHead(data)
+	change.Caption, position = util.ParseString(data, position)
+	if change.Authored.parseTail(data, position) {
+		return &change
+	}
+	return nil
+}
+
+type TransferCaption struct {
+	Authored  *AuthoredInstruction
+	Caption   string
+	Recipient crypto.Token
+}
+
+func (a *TransferCaption) Authority() crypto.Token {
+	return a.Authored.Author
+}
+
+func (a *TransferCaption) Epoch() uint64 {
+	return a.Authored.epoch
+}
+
+func (transfer *TransferCaption) Validate(v InstructionValidator) error {
+	if !v.HasMember(transfer.Authored.authorHash()) {
+		return ErrNotMember
+	}
+	if err := transfer.Authored.validateAttorney(v, transfer, crypto.ZeroToken); err != nil {
+		return err
+	}
+	if transfer.Recipient == transfer.Authored.Author || !v.HasMember(crypto.HashToken(transfer.Recipient)) {
+		return ErrRecipientNotMember
+	}
+	captionHash := crypto.Hasher([]byte(transfer.Caption))
+	if ok, owner := v.CaptionOwner(captionHash); !ok || owner != transfer.Authored.Author {
+		return ErrNotCaptionOwner
+	}
+	if !v.SetCaptionTransfer(transfer.Authored.Author, transfer.Recipient, captionHash) {
+		return ErrConflictingInstruction
+	}
+	v.AddFeeCollected(transfer.Authored.Fee)
+	return nil
+}
+
+func (transfer *TransferCaption) Payments() *Payment {
+	return transfer.Authored.payments()
+}
+
+func (transfer *TransferCaption) Kind() byte {
+	return ITransferCaption
+}
+
+func (transfer *TransferCaption) serializeBulk() []byte {
+	bytes := make([]byte, 0)
+	util.PutString(transfer.Caption, &bytes)
+	util.PutToken(transfer.Recipient, &bytes)
+	return bytes
+}
+
+func (transfer *TransferCaption) Serialize() []byte {
+	return transfer.Authored.serialize(ITransferCaption, transfer.serializeBulk())
+}
+
+func ParseTransferCaption(data []byte) *TransferCaption {
+	if data[0] != 0 || data[1] != ITransferCaption {
+		return nil
+	}
+	transfer := TransferCaption{
+		Authored: &AuthoredInstruction{},
+	}
+	position := transfer.Authored.parseHead(data)
+	transfer.Caption, position = util.ParseString(data, position)
+	transfer.Recipient, position = util.ParseToken(data, position)
+	if transfer.Authored.parseTail(data, position) {
+		return &transfer
+	}
+	return nil
+}
+
+// NewRotateIdentity moves the identity of the member from to the token of to.
+// attorneys must list every power of attorney granted by from and stages the
+// tokens of every stage it owns, all of which are moved to the new token.
+func NewRotateIdentity(from, to crypto.PrivateKey, attorneys, stages []crypto.Token, epoch, fee uint64) *RotateIdentity {
+	rotate := &RotateIdentity{
+		epoch:     epoch,
+		Token:     from.PublicKey(),
+		NewToken:  to.PublicKey(),
+		Attorneys: attorneys,
+		Stages:    stages,
+		Fee:       fee,
+	}
+	bytes := rotate.serializeWithoutSignatures()
+	rotate.Signature = from.Sign(bytes)
+	util.PutSignature(rotate.Signature, &bytes)
+	rotate.NewSignature = to.Sign(bytes)
+	return rotate
+}
+
+// RotateIdentity replaces the token of a member by a new one, signed by both.
+// Membership, caption, wallet balance, powers of attorney and stages are
+// moved atomically. It is rejected unless every power of attorney granted and
+// every stage owned by the member is listed. The fee is paid by the old token before
+// its balance is moved.
+type RotateIdentity struct {
+	epoch        uint64
+	Token        crypto.Token
+	NewToken     crypto.Token
+	Attorneys    []crypto.Token
+	Stages       []crypto.Token
+	Fee          uint64
+	Signature    crypto.Signature
+	NewSignature crypto.Signature
+}
+
+func (a *RotateIdentity) Authority() crypto.Token {
+	return crypto.ZeroToken
+}
+
+func (a *RotateIdentity) Epoch() uint64 {
+	return a.epoch
+}
+
+func (rotate *RotateIdentity) Validate(v InstructionValidator) error {
+	if !v.HasMember(crypto.HashToken(rotate.Token)) {
+		return ErrNotMember
+	}
+	if rotate.NewToken == rotate.Token || v.HasMember(crypto.HashToken(rotate.NewToken)) {
+		return ErrAlreadyMember
+	}
+	stages, err := rotationStages(v, rotate.Token, rotate.Attorneys, rotate.Stages)
+	if err != nil {
+		return err
+	}
+	if !v.SetNewRotation(rotate.Token, rotate.NewToken, rotate.Attorneys, stages) {
+		return ErrConflictingInstruction
+	}
+	v.AddFeeCollected(rotate.Fee)
+	return nil
+}
+
+// rotationStages checks that token granted power of attorney to attorneys and
+// owns stages, and that they are every power of attorney it granted and every
+// stage it owns. It returns the hashes of stages.
+func rotationStages(v InstructionValidator, token crypto.Token, attorneys, stages []crypto.Token) ([]crypto.Hash, error) {
+	listed := make(map[crypto.Hash]struct{})
+	for _, attorney := range attorneys {
+		hash := crypto.Hasher(append(token[:], attorney[:]...))
+		if !v.PowerOfAttorney(hash) {
+			return nil, ErrNoPowerOfAttorney
+		}
+		listed[hash] = struct{}{}
+	}
+	hashes := make([]crypto.Hash, len(stages))
+	for n, stage := range stages {
+		hashes[n] = crypto.HashToken(stage)
+		if ok, owner := v.StageOwner(hashes[n]); !ok || owner != token {
+			return nil, ErrNotStageOwner
+		}
+		listed[hashes[n]] = struct{}{}
+	}
+	if uint64(len(listed)) != v.Holdings(crypto.HashToken(token)) {
+		return nil, ErrIncompleteRotation
+	}
+	return hashes, nil
+}
+
+func (rotate *RotateIdentity) Payments() *Payment {
+	return NewPayment(crypto.HashToken(rotate.Token), rotate.Fee)
+}
+
+func (rotate *RotateIdentity) Kind() byte {
+	return IRotateIdentity
+}
+
+func putTokens(tokens []crypto.Token, data *[]byte) {
+	util.PutUint16(uint16(len(tokens)), data)
+	for _, token := range tokens {
+		util.PutToken(token, data)
+	}
+}
+
+func parseTokens(data []byte, position int) ([]crypto.Token, int) {
+	length, position := util.ParseUint16(data, position)
+	if length == 0 {
+		return nil, position
+	}
+	tokens := make([]crypto.Token, length)
+	for n := range tokens {
+		tokens[n], position = util.ParseToken(data, position)
+	}
+	return tokens, position
+}
+
+func (rotate *RotateIdentity) serializeWithoutSignatures() []byte {
+	bytes := []byte{0, IRotateIdentity}
+	util.PutUint64(rotate.epoch, &bytes)
+	util.PutToken(rotate.Token, &bytes)
+	util.PutToken(rotate.NewToken, &bytes)
+	putTokens(rotate.Attorneys, &bytes)
+	putTokens(rotate.Stages, &bytes)
+	util.PutUint64(rotate.Fee, &bytes)
+	return bytes
+}
+
+func (rotate *RotateIdentity) Serialize() []byte {
+	bytes := rotate.serializeWithoutSignatures()
+	util.PutSignature(rotate.Signature, &bytes)
+	util.PutSignature(rotate.NewSignature, &bytes)
+	return bytes
+}
+
+func ParseRotateIdentity(data []byte) *RotateIdentity {
+	if len(data) < 2 || data[1] != IRotateIdentity {
+		return nil
+	}
+	p := RotateIdentity{}
+	position := 2
+	p.epoch, position = util.ParseUint64(data, position)
+	p.Token, position = util.ParseToken(data, position)
+	p.NewToken, position = util.ParseToken(data, position)
+	p.Attorneys, position = parseTokens(data, position)
+	p.Stages, position = parseTokens(data, position)
+	p.Fee, position = util.ParseUint64(data, position)
+	msgToVerify := data[0:position]
+	p.Signature, position = util.ParseSignature(data, position)
+	if !p.Token.Verify(msgToVerify, p.Signature) {
+		return nil
+	}
+	msgToVerify = data[0:position]
+	p.NewSignature, _ = util.ParseSignature(data, position)
+	if !p.NewToken.Verify(msgToVerify, p.NewSignature) {
+		return nil
+	}
+	return &p
+}
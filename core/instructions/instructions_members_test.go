@@ -1,10 +1,12 @@
 package instructions
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
 
 	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/store"
 )
 
 var (
@@ -43,6 +45,31 @@ func TestUpdateInfo(t *testing.T) {
 	}
 }
 
+func TestChangeCaption(t *testing.T) {
+	change := author.NewChangeCaption("teste", 10, 2000)
+	change2 := ParseChangeCaption(change.Serialize())
+	if change2 == nil {
+		t.Error("could not parse ChangeCaption")
+		return
+	}
+	if !reflect.DeepEqual(change, change2) {
+		t.Error("Parse and Serialize not working for ChangeCaption")
+	}
+}
+
+func TestTransferCaption(t *testing.T) {
+	token, _ := crypto.RandomAsymetricKey()
+	transfer := author.NewTransferCaption("teste", token, 10, 2000)
+	transfer2 := ParseTransferCaption(transfer.Serialize())
+	if transfer2 == nil {
+		t.Error("could not parse TransferCaption")
+		return
+	}
+	if !reflect.DeepEqual(transfer, transfer2) {
+		t.Error("Parse and Serialize not working for TransferCaption")
+	}
+}
+
 func TestGrantPowerOfAttorney(t *testing.T) {
 	token, _ := crypto.RandomAsymetricKey()
 	grant := author.NewGrantPowerOfAttorney(token, 10, 2000)
@@ -56,6 +83,21 @@ func TestGrantPowerOfAttorney(t *testing.T) {
 	}
 }
 
+func TestScopedPowerOfAttorney(t *testing.T) {
+	token, _ := crypto.RandomAsymetricKey()
+	audience, _ := crypto.RandomAsymetricKey()
+	scope := store.AttorneyScope{Kinds: KindsMask(IContent, IReact), Expire: 20, Limit: 5000, Audience: audience}
+	grant := author.NewScopedPowerOfAttorney(token, scope, 10, 2000)
+	grant2 := ParseGrantPowerOfAttorney(grant.Serialize())
+	if grant2 == nil {
+		t.Error("could not parse scoped GrantPowerOfAttorney")
+		return
+	}
+	if !reflect.DeepEqual(grant, grant2) {
+		t.Error("Parse and Serialize not working for scoped GrantPowerOfAttorney")
+	}
+}
+
 func TestRevokePowerOfAttorney(t *testing.T) {
 	token, _ := crypto.RandomAsymetricKey()
 	revoke := author.NewRevokePowerOfAttorney(token, 10, 2000)
@@ -93,3 +135,24 @@ func TestSecureChannel(t *testing.T) {
 		t.Error("Parse and Serialize not working for SecureChannel")
 	}
 }
+
+func TestRotateIdentity(t *testing.T) {
+	_, next := crypto.RandomAsymetricKey()
+	stage, _ := crypto.RandomAsymetricKey()
+	rotate := NewRotateIdentity(token, next, []crypto.Token{attorney.PublicKey()}, []crypto.Token{stage}, 10, 2000)
+	rotate2 := ParseRotateIdentity(rotate.Serialize())
+	if rotate2 == nil {
+		t.Error("could not parse RotateIdentity")
+		return
+	}
+	if !reflect.DeepEqual(rotate, rotate2) {
+		t.Error("Parse and Serialize not working for RotateIdentity")
+	}
+	rotate.NewSignature = rotate.Signature
+	if ParseRotateIdentity(rotate.Serialize()) != nil {
+		t.Error("RotateIdentity parsed without the signature of the new token")
+	}
+	if !json.Valid([]byte(rotate2.JSON())) {
+		t.Error("invalid rotate identity json")
+	}
+}
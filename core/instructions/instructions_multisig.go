@@ -0,0 +1,274 @@
+package instructions
+
+import (
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/util"
+)
+
+// MultisigHash returns the commitment to signers and threshold kept by the
+// state for a multisig wallet.
+func MultisigHash(signers []crypto.Token, threshold byte) crypto.Hash {
+	bytes := []byte{threshold}
+	putTokens(signers, &bytes)
+	return crypto.Hasher(bytes)
+}
+
+// countSignatures returns the number of signatures present.
+func countSignatures(signatures []crypto.Signature) int {
+	signed := 0
+	for _, signature := range signatures {
+		if signature != (crypto.Signature{}) {
+			signed++
+		}
+	}
+	return signed
+}
+
+// parseSignatures parses one signature for each of signers, checking every
+// signature present against msg.
+func parseSignatures(signers []crypto.Token, msg, data []byte, position int) ([]crypto.Signature, bool) {
+	signatures := make([]crypto.Signature, len(signers))
+	for n, signer := range signers {
+		signatures[n], position = util.ParseSignature(data, position)
+		if signatures[n] != (crypto.Signature{}) && !signer.Verify(msg, signatures[n]) {
+			return nil, false
+		}
+	}
+	return signatures, true
+}
+
+func NewCreateMultisig(wallet crypto.PrivateKey, signers []crypto.Token, threshold byte, epoch, fee uint64) *CreateMultisig {
+	create := &CreateMultisig{
+		epoch:     epoch,
+		Wallet:    wallet.PublicKey(),
+		Signers:   signers,
+		Threshold: threshold,
+		Fee:       fee,
+	}
+	create.Signature = wallet.Sign(create.serializeWithoutSignature())
+	return create
+}
+
+// CreateMultisig puts a wallet under the control of threshold of signers.
+// From then on its funds can only be moved by a MultisigTransfer. It is signed
+// by the wallet key and the fee is paid by the wallet.
+type CreateMultisig struct {
+	epoch     uint64
+	Wallet    crypto.Token
+	Signers   []crypto.Token
+	Threshold byte
+	Fee       uint64
+	Signature crypto.Signature
+}
+
+func (a *CreateMultisig) Authority() crypto.Token {
+	return crypto.ZeroToken
+}
+
+func (a *CreateMultisig) Epoch() uint64 {
+	return a.epoch
+}
+
+func (create *CreateMultisig) validSigners() bool {
+	if create.Threshold == 0 || int(create.Threshold) > len(create.Signers) {
+		return false
+	}
+	unique := make(map[crypto.Token]struct{})
+	for _, signer := range create.Signers {
+		if _, ok := unique[signer]; ok {
+			return false
+		}
+		unique[signer] = struct{}{}
+	}
+	return true
+}
+
+func (create *CreateMultisig) Validate(v InstructionValidator) error {
+	if !create.validSigners() {
+		return ErrInvalidSigners
+	}
+	wallet := crypto.HashToken(create.Wallet)
+	if ok, _ := v.Multisig(wallet); ok {
+		return ErrMultisigExists
+	}
+	if !v.SetNewMultisig(wallet, MultisigHash(create.Signers, create.Threshold)) {
+		return ErrConflictingInstruction
+	}
+	v.AddFeeCollected(create.Fee)
+	return nil
+}
+
+func (create *CreateMultisig) Payments() *Payment {
+	return NewPayment(crypto.HashToken(create.Wallet), create.Fee)
+}
+
+func (create *CreateMultisig) Kind() byte {
+	return ICreateMultisig
+}
+
+func (create *CreateMultisig) serializeWithoutSignature() []byte {
+	bytes := []byte{0, ICreateMultisig}
+	util.PutUint64(create.epoch, &bytes)
+	util.PutToken(create.Wallet, &bytes)
+	putTokens(create.Signers, &bytes)
+	util.PutByte(create.Threshold, &bytes)
+	util.PutUint64(create.Fee, &bytes)
+	return bytes
+}
+
+func (create *CreateMultisig) Serialize() []byte {
+	bytes := create.serializeWithoutSignature()
+	util.PutSignature(create.Signature, &bytes)
+	return bytes
+}
+
+func ParseCreateMultisig(data []byte) *CreateMultisig {
+	if len(data) < 2 || data[1] != ICreateMultisig {
+		return nil
+	}
+	p := CreateMultisig{}
+	position := 2
+	p.epoch, position = util.ParseUint64(data, position)
+	p.Wallet, position = util.ParseToken(data, position)
+	p.Signers, position = parseTokens(data, position)
+	p.Threshold, position = util.ParseByte(data, position)
+	p.Fee, position = util.ParseUint64(data, position)
+	msgToVerify := data[0:position]
+	p.Signature, _ = util.ParseSignature(data, position)
+	if !p.Wallet.Verify(msgToVerify, p.Signature) {
+		return nil
+	}
+	return &p
+}
+
+// NewMultisigTransfer returns a transfer from a multisig wallet to be signed
+// by at least threshold of signers.
+func NewMultisigTransfer(wallet crypto.Token, signers []crypto.Token, threshold byte, to []Recipient, reason string, epoch, fee uint64) *MultisigTransfer {
+	return &MultisigTransfer{
+		epoch:      epoch,
+		Wallet:     wallet,
+		To:         to,
+		Reason:     reason,
+		Signers:    signers,
+		Threshold:  threshold,
+		Fee:        fee,
+		Signatures: make([]crypto.Signature, len(signers)),
+	}
+}
+
+// MultisigTransfer transfers aero from a multisig wallet to a series of
+// other wallets. It must be signed by at least the threshold of the signers
+// of the wallet. The fee is paid by the wallet.
+type MultisigTransfer struct {
+	epoch      uint64
+	Wallet     crypto.Token
+	To         []Recipient
+	Reason     string
+	Signers    []crypto.Token
+	Threshold  byte
+	Fee        uint64
+	Signatures []crypto.Signature // signatures of signers, zero if absent
+}
+
+// Sign adds the signature of signer. It returns false if it is not one of the
+// signers of the transfer.
+func (t *MultisigTransfer) Sign(signer crypto.PrivateKey) bool {
+	token := signer.PublicKey()
+	for n, other := range t.Signers {
+		if other == token {
+			t.Signatures[n] = signer.Sign(t.serializeWithoutSignatures())
+			return true
+		}
+	}
+	return false
+}
+
+func (a *MultisigTransfer) Authority() crypto.Token {
+	return crypto.ZeroToken
+}
+
+func (a *MultisigTransfer) Epoch() uint64 {
+	return a.epoch
+}
+
+func (t *MultisigTransfer) Payments() *Payment {
+	from := crypto.HashToken(t.Wallet)
+	payment := NewPayment(from, t.Fee)
+	for _, credit := range t.To {
+		payment.NewCredit(crypto.HashToken(credit.Token), credit.Value)
+		payment.NewDebit(from, credit.Value)
+	}
+	return payment
+}
+
+func (t *MultisigTransfer) Validate(v InstructionValidator) error {
+	ok, policy := v.Multisig(crypto.HashToken(t.Wallet))
+	if !ok || policy != MultisigHash(t.Signers, t.Threshold) {
+		return ErrMultisigMismatch
+	}
+	if countSignatures(t.Signatures) < int(t.Threshold) {
+		return ErrInsufficientSignatures
+	}
+	v.AddFeeCollected(t.Fee)
+	return nil
+}
+
+func (t *MultisigTransfer) Kind() byte {
+	return IMultisigTransfer
+}
+
+func (t *MultisigTransfer) serializeWithoutSignatures() []byte {
+	bytes := []byte{0, IMultisigTransfer}
+	util.PutUint64(t.epoch, &bytes)
+	util.PutToken(t.Wallet, &bytes)
+	count := len(t.To)
+	if count > 1<<16-1 {
+		count = 1<<16 - 1
+	}
+	util.PutUint16(uint16(count), &bytes)
+	for n := 0; n < count; n++ {
+		util.PutToken(t.To[n].Token, &bytes)
+		util.PutUint64(t.To[n].Value, &bytes)
+	}
+	util.PutString(t.Reason, &bytes)
+	putTokens(t.Signers, &bytes)
+	util.PutByte(t.Threshold, &bytes)
+	util.PutUint64(t.Fee, &bytes)
+	return bytes
+}
+
+func (t *MultisigTransfer) Serialize() []byte {
+	bytes := t.serializeWithoutSignatures()
+	for _, signature := range t.Signatures {
+		util.PutSignature(signature, &bytes)
+	}
+	return bytes
+}
+
+// ParseMultisigTransfer parses a multisig transfer, checking every signature
+// present.
+func ParseMultisigTransfer(data []byte) *MultisigTransfer {
+	if len(data) < 2 || data[1] != IMultisigTransfer {
+		return nil
+	}
+	p := MultisigTransfer{}
+	position := 2
+	p.epoch, position = util.ParseUint64(data, position)
+	p.Wallet, position = util.ParseToken(data, position)
+	var count uint16
+	count, position = util.ParseUint16(data, position)
+	p.To = make([]Recipient, int(count))
+	for i := 0; i < int(count); i++ {
+		p.To[i].Token, position = util.ParseToken(data, position)
+		p.To[i].Value, position = util.ParseUint64(data, position)
+	}
+	p.Reason, position = util.ParseString(data, position)
+	p.Signers, position = parseTokens(data, position)
+	p.Threshold, position = util.ParseByte(data, position)
+	p.Fee, position = util.ParseUint64(data, position)
+	var ok bool
+	if p.Signatures, ok = parseSignatures(p.Signers, data[0:position], data, position); !ok {
+		return nil
+	}
+	return &p
+}
@@ -0,0 +1,54 @@
+package instructions
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/Aereum/aereum/core/crypto"
+)
+
+func TestCreateMultisig(t *testing.T) {
+	first, _ := crypto.RandomAsymetricKey()
+	second, _ := crypto.RandomAsymetricKey()
+	_, wallet := crypto.RandomAsymetricKey()
+	create := NewCreateMultisig(wallet, []crypto.Token{first, second}, 2, 10, 2000)
+	create2 := ParseCreateMultisig(create.Serialize())
+	if create2 == nil {
+		t.Error("could not parse CreateMultisig")
+		return
+	}
+	if !reflect.DeepEqual(create, create2) {
+		t.Error("Parse and Serialize not working for CreateMultisig")
+	}
+	if !json.Valid([]byte(create.JSON())) {
+		t.Error("invalid create multisig json")
+	}
+}
+
+func TestMultisigTransfer(t *testing.T) {
+	first, firstKey := crypto.RandomAsymetricKey()
+	second, _ := crypto.RandomAsymetricKey()
+	_, other := crypto.RandomAsymetricKey()
+	wallet, _ := crypto.RandomAsymetricKey()
+	to := []Recipient{{Token: token.PublicKey(), Value: 100}}
+	transfer := NewMultisigTransfer(wallet, []crypto.Token{first, second}, 1, to, "treasury", 10, 2000)
+	if transfer.Sign(other) || !transfer.Sign(firstKey) {
+		t.Fatal("transfer signed by wrong signers")
+	}
+	transfer2 := ParseMultisigTransfer(transfer.Serialize())
+	if transfer2 == nil {
+		t.Error("could not parse MultisigTransfer")
+		return
+	}
+	if !reflect.DeepEqual(transfer, transfer2) {
+		t.Error("Parse and Serialize not working for MultisigTransfer")
+	}
+	transfer.Signatures[1] = transfer.Signatures[0]
+	if ParseMultisigTransfer(transfer.Serialize()) != nil {
+		t.Error("MultisigTransfer parsed with invalid signature")
+	}
+	if !json.Valid([]byte(transfer2.JSON())) {
+		t.Error("invalid multisig transfer json")
+	}
+}
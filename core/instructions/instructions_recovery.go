@@ -0,0 +1,396 @@
+package instructions
+
+import (
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/util"
+)
+
+// GuardiansHash returns the commitment to guardians and threshold kept by the
+// state for a member.
+func GuardiansHash(guardians []crypto.Token, threshold byte) crypto.Hash {
+	bytes := []byte{threshold}
+	putTokens(guardians, &bytes)
+	return crypto.Hasher(bytes)
+}
+
+// SetGuardians replaces the guardians of the author and the number of them
+// needed to recovery its identity. No guardians removes them.
+type SetGuardians struct {
+	Authored  *AuthoredInstruction
+	Guardians []crypto.Token
+	Threshold byte
+}
+
+func (a *SetGuardians) Authority() crypto.Token {
+	return a.Authored.Author
+}
+
+func (a *SetGuardians) Epoch() uint64 {
+	return a.Authored.epoch
+}
+
+func (set *SetGuardians) validGuardians() bool {
+	if len(set.Guardians) == 0 {
+		return set.Threshold == 0
+	}
+	if set.Threshold == 0 || int(set.Threshold) > len(set.Guardians) {
+		return false
+	}
+	unique := make(map[crypto.Token]struct{})
+	for _, guardian := range set.Guardians {
+		if _, ok := unique[guardian]; ok || guardian == set.Authored.Author {
+			return false
+		}
+		unique[guardian] = struct{}{}
+	}
+	return true
+}
+
+func (set *SetGuardians) Validate(v InstructionValidator) error {
+	if set.Authored.Attorney != crypto.ZeroToken {
+		return ErrAttorneyNotAllowed
+	}
+	if !v.HasMember(set.Authored.authorHash()) {
+		return ErrNotMember
+	}
+	if !set.validGuardians() {
+		return ErrInvalidGuardians
+	}
+	var guardians crypto.Hash
+	if len(set.Guardians) > 0 {
+		guardians = GuardiansHash(set.Guardians, set.Threshold)
+	}
+	if !v.SetNewGuardians(set.Authored.Author, guardians) {
+		return ErrConflictingInstruction
+	}
+	v.AddFeeCollected(set.Authored.Fee)
+	return nil
+}
+
+func (set *SetGuardians) Payments() *Payment {
+	return set.Authored.payments()
+}
+
+func (set *SetGuardians) Kind() byte {
+	return ISetGuardians
+}
+
+func (set *SetGuardians) serializeBulk() []byte {
+	bytes := make([]byte, 0)
+	putTokens(set.Guardians, &bytes)
+	util.PutByte(set.Threshold, &bytes)
+	return bytes
+}
+
+func (set *SetGuardians) Serialize() []byte {
+	return set.Authored.serialize(ISetGuardians, set.serializeBulk())
+}
+
+func ParseSetGuardians(data []byte) *SetGuardians {
+	if data[0] != 0 || data[1] != ISetGuardians {
+		return nil
+	}
+	set := SetGuardians{
+		Authored: &AuthoredInstruction{},
+	}
+	position := set.Authored.parseHead(data)
+	set.Guardians, position = parseTokens(data, position)
+	set.Threshold, position = util.ParseByte(data, position)
+	if set.Authored.parseTail(data, position) {
+		return &set
+	}
+	return nil
+}
+
+// NewRecoverIdentity returns a recovery of member to the token to be signed by
+// at least threshold of guardians.
+func NewRecoverIdentity(member, to crypto.Token, guardians []crypto.Token, threshold byte, epoch, fee uint64) *RecoverIdentity {
+	return &RecoverIdentity{
+		epoch:      epoch,
+		Member:     member,
+		NewToken:   to,
+		Guardians:  guardians,
+		Threshold:  threshold,
+		Fee:        fee,
+		Signatures: make([]crypto.Signature, len(guardians)),
+	}
+}
+
+// RecoverIdentity starts the recovery of a member who lost its key to a new
+// token. It must be signed by at least the threshold of the guardians of the
+// member, and can be completed by CompleteRecovery after a delay during which
+// the member can cancel it. The fee is paid by the wallet of the member.
+type RecoverIdentity struct {
+	epoch      uint64
+	Member     crypto.Token
+	NewToken   crypto.Token
+	Guardians  []crypto.Token
+	Threshold  byte
+	Fee        uint64
+	Signatures []crypto.Signature // signatures of guardians, zero if absent
+}
+
+// Sign adds the signature of guardian. It returns false if it is not one of
+// the guardians of the recovery.
+func (recovery *RecoverIdentity) Sign(guardian crypto.PrivateKey) bool {
+	token := guardian.PublicKey()
+	for n, other := range recovery.Guardians {
+		if other == token {
+			recovery.Signatures[n] = guardian.Sign(recovery.serializeWithoutSignatures())
+			return true
+		}
+	}
+	return false
+}
+
+func (a *RecoverIdentity) Authority() crypto.Token {
+	return crypto.ZeroToken
+}
+
+func (a *RecoverIdentity) Epoch() uint64 {
+	return a.epoch
+}
+
+func (recovery *RecoverIdentity) Validate(v InstructionValidator) error {
+	member := crypto.HashToken(recovery.Member)
+	if !v.HasMember(member) {
+		return ErrNotMember
+	}
+	ok, guardians := v.Guardians(member)
+	if !ok || guardians != GuardiansHash(recovery.Guardians, recovery.Threshold) {
+		return ErrGuardiansMismatch
+	}
+	if countSignatures(recovery.Signatures) < int(recovery.Threshold) {
+		return ErrInsufficientGuardians
+	}
+	if recovery.NewToken == recovery.Member || v.HasMember(crypto.HashToken(recovery.NewToken)) {
+		return ErrAlreadyMember
+	}
+	if v.Recovery(recovery.Member, recovery.NewToken) != 0 {
+		return ErrRecoveryPending
+	}
+	if !v.SetNewRecovery(recovery.Member, recovery.NewToken) {
+		return ErrConflictingInstruction
+	}
+	v.AddFeeCollected(recovery.Fee)
+	return nil
+}
+
+func (recovery *RecoverIdentity) Payments() *Payment {
+	return NewPayment(crypto.HashToken(recovery.Member), recovery.Fee)
+}
+
+func (recovery *RecoverIdentity) Kind() byte {
+	return IRecoverIdentity
+}
+
+func (recovery *RecoverIdentity) serializeWithoutSignatures() []byte {
+	bytes := []byte{0, IRecoverIdentity}
+	util.PutUint64(recovery.epoch, &bytes)
+	util.PutToken(recovery.Member, &bytes)
+	util.PutToken(recovery.NewToken, &bytes)
+	putTokens(recovery.Guardians, &bytes)
+	util.PutByte(recovery.Threshold, &bytes)
+	util.PutUint64(recovery.Fee, &bytes)
+	return bytes
+}
+
+func (recovery *RecoverIdentity) Serialize() []byte {
+	bytes := recovery.serializeWithoutSignatures()
+	for _, signature := range recovery.Signatures {
+		util.PutSignature(signature, &bytes)
+	}
+	return bytes
+}
+
+// ParseRecoverIdentity parses a recovery, checking every signature present.
+func ParseRecoverIdentity(data []byte) *RecoverIdentity {
+	if len(data) < 2 || data[1] != IRecoverIdentity {
+		return nil
+	}
+	p := RecoverIdentity{}
+	position := 2
+	p.epoch, position = util.ParseUint64(data, position)
+	p.Member, position = util.ParseToken(data, position)
+	p.NewToken, position = util.ParseToken(data, position)
+	p.Guardians, position = parseTokens(data, position)
+	p.Threshold, position = util.ParseByte(data, position)
+	p.Fee, position = util.ParseUint64(data, position)
+	var ok bool
+	if p.Signatures, ok = parseSignatures(p.Guardians, data[0:position], data, position); !ok {
+		return nil
+	}
+	return &p
+}
+
+// CancelRecovery cancels a pending recovery of the author to the token. It
+// must be signed by the author key.
+type CancelRecovery struct {
+	Authored *AuthoredInstruction
+	Token    crypto.Token
+}
+
+func (a *CancelRecovery) Authority() crypto.Token {
+	return a.Authored.Author
+}
+
+func (a *CancelRecovery) Epoch() uint64 {
+	return a.Authored.epoch
+}
+
+func (cancel *CancelRecovery) Validate(v InstructionValidator) error {
+	if cancel.Authored.Attorney != crypto.ZeroToken {
+		return ErrAttorneyNotAllowed
+	}
+	if !v.HasMember(cancel.Authored.authorHash()) {
+		return ErrNotMember
+	}
+	if v.Recovery(cancel.Authored.Author, cancel.Token) == 0 {
+		return ErrNoRecovery
+	}
+	if !v.SetCancelRecovery(cancel.Authored.Author, cancel.Token) {
+		return ErrConflictingInstruction
+	}
+	v.AddFeeCollected(cancel.Authored.Fee)
+	return nil
+}
+
+func (cancel *CancelRecovery) Payments() *Payment {
+	return cancel.Authored.payments()
+}
+
+func (cancel *CancelRecovery) Kind() byte {
+	return ICancelRecovery
+}
+
+func (cancel *CancelRecovery) serializeBulk() []byte {
+	bytes := make([]byte, 0)
+	util.PutToken(cancel.Token, &bytes)
+	return bytes
+}
+
+func (cancel *CancelRecovery) Serialize() []byte {
+	return cancel.Authored.serialize(ICancelRecovery, cancel.serializeBulk())
+}
+
+func ParseCancelRecovery(data []byte) *CancelRecovery {
+	if data[0] != 0 || data[1] != ICancelRecovery {
+		return nil
+	}
+	cancel := CancelRecovery{
+		Authored: &AuthoredInstruction{},
+	}
+	position := cancel.Authored.parseHead(data)
+	cancel.Token, position = util.ParseToken(data, position)
+	if cancel.Authored.parseTail(data, position) {
+		return &cancel
+	}
+	return nil
+}
+
+// NewCompleteRecovery completes the recovery of member to the key to. As with
+// RotateIdentity, attorneys and stages must list every power of attorney
+// granted and every stage owned by member, which are moved to the new token.
+func NewCompleteRecovery(member crypto.Token, to crypto.PrivateKey, attorneys, stages []crypto.Token, epoch, fee uint64) *CompleteRecovery {
+	complete := &CompleteRecovery{
+		epoch:     epoch,
+		Member:    member,
+		NewToken:  to.PublicKey(),
+		Attorneys: attorneys,
+		Stages:    stages,
+		Fee:       fee,
+	}
+	complete.Signature = to.Sign(complete.serializeWithoutSignature())
+	return complete
+}
+
+// CompleteRecovery moves the identity of a member to the token of a recovery
+// whose delay has elapsed, the same way as RotateIdentity. It is signed by the
+// new token and the fee is paid by the wallet of the member.
+type CompleteRecovery struct {
+	epoch     uint64
+	Member    crypto.Token
+	NewToken  crypto.Token
+	Attorneys []crypto.Token
+	Stages    []crypto.Token
+	Fee       uint64
+	Signature crypto.Signature
+}
+
+func (a *CompleteRecovery) Authority() crypto.Token {
+	return crypto.ZeroToken
+}
+
+func (a *CompleteRecovery) Epoch() uint64 {
+	return a.epoch
+}
+
+func (complete *CompleteRecovery) Validate(v InstructionValidator) error {
+	if !v.HasMember(crypto.HashToken(complete.Member)) {
+		return ErrNotMember
+	}
+	if v.HasMember(crypto.HashToken(complete.NewToken)) {
+		return ErrAlreadyMember
+	}
+	release := v.Recovery(complete.Member, complete.NewToken)
+	if release == 0 {
+		return ErrNoRecovery
+	}
+	if release > v.Epoch() {
+		return ErrRecoveryDelay
+	}
+	stages, err := rotationStages(v, complete.Member, complete.Attorneys, complete.Stages)
+	if err != nil {
+		return err
+	}
+	if !v.SetCompleteRecovery(complete.Member, complete.NewToken, complete.Attorneys, stages) {
+		return ErrConflictingInstruction
+	}
+	v.AddFeeCollected(complete.Fee)
+	return nil
+}
+
+func (complete *CompleteRecovery) Payments() *Payment {
+	return NewPayment(crypto.HashToken(complete.Member), complete.Fee)
+}
+
+func (complete *CompleteRecovery) Kind() byte {
+	return ICompleteRecovery
+}
+
+func (complete *CompleteRecovery) serializeWithoutSignature() []byte {
+	bytes := []byte{0, ICompleteRecovery}
+	util.PutUint64(complete.epoch, &bytes)
+	util.PutToken(complete.Member, &bytes)
+	util.PutToken(complete.NewToken, &bytes)
+	putTokens(complete.Attorneys, &bytes)
+	putTokens(complete.Stages, &bytes)
+	util.PutUint64(complete.Fee, &bytes)
+	return bytes
+}
+
+func (complete *CompleteRecovery) Serialize() []byte {
+	bytes := complete.serializeWithoutSignature()
+	util.PutSignature(complete.Signature, &bytes)
+	return bytes
+}
+
+func ParseCompleteRecovery(data []byte) *CompleteRecovery {
+	if len(data) < 2 || data[1] != ICompleteRecovery {
+		return nil
+	}
+	p := CompleteRecovery{}
+	position := 2
+	p.epoch, position = util.ParseUint64(data, position)
+	p.Member, position = util.ParseToken(data, position)
+	p.NewToken, position = util.ParseToken(data, position)
+	p.Attorneys, position = parseTokens(data, position)
+	p.Stages, position = parseTokens(data, position)
+	p.Fee, position = util.ParseUint64(data, position)
+	msgToVerify := data[0:position]
+	p.Signature, _ = util.ParseSignature(data, position)
+	if !p.NewToken.Verify(msgToVerify, p.Signature) {
+		return nil
+	}
+	return &p
+}
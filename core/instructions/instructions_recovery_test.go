@@ -0,0 +1,80 @@
+package instructions
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/Aereum/aereum/core/crypto"
+)
+
+func TestSetGuardians(t *testing.T) {
+	guardian, _ := crypto.RandomAsymetricKey()
+	set := author.NewSetGuardians([]crypto.Token{guardian}, 1, 10, 2000)
+	set2 := ParseSetGuardians(set.Serialize())
+	if set2 == nil {
+		t.Error("could not parse SetGuardians")
+		return
+	}
+	if !reflect.DeepEqual(set, set2) {
+		t.Error("Parse and Serialize not working for SetGuardians")
+	}
+	if !json.Valid([]byte(set.JSON())) {
+		t.Error("invalid set guardians json")
+	}
+}
+
+func TestRecoverIdentity(t *testing.T) {
+	first, firstKey := crypto.RandomAsymetricKey()
+	second, _ := crypto.RandomAsymetricKey()
+	_, other := crypto.RandomAsymetricKey()
+	next, _ := crypto.RandomAsymetricKey()
+	recovery := NewRecoverIdentity(token.PublicKey(), next, []crypto.Token{first, second}, 1, 10, 2000)
+	if recovery.Sign(other) || !recovery.Sign(firstKey) {
+		t.Fatal("recovery signed by wrong guardians")
+	}
+	recovery2 := ParseRecoverIdentity(recovery.Serialize())
+	if recovery2 == nil {
+		t.Error("could not parse RecoverIdentity")
+		return
+	}
+	if !reflect.DeepEqual(recovery, recovery2) {
+		t.Error("Parse and Serialize not working for RecoverIdentity")
+	}
+	recovery.Signatures[1] = recovery.Signatures[0]
+	if ParseRecoverIdentity(recovery.Serialize()) != nil {
+		t.Error("RecoverIdentity parsed with invalid guardian signature")
+	}
+	if !json.Valid([]byte(recovery2.JSON())) {
+		t.Error("invalid recover identity json")
+	}
+}
+
+func TestCancelRecovery(t *testing.T) {
+	next, _ := crypto.RandomAsymetricKey()
+	cancel := author.NewCancelRecovery(next, 10, 2000)
+	cancel2 := ParseCancelRecovery(cancel.Serialize())
+	if cancel2 == nil {
+		t.Error("could not parse CancelRecovery")
+		return
+	}
+	if !reflect.DeepEqual(cancel, cancel2) {
+		t.Error("Parse and Serialize not working for CancelRecovery")
+	}
+}
+
+func TestCompleteRecovery(t *testing.T) {
+	_, next := crypto.RandomAsymetricKey()
+	complete := NewCompleteRecovery(token.PublicKey(), next, []crypto.Token{attorney.PublicKey()}, nil, 10, 2000)
+	complete2 := ParseCompleteRecovery(complete.Serialize())
+	if complete2 == nil {
+		t.Error("could not parse CompleteRecovery")
+		return
+	}
+	if !reflect.DeepEqual(complete, complete2) {
+		t.Error("Parse and Serialize not working for CompleteRecovery")
+	}
+	if !json.Valid([]byte(complete2.JSON())) {
+		t.Error("invalid complete recovery json")
+	}
+}
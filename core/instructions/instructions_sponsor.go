@@ -23,17 +23,20 @@ func (a *SponsorshipOffer) Epoch() uint64 {
 	return a.Authored.epoch
 }
 
-func (sponsored *SponsorshipOffer) Validate(v InstructionValidator) bool {
+func (sponsored *SponsorshipOffer) Validate(v InstructionValidator) error {
 	if !v.HasMember(sponsored.Authored.authorHash()) {
-		return false
+		return ErrNotMember
+	}
+	if err := sponsored.Authored.validateAttorney(v, sponsored, sponsored.Stage); err != nil {
+		return err
 	}
 	stageHash := crypto.HashToken(sponsored.Stage)
 	stageKeys := v.GetAudienceKeys(stageHash)
 	if stageKeys == nil {
-		return false
+		return ErrUnknownStage
 	}
 	if sponsored.Expiry <= v.Epoch() {
-		return false
+		return ErrExpired
 	}
 	var balance uint64
 	if sponsored.Authored.Wallet != crypto.ZeroToken {
@@ -44,14 +47,14 @@ func (sponsored *SponsorshipOffer) Validate(v InstructionValidator) bool {
 		balance = v.Balance(crypto.HashToken(sponsored.Authored.Author))
 	}
 	if sponsored.Revenue+sponsored.Authored.Fee > balance {
-		return false
+		return ErrInsufficientFunds
 	}
 	hash := crypto.Hasher(sponsored.Serialize())
-	if v.SetNewSpnOffer(hash, sponsored.Expiry) {
-		v.AddFeeCollected(sponsored.Authored.Fee)
-		return true
+	if !v.SetNewSpnOffer(hash, sponsored.Expiry) {
+		return ErrConflictingInstruction
 	}
-	return false
+	v.AddFeeCollected(sponsored.Authored.Fee)
+	return nil
 }
 
 func (sponsored *SponsorshipOffer) Payments() *Payment {
@@ -111,34 +114,38 @@ func (a *SponsorshipAcceptance) Epoch() uint64 {
 	return a.Authored.epoch
 }
 
-func (accept *SponsorshipAcceptance) Validate(v InstructionValidator) bool {
-
-	// NAO ENCONTREI A FUNCAO E NAO CONSEGUI MONTAR A FUNCAO
-	// block.validator.setNewHash(accept.offer.content)
+func (accept *SponsorshipAcceptance) Validate(v InstructionValidator) error {
 	if !v.HasMember(accept.Authored.authorHash()) {
-		return false
+		return ErrNotMember
+	}
+	if err := accept.Authored.validateAttorney(v, accept, accept.Stage); err != nil {
+		return err
 	}
 	stageHash := crypto.HashToken(accept.Stage)
 	stageKeys := v.GetAudienceKeys(stageHash)
 	if stageKeys == nil {
-		return false
+		return ErrUnknownStage
 	}
 	if accept.Offer.Expiry < v.Epoch() {
-		return false
+		return ErrExpired
 	}
 	offerHash := crypto.Hasher(accept.Offer.Serialize())
-	if v.SponsorshipOffer(offerHash) == 0 {
-		return false
+	if accept.Offer.Stage != accept.Stage || v.SponsorshipOffer(offerHash) == 0 {
+		return ErrUnknownSponsorOffer
 	}
 	//hash := crypto.Hasher(accept.serializeModBulk())
 	if !stageKeys.Moderate.Verify(accept.serializeModBulk(), accept.modSignature) {
-		return false
+		return ErrInvalidModSignature
+	}
+	if !v.SetNewUseSpnOffer(offerHash) {
+		return ErrConflictingInstruction
 	}
-	if v.SetNewUseSpnOffer(offerHash) {
-		v.AddFeeCollected(accept.Authored.Fee)
-		return true
+	grant := crypto.Hasher(append(accept.Offer.Authored.Author[:], accept.Offer.Stage[:]...))
+	if !v.SetNewGrantSponsor(grant, crypto.Hasher(accept.Offer.Content)) {
+		return ErrConflictingInstruction
 	}
-	return false
+	v.AddFeeCollected(accept.Authored.Fee)
+	return nil
 }
 
 func (accept *SponsorshipAcceptance) Payments() *Payment {
@@ -41,6 +41,7 @@ func NewSingleReciepientTransfer(from crypto.PrivateKey, to crypto.Token, reason
 func NewDeposit(from crypto.PrivateKey, value, epoch, fee uint64) *Deposit {
 	deposit := &Deposit{
 		Token: from.PublicKey(),
+		Value: value,
 		epoch: epoch,
 		Fee:   fee,
 	}
@@ -49,13 +50,38 @@ func NewDeposit(from crypto.PrivateKey, value, epoch, fee uint64) *Deposit {
 }
 
 func NewWithdraw(from crypto.PrivateKey, value, epoch, fee uint64) *Withdraw {
-	deposit := &Withdraw{
+	withdraw := &Withdraw{
 		Token: from.PublicKey(),
+		Value: value,
 		epoch: epoch,
 		Fee:   fee,
 	}
-	deposit.Signature = from.Sign(deposit.serializeWithoutSignature())
-	return deposit
+	withdraw.Signature = from.Sign(withdraw.serializeWithoutSignature())
+	return withdraw
+}
+
+func NewDelegate(from crypto.PrivateKey, validator crypto.Token, value, epoch, fee uint64) *Delegate {
+	delegate := &Delegate{
+		Token:     from.PublicKey(),
+		Validator: validator,
+		Value:     value,
+		epoch:     epoch,
+		Fee:       fee,
+	}
+	delegate.Signature = from.Sign(delegate.serializeWithoutSignature())
+	return delegate
+}
+
+func NewUndelegate(from crypto.PrivateKey, validator crypto.Token, value, epoch, fee uint64) *Undelegate {
+	undelegate := &Undelegate{
+		Token:     from.PublicKey(),
+		Validator: validator,
+		Value:     value,
+		epoch:     epoch,
+		Fee:       fee,
+	}
+	undelegate.Signature = from.Sign(undelegate.serializeWithoutSignature())
+	return undelegate
 }
 
 // Transfer aero from a wallet to a series of other wallets
@@ -69,16 +95,12 @@ type Transfer struct {
 }
 
 func (t *Transfer) Payments() *Payment {
-	total := uint64(0)
-	payment := &Payment{
-		Credit: make([]Wallet, 0),
-		Debit:  make([]Wallet, 0),
-	}
+	from := crypto.HashToken(t.From)
+	payment := NewPayment(from, t.Fee)
 	for _, credit := range t.To {
 		payment.NewCredit(crypto.HashToken(credit.Token), credit.Value)
-		total += credit.Value
+		payment.NewDebit(from, credit.Value)
 	}
-	payment.NewDebit(crypto.HashToken(t.From), total+t.Fee)
 	return payment
 }
 
@@ -86,9 +108,9 @@ func (a *Transfer) Authority() crypto.Token {
 	return crypto.ZeroToken
 }
 
-func (t *Transfer) Validate(v InstructionValidator) bool {
+func (t *Transfer) Validate(v InstructionValidator) error {
 	v.AddFeeCollected(t.Fee)
-	return true
+	return nil
 }
 
 func (a *Transfer) Kind() byte {
@@ -148,7 +170,7 @@ func ParseTransfer(data []byte) *Transfer {
 	return &p
 }
 
-// Deposit aero in a wallet
+// Deposit moves aero from a wallet to the stake of its token
 type Deposit struct {
 	epoch     uint64
 	Token     crypto.Token
@@ -162,12 +184,17 @@ func (a *Deposit) Authority() crypto.Token {
 }
 
 func (d *Deposit) Payments() *Payment {
-	return NewPayment(crypto.HashToken(d.Token), d.Value)
+	payment := NewPayment(crypto.HashToken(d.Token), d.Value)
+	payment.NewDebit(crypto.HashToken(d.Token), d.Fee)
+	return payment
 }
 
-func (t *Deposit) Validate(v InstructionValidator) bool {
+func (t *Deposit) Validate(v InstructionValidator) error {
+	if !v.SetNewDeposit(t.Token, t.Value) {
+		return ErrConflictingInstruction
+	}
 	v.AddFeeCollected(t.Fee)
-	return true
+	return nil
 }
 
 func (a *Deposit) Kind() byte {
@@ -211,7 +238,8 @@ func ParseDeposit(data []byte) *Deposit {
 	return &p
 }
 
-// Withdraw aero from a wallet
+// Withdraw moves aero from the stake of a token back to its wallet once the
+// unbonding period is over
 type Withdraw struct {
 	epoch     uint64
 	Token     crypto.Token
@@ -225,15 +253,19 @@ func (a *Withdraw) Authority() crypto.Token {
 }
 
 func (w *Withdraw) Payments() *Payment {
-	return &Payment{
-		Credit: []Wallet{{crypto.HashToken(w.Token), w.Value}},
-		Debit:  []Wallet{},
-	}
+	return NewPayment(crypto.HashToken(w.Token), w.Fee)
 }
 
-func (t *Withdraw) Validate(v InstructionValidator) bool {
+func (t *Withdraw) Validate(v InstructionValidator) error {
+	hash := crypto.HashToken(t.Token)
+	if v.Stake(hash) < t.Value {
+		return ErrInsufficientStake
+	}
+	if !v.SetNewWithdraw(hash, t.Value) {
+		return ErrConflictingInstruction
+	}
 	v.AddFeeCollected(t.Fee)
-	return true
+	return nil
 }
 
 func (a *Withdraw) Kind() byte {
@@ -276,3 +308,150 @@ func ParseWithdraw(data []byte) *Withdraw {
 	}
 	return &p
 }
+
+// Delegate moves aero from a wallet to the validation pool of a validator
+type Delegate struct {
+	epoch     uint64
+	Token     crypto.Token
+	Validator crypto.Token
+	Value     uint64
+	Fee       uint64
+	Signature crypto.Signature
+}
+
+func (a *Delegate) Authority() crypto.Token {
+	return crypto.ZeroToken
+}
+
+func (d *Delegate) Payments() *Payment {
+	payment := NewPayment(crypto.HashToken(d.Token), d.Value)
+	payment.NewDebit(crypto.HashToken(d.Token), d.Fee)
+	return payment
+}
+
+func (t *Delegate) Validate(v InstructionValidator) error {
+	if v.Stake(crypto.HashToken(t.Validator)) == 0 {
+		return ErrNotValidator
+	}
+	if !v.SetNewDelegation(t.Token, t.Validator, t.Value) {
+		return ErrConflictingInstruction
+	}
+	v.AddFeeCollected(t.Fee)
+	return nil
+}
+
+func (a *Delegate) Kind() byte {
+	return IDelegate
+}
+
+func (a *Delegate) Epoch() uint64 {
+	return a.epoch
+}
+
+func (s *Delegate) serializeWithoutSignature() []byte {
+	bytes := []byte{0, IDelegate}
+	util.PutUint64(s.epoch, &bytes)
+	util.PutToken(s.Token, &bytes)
+	util.PutToken(s.Validator, &bytes)
+	util.PutUint64(s.Value, &bytes)
+	util.PutUint64(s.Fee, &bytes)
+	return bytes
+}
+
+func (s *Delegate) Serialize() []byte {
+	bytes := s.serializeWithoutSignature()
+	util.PutSignature(s.Signature, &bytes)
+	return bytes
+}
+
+func ParseDelegate(data []byte) *Delegate {
+	if len(data) < 2 || data[1] != IDelegate {
+		return nil
+	}
+	p := Delegate{}
+	position := 2
+	p.epoch, position = util.ParseUint64(data, position)
+	p.Token, position = util.ParseToken(data, position)
+	p.Validator, position = util.ParseToken(data, position)
+	p.Value, position = util.ParseUint64(data, position)
+	p.Fee, position = util.ParseUint64(data, position)
+	msgToVerify := data[0:position]
+	p.Signature, _ = util.ParseSignature(data, position)
+	if !p.Token.Verify(msgToVerify, p.Signature) {
+		return nil
+	}
+	return &p
+}
+
+// Undelegate moves aero from the validation pool of a validator back to the
+// wallet of the delegator once the unbonding period is over
+type Undelegate struct {
+	epoch     uint64
+	Token     crypto.Token
+	Validator crypto.Token
+	Value     uint64
+	Fee       uint64
+	Signature crypto.Signature
+}
+
+func (a *Undelegate) Authority() crypto.Token {
+	return crypto.ZeroToken
+}
+
+func (u *Undelegate) Payments() *Payment {
+	return NewPayment(crypto.HashToken(u.Token), u.Fee)
+}
+
+func (t *Undelegate) Validate(v InstructionValidator) error {
+	if v.Delegated(t.Token, t.Validator) < t.Value {
+		return ErrInsufficientDelegation
+	}
+	if !v.SetNewUndelegation(t.Token, t.Validator, t.Value) {
+		return ErrConflictingInstruction
+	}
+	v.AddFeeCollected(t.Fee)
+	return nil
+}
+
+func (a *Undelegate) Kind() byte {
+	return IUndelegate
+}
+
+func (a *Undelegate) Epoch() uint64 {
+	return a.epoch
+}
+
+func (s *Undelegate) serializeWithoutSignature() []byte {
+	bytes := []byte{0, IUndelegate}
+	util.PutUint64(s.epoch, &bytes)
+	util.PutToken(s.Token, &bytes)
+	util.PutToken(s.Validator, &bytes)
+	util.PutUint64(s.Value, &bytes)
+	util.PutUint64(s.Fee, &bytes)
+	return bytes
+}
+
+func (s *Undelegate) Serialize() []byte {
+	bytes := s.serializeWithoutSignature()
+	util.PutSignature(s.Signature, &bytes)
+	return bytes
+}
+
+func ParseUndelegate(data []byte) *Undelegate {
+	if len(data) < 2 || data[1] != IUndelegate {
+		return nil
+	}
+	p := Undelegate{}
+	position := 2
+	p.epoch, position = util.ParseUint64(data, position)
+	p.Token, position = util.ParseToken(data, position)
+	p.Validator, position = util.ParseToken(data, position)
+	p.Value, position = util.ParseUint64(data, position)
+	p.Fee, position = util.ParseUint64(data, position)
+	msgToVerify := data[0:position]
+	p.Signature, _ = util.ParseSignature(data, position)
+	if !p.Token.Verify(msgToVerify, p.Signature) {
+		return nil
+	}
+	return &p
+}
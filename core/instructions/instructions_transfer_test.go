@@ -52,3 +52,18 @@ func TestWithdraw(t *testing.T) {
 	}
 
 }
+
+func TestDelegate(t *testing.T) {
+	_, from := crypto.RandomAsymetricKey()
+	validator, _ := crypto.RandomAsymetricKey()
+	delegate := NewDelegate(from, validator, 10, 10, 10)
+	delegate2 := ParseDelegate(delegate.Serialize())
+	if delegate2 == nil || !reflect.DeepEqual(*delegate, *delegate2) {
+		t.Error("Parse and Serialization not working for Delegate messages.")
+	}
+	undelegate := NewUndelegate(from, validator, 10, 10, 10)
+	undelegate2 := ParseUndelegate(undelegate.Serialize())
+	if undelegate2 == nil || !reflect.DeepEqual(*undelegate, *undelegate2) {
+		t.Error("Parse and Serialization not working for Undelegate messages.")
+	}
+}
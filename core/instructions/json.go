@@ -2,6 +2,7 @@ package instructions
 
 import (
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 
 	"github.com/Aereum/aereum/core/crypto"
@@ -14,15 +15,14 @@ func putReciepientArray(j *util.JSONBuilder, fieldName string, receipients []Rec
 	}
 	array := &util.JSONBuilder{}
 	array.Encode.WriteRune('[')
-	first := true
-	for _, r := range receipients {
-		if !first {
+	for n, r := range receipients {
+		if n > 0 {
 			array.Encode.WriteRune(',')
 		}
-		fmt.Fprintf(&array.Encode, `{"token":"%v","value":%v`, base64.StdEncoding.EncodeToString(r.Token[:]), r.Value)
+		fmt.Fprintf(&array.Encode, `{"token":"%v","value":%v}`, base64.StdEncoding.EncodeToString(r.Token[:]), r.Value)
 	}
 	array.Encode.WriteRune(']')
-	j.PutString(fieldName, array.Encode.String())
+	j.PutJSON(fieldName, array.Encode.String())
 }
 
 func putTokenCiphersJSON(j *util.JSONBuilder, fieldName string, tc TokenCiphers) {
@@ -74,9 +74,111 @@ func (j *UpdateInfo) JSON() string {
 	return j.Authored.JSON(IUpdateInfo, bulk)
 }
 
+func (j *ChangeCaption) JSON() string {
+	bulk := &util.JSONBuilder{}
+	bulk.PutString("caption", j.Caption)
+	return j.Authored.JSON(IChangeCaption, bulk)
+}
+
+func (j *TransferCaption) JSON() string {
+	bulk := &util.JSONBuilder{}
+	bulk.PutString("caption", j.Caption)
+	bulk.PutHex("recipient", j.Recipient[:])
+	return j.Authored.JSON(ITransferCaption, bulk)
+}
+
+func putTokenArray(j *util.JSONBuilder, fieldName string, tokens []crypto.Token) {
+	if len(tokens) == 0 {
+		return
+	}
+	array := &util.JSONBuilder{}
+	array.Encode.WriteRune('[')
+	for n, token := range tokens {
+		if n > 0 {
+			array.Encode.WriteRune(',')
+		}
+		fmt.Fprintf(&array.Encode, `"0x%v"`, hex.EncodeToString(token[:]))
+	}
+	array.Encode.WriteRune(']')
+	j.PutJSON(fieldName, array.Encode.String())
+}
+
+func putSignatureArray(j *util.JSONBuilder, fieldName string, signatures []crypto.Signature) {
+	array := &util.JSONBuilder{}
+	array.Encode.WriteRune('[')
+	for n, signature := range signatures {
+		if n > 0 {
+			array.Encode.WriteRune(',')
+		}
+		fmt.Fprintf(&array.Encode, `"%v"`, base64.StdEncoding.EncodeToString(signature[:]))
+	}
+	array.Encode.WriteRune(']')
+	j.PutJSON(fieldName, array.Encode.String())
+}
+
+func (j *RotateIdentity) JSON() string {
+	bulk := &util.JSONBuilder{}
+	bulk.PutUint64("version", 0)
+	bulk.PutUint64("instructionType", uint64(IRotateIdentity))
+	bulk.PutUint64("epoch", j.epoch)
+	bulk.PutHex("token", j.Token[:])
+	bulk.PutHex("newToken", j.NewToken[:])
+	putTokenArray(bulk, "attorneys", j.Attorneys)
+	putTokenArray(bulk, "stages", j.Stages)
+	bulk.PutUint64("fee", j.Fee)
+	bulk.PutBase64("signature", j.Signature[:])
+	bulk.PutBase64("newSignature", j.NewSignature[:])
+	return bulk.ToString()
+}
+
+func (j *SetGuardians) JSON() string {
+	bulk := &util.JSONBuilder{}
+	putTokenArray(bulk, "guardians", j.Guardians)
+	bulk.PutUint64("threshold", uint64(j.Threshold))
+	return j.Authored.JSON(ISetGuardians, bulk)
+}
+
+func (j *RecoverIdentity) JSON() string {
+	bulk := &util.JSONBuilder{}
+	bulk.PutUint64("version", 0)
+	bulk.PutUint64("instructionType", uint64(IRecoverIdentity))
+	bulk.PutUint64("epoch", j.epoch)
+	bulk.PutHex("member", j.Member[:])
+	bulk.PutHex("newToken", j.NewToken[:])
+	putTokenArray(bulk, "guardians", j.Guardians)
+	bulk.PutUint64("threshold", uint64(j.Threshold))
+	bulk.PutUint64("fee", j.Fee)
+	putSignatureArray(bulk, "signatures", j.Signatures)
+	return bulk.ToString()
+}
+
+func (j *CancelRecovery) JSON() string {
+	bulk := &util.JSONBuilder{}
+	bulk.PutHex("token", j.Token[:])
+	return j.Authored.JSON(ICancelRecovery, bulk)
+}
+
+func (j *CompleteRecovery) JSON() string {
+	bulk := &util.JSONBuilder{}
+	bulk.PutUint64("version", 0)
+	bulk.PutUint64("instructionType", uint64(ICompleteRecovery))
+	bulk.PutUint64("epoch", j.epoch)
+	bulk.PutHex("member", j.Member[:])
+	bulk.PutHex("newToken", j.NewToken[:])
+	putTokenArray(bulk, "attorneys", j.Attorneys)
+	putTokenArray(bulk, "stages", j.Stages)
+	bulk.PutUint64("fee", j.Fee)
+	bulk.PutBase64("signature", j.Signature[:])
+	return bulk.ToString()
+}
+
 func (j *GrantPowerOfAttorney) JSON() string {
 	bulk := &util.JSONBuilder{}
 	bulk.PutHex("details", j.Attorney[:])
+	bulk.PutUint64("kinds", j.Scope.Kinds)
+	bulk.PutUint64("expire", j.Scope.Expire)
+	bulk.PutUint64("limit", j.Scope.Limit)
+	bulk.PutHex("audience", j.Scope.Audience[:])
 	return j.Authored.JSON(IGrantPowerOfAttorney, bulk)
 }
 
@@ -204,6 +306,125 @@ func (j *Transfer) JSON() string {
 	return bulk.ToString()
 }
 
+func (j *CreateMultisig) JSON() string {
+	bulk := &util.JSONBuilder{}
+	bulk.PutUint64("version", 0)
+	bulk.PutUint64("instructionType", uint64(ICreateMultisig))
+	bulk.PutUint64("epoch", j.epoch)
+	bulk.PutHex("wallet", j.Wallet[:])
+	putTokenArray(bulk, "signers", j.Signers)
+	bulk.PutUint64("threshold", uint64(j.Threshold))
+	bulk.PutUint64("fee", j.Fee)
+	bulk.PutBase64("signature", j.Signature[:])
+	return bulk.ToString()
+}
+
+func (j *MultisigTransfer) JSON() string {
+	bulk := &util.JSONBuilder{}
+	bulk.PutUint64("version", 0)
+	bulk.PutUint64("instructionType", uint64(IMultisigTransfer))
+	bulk.PutUint64("epoch", j.epoch)
+	bulk.PutHex("wallet", j.Wallet[:])
+	putReciepientArray(bulk, "to", j.To)
+	bulk.PutString("reason", j.Reason)
+	putTokenArray(bulk, "signers", j.Signers)
+	bulk.PutUint64("threshold", uint64(j.Threshold))
+	bulk.PutUint64("fee", j.Fee)
+	putSignatureArray(bulk, "signatures", j.Signatures)
+	return bulk.ToString()
+}
+
+func (j *EscrowTransfer) JSON() string {
+	bulk := &util.JSONBuilder{}
+	bulk.PutUint64("version", 0)
+	bulk.PutUint64("instructionType", uint64(IEscrowTransfer))
+	bulk.PutUint64("epoch", j.epoch)
+	bulk.PutHex("from", j.From[:])
+	bulk.PutHex("to", j.To[:])
+	bulk.PutString("reason", j.Reason)
+	bulk.PutUint64("value", j.Value)
+	bulk.PutUint64("installments", j.Installments)
+	bulk.PutUint64("period", j.Period)
+	bulk.PutUint64("release", j.Release)
+	bulk.PutUint64("fee", j.Fee)
+	bulk.PutBase64("signature", j.Signature[:])
+	return bulk.ToString()
+}
+
+func (j *CancelEscrow) JSON() string {
+	bulk := &util.JSONBuilder{}
+	bulk.PutUint64("version", 0)
+	bulk.PutUint64("instructionType", uint64(ICancelEscrow))
+	bulk.PutUint64("epoch", j.epoch)
+	bulk.PutHex("token", j.Token[:])
+	bulk.PutHex("escrow", j.Escrow[:])
+	bulk.PutUint64("fee", j.Fee)
+	bulk.PutBase64("signature", j.Signature[:])
+	return bulk.ToString()
+}
+
+func putConflict(j *util.JSONBuilder, c *Conflict) {
+	conflict := &util.JSONBuilder{}
+	conflict.PutUint64("epoch", c.Epoch)
+	conflict.PutHex("first", c.First[:])
+	conflict.PutBase64("firstSignature", c.FirstSignature[:])
+	conflict.PutHex("second", c.Second[:])
+	conflict.PutBase64("secondSignature", c.SecondSignature[:])
+	j.PutJSON("conflict", conflict.ToString())
+}
+
+func (j *DenounceCheckpoint) JSON() string {
+	bulk := &util.JSONBuilder{}
+	bulk.PutUint64("version", 0)
+	bulk.PutUint64("instructionType", uint64(IDenounceCheckpoint))
+	bulk.PutUint64("epoch", j.epoch)
+	bulk.PutHex("author", j.Author[:])
+	bulk.PutHex("offender", j.Offender[:])
+	putConflict(bulk, &j.Conflict)
+	bulk.PutUint64("fee", j.Fee)
+	bulk.PutBase64("signature", j.Signature[:])
+	return bulk.ToString()
+}
+
+func (j *DenounceChecksum) JSON() string {
+	bulk := &util.JSONBuilder{}
+	bulk.PutUint64("version", 0)
+	bulk.PutUint64("instructionType", uint64(IDenounceChecksum))
+	bulk.PutUint64("epoch", j.epoch)
+	bulk.PutHex("author", j.Author[:])
+	bulk.PutHex("offender", j.Offender[:])
+	putConflict(bulk, &j.Conflict)
+	bulk.PutUint64("fee", j.Fee)
+	bulk.PutBase64("signature", j.Signature[:])
+	return bulk.ToString()
+}
+
+func (j *DenounceInstruction) JSON() string {
+	bulk := &util.JSONBuilder{}
+	bulk.PutUint64("version", 0)
+	bulk.PutUint64("instructionType", uint64(IDenounceInstruction))
+	bulk.PutUint64("epoch", j.epoch)
+	bulk.PutHex("author", j.Author[:])
+	bulk.PutHex("offender", j.Offender[:])
+	bulk.PutBase64("header", j.Header)
+	bulk.PutBase64("vote", j.Vote[:])
+	bulk.PutBase64("instruction", j.Instruction)
+	bulk.PutUint64("index", uint64(j.Index))
+	proof := &util.JSONBuilder{}
+	proof.Encode.WriteRune('[')
+	for n, hash := range j.Proof {
+		if n > 0 {
+			proof.Encode.WriteRune(',')
+		}
+		fmt.Fprintf(&proof.Encode, `"0x%v"`, hex.EncodeToString(hash[:]))
+	}
+	proof.Encode.WriteRune(']')
+	bulk.PutJSON("proof", proof.Encode.String())
+	bulk.PutUint64("fee", j.Fee)
+	bulk.PutBase64("signature", j.Signature[:])
+	return bulk.ToString()
+}
+
 func (j *Deposit) JSON() string {
 	bulk := &util.JSONBuilder{}
 	bulk.PutUint64("version", 0)
@@ -219,9 +440,35 @@ func (j *Deposit) JSON() string {
 func (j *Withdraw) JSON() string {
 	bulk := &util.JSONBuilder{}
 	bulk.PutUint64("version", 0)
-	bulk.PutUint64("instructionType", uint64(IDeposit))
+	bulk.PutUint64("instructionType", uint64(IWithdraw))
+	bulk.PutUint64("epoch", j.epoch)
+	bulk.PutHex("token", j.Token[:])
+	bulk.PutUint64("value", j.Value)
+	bulk.PutUint64("fee", j.Fee)
+	bulk.PutBase64("signature", j.Signature[:])
+	return bulk.ToString()
+}
+
+func (j *Delegate) JSON() string {
+	bulk := &util.JSONBuilder{}
+	bulk.PutUint64("version", 0)
+	bulk.PutUint64("instructionType", uint64(IDelegate))
+	bulk.PutUint64("epoch", j.epoch)
+	bulk.PutHex("token", j.Token[:])
+	bulk.PutHex("validator", j.Validator[:])
+	bulk.PutUint64("value", j.Value)
+	bulk.PutUint64("fee", j.Fee)
+	bulk.PutBase64("signature", j.Signature[:])
+	return bulk.ToString()
+}
+
+func (j *Undelegate) JSON() string {
+	bulk := &util.JSONBuilder{}
+	bulk.PutUint64("version", 0)
+	bulk.PutUint64("instructionType", uint64(IUndelegate))
 	bulk.PutUint64("epoch", j.epoch)
 	bulk.PutHex("token", j.Token[:])
+	bulk.PutHex("validator", j.Validator[:])
 	bulk.PutUint64("value", j.Value)
 	bulk.PutUint64("fee", j.Fee)
 	bulk.PutBase64("signature", j.Signature[:])
@@ -3,6 +3,7 @@ package network
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net"
 
 	"github.com/Aereum/aereum/core/consensus"
@@ -33,16 +34,16 @@ func (s *SecureConnection) WriteMessage(msg []byte) error {
 
 func (s *SecureConnection) ReadMessage() ([]byte, error) {
 	nonce := make([]byte, crypto.NonceSize)
-	if n, err := s.conn.Read(nonce); n != crypto.NonceSize {
+	if _, err := io.ReadFull(s.conn, nonce); err != nil {
 		return nil, err
 	}
 	lengthBytes := make([]byte, 4)
-	if n, err := s.conn.Read(lengthBytes); n != 4 {
+	if _, err := io.ReadFull(s.conn, lengthBytes); err != nil {
 		return nil, err
 	}
 	lenght := int(lengthBytes[0]) + (int(lengthBytes[1]) << 8) + (int(lengthBytes[2]) << 16) + (int(lengthBytes[3]) << 24)
 	sealedMsg := make([]byte, lenght)
-	if n, err := s.conn.Read(sealedMsg); n != int(lenght) {
+	if _, err := io.ReadFull(s.conn, sealedMsg); err != nil {
 		return nil, err
 	}
 	if msg, err := s.cipherRemote.OpenNewNonce(sealedMsg, nonce); err != nil {
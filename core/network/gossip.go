@@ -0,0 +1,139 @@
+package network
+
+import (
+	"sync"
+
+	"github.com/Aereum/aereum/core/chain"
+	"github.com/Aereum/aereum/core/consensus"
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/instructions"
+)
+
+// Gossip relays the messages published by a consensus engine to the validator
+// peers and delivers the messages received from the peers to the engine.
+type Gossip struct {
+	mu     sync.Mutex
+	token  crypto.PrivateKey
+	peers  map[crypto.Hash]*SecureConnection
+	comm   *consensus.Communication
+	broker InstructionBroker // receives instructions broadcast by peers, if set
+}
+
+// NewGossip starts relaying the blocks, block signatures and state checksums
+// published by the engine on comm to the peers added to the gossip.
+func NewGossip(token crypto.PrivateKey, comm *consensus.Communication) *Gossip {
+	gossip := &Gossip{
+		token: token,
+		peers: make(map[crypto.Hash]*SecureConnection),
+		comm:  comm,
+	}
+	go gossip.publish()
+	return gossip
+}
+
+// AddPeer broadcasts to conn and delivers the messages read from it until the
+// connection fails.
+func (g *Gossip) AddPeer(conn *SecureConnection) {
+	g.mu.Lock()
+	g.peers[conn.hash] = conn
+	g.mu.Unlock()
+	go g.read(conn)
+}
+
+func (g *Gossip) removePeer(conn *SecureConnection) {
+	g.mu.Lock()
+	if g.peers[conn.hash] == conn {
+		delete(g.peers, conn.hash)
+	}
+	g.mu.Unlock()
+	conn.conn.Close()
+}
+
+func (g *Gossip) publish() {
+	for {
+		select {
+		case block := <-g.comm.Proposal:
+			g.broadcast(NewBlock(block.Serialize()))
+		case signature := <-g.comm.Vote:
+			g.broadcast(BlockValidation(signature.Serialize()))
+		case checksum := <-g.comm.Attestation:
+			g.broadcast(ChecksumBrodcast(checksum.Serialize()))
+		}
+	}
+}
+
+func (g *Gossip) broadcast(msg Serializer) {
+	g.Broadcast(NewNetworkMessage(msg, g.token, false))
+}
+
+// Broadcast sends msg to every peer and drops the peers that cannot be
+// written to.
+func (g *Gossip) Broadcast(msg *NetworkMessageTemplate) {
+	data := msg.Serialize()
+	g.mu.Lock()
+	peers := make([]*SecureConnection, 0, len(g.peers))
+	for _, peer := range g.peers {
+		peers = append(peers, peer)
+	}
+	g.mu.Unlock()
+	for _, peer := range peers {
+		if err := peer.WriteMessage(data); err != nil {
+			g.removePeer(peer)
+		}
+	}
+}
+
+func (g *Gossip) read(conn *SecureConnection) {
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			g.removePeer(conn)
+			return
+		}
+		g.Deliver(data)
+	}
+}
+
+// Deliver passes a message received from a peer to the engine. It returns
+// false if the message is not valid or not meant for the engine.
+func (g *Gossip) Deliver(data []byte) bool {
+	msg := ParseNetworkMessage(data)
+	if msg == nil {
+		return false
+	}
+	switch msg.MessageType {
+	case INewBlock:
+		block, err := chain.ParseBlock(msg.Data.Serialize())
+		if err != nil {
+			return false
+		}
+		g.comm.NewBlock <- block
+		return true
+	case IBlockValidation:
+		signature := consensus.ParseSignature(msg.Data.Serialize())
+		if signature == nil {
+			return false
+		}
+		g.comm.BlockSignature <- signature
+		return true
+	case IBroadcastInstruction:
+		if g.broker == nil {
+			return false
+		}
+		data := msg.Data.Serialize()
+		g.broker <- &HashedInstructionBytes{
+			msg:   data,
+			hash:  crypto.Hasher(data),
+			epoch: int(instructions.GetEpochFromByteArray(data)),
+		}
+		return true
+	case IChecksumBrodcast:
+		checksum := consensus.ParseChecksum(msg.Data.Serialize())
+		if checksum == nil {
+			return false
+		}
+		g.comm.Checksum <- checksum
+		return true
+	}
+	return false
+}
@@ -19,9 +19,14 @@ type HashedInstructionBytes struct {
 
 type InstructionBroker chan *HashedInstructionBytes
 
+// Broadcaster sends a message to every validator peer.
+type Broadcaster interface {
+	Broadcast(msg *NetworkMessageTemplate)
+}
+
 func NewInstructionBroker(
 	token crypto.PrivateKey,
-	peers *ValidatorNetwork,
+	peers Broadcaster,
 	comm *consensus.Communication,
 	newBlockSignal chan uint64,
 	epoch uint64,
@@ -55,10 +60,18 @@ func NewInstructionBroker(
 				}
 			case newEpoch := <-newBlockSignal:
 				deltaEpoch := int(newEpoch) - currentEpoch
-				if deltaEpoch != 1 {
-					panic(fmt.Sprintf("TODO: decide what to do... %v, %v", newEpoch, currentEpoch))
+				if deltaEpoch <= 0 {
+					continue
+				}
+				// epochs without a block are skipped, recentHashes[0] is the
+				// current epoch
+				if deltaEpoch > maxEpochReceiveMessage {
+					deltaEpoch = maxEpochReceiveMessage
+				}
+				for n := 0; n < deltaEpoch; n++ {
+					recent := []map[crypto.Hash]struct{}{make(map[crypto.Hash]struct{})}
+					recentHashes = append(recent, recentHashes[:maxEpochReceiveMessage-1]...)
 				}
-				recentHashes = append(recentHashes[1:], make(map[crypto.Hash]struct{}))
 				currentEpoch = int(newEpoch)
 				fmt.Printf("current epoch: %v\n", currentEpoch)
 			}
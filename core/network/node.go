@@ -25,6 +25,10 @@ type MsgValidator struct {
 
 type MsgValidatorChan chan *MsgValidator
 
+// NewNode connects the consensus engine on comm to the network. Blocks, block
+// signatures, state checksums and instructions are gossiped with the trusted
+// validators and with the validators that connect to validationNodePort.
+// Trusted validators that are not reachable yet are expected to connect back.
 func NewNode(prvKey crypto.PrivateKey,
 	trusted map[crypto.Token]string,
 	comm *consensus.Communication,
@@ -32,8 +36,17 @@ func NewNode(prvKey crypto.PrivateKey,
 ) {
 	//
 	newBlockSignal := make(chan uint64)
-	peers := ValidatorNetwork(ConnectTCPPool(trusted, prvKey))
-	instructionBroker := NewInstructionBroker(prvKey, &peers, comm, newBlockSignal, epoch)
+	gossip := NewGossip(prvKey, comm)
+	instructionBroker := NewInstructionBroker(prvKey, gossip, comm, newBlockSignal, epoch)
+	gossip.broker = instructionBroker
+	go ListenTCP(validationNodePort, gossip.AddPeer, prvKey, comm.ValidateConn)
+	for token, address := range trusted {
+		go func(token crypto.Token, address string) {
+			if conn := ConnectTCP(address, prvKey, token); conn != nil {
+				gossip.AddPeer(conn)
+			}
+		}(token, address)
+	}
 	NewInstructionNetwork(messageReceiveConnectionPort, prvKey, instructionBroker, comm.ValidateConn)
 	attendees := NewAttendeeNetwork(
 		blockBroadcastPort,
@@ -61,7 +61,10 @@ func (msg *NetworkMessageTemplate) serializeWithoutSignatute() []byte {
 	output := []byte{0, msg.MessageType}
 	util.PutUint64(uint64(msg.Timestamp.Unix()), &output)
 	util.PutByteArray(msg.Nonce, &output)
-	util.PutByteArray(msg.Data.Serialize(), &output)
+	// data is prefixed by a 64-bit length since blocks may not fit a byte array
+	data := msg.Data.Serialize()
+	util.PutUint64(uint64(len(data)), &output)
+	output = append(output, data...)
 	if msg.Confirmation {
 		output = append(output, 1)
 	} else {
@@ -76,6 +79,42 @@ func (msg *NetworkMessageTemplate) Serialize() []byte {
 	return output
 }
 
+// ParseNetworkMessage parses a serialized network message. It returns nil if
+// data is not a message or carries a kind of data the node does not parse.
+func ParseNetworkMessage(data []byte) *NetworkMessageTemplate {
+	msg := NetworkMessageTemplate{}
+	position := 0
+	msg.Version, position = util.ParseByte(data, position)
+	msg.MessageType, position = util.ParseByte(data, position)
+	timestamp, position := util.ParseUint64(data, position)
+	msg.Timestamp = time.Unix(int64(timestamp), 0)
+	msg.Nonce, position = util.ParseByteArray(data, position)
+	length, position := util.ParseUint64(data, position)
+	if length > uint64(len(data)-position) {
+		return nil
+	}
+	payload := data[position : position+int(length)]
+	position += int(length)
+	msg.Confirmation, position = util.ParseBool(data, position)
+	msg.Signature, position = util.ParseSignature(data, position)
+	if position != len(data) {
+		return nil
+	}
+	switch msg.MessageType {
+	case IBroadcastInstruction:
+		msg.Data = BroadcastInstruction(payload)
+	case INewBlock:
+		msg.Data = NewBlock(payload)
+	case IBlockValidation:
+		msg.Data = BlockValidation(payload)
+	case IChecksumBrodcast:
+		msg.Data = ChecksumBrodcast(payload)
+	default:
+		return nil
+	}
+	return &msg
+}
+
 type SyncRequest struct{}
 
 func (s *SyncRequest) Serialize() []byte {
@@ -159,13 +198,14 @@ func (s BroadcastInstruction) Kind() byte {
 	return IBroadcastInstruction
 }
 
-type DenounceInstruction struct{}
+// DenounceInstruction carries a serialized instructions.DenounceInstruction evidence instruction.
+type DenounceInstruction []byte
 
-func (s *DenounceInstruction) Serialize() []byte {
-	return []byte{}
+func (s DenounceInstruction) Serialize() []byte {
+	return []byte(s)
 }
 
-func (s *DenounceInstruction) Kind() byte {
+func (s DenounceInstruction) Kind() byte {
 	return IDenounceInstruction
 }
 
@@ -189,33 +229,36 @@ func (s *Pong) Kind() byte {
 	return IPong
 }
 
-type NewBlock struct{}
+// NewBlock carries a serialized chain.Block proposed by the leader of its epoch.
+type NewBlock []byte
 
-func (s *NewBlock) Serialize() []byte {
-	return []byte{}
+func (s NewBlock) Serialize() []byte {
+	return []byte(s)
 }
 
-func (s *NewBlock) Kind() byte {
+func (s NewBlock) Kind() byte {
 	return INewBlock
 }
 
-type BlockValidation struct{}
+// BlockValidation carries a serialized consensus.Signature of a block.
+type BlockValidation []byte
 
-func (s *BlockValidation) Serialize() []byte {
-	return []byte{}
+func (s BlockValidation) Serialize() []byte {
+	return []byte(s)
 }
 
-func (s *BlockValidation) Kind() byte {
+func (s BlockValidation) Kind() byte {
 	return IBlockValidation
 }
 
-type DenounceCheckpoint struct{}
+// DenounceCheckpoint carries a serialized instructions.DenounceCheckpoint evidence instruction.
+type DenounceCheckpoint []byte
 
-func (s *DenounceCheckpoint) Serialize() []byte {
-	return []byte{}
+func (s DenounceCheckpoint) Serialize() []byte {
+	return []byte(s)
 }
 
-func (s *DenounceCheckpoint) Kind() byte {
+func (s DenounceCheckpoint) Kind() byte {
 	return IDenounceCheckpoint
 }
 
@@ -229,23 +272,25 @@ func (s *ChecksumReceive) Kind() byte {
 	return IChecksumReceive
 }
 
-type ChecksumBrodcast struct{}
+// ChecksumBrodcast carries a serialized consensus.Checksum.
+type ChecksumBrodcast []byte
 
-func (s *ChecksumBrodcast) Serialize() []byte {
-	return []byte{}
+func (s ChecksumBrodcast) Serialize() []byte {
+	return []byte(s)
 }
 
-func (s *ChecksumBrodcast) Kind() byte {
+func (s ChecksumBrodcast) Kind() byte {
 	return IChecksumBrodcast
 }
 
-type DenounceChecksum struct{}
+// DenounceChecksum carries a serialized instructions.DenounceChecksum evidence instruction.
+type DenounceChecksum []byte
 
-func (s *DenounceChecksum) Serialize() []byte {
-	return []byte{}
+func (s DenounceChecksum) Serialize() []byte {
+	return []byte(s)
 }
 
-func (s *DenounceChecksum) Kind() byte {
+func (s DenounceChecksum) Kind() byte {
 	return IDenounceChecksum
 }
 
@@ -0,0 +1,34 @@
+package network
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Aereum/aereum/core/crypto"
+)
+
+func TestNetworkMessage(t *testing.T) {
+	_, token := crypto.RandomAsymetricKey()
+	block := make([]byte, 1<<17)
+	for n := range block {
+		block[n] = byte(n)
+	}
+	msg := NewNetworkMessage(NewBlock(block), token, true)
+	parsed := ParseNetworkMessage(msg.Serialize())
+	if parsed == nil {
+		t.Fatal("could not parse network message")
+	}
+	if parsed.MessageType != INewBlock || !parsed.Confirmation || parsed.Signature != msg.Signature {
+		t.Fatal("wrong network message header")
+	}
+	if !token.PublicKey().Verify(parsed.serializeWithoutSignatute(), parsed.Signature) {
+		t.Fatal("signature does not match parsed message")
+	}
+	if !bytes.Equal(parsed.Data.Serialize(), block) {
+		t.Fatal("message data truncated")
+	}
+	data := msg.Serialize()
+	if ParseNetworkMessage(data[:len(data)-1]) != nil {
+		t.Fatal("truncated message parsed")
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2021 The aereum Authors
+// This file is part of the aereum library.
+//
+// The aereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aereum library. If not, see <http://www.gnu.org/licenses/>.
+package store
+
+import (
+	"encoding/binary"
+
+	"github.com/Aereum/aereum/core/crypto"
+)
+
+const scopeSize = 3*8 + crypto.TokenSize
+
+// AttorneyScope restricts what an attorney can sign on behalf of an author.
+type AttorneyScope struct {
+	Kinds    uint64       // bitmask of the instruction kinds allowed
+	Expire   uint64       // last epoch of the grant, zero if it does not expire
+	Limit    uint64       // spending cap per epoch, zero if unlimited
+	Audience crypto.Token // only stage allowed, zero if any
+}
+
+func (s AttorneyScope) serialize() []byte {
+	bytes := make([]byte, scopeSize)
+	binary.LittleEndian.PutUint64(bytes[0:8], s.Kinds)
+	binary.LittleEndian.PutUint64(bytes[8:16], s.Expire)
+	binary.LittleEndian.PutUint64(bytes[16:24], s.Limit)
+	copy(bytes[24:], s.Audience[:])
+	return bytes
+}
+
+func parseScope(bytes []byte) *AttorneyScope {
+	scope := AttorneyScope{
+		Kinds:  binary.LittleEndian.Uint64(bytes[0:8]),
+		Expire: binary.LittleEndian.Uint64(bytes[8:16]),
+		Limit:  binary.LittleEndian.Uint64(bytes[16:24]),
+	}
+	copy(scope.Audience[:], bytes[24:scopeSize])
+	return &scope
+}
+
+// AttorneyVault keeps the scope of every power of attorney granted.
+type AttorneyVault struct {
+	hs *HashStore
+}
+
+// Get returns the scope of the power of attorney hash, or nil if it was not
+// granted.
+func (w *AttorneyVault) Get(hash crypto.Hash) *AttorneyScope {
+	response := make(chan QueryResult)
+	ok, data := w.hs.Query(Query{hash: hash, param: []byte{exists}, response: response})
+	if !ok {
+		return nil
+	}
+	return parseScope(data)
+}
+
+func (w *AttorneyVault) ExistsHash(hash crypto.Hash) bool {
+	return w.Get(hash) != nil
+}
+
+// Set grants the power of attorney hash with scope, replacing any previous
+// scope. It returns true if hash was not granted before.
+func (w *AttorneyVault) Set(hash crypto.Hash, scope AttorneyScope) bool {
+	response := make(chan QueryResult)
+	ok, _ := w.hs.Query(Query{hash: hash, param: append([]byte{insert}, scope.serialize()...), response: response})
+	return ok
+}
+
+func (w *AttorneyVault) RemoveHash(hash crypto.Hash) bool {
+	response := make(chan QueryResult)
+	ok, _ := w.hs.Query(Query{hash: hash, param: []byte{delete}, response: response})
+	return ok
+}
+
+func (w *AttorneyVault) Epoch() uint64 {
+	return w.hs.Epoch()
+}
+
+func (w *AttorneyVault) SetEpoch(epoch uint64) {
+	w.hs.SetEpoch(epoch)
+}
+
+// Hash returns a commitment to the content of the vault.
+func (w *AttorneyVault) Hash() crypto.Hash {
+	return w.hs.Hash()
+}
+
+// Prove returns a proof of the presence or absence of hash on the vault
+// against the vault hash.
+func (w *AttorneyVault) Prove(hash crypto.Hash) *Proof {
+	return w.hs.Prove(hash)
+}
+
+func (w *AttorneyVault) Close() bool {
+	return w.hs.Stop()
+}
+
+// Sync flushes the vault to durable storage.
+func (w *AttorneyVault) Sync() {
+	w.hs.Sync()
+}
+
+// MemoryCopy returns a copy of the vault kept on memory. The copy must be
+// closed when no longer needed.
+func (w *AttorneyVault) MemoryCopy() *AttorneyVault {
+	return &AttorneyVault{hs: w.hs.MemoryCopy()}
+}
+
+func newAttorneyVault(name, path string, epoch uint64, bitsForBucket int64) *AttorneyVault {
+	itemsize := int64(size + scopeSize)
+	bytestore := newByteStore(path, itemsize, bitsForBucket)
+	bucketstore := NewBucketStore(itemsize, 6, bytestore)
+	bucketstore.SetEpoch(epoch)
+	w := &AttorneyVault{
+		hs: NewHashStore(name, bucketstore, int(bitsForBucket), GetSetOrDelete),
+	}
+	w.hs.Start()
+	return w
+}
+
+func NewAttorneyVault(name string, epoch uint64, bitsForBucket int64) *AttorneyVault {
+	return newAttorneyVault(name, "", epoch, bitsForBucket)
+}
+
+// NewFileAttorneyVault creates a new vault persisted on the file at path.
+func NewFileAttorneyVault(path string, epoch uint64, bitsForBucket int64) *AttorneyVault {
+	return newAttorneyVault(path, path, epoch, bitsForBucket)
+}
+
+// OpenFileAttorneyVault reopens a vault persisted on the file at path. It
+// returns nil if the file cannot be opened.
+func OpenFileAttorneyVault(path string) *AttorneyVault {
+	hs := openFileHashStore(path, GetSetOrDelete)
+	if hs == nil {
+		return nil
+	}
+	hs.Start()
+	return &AttorneyVault{hs: hs}
+}
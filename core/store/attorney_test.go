@@ -0,0 +1,37 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Aereum/aereum/core/crypto"
+)
+
+func TestAttorneyVault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "poa.dat")
+	vault := NewFileAttorneyVault(path, 0, 6)
+	hash := crypto.Hasher([]byte("grant"))
+	audience, _ := crypto.RandomAsymetricKey()
+	scope := AttorneyScope{Kinds: 1<<3 | 1<<5, Expire: 100, Limit: 1000, Audience: audience}
+	if vault.Get(hash) != nil {
+		t.Fatal("empty vault returned a scope")
+	}
+	if !vault.Set(hash, AttorneyScope{Kinds: 1}) || vault.Set(hash, scope) {
+		t.Error("set not reporting new grants")
+	}
+	if got := vault.Get(hash); got == nil || *got != scope {
+		t.Errorf("scope not replaced: %v", got)
+	}
+	vault.Close()
+	vault = OpenFileAttorneyVault(path)
+	if vault == nil {
+		t.Fatal("could not reopen vault")
+	}
+	defer vault.Close()
+	if got := vault.Get(hash); got == nil || *got != scope {
+		t.Error("scope not persisted")
+	}
+	if !vault.RemoveHash(hash) || vault.ExistsHash(hash) {
+		t.Error("vault remove not working")
+	}
+}
@@ -21,7 +21,11 @@ package store
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"os"
+
+	"github.com/Aereum/aereum/core/crypto"
 )
 
 const maxCloningBlockSize = 1 << 20
@@ -62,15 +66,46 @@ func (b *BucketStore) toJournal(bucket int64, item byte, oldData, newData []byte
 	b.journal.Append(data)
 }
 
+// bytes reserved at the start of the ByteStore for the header:
+// epoch + version + hash + itemBytes + itemsPerBucket + bitsForBucket
+const bucketHeaderBytes = int64(72)
+
+// bucketStoreVersion is the version of the file format of the stores recorded
+// on the header. It must change whenever the header or the items of any store
+// change. Files without a version were written with a 56 or 64 byte header and
+// sponsor items without the content hash.
+const bucketStoreVersion = uint64(1)
+
+var ErrStoreVersion = errors.New("store file format version not supported: the state must be created again")
+
+// CheckFileVersion returns ErrStoreVersion if the store persisted on the file
+// at path was written with another version of the file format.
+func CheckFileVersion(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	version := make([]byte, 8)
+	if _, err := file.ReadAt(version, 8); err != nil || binary.LittleEndian.Uint64(version) != bucketStoreVersion {
+		return ErrStoreVersion
+	}
+	return nil
+}
+
 // create a new bucket store of size itemBytes
 func NewBucketStore(itemBytes, itemsPerBucket int64, bytes ByteStore) *BucketStore {
-	headerBytes := int64(56) // epoch + hash + itemBytes + itemsPerBucket
+	headerBytes := bucketHeaderBytes
 	if (bytes.Size()-headerBytes)%(itemsPerBucket*itemBytes+8) != 0 {
 		panic("ByteStore size incompatible with bucket store")
 	}
-	header := make([]byte, headerBytes)
-	binary.LittleEndian.PutUint64(header[40:48], uint64(itemBytes))
-	binary.LittleEndian.PutUint64(header[48:56], uint64(itemsPerBucket))
+	version := make([]byte, 8)
+	binary.LittleEndian.PutUint64(version, bucketStoreVersion)
+	bytes.WriteAt(8, version)
+	header := make([]byte, 16)
+	binary.LittleEndian.PutUint64(header[0:8], uint64(itemBytes))
+	binary.LittleEndian.PutUint64(header[8:16], uint64(itemsPerBucket))
+	bytes.WriteAt(48, header)
 	return &BucketStore{
 		bytes:          bytes,
 		bucketCount:    (bytes.Size() - headerBytes) / (itemsPerBucket*itemBytes + 8),
@@ -81,6 +116,61 @@ func NewBucketStore(itemBytes, itemsPerBucket int64, bytes ByteStore) *BucketSto
 	}
 }
 
+// OpenBucketStore returns the bucket store previously persisted on bytes, with
+// item dimensions read from its header. It returns nil if the header is of
+// another version of the file format.
+func OpenBucketStore(bytes ByteStore) *BucketStore {
+	if bytes.Size() < bucketHeaderBytes {
+		panic("ByteStore too small for a bucket store")
+	}
+	if binary.LittleEndian.Uint64(bytes.ReadAt(8, 8)) != bucketStoreVersion {
+		return nil
+	}
+	itemBytes := int64(binary.LittleEndian.Uint64(bytes.ReadAt(48, 8)))
+	itemsPerBucket := int64(binary.LittleEndian.Uint64(bytes.ReadAt(56, 8)))
+	if itemBytes == 0 || itemsPerBucket == 0 {
+		panic("ByteStore has no bucket store header")
+	}
+	return NewBucketStore(itemBytes, itemsPerBucket, bytes)
+}
+
+// Epoch returns the epoch recorded on the header of the store.
+func (b *BucketStore) Epoch() uint64 {
+	return binary.LittleEndian.Uint64(b.bytes.ReadAt(0, 8))
+}
+
+// SetEpoch records epoch on the header of the store.
+func (b *BucketStore) SetEpoch(epoch uint64) {
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint64(data, epoch)
+	b.bytes.WriteAt(0, data)
+}
+
+// Head returns the hash recorded on the header of the store.
+func (b *BucketStore) Head() crypto.Hash {
+	var hash crypto.Hash
+	copy(hash[:], b.bytes.ReadAt(16, crypto.Size))
+	return hash
+}
+
+// SetHead records hash on the header of the store.
+func (b *BucketStore) SetHead(hash crypto.Hash) {
+	b.bytes.WriteAt(16, hash[:])
+}
+
+// BitsForBucket returns the number of bits of the hash used to address the
+// primary buckets of the store, as recorded on the header.
+func (b *BucketStore) BitsForBucket() int {
+	return int(binary.LittleEndian.Uint64(b.bytes.ReadAt(64, 8)))
+}
+
+// SetBitsForBucket records the number of address bits on the header.
+func (b *BucketStore) SetBitsForBucket(bits int) {
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint64(data, uint64(bits))
+	b.bytes.WriteAt(64, data)
+}
+
 // Read the n-th sequential (begining at zero up to bucketCount - 1)
 func (b *BucketStore) ReadBucket(n int64) *Bucket {
 	return &Bucket{
@@ -235,8 +325,8 @@ func (b *Bucket) AppendOverflow() *Bucket {
 // The clone ByteStore is modificated in the process.
 func RecreateBucket(clone ByteStore, journal ByteStore) *BucketStore {
 	// read header
-	itemBytes := int64(binary.LittleEndian.Uint64(clone.ReadAt(40, 8)))
-	itemsPerBucket := int64(binary.LittleEndian.Uint64(clone.ReadAt(48, 8)))
+	itemBytes := int64(binary.LittleEndian.Uint64(clone.ReadAt(48, 8)))
+	itemsPerBucket := int64(binary.LittleEndian.Uint64(clone.ReadAt(56, 8)))
 	bs := NewBucketStore(itemBytes, itemsPerBucket, clone)
 	eof := journal.Size()
 	journalEntry := 2*itemBytes + 9
@@ -0,0 +1,152 @@
+// Copyright 2021 The aereum Authors
+// This file is part of the aereum library.
+//
+// The aereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aereum library. If not, see <http://www.gnu.org/licenses/>.
+package store
+
+import (
+	"encoding/binary"
+
+	"github.com/Aereum/aereum/core/crypto"
+)
+
+const escrowSize = 2*crypto.TokenSize + 2*8
+
+// Escrow is a transfer of aero held by the chain and released to the
+// recipient in installments of value every period epochs.
+type Escrow struct {
+	Payer     crypto.Token
+	Recipient crypto.Token
+	Value     uint64 // aero released per installment
+	Period    uint64 // epochs between installments, zero if released at once
+}
+
+func (e Escrow) serialize() []byte {
+	bytes := make([]byte, escrowSize)
+	copy(bytes[0:crypto.TokenSize], e.Payer[:])
+	copy(bytes[crypto.TokenSize:2*crypto.TokenSize], e.Recipient[:])
+	binary.LittleEndian.PutUint64(bytes[2*crypto.TokenSize:], e.Value)
+	binary.LittleEndian.PutUint64(bytes[2*crypto.TokenSize+8:], e.Period)
+	return bytes
+}
+
+func parseEscrow(bytes []byte) *Escrow {
+	escrow := Escrow{
+		Value:  binary.LittleEndian.Uint64(bytes[2*crypto.TokenSize:]),
+		Period: binary.LittleEndian.Uint64(bytes[2*crypto.TokenSize+8:]),
+	}
+	copy(escrow.Payer[:], bytes[0:crypto.TokenSize])
+	copy(escrow.Recipient[:], bytes[crypto.TokenSize:2*crypto.TokenSize])
+	return &escrow
+}
+
+// EscrowVault keeps the terms of every escrowed transfer not yet fully
+// released.
+type EscrowVault struct {
+	hs *HashStore
+}
+
+// Get returns the terms of the escrow hash, or nil if there is none.
+func (w *EscrowVault) Get(hash crypto.Hash) *Escrow {
+	response := make(chan QueryResult)
+	ok, data := w.hs.Query(Query{hash: hash, param: []byte{exists}, response: response})
+	if !ok {
+		return nil
+	}
+	return parseEscrow(data)
+}
+
+func (w *EscrowVault) ExistsHash(hash crypto.Hash) bool {
+	return w.Get(hash) != nil
+}
+
+// Set replaces the terms of the escrow hash. It returns true if there was no
+// such escrow before.
+func (w *EscrowVault) Set(hash crypto.Hash, escrow Escrow) bool {
+	response := make(chan QueryResult)
+	ok, _ := w.hs.Query(Query{hash: hash, param: append([]byte{insert}, escrow.serialize()...), response: response})
+	return ok
+}
+
+func (w *EscrowVault) RemoveHash(hash crypto.Hash) bool {
+	response := make(chan QueryResult)
+	ok, _ := w.hs.Query(Query{hash: hash, param: []byte{delete}, response: response})
+	return ok
+}
+
+func (w *EscrowVault) Epoch() uint64 {
+	return w.hs.Epoch()
+}
+
+func (w *EscrowVault) SetEpoch(epoch uint64) {
+	w.hs.SetEpoch(epoch)
+}
+
+// Hash returns a commitment to the content of the vault.
+func (w *EscrowVault) Hash() crypto.Hash {
+	return w.hs.Hash()
+}
+
+// Prove returns a proof of the presence or absence of hash on the vault
+// against the vault hash.
+func (w *EscrowVault) Prove(hash crypto.Hash) *Proof {
+	return w.hs.Prove(hash)
+}
+
+func (w *EscrowVault) Close() bool {
+	return w.hs.Stop()
+}
+
+// Sync flushes the vault to durable storage.
+func (w *EscrowVault) Sync() {
+	w.hs.Sync()
+}
+
+// MemoryCopy returns a copy of the vault kept on memory. The copy must be
+// closed when no longer needed.
+func (w *EscrowVault) MemoryCopy() *EscrowVault {
+	return &EscrowVault{hs: w.hs.MemoryCopy()}
+}
+
+func newEscrowVault(name, path string, epoch uint64, bitsForBucket int64) *EscrowVault {
+	itemsize := int64(size + escrowSize)
+	bytestore := newByteStore(path, itemsize, bitsForBucket)
+	bucketstore := NewBucketStore(itemsize, 6, bytestore)
+	bucketstore.SetEpoch(epoch)
+	w := &EscrowVault{
+		hs: NewHashStore(name, bucketstore, int(bitsForBucket), GetSetOrDelete),
+	}
+	w.hs.Start()
+	return w
+}
+
+func NewEscrowVault(name string, epoch uint64, bitsForBucket int64) *EscrowVault {
+	return newEscrowVault(name, "", epoch, bitsForBucket)
+}
+
+// NewFileEscrowVault creates a new vault persisted on the file at path.
+func NewFileEscrowVault(path string, epoch uint64, bitsForBucket int64) *EscrowVault {
+	return newEscrowVault(path, path, epoch, bitsForBucket)
+}
+
+// OpenFileEscrowVault reopens a vault persisted on the file at path. It
+// returns nil if the file cannot be opened.
+func OpenFileEscrowVault(path string) *EscrowVault {
+	hs := openFileHashStore(path, GetSetOrDelete)
+	if hs == nil {
+		return nil
+	}
+	hs.Start()
+	return &EscrowVault{hs: hs}
+}
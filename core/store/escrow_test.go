@@ -0,0 +1,35 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Aereum/aereum/core/crypto"
+)
+
+func TestEscrowVault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "escrows.dat")
+	vault := NewFileEscrowVault(path, 0, 6)
+	hash := crypto.Hasher([]byte("escrow"))
+	payer, _ := crypto.RandomAsymetricKey()
+	recipient, _ := crypto.RandomAsymetricKey()
+	escrow := Escrow{Payer: payer, Recipient: recipient, Value: 100, Period: 10}
+	if vault.Get(hash) != nil {
+		t.Fatal("empty vault returned an escrow")
+	}
+	if !vault.Set(hash, escrow) {
+		t.Error("set not reporting new escrow")
+	}
+	vault.Close()
+	vault = OpenFileEscrowVault(path)
+	if vault == nil {
+		t.Fatal("could not reopen vault")
+	}
+	defer vault.Close()
+	if got := vault.Get(hash); got == nil || *got != escrow {
+		t.Errorf("escrow not persisted: %v", got)
+	}
+	if !vault.RemoveHash(hash) || vault.ExistsHash(hash) {
+		t.Error("vault remove not working")
+	}
+}
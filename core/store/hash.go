@@ -93,20 +93,78 @@ func (w *HashVault) RemoveToken(token crypto.Token) bool {
 	return w.RemoveHash(hash)
 }
 
+func (w *HashVault) Epoch() uint64 {
+	return w.hs.Epoch()
+}
+
+func (w *HashVault) SetEpoch(epoch uint64) {
+	w.hs.SetEpoch(epoch)
+}
+
+// Head returns the block hash recorded on the vault header.
+func (w *HashVault) Head() crypto.Hash {
+	return w.hs.Head()
+}
+
+// SetHead records on the vault header the hash of the block that produced the
+// state it belongs to.
+func (w *HashVault) SetHead(hash crypto.Hash) {
+	w.hs.SetHead(hash)
+}
+
+// Hash returns a commitment to the content of the vault.
+func (w *HashVault) Hash() crypto.Hash {
+	return w.hs.Hash()
+}
+
+// Prove returns a proof of the presence or absence of hash on the vault
+// against the vault hash.
+func (w *HashVault) Prove(hash crypto.Hash) *Proof {
+	return w.hs.Prove(hash)
+}
+
 func (w *HashVault) Close() bool {
-	ok := make(chan bool)
-	w.hs.stop <- ok
-	return <-ok
+	return w.hs.Stop()
 }
 
-func NewHashVault(name string, epoch uint64, bitsForBucket int64) *HashVault {
-	nbytes := 56 + (32*6+8)*int64(1<<bitsForBucket)
-	bytestore := NewMemoryStore(nbytes)
+// Sync flushes the vault to durable storage.
+func (w *HashVault) Sync() {
+	w.hs.Sync()
+}
+
+// MemoryCopy returns a copy of the vault kept on memory. The copy must be
+// closed when no longer needed.
+func (w *HashVault) MemoryCopy() *HashVault {
+	return &HashVault{hs: w.hs.MemoryCopy()}
+}
+
+func newHashVault(name, path string, epoch uint64, bitsForBucket int64) *HashVault {
+	bytestore := newByteStore(path, 32, bitsForBucket)
 	bucketstore := NewBucketStore(32, 6, bytestore)
+	bucketstore.SetEpoch(epoch)
 	vault := &HashVault{
 		hs: NewHashStore(name, bucketstore, int(bitsForBucket), DeleteOrInsert),
 	}
 	vault.hs.Start()
 	return vault
+}
 
+func NewHashVault(name string, epoch uint64, bitsForBucket int64) *HashVault {
+	return newHashVault(name, "", epoch, bitsForBucket)
+}
+
+// NewFileHashVault creates a new hash vault persisted on the file at path.
+func NewFileHashVault(path string, epoch uint64, bitsForBucket int64) *HashVault {
+	return newHashVault(path, path, epoch, bitsForBucket)
+}
+
+// OpenFileHashVault reopens a hash vault persisted on the file at path. It
+// returns nil if the file cannot be opened.
+func OpenFileHashVault(path string) *HashVault {
+	hs := openFileHashStore(path, DeleteOrInsert)
+	if hs == nil {
+		return nil
+	}
+	hs.Start()
+	return &HashVault{hs: hs}
 }
@@ -1,6 +1,8 @@
 package store
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/Aereum/aereum/core/crypto"
@@ -18,3 +20,114 @@ func TestHashVault(t *testing.T) {
 	}
 
 }
+
+func TestHashVaultRemoveKeepsChain(t *testing.T) {
+	vault := NewHashVault("teste", 0, 6)
+	hashes := make([]crypto.Hash, 0)
+	// force long bucket chains on a few buckets
+	for n := 0; len(hashes) < 40; n++ {
+		hash := crypto.Hasher([]byte{byte(n), byte(n >> 8)})
+		if hash.ToInt64()&3 == 0 {
+			vault.InsertHash(hash)
+			hashes = append(hashes, hash)
+		}
+	}
+	for n, hash := range hashes {
+		if n%3 == 0 && !vault.RemoveHash(hash) {
+			t.Fatalf("vault remove not working")
+		}
+	}
+	for n, hash := range hashes {
+		if vault.ExistsHash(hash) != (n%3 != 0) {
+			t.Fatalf("vault remove corrupted bucket chain")
+		}
+	}
+}
+
+func TestFileHashVault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.dat")
+	vault := NewFileHashVault(path, 0, 6)
+	hashes := make([]crypto.Hash, 1000)
+	for n := range hashes {
+		hashes[n] = crypto.Hasher([]byte{byte(n), byte(n >> 8)})
+		vault.InsertHash(hashes[n])
+	}
+	vault.RemoveHash(hashes[0])
+	vault.SetEpoch(12)
+	vault.Close()
+	vault = OpenFileHashVault(path)
+	if vault == nil {
+		t.Fatalf("could not reopen file vault")
+	}
+	defer vault.Close()
+	if vault.Epoch() != 12 {
+		t.Errorf("vault epoch not persisted")
+	}
+	if vault.ExistsHash(hashes[0]) {
+		t.Errorf("removed hash persisted")
+	}
+	for _, hash := range hashes[1:] {
+		if !vault.ExistsHash(hash) {
+			t.Fatalf("hash not persisted")
+		}
+	}
+}
+
+func TestFileVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.dat")
+	NewFileHashVault(path, 0, 6).Close()
+	if err := CheckFileVersion(path); err != nil {
+		t.Fatalf("current version rejected: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteAt(make([]byte, 8), 8)
+	file.Close()
+	if err := CheckFileVersion(path); err != ErrStoreVersion {
+		t.Fatalf("file without version accepted: %v", err)
+	}
+	if OpenFileHashVault(path) != nil {
+		t.Fatal("vault without version opened")
+	}
+}
+
+func TestMemoryCopy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.dat")
+	vault := NewFileHashVault(path, 3, 6)
+	defer vault.Close()
+	first := crypto.Hasher([]byte("first"))
+	vault.InsertHash(first)
+	clone := vault.MemoryCopy()
+	defer clone.Close()
+	second := crypto.Hasher([]byte("second"))
+	clone.InsertHash(second)
+	clone.RemoveHash(first)
+	if !vault.ExistsHash(first) || vault.ExistsHash(second) {
+		t.Fatal("changes to the copy reached the vault")
+	}
+	if clone.ExistsHash(first) || !clone.ExistsHash(second) || clone.Epoch() != 3 {
+		t.Fatal("copy does not hold the vault content")
+	}
+}
+
+func TestHashVaultHash(t *testing.T) {
+	small := NewHashVault("small", 0, 6)
+	large := NewHashVault("large", 0, 8)
+	hashes := make([]crypto.Hash, 500)
+	for n := range hashes {
+		hashes[n] = crypto.Hasher([]byte{byte(n), byte(n >> 8)})
+		small.InsertHash(hashes[n])
+	}
+	for n := len(hashes) - 1; n >= 0; n-- {
+		large.InsertHash(hashes[n])
+	}
+	if small.Hash() != large.Hash() {
+		t.Fatal("vault hash depends on insertion order or number of buckets")
+	}
+	large.RemoveHash(hashes[0])
+	if small.Hash() == large.Hash() {
+		t.Fatal("vault hash does not depend on content")
+	}
+}
@@ -85,19 +85,75 @@ func (w *HashExpireVault) Remove(hash crypto.Hash) bool {
 	return ok
 }
 
+// All returns every hash of the vault together with its expire epoch, sorted
+// by hash.
+func (w *HashExpireVault) All() ([]crypto.Hash, []uint64) {
+	items := w.hs.Items()
+	hashes := make([]crypto.Hash, len(items))
+	expires := make([]uint64, len(items))
+	for n, item := range items {
+		copy(hashes[n][:], item[0:size])
+		expires[n] = binary.LittleEndian.Uint64(item[size:])
+	}
+	return hashes, expires
+}
+
+func (w *HashExpireVault) Epoch() uint64 {
+	return w.hs.Epoch()
+}
+
+func (w *HashExpireVault) SetEpoch(epoch uint64) {
+	w.hs.SetEpoch(epoch)
+}
+
+// Hash returns a commitment to the content of the vault.
+func (w *HashExpireVault) Hash() crypto.Hash {
+	return w.hs.Hash()
+}
+
 func (w *HashExpireVault) Close() bool {
-	ok := make(chan bool)
-	w.hs.stop <- ok
-	return <-ok
+	return w.hs.Stop()
 }
 
-func NewExpireHashVault(name string, epoch uint64, bitsForBucket int64) *HashExpireVault {
-	nbytes := 56 + (40*6+8)*int64(1<<bitsForBucket)
-	bytestore := NewMemoryStore(nbytes)
+// Sync flushes the vault to durable storage.
+func (w *HashExpireVault) Sync() {
+	w.hs.Sync()
+}
+
+// MemoryCopy returns a copy of the vault kept on memory. The copy must be
+// closed when no longer needed.
+func (w *HashExpireVault) MemoryCopy() *HashExpireVault {
+	return &HashExpireVault{hs: w.hs.MemoryCopy()}
+}
+
+func newExpireHashVault(name, path string, epoch uint64, bitsForBucket int64) *HashExpireVault {
+	bytestore := newByteStore(path, 40, bitsForBucket)
 	bucketstore := NewBucketStore(40, 6, bytestore)
+	bucketstore.SetEpoch(epoch)
 	vault := &HashExpireVault{
 		hs: NewHashStore(name, bucketstore, int(bitsForBucket), DeleteOrInsertExpire),
 	}
 	vault.hs.Start()
 	return vault
 }
+
+func NewExpireHashVault(name string, epoch uint64, bitsForBucket int64) *HashExpireVault {
+	return newExpireHashVault(name, "", epoch, bitsForBucket)
+}
+
+// NewFileExpireHashVault creates a new expire hash vault persisted on the file
+// at path.
+func NewFileExpireHashVault(path string, epoch uint64, bitsForBucket int64) *HashExpireVault {
+	return newExpireHashVault(path, path, epoch, bitsForBucket)
+}
+
+// OpenFileExpireHashVault reopens an expire hash vault persisted on the file at
+// path. It returns nil if the file cannot be opened.
+func OpenFileExpireHashVault(path string) *HashExpireVault {
+	hs := openFileHashStore(path, DeleteOrInsertExpire)
+	if hs == nil {
+		return nil
+	}
+	hs.Start()
+	return &HashExpireVault{hs: hs}
+}
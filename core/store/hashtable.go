@@ -18,6 +18,7 @@
 package store
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"fmt"
 	"sort"
@@ -80,6 +81,16 @@ type HashStore struct {
 	doubleJob        chan int64
 	cloneJob         chan int64
 	stop             chan chan bool
+	sync             chan chan bool
+	copy             chan chan *HashStore
+	setEpoch         chan uint64
+	getEpoch         chan chan uint64
+	setHead          chan crypto.Hash
+	getHead          chan chan crypto.Hash
+	hash             chan chan crypto.Hash
+	pendingHash      []chan crypto.Hash
+	items            chan chan [][]byte
+	pendingItems     []chan [][]byte
 	clone            chan chan bool
 	cloned           chan bool
 	isDoubling       bool
@@ -91,6 +102,7 @@ func NewHashStore(name string, buckets *BucketStore, bitsForBucket int, operatio
 	if bitsForBucket < 6 {
 		panic("bitsForBucket too small")
 	}
+	buckets.SetBitsForBucket(bitsForBucket)
 	return &HashStore{
 		name:             name,
 		store:            buckets,
@@ -103,6 +115,16 @@ func NewHashStore(name string, buckets *BucketStore, bitsForBucket int, operatio
 		query:            make(chan Query),
 		doubleJob:        make(chan int64),
 		stop:             make(chan chan bool),
+		sync:             make(chan chan bool),
+		copy:             make(chan chan *HashStore),
+		setEpoch:         make(chan uint64),
+		getEpoch:         make(chan chan uint64),
+		setHead:          make(chan crypto.Hash),
+		getHead:          make(chan chan crypto.Hash),
+		hash:             make(chan chan crypto.Hash),
+		pendingHash:      make([]chan crypto.Hash, 0),
+		items:            make(chan chan [][]byte),
+		pendingItems:     make([]chan [][]byte, 0),
 		cloneJob:         make(chan int64),
 		clone:            make(chan chan bool),
 		cloned:           make(chan bool),
@@ -112,6 +134,141 @@ func NewHashStore(name string, buckets *BucketStore, bitsForBucket int, operatio
 	}
 }
 
+// OpenHashStore returns a hash store over buckets previously populated by
+// another hash store (typically persisted on a file). The address bits are
+// read from the bucket store header and the number of items of every bucket
+// chain is recounted.
+func OpenHashStore(name string, buckets *BucketStore, operation QueryOperation) *HashStore {
+	hs := NewHashStore(name, buckets, buckets.BitsForBucket(), operation)
+	empty := make([]byte, size)
+	for n := int64(0); n < 1<<hs.bitsForBucket; n++ {
+		count := 0
+		bucket := buckets.ReadBucket(n)
+	chain:
+		for bucket != nil {
+			for item := int64(0); item < buckets.itemsPerBucket; item++ {
+				if bytes.Equal(bucket.ReadItem(item)[0:size], empty) {
+					break chain
+				}
+				count++
+			}
+			bucket = bucket.NextBucket()
+		}
+		hs.bitsCount[n] = count
+	}
+	return hs
+}
+
+// newByteStore returns a byte store large enough for a hash store of
+// 1<<bitsForBucket buckets of six items of itemBytes bytes. Data is kept on
+// memory if path is empty or on a new file at path otherwise.
+func newByteStore(path string, itemBytes, bitsForBucket int64) ByteStore {
+	nbytes := bucketHeaderBytes + int64(1<<bitsForBucket)*(itemBytes*6+8)
+	if path == "" {
+		return NewMemoryStore(nbytes)
+	}
+	return NewFileStore(path, nbytes)
+}
+
+// openFileHashStore reopens a hash store persisted on the file at path. It
+// returns nil if the file cannot be opened or is of another format version.
+func openFileHashStore(path string, operation QueryOperation) *HashStore {
+	bytes := OpenFileStore(path)
+	if bytes == nil {
+		return nil
+	}
+	buckets := OpenBucketStore(bytes)
+	if buckets == nil {
+		bytes.Close()
+		return nil
+	}
+	return OpenHashStore(path, buckets, operation)
+}
+
+// SetEpoch records on the store header the epoch of the state it represents.
+func (hs *HashStore) SetEpoch(epoch uint64) {
+	hs.setEpoch <- epoch
+}
+
+// Epoch returns the epoch recorded on the store header.
+func (hs *HashStore) Epoch() uint64 {
+	response := make(chan uint64)
+	hs.getEpoch <- response
+	return <-response
+}
+
+// SetHead records on the store header the hash of the block that produced
+// the state it represents.
+func (hs *HashStore) SetHead(hash crypto.Hash) {
+	hs.setHead <- hash
+}
+
+// Head returns the block hash recorded on the store header.
+func (hs *HashStore) Head() crypto.Hash {
+	response := make(chan crypto.Hash)
+	hs.getHead <- response
+	return <-response
+}
+
+// Stop waits for any duplication or cloning job in progress to complete,
+// terminates the store goroutine and closes the underlying byte store.
+func (hs *HashStore) Stop() bool {
+	for {
+		ok := make(chan bool)
+		hs.stop <- ok
+		if <-ok {
+			return true
+		}
+		time.Sleep(cloneInterval)
+	}
+}
+
+// Sync waits for any duplication in progress to complete and flushes the
+// store to durable storage.
+func (hs *HashStore) Sync() {
+	for {
+		ok := make(chan bool)
+		hs.sync <- ok
+		if <-ok {
+			return
+		}
+		time.Sleep(cloneInterval)
+	}
+}
+
+// MemoryCopy waits for any duplication in progress to complete and returns a
+// started copy of the store kept on memory. Changes to the copy do not affect
+// the store.
+func (hs *HashStore) MemoryCopy() *HashStore {
+	for {
+		response := make(chan *HashStore)
+		hs.copy <- response
+		if clone := <-response; clone != nil {
+			return clone
+		}
+		time.Sleep(cloneInterval)
+	}
+}
+
+func (hs *HashStore) memoryCopy() *HashStore {
+	size := hs.store.bytes.Size()
+	bytes := NewMemoryStore(size)
+	bytes.WriteAt(0, hs.store.bytes.ReadAt(0, size))
+	buckets := &BucketStore{
+		bytes:          bytes,
+		bucketCount:    hs.store.bucketCount,
+		itemBytes:      hs.store.itemBytes,
+		bucketBytes:    hs.store.bucketBytes,
+		itemsPerBucket: hs.store.itemsPerBucket,
+		headerBytes:    hs.store.headerBytes,
+	}
+	clone := NewHashStore(hs.name, buckets, hs.bitsForBucket, hs.operation)
+	clone.bitsCount = append([]int{}, hs.bitsCount...)
+	clone.freeOverflows = append([]int64{}, hs.freeOverflows...)
+	clone.Start()
+	return clone
+}
+
 func (hs *HashStore) Query(q Query) (bool, []byte) {
 	hs.query <- q
 	resp := <-q.response
@@ -131,15 +288,58 @@ func (hs *HashStore) Start() {
 				hs.StartCloning()
 			case <-hs.cloneJob:
 				hs.continueCloning()
+			case epoch := <-hs.setEpoch:
+				hs.store.SetEpoch(epoch)
+				if hs.isDoubling {
+					hs.newHashStore.store.SetEpoch(epoch)
+				}
+			case response := <-hs.getEpoch:
+				response <- hs.store.Epoch()
+			case hash := <-hs.setHead:
+				hs.store.SetHead(hash)
+				if hs.isDoubling {
+					hs.newHashStore.store.SetHead(hash)
+				}
+			case response := <-hs.getHead:
+				response <- hs.store.Head()
+			case response := <-hs.hash:
+				if hs.isDoubling {
+					hs.pendingHash = append(hs.pendingHash, response)
+				} else {
+					response <- hs.hashItems()
+				}
+			case response := <-hs.items:
+				if hs.isDoubling {
+					hs.pendingItems = append(hs.pendingItems, response)
+				} else {
+					response <- hs.sortedItems()
+				}
+			case ok := <-hs.sync:
+				// during duplication part of the items are only on the new store
+				if hs.isDoubling {
+					ok <- false
+					continue
+				}
+				hs.store.bytes.Sync()
+				ok <- true
+			case response := <-hs.copy:
+				// during duplication part of the items are only on the new store
+				if hs.isDoubling {
+					response <- nil
+					continue
+				}
+				response <- hs.memoryCopy()
 			case ok := <-hs.stop:
 				// wait until cloning and doubling is complete
 				if hs.store.isCloning || hs.isDoubling {
 					ok <- false
+					continue
 				}
 				close(hs.query)
 				close(hs.doubleJob)
 				close(hs.cloneJob)
 				close(hs.stop)
+				hs.store.bytes.Close()
 				ok <- true
 				return
 			}
@@ -181,7 +381,7 @@ func (ws *HashStore) findAndOperate(q Query) QueryResult {
 func (ws *HashStore) ProcessMutation(hashMask int64, added *Item, deleted *Item, count int) {
 	if added != nil {
 		ws.bitsCount[hashMask] += 1
-		if added.item == ws.store.itemsPerBucket-1 {
+		if added.item == ws.store.itemsPerBucket-1 && added.bucket.ReadOverflow() == 0 {
 			if len(ws.freeOverflows) > 0 {
 				added.bucket.WriteOverflow(ws.freeOverflows[0])
 				ws.freeOverflows = ws.freeOverflows[1:]
@@ -194,28 +394,19 @@ func (ws *HashStore) ProcessMutation(hashMask int64, added *Item, deleted *Item,
 		}
 	}
 	if deleted != nil {
-		lastItem := ws.bitsCount[hashMask] - 1
+		// items of a bucket chain are kept contiguous: the last item of the
+		// chain is moved into the position of the deleted one.
+		lastItem := int64(ws.bitsCount[hashMask] - 1)
 		ws.bitsCount[hashMask] -= 1
-		if count == lastItem {
-			deleted.bucket.WriteItem(deleted.item, make([]byte, ws.store.itemBytes))
-			return
+		lastBucket := ws.store.ReadBucket(hashMask)
+		for n := int64(0); n < lastItem/ws.store.itemsPerBucket; n++ {
+			lastBucket = lastBucket.NextBucket()
 		}
-		var previousBucket *Bucket
-		lastBucket := deleted.bucket
-		for {
-			if nextBucket := lastBucket.NextBucket(); nextBucket != nil {
-				previousBucket = lastBucket
-				lastBucket = nextBucket
-			} else {
-				item := lastItem % int(ws.store.itemsPerBucket)
-				lastBucket.WriteItem(int64(item), make([]byte, ws.store.itemBytes))
-				if item == 0 && previousBucket != nil {
-					ws.freeOverflows = append(ws.freeOverflows, lastBucket.n)
-					previousBucket.WriteOverflow(0)
-				}
-				break
-			}
+		item := lastItem % ws.store.itemsPerBucket
+		if lastBucket.n != deleted.bucket.n || item != deleted.item {
+			deleted.bucket.WriteItem(deleted.item, lastBucket.ReadItem(item))
 		}
+		lastBucket.WriteItem(item, make([]byte, ws.store.itemBytes))
 	}
 }
 
@@ -273,6 +464,20 @@ func (w *HashStore) continueDuplication(bucket int64) {
 		w.bitsTransferered = 0
 		w.newHashStore = nil
 		w.isReady = true
+		if len(w.pendingHash) > 0 {
+			hash := w.hashItems()
+			for _, response := range w.pendingHash {
+				response <- hash
+			}
+			w.pendingHash = w.pendingHash[:0]
+		}
+		if len(w.pendingItems) > 0 {
+			items := w.sortedItems()
+			for _, response := range w.pendingItems {
+				response <- items
+			}
+			w.pendingItems = w.pendingItems[:0]
+		}
 	}
 }
 
@@ -345,25 +550,34 @@ func (ia itemsArray) Swap(i, j int) {
 	ia[i], ia[j] = ia[j], ia[i]
 }
 
+// Hash returns a commitment to the content of the store: the hash of the
+// number of items and of the Merkle root of the items sorted by hash. The
+// result does not depend on the number of buckets or on the order of
+// insertion. If a duplication is in progress, the hash is computed after it
+// completes.
 func (hs *HashStore) Hash() crypto.Hash {
-	hasharray := make([]byte, 0)
-	hashBlock := 256 * 256 * 16 * hs.store.itemBytes
-	bucketCollection := make([]byte, 0, hashBlock)
+	response := make(chan crypto.Hash)
+	hs.hash <- response
+	return <-response
+}
+
+// Items returns a copy of every item of the store sorted by hash.
+func (hs *HashStore) Items() [][]byte {
+	response := make(chan [][]byte)
+	hs.items <- response
+	return <-response
+}
+
+func (hs *HashStore) sortedItems() itemsArray {
+	items := make(itemsArray, 0)
 	for n := int64(0); n < 1<<hs.bitsForBucket; n++ {
-		buckets := itemsArray(hs.store.ReadBucket(n).ReadBulk(int64(hs.bitsCount[n])))
-		sort.Sort(buckets)
-		for _, b := range buckets {
-			bucketCollection = append(bucketCollection, b...)
-			if len(bucketCollection) >= int(hashBlock) {
-				hash := sha256.Sum256(bucketCollection)
-				hasharray = append(hasharray, hash[:]...)
-				bucketCollection = make([]byte, 0, hashBlock)
-			}
-		}
-	}
-	if len(bucketCollection) > 0 {
-		hash := sha256.Sum256(bucketCollection)
-		hasharray = append(hasharray, hash[:]...)
+		items = append(items, hs.store.ReadBucket(n).ReadBulk(int64(hs.bitsCount[n]))...)
 	}
-	return crypto.Hasher(hasharray)
+	sort.Sort(items)
+	return items
+}
+
+func (hs *HashStore) hashItems() crypto.Hash {
+	items := hs.sortedItems()
+	return storeHash(len(items), crypto.MerkleRoot(items))
 }
@@ -79,6 +79,7 @@ type ByteStore interface {
 	New(int64) ByteStore // create a new empty bytestore os size int64
 	Merge(ByteStore)
 	Size() int64
+	Sync() // flush written data to durable storage
 	Close()
 }
 
@@ -138,37 +139,34 @@ func (f *FileStore) Size() int64 {
 	return f.size
 }
 
+func (f *FileStore) Sync() {
+	if err := f.data.Sync(); err != nil {
+		panic(err)
+	}
+}
+
 func (f *FileStore) WriteAt(offset int64, b []byte) {
-	if offset+int64(len(b)) >= f.size || offset < 0 {
+	if offset+int64(len(b)) > f.size || offset < 0 {
 		panic("invalid offset")
 	}
-	if _, err := f.data.Seek(offset, 0); err != nil {
-		panic(err)
-	}
-	if n, err := f.data.Write(b); n != len(b) {
+	if n, err := f.data.WriteAt(b, offset); n != len(b) {
 		panic(err)
 	}
 }
 
 func (f *FileStore) Append(b []byte) {
-	if _, err := f.data.Seek(0, 2); err != nil {
-		panic(err)
-	}
-	if n, err := f.data.Write(b); n != len(b) {
+	if n, err := f.data.WriteAt(b, f.size); n != len(b) {
 		panic(err)
 	}
 	f.size += int64(len(b))
 }
 
 func (f *FileStore) ReadAt(offset int64, nbytes int64) []byte {
-	if offset+nbytes >= f.size || offset < 0 || nbytes < 1 {
+	if offset+nbytes > f.size || offset < 0 || nbytes < 1 {
 		panic("invalid read parameters")
 	}
 	data := make([]byte, nbytes)
-	if _, err := f.data.Seek(offset, 0); err != nil {
-		panic(err)
-	}
-	if n, err := f.data.Read(data); int64(n) != nbytes {
+	if n, err := f.data.ReadAt(data, offset); int64(n) != nbytes {
 		panic(err)
 	}
 	return data
@@ -183,6 +181,8 @@ func (f *FileStore) Merge(another ByteStore) {
 	if !ok {
 		panic("can only merge FileStore with FileStore")
 	}
+	// the merged data must be durable before it replaces the current file
+	other.Sync()
 	f.data.Close()
 	other.data.Close()
 	os.Rename(other.name, f.name)
@@ -212,6 +212,8 @@ func (m *MemoryStore) Size() int64 {
 	return int64(len(m.data))
 }
 
+func (m *MemoryStore) Sync() {}
+
 func (m *MemoryStore) WriteAt(offset int64, b []byte) {
 	if offset+int64(len(b)) > int64(len(m.data)) || offset < 0 {
 		panic(fmt.Sprintf("invalid offset %v, %v, %v", offset, len(b), len(m.data)))
@@ -0,0 +1,152 @@
+// Copyright 2021 The aereum Authors
+// This file is part of the aereum library.
+//
+// The aereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aereum library. If not, see <http://www.gnu.org/licenses/>.
+package store
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/util"
+)
+
+func storeHash(count int, root crypto.Hash) crypto.Hash {
+	data := make([]byte, 0)
+	util.PutUint64(uint64(count), &data)
+	data = append(data, root[:]...)
+	return crypto.Hasher(data)
+}
+
+// ItemProof is a Merkle proof that Item is the Index-th item of a store.
+type ItemProof struct {
+	Index int
+	Item  []byte
+	Path  []crypto.Hash
+}
+
+// Proof proves the presence or the absence of a hash on a store with Count
+// items. The presence is proved by the item of the hash. The absence is
+// proved by the items immediately before and after the position the hash
+// would occupy, or by one of them if the hash would be the first or the last
+// item.
+type Proof struct {
+	Count int
+	Items []ItemProof
+}
+
+// Prove returns a proof of the presence or absence of hash on the store.
+func (hs *HashStore) Prove(hash crypto.Hash) *Proof {
+	items := hs.Items()
+	proof := &Proof{Count: len(items), Items: make([]ItemProof, 0)}
+	position := sort.Search(len(items), func(n int) bool {
+		return bytes.Compare(items[n][0:size], hash[:]) >= 0
+	})
+	add := func(n int) {
+		proof.Items = append(proof.Items, ItemProof{Index: n, Item: items[n], Path: crypto.MerkleProof(items, n)})
+	}
+	if position < len(items) && bytes.Equal(items[position][0:size], hash[:]) {
+		add(position)
+		return proof
+	}
+	if position > 0 {
+		add(position - 1)
+	}
+	if position < len(items) {
+		add(position)
+	}
+	return proof
+}
+
+// Verify checks the proof against the hash of a store and returns whether
+// hash is present together with the data associated to it. The last return
+// value is false if the proof is not valid.
+func (p *Proof) Verify(commitment crypto.Hash, hash crypto.Hash) (bool, []byte, bool) {
+	if len(p.Items) == 0 {
+		return false, nil, p.Count == 0 && commitment == storeHash(0, crypto.MerkleRoot(nil))
+	}
+	// every item must prove the same Merkle root
+	var root crypto.Hash
+	for n, item := range p.Items {
+		if len(item.Item) < size {
+			return false, nil, false
+		}
+		itemRoot, ok := crypto.MerkleProofRoot(item.Item, item.Index, p.Count, item.Path)
+		if !ok || (n > 0 && itemRoot != root) {
+			return false, nil, false
+		}
+		root = itemRoot
+	}
+	if storeHash(p.Count, root) != commitment {
+		return false, nil, false
+	}
+	first := p.Items[0]
+	compare := bytes.Compare(first.Item[0:size], hash[:])
+	if len(p.Items) == 1 {
+		if compare == 0 {
+			return true, first.Item[size:], true
+		}
+		// hash would be the first or the last item
+		valid := (compare > 0 && first.Index == 0) || (compare < 0 && first.Index == p.Count-1)
+		return false, nil, valid
+	}
+	if len(p.Items) != 2 {
+		return false, nil, false
+	}
+	second := p.Items[1]
+	valid := second.Index == first.Index+1 && compare < 0 && bytes.Compare(second.Item[0:size], hash[:]) > 0
+	return false, nil, valid
+}
+
+// Serialize returns the binary representation of the proof.
+func (p *Proof) Serialize() []byte {
+	data := make([]byte, 0)
+	util.PutUint64(uint64(p.Count), &data)
+	util.PutUint16(uint16(len(p.Items)), &data)
+	for _, item := range p.Items {
+		util.PutUint64(uint64(item.Index), &data)
+		util.PutByteArray(item.Item, &data)
+		util.PutUint16(uint16(len(item.Path)), &data)
+		for _, hash := range item.Path {
+			util.PutByteArray(hash[:], &data)
+		}
+	}
+	return data
+}
+
+// ParseProof parses a proof from data starting at position and returns the
+// new position.
+func ParseProof(data []byte, position int) (*Proof, int) {
+	proof := &Proof{}
+	var count uint64
+	var items uint16
+	count, position = util.ParseUint64(data, position)
+	items, position = util.ParseUint16(data, position)
+	proof.Count = int(count)
+	proof.Items = make([]ItemProof, int(items))
+	for n := range proof.Items {
+		var index uint64
+		var length uint16
+		index, position = util.ParseUint64(data, position)
+		proof.Items[n].Index = int(index)
+		proof.Items[n].Item, position = util.ParseByteArray(data, position)
+		length, position = util.ParseUint16(data, position)
+		proof.Items[n].Path = make([]crypto.Hash, int(length))
+		for m := range proof.Items[n].Path {
+			proof.Items[n].Path[m], position = util.ParseHash(data, position)
+		}
+	}
+	return proof, position
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/Aereum/aereum/core/crypto"
+)
+
+func TestProof(t *testing.T) {
+	vault := NewHashVault("proof", 0, 6)
+	absent := crypto.Hasher([]byte("absent"))
+	if found, _, ok := vault.Prove(absent).Verify(vault.Hash(), absent); found || !ok {
+		t.Fatal("could not prove absence on empty vault")
+	}
+	hashes := make([]crypto.Hash, 100)
+	for n := range hashes {
+		hashes[n] = crypto.Hasher([]byte{byte(n)})
+		vault.InsertHash(hashes[n])
+	}
+	hash := vault.Hash()
+	for _, present := range hashes {
+		proof := parseProofBytes(vault.Prove(present).Serialize())
+		if found, _, ok := proof.Verify(hash, present); !found || !ok {
+			t.Fatal("could not prove presence")
+		}
+		if _, _, ok := proof.Verify(hash, absent); ok {
+			t.Fatal("presence proof accepted for another hash")
+		}
+	}
+	for n := 0; n < 100; n++ {
+		missing := crypto.Hasher([]byte{byte(n), 1})
+		if found, _, ok := vault.Prove(missing).Verify(hash, missing); found || !ok {
+			t.Fatal("could not prove absence")
+		}
+	}
+	wallet := NewMemoryWalletStore(0, 6)
+	wallet.CreditHash(absent, 10)
+	found, data, ok := wallet.Prove(absent).Verify(wallet.Hash(), absent)
+	if !found || !ok || len(data) != 8 || data[0] != 10 {
+		t.Fatal("could not prove wallet balance")
+	}
+}
+
+func parseProofBytes(data []byte) *Proof {
+	proof, _ := ParseProof(data, 0)
+	return proof
+}
@@ -0,0 +1,174 @@
+// Copyright 2021 The aereum Authors
+// This file is part of the aereum library.
+//
+// The aereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The aereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the aereum library. If not, see <http://www.gnu.org/licenses/>.
+package store
+
+import (
+	"github.com/Aereum/aereum/core/crypto"
+)
+
+// GetSetOrDelete reads (exists), overwrites (insert) or deletes the value
+// associated to hash according to the first byte of param. On insert the
+// remaining bytes of param are the new value, which must fill the item.
+func GetSetOrDelete(found bool, hash crypto.Hash, b *Bucket, item int64, param []byte) OperationResult {
+	if found {
+		if param[0] == delete {
+			return OperationResult{
+				deleted: &Item{bucket: b, item: item},
+				result:  QueryResult{ok: true},
+			}
+		} else if param[0] == exists {
+			data := b.ReadItem(item)
+			return OperationResult{
+				result: QueryResult{ok: true, data: data[size:]},
+			}
+		} else {
+			updated := make([]byte, size+len(param)-1)
+			copy(updated[0:size], hash[:])
+			copy(updated[size:], param[1:])
+			b.WriteItem(item, updated)
+			return OperationResult{
+				result: QueryResult{ok: false},
+			}
+		}
+	} else {
+		if param[0] == insert {
+			added := make([]byte, size+len(param)-1)
+			copy(added[0:size], hash[:])
+			copy(added[size:], param[1:])
+			b.WriteItem(item, added)
+			return OperationResult{
+				added:  &Item{bucket: b, item: item},
+				result: QueryResult{ok: true},
+			}
+		} else {
+			return OperationResult{
+				result: QueryResult{ok: false},
+			}
+		}
+	}
+}
+
+// Registry associates a hash to another hash, such as a caption to the token
+// of its owner.
+type Registry struct {
+	hs *HashStore
+}
+
+// Get returns the value associated to hash, if any.
+func (w *Registry) Get(hash crypto.Hash) (bool, crypto.Hash) {
+	response := make(chan QueryResult)
+	ok, data := w.hs.Query(Query{hash: hash, param: []byte{exists}, response: response})
+	var value crypto.Hash
+	if ok {
+		copy(value[:], data)
+	}
+	return ok, value
+}
+
+func (w *Registry) ExistsHash(hash crypto.Hash) bool {
+	ok, _ := w.Get(hash)
+	return ok
+}
+
+// Set associates value to hash, replacing any previous value. It returns true
+// if hash had no previous value.
+func (w *Registry) Set(hash crypto.Hash, value crypto.Hash) bool {
+	response := make(chan QueryResult)
+	ok, _ := w.hs.Query(Query{hash: hash, param: append([]byte{insert}, value[:]...), response: response})
+	return ok
+}
+
+func (w *Registry) RemoveHash(hash crypto.Hash) bool {
+	response := make(chan QueryResult)
+	ok, _ := w.hs.Query(Query{hash: hash, param: []byte{delete}, response: response})
+	return ok
+}
+
+// Values returns the value associated to every hash of the registry.
+func (w *Registry) Values() []crypto.Hash {
+	items := w.hs.Items()
+	values := make([]crypto.Hash, len(items))
+	for n, item := range items {
+		copy(values[n][:], item[size:2*size])
+	}
+	return values
+}
+
+func (w *Registry) Epoch() uint64 {
+	return w.hs.Epoch()
+}
+
+func (w *Registry) SetEpoch(epoch uint64) {
+	w.hs.SetEpoch(epoch)
+}
+
+// Hash returns a commitment to the content of the vault.
+func (w *Registry) Hash() crypto.Hash {
+	return w.hs.Hash()
+}
+
+// Prove returns a proof of the presence or absence of hash on the vault
+// against the vault hash.
+func (w *Registry) Prove(hash crypto.Hash) *Proof {
+	return w.hs.Prove(hash)
+}
+
+func (w *Registry) Close() bool {
+	return w.hs.Stop()
+}
+
+// Sync flushes the vault to durable storage.
+func (w *Registry) Sync() {
+	w.hs.Sync()
+}
+
+// MemoryCopy returns a copy of the vault kept on memory. The copy must be
+// closed when no longer needed.
+func (w *Registry) MemoryCopy() *Registry {
+	return &Registry{hs: w.hs.MemoryCopy()}
+}
+
+func newRegistry(name, path string, epoch uint64, bitsForBucket int64) *Registry {
+	itemsize := int64(2 * size)
+	bytestore := newByteStore(path, itemsize, bitsForBucket)
+	bucketstore := NewBucketStore(itemsize, 6, bytestore)
+	bucketstore.SetEpoch(epoch)
+	w := &Registry{
+		hs: NewHashStore(name, bucketstore, int(bitsForBucket), GetSetOrDelete),
+	}
+	w.hs.Start()
+	return w
+}
+
+func NewRegistry(name string, epoch uint64, bitsForBucket int64) *Registry {
+	return newRegistry(name, "", epoch, bitsForBucket)
+}
+
+// NewFileRegistry creates a new registry persisted on the file at path.
+func NewFileRegistry(path string, epoch uint64, bitsForBucket int64) *Registry {
+	return newRegistry(path, path, epoch, bitsForBucket)
+}
+
+// OpenFileRegistry reopens a registry persisted on the file at path. It
+// returns nil if the file cannot be opened.
+func OpenFileRegistry(path string) *Registry {
+	hs := openFileHashStore(path, GetSetOrDelete)
+	if hs == nil {
+		return nil
+	}
+	hs.Start()
+	return &Registry{hs: hs}
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Aereum/aereum/core/crypto"
+)
+
+func TestRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.dat")
+	registry := NewFileRegistry(path, 0, 6)
+	hash := crypto.Hasher([]byte("alice"))
+	first, second := crypto.Hasher([]byte{1}), crypto.Hasher([]byte{2})
+	if ok, _ := registry.Get(hash); ok {
+		t.Fatal("empty registry returned a value")
+	}
+	if !registry.Set(hash, first) {
+		t.Error("new value not reported as new")
+	}
+	before := registry.Hash()
+	if registry.Set(hash, second) {
+		t.Error("replaced value reported as new")
+	}
+	if ok, value := registry.Get(hash); !ok || value != second {
+		t.Errorf("registry set not working: %v", value)
+	}
+	if registry.Hash() == before {
+		t.Error("registry hash unchanged by replaced value")
+	}
+	if values := registry.Values(); len(values) != 1 || values[0] != second {
+		t.Errorf("registry values not working: %v", values)
+	}
+	registry.SetEpoch(3)
+	registry.Close()
+	registry = OpenFileRegistry(path)
+	if registry == nil {
+		t.Fatal("could not reopen registry")
+	}
+	defer registry.Close()
+	if ok, value := registry.Get(hash); !ok || value != second || registry.Epoch() != 3 {
+		t.Error("registry not persisted")
+	}
+	if !registry.RemoveHash(hash) || registry.ExistsHash(hash) {
+		t.Error("registry remove not working")
+	}
+}
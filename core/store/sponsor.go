@@ -7,9 +7,9 @@ import (
 func GetOrSetSponsor(found bool, hash crypto.Hash, b *Bucket, item int64, param []byte) OperationResult {
 	if found {
 		if param[0] == 0 { // get
-			keys := b.ReadItem(item)
+			data := b.ReadItem(item)
 			return OperationResult{
-				result: QueryResult{ok: true, data: keys},
+				result: QueryResult{ok: true, data: data[crypto.Size:]},
 			}
 		} else if param[0] == 1 { // set
 			return OperationResult{
@@ -27,9 +27,10 @@ func GetOrSetSponsor(found bool, hash crypto.Hash, b *Bucket, item int64, param
 				result: QueryResult{ok: false},
 			}
 		} else if param[0] == 1 { // set
-			contentHash := make([]byte, crypto.Size)
-			copy(contentHash[0:crypto.Size], param[1:])
-			b.WriteItem(item, contentHash)
+			added := make([]byte, 2*crypto.Size)
+			copy(added[0:crypto.Size], hash[:])
+			copy(added[crypto.Size:], param[1:])
+			b.WriteItem(item, added)
 			return OperationResult{
 				added:  &Item{bucket: b, item: item},
 				result: QueryResult{ok: true},
@@ -50,7 +51,7 @@ func (w *Sponsor) GetContentHash(hash crypto.Hash) (bool, []byte) {
 	response := make(chan QueryResult)
 	ok, keys := w.hs.Query(Query{hash: hash, param: []byte{0}, response: response})
 	if ok {
-		return ok, keys
+		return ok, append([]byte{}, keys...)
 	}
 	return false, nil
 }
@@ -63,7 +64,7 @@ func (w *Sponsor) Exists(hash crypto.Hash) bool {
 
 func (w *Sponsor) SetContentHash(hash crypto.Hash, keys []byte) bool {
 	response := make(chan QueryResult)
-	ok, _ := w.hs.Query(Query{hash: hash, param: append([]byte{1}, hash[:]...), response: response})
+	ok, _ := w.hs.Query(Query{hash: hash, param: append([]byte{1}, keys...), response: response})
 	return ok
 }
 
@@ -73,20 +74,63 @@ func (w *Sponsor) RemoveContentHash(hash crypto.Hash) bool {
 	return ok
 }
 
+func (w *Sponsor) Epoch() uint64 {
+	return w.hs.Epoch()
+}
+
+func (w *Sponsor) SetEpoch(epoch uint64) {
+	w.hs.SetEpoch(epoch)
+}
+
+// Hash returns a commitment to the content of the vault.
+func (w *Sponsor) Hash() crypto.Hash {
+	return w.hs.Hash()
+}
+
 func (w *Sponsor) Close() bool {
-	ok := make(chan bool)
-	w.hs.stop <- ok
-	return <-ok
+	return w.hs.Stop()
 }
 
-func NewSponsorShipOfferStore(epoch uint64, bitsForBucket int64) *Sponsor {
-	itemsize := int64(crypto.Size)
-	nbytes := 56 + int64(1<<bitsForBucket)*(itemsize*6+8)
-	bytestore := NewMemoryStore(nbytes)
+// Sync flushes the vault to durable storage.
+func (w *Sponsor) Sync() {
+	w.hs.Sync()
+}
+
+// MemoryCopy returns a copy of the vault kept on memory. The copy must be
+// closed when no longer needed.
+func (w *Sponsor) MemoryCopy() *Sponsor {
+	return &Sponsor{hs: w.hs.MemoryCopy()}
+}
+
+func newSponsorShipOfferStore(name, path string, epoch uint64, bitsForBucket int64) *Sponsor {
+	itemsize := int64(2 * crypto.Size)
+	bytestore := newByteStore(path, itemsize, bitsForBucket)
 	bucketstore := NewBucketStore(itemsize, 6, bytestore)
+	bucketstore.SetEpoch(epoch)
 	w := &Sponsor{
-		hs: NewHashStore("sponsor", bucketstore, int(bitsForBucket), GetOrSetSponsor),
+		hs: NewHashStore(name, bucketstore, int(bitsForBucket), GetOrSetSponsor),
 	}
 	w.hs.Start()
 	return w
 }
+
+func NewSponsorShipOfferStore(epoch uint64, bitsForBucket int64) *Sponsor {
+	return newSponsorShipOfferStore("sponsor", "", epoch, bitsForBucket)
+}
+
+// NewFileSponsorShipOfferStore creates a new sponsor store persisted on the
+// file at path.
+func NewFileSponsorShipOfferStore(path string, epoch uint64, bitsForBucket int64) *Sponsor {
+	return newSponsorShipOfferStore(path, path, epoch, bitsForBucket)
+}
+
+// OpenFileSponsorShipOfferStore reopens a sponsor store persisted on the file
+// at path. It returns nil if the file cannot be opened.
+func OpenFileSponsorShipOfferStore(path string) *Sponsor {
+	hs := openFileHashStore(path, GetOrSetSponsor)
+	if hs == nil {
+		return nil
+	}
+	hs.Start()
+	return &Sponsor{hs: hs}
+}
@@ -13,7 +13,7 @@ func TestSponsor(t *testing.T) {
 	hash2 := crypto.Hasher([]byte{1, 4, 3, 4})
 	sponsor.SetContentHash(hash, hash2[:])
 	ok, hash3 := sponsor.GetContentHash(hash)
-	if !ok || bytes.Equal(hash2[:], hash3) {
+	if !ok || !bytes.Equal(hash2[:], hash3) {
 		t.Errorf("sponsor set/get not working")
 	}
 	sponsor.RemoveContentHash(hash)
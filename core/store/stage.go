@@ -17,13 +17,19 @@ func GetOrSetStage(found bool, hash crypto.Hash, b *Bucket, item int64, param []
 		get = true
 	}
 	if found {
+		if len(param) == 1 { // remove
+			return OperationResult{
+				deleted: &Item{bucket: b, item: item},
+				result:  QueryResult{ok: true},
+			}
+		}
 		if get {
 			keys := b.ReadItem(item)
 			return OperationResult{
 				result: QueryResult{ok: true, data: keys[size:]},
 			}
 		} else {
-			updated := make([]byte, crypto.Size+3*crypto.PublicKeySize)
+			updated := make([]byte, crypto.Size+3*crypto.PublicKeySize+1)
 			copy(updated[0:size], hash[:])
 			copy(updated[size:], param)
 			b.WriteItem(item, updated)
@@ -33,8 +39,8 @@ func GetOrSetStage(found bool, hash crypto.Hash, b *Bucket, item int64, param []
 
 		}
 	} else {
-		if !get {
-			newKeys := make([]byte, crypto.Size+3*crypto.PublicKeySize)
+		if !get && len(param) > 1 {
+			newKeys := make([]byte, crypto.Size+3*crypto.PublicKeySize+1)
 			copy(newKeys[0:size], hash[:])
 			copy(newKeys[size:], param)
 			b.WriteItem(item, newKeys)
@@ -75,7 +81,7 @@ func (w *Stage) Exists(hash crypto.Hash) bool {
 }
 
 func (w *Stage) SetKeys(hash crypto.Hash, stage *StageKeys) bool {
-	keys := make([]byte, 2*crypto.TokenSize+1)
+	keys := make([]byte, 3*crypto.TokenSize+1)
 	copy(keys[0:crypto.TokenSize], stage.Moderate[:])
 	copy(keys[crypto.TokenSize:2*crypto.TokenSize], stage.Submit[:])
 	copy(keys[2*crypto.TokenSize:3*crypto.TokenSize], stage.Stage[:])
@@ -85,20 +91,77 @@ func (w *Stage) SetKeys(hash crypto.Hash, stage *StageKeys) bool {
 	return ok
 }
 
+// RemoveKeys removes the keys associated to hash. It returns false if there
+// are no keys associated to hash.
+func (w *Stage) RemoveKeys(hash crypto.Hash) bool {
+	response := make(chan QueryResult)
+	ok, _ := w.hs.Query(Query{hash: hash, param: []byte{0}, response: response})
+	return ok
+}
+
+func (w *Stage) Epoch() uint64 {
+	return w.hs.Epoch()
+}
+
+func (w *Stage) SetEpoch(epoch uint64) {
+	w.hs.SetEpoch(epoch)
+}
+
+// Hash returns a commitment to the content of the vault.
+func (w *Stage) Hash() crypto.Hash {
+	return w.hs.Hash()
+}
+
+// Prove returns a proof of the presence or absence of hash on the vault
+// against the vault hash.
+func (w *Stage) Prove(hash crypto.Hash) *Proof {
+	return w.hs.Prove(hash)
+}
+
 func (w *Stage) Close() bool {
-	ok := make(chan bool)
-	w.hs.stop <- ok
-	return <-ok
+	return w.hs.Stop()
 }
 
-func NewMemoryAudienceStore(epoch uint64, bitsForBucket int64) *Stage {
+// Sync flushes the vault to durable storage.
+func (w *Stage) Sync() {
+	w.hs.Sync()
+}
+
+// MemoryCopy returns a copy of the vault kept on memory. The copy must be
+// closed when no longer needed.
+func (w *Stage) MemoryCopy() *Stage {
+	return &Stage{hs: w.hs.MemoryCopy()}
+}
+
+func newAudienceStore(name, path string, epoch uint64, bitsForBucket int64) *Stage {
 	itemsize := int64(crypto.Size + 3*crypto.TokenSize + 1)
-	nbytes := 56 + int64(1<<bitsForBucket)*(itemsize*6+8)
-	bytestore := NewMemoryStore(nbytes)
+	bytestore := newByteStore(path, itemsize, bitsForBucket)
 	bucketstore := NewBucketStore(itemsize, 6, bytestore)
+	bucketstore.SetEpoch(epoch)
 	w := &Stage{
-		hs: NewHashStore("audience", bucketstore, int(bitsForBucket), GetOrSetStage),
+		hs: NewHashStore(name, bucketstore, int(bitsForBucket), GetOrSetStage),
 	}
 	w.hs.Start()
 	return w
 }
+
+func NewMemoryAudienceStore(epoch uint64, bitsForBucket int64) *Stage {
+	return newAudienceStore("audience", "", epoch, bitsForBucket)
+}
+
+// NewFileAudienceStore creates a new audience store persisted on the file at
+// path.
+func NewFileAudienceStore(path string, epoch uint64, bitsForBucket int64) *Stage {
+	return newAudienceStore(path, path, epoch, bitsForBucket)
+}
+
+// OpenFileAudienceStore reopens an audience store persisted on the file at
+// path. It returns nil if the file cannot be opened.
+func OpenFileAudienceStore(path string) *Stage {
+	hs := openFileHashStore(path, GetOrSetStage)
+	if hs == nil {
+		return nil
+	}
+	hs.Start()
+	return &Stage{hs: hs}
+}
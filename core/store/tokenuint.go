@@ -71,13 +71,16 @@ func (w *TokenByteArrayStore) GetToken(token crypto.Token) [8]byte {
 }
 
 func (w *TokenByteArrayStore) Close() bool {
-	ok := make(chan bool)
-	w.hs.stop <- ok
-	return <-ok
+	return w.hs.Stop()
+}
+
+// Sync flushes the vault to durable storage.
+func (w *TokenByteArrayStore) Sync() {
+	w.hs.Sync()
 }
 
 func NewTokenByteArrayStore(storage string, bitsForBucket int64) *TokenByteArrayStore {
-	nbytes := 56 + int64(1<<bitsForBucket)*(40*6+8)
+	nbytes := bucketHeaderBytes + int64(1<<bitsForBucket)*(40*6+8)
 	var bytestore ByteStore
 	if storage == "RAM" {
 		bytestore = NewMemoryStore(nbytes)
@@ -21,23 +21,33 @@ import (
 	"encoding/binary"
 
 	"github.com/Aereum/aereum/core/crypto"
+	"github.com/Aereum/aereum/core/util"
 )
 
+// CreditOrDebit credits or debits (if the first byte of param is 1) the
+// value on the remaining bytes of param to the balance of hash. Balances
+// never go negative nor overflow: such operations are not performed and are
+// reported as not ok.
 func CreditOrDebit(found bool, hash crypto.Hash, b *Bucket, item int64, param []byte) OperationResult {
-	sign := int64(1)
-	if param[0] == 1 {
-		sign = -1 * sign
-	}
-	value := sign * int64(binary.LittleEndian.Uint64(param[1:]))
+	debit := param[0] == 1
+	value := binary.LittleEndian.Uint64(param[1:])
 	if found {
 		acc := b.ReadItem(item)
-		balance := int64(binary.LittleEndian.Uint64(acc[size:]))
+		balance := binary.LittleEndian.Uint64(acc[size:])
 		if value == 0 {
 			return OperationResult{
 				result: QueryResult{ok: true, data: acc},
 			}
 		}
-		newbalance := balance + value
+		newbalance, ok := balance-value, value <= balance
+		if !debit {
+			newbalance, ok = util.AddUint64(balance, value)
+		}
+		if !ok {
+			return OperationResult{
+				result: QueryResult{ok: false},
+			}
+		}
 		if newbalance > 0 {
 			// update balance
 			acc := make([]byte, size+8)
@@ -47,26 +57,22 @@ func CreditOrDebit(found bool, hash crypto.Hash, b *Bucket, item int64, param []
 			return OperationResult{
 				result: QueryResult{ok: true, data: acc},
 			}
-		} else if newbalance == 0 {
+		} else {
 			// account is market to be deleted
 			return OperationResult{
 				deleted: &Item{bucket: b, item: item},
 				result:  QueryResult{ok: true, data: acc},
 			}
-		} else {
-			return OperationResult{
-				result: QueryResult{ok: false},
-			}
 		}
 	} else {
-		if value > 0 {
+		if value > 0 && !debit {
 			acc := make([]byte, size+8)
-			binary.LittleEndian.PutUint64(acc[size:], uint64(value))
+			binary.LittleEndian.PutUint64(acc[size:], value)
 			copy(acc[0:size], hash[:])
 			b.WriteItem(item, acc)
 			return OperationResult{
 				added:  &Item{bucket: b, item: item},
-				result: QueryResult{ok: false, data: acc},
+				result: QueryResult{ok: true, data: acc},
 			}
 		} else {
 			return OperationResult{
@@ -124,19 +130,88 @@ func (w *Wallet) Debit(token crypto.Token, value uint64) bool {
 	return w.DebitHash(hash, value)
 }
 
+// Total returns the sum of the balances of every wallet.
+func (w *Wallet) Total() uint64 {
+	total := uint64(0)
+	for _, item := range w.hs.Items() {
+		total += binary.LittleEndian.Uint64(item[size:])
+	}
+	return total
+}
+
+// TotalExcept returns the sum of the balances of every wallet whose hash is
+// not in excluded.
+func (w *Wallet) TotalExcept(excluded *HashVault) uint64 {
+	total := uint64(0)
+	for _, item := range w.hs.Items() {
+		if !excluded.ExistsHash(crypto.BytesToHash(item[:size])) {
+			total += binary.LittleEndian.Uint64(item[size:])
+		}
+	}
+	return total
+}
+
+func (w *Wallet) Epoch() uint64 {
+	return w.hs.Epoch()
+}
+
+func (w *Wallet) SetEpoch(epoch uint64) {
+	w.hs.SetEpoch(epoch)
+}
+
+// Hash returns a commitment to the content of the vault.
+func (w *Wallet) Hash() crypto.Hash {
+	return w.hs.Hash()
+}
+
+// Prove returns a proof of the presence or absence of hash on the vault
+// against the vault hash.
+func (w *Wallet) Prove(hash crypto.Hash) *Proof {
+	return w.hs.Prove(hash)
+}
+
 func (w *Wallet) Close() bool {
-	ok := make(chan bool)
-	w.hs.stop <- ok
-	return <-ok
+	return w.hs.Stop()
 }
 
-func NewMemoryWalletStore(epoch uint64, bitsForBucket int64) *Wallet {
-	nbytes := 56 + int64(1<<bitsForBucket)*(40*6+8)
-	bytestore := NewMemoryStore(nbytes)
+// Sync flushes the vault to durable storage.
+func (w *Wallet) Sync() {
+	w.hs.Sync()
+}
+
+// MemoryCopy returns a copy of the vault kept on memory. The copy must be
+// closed when no longer needed.
+func (w *Wallet) MemoryCopy() *Wallet {
+	return &Wallet{hs: w.hs.MemoryCopy()}
+}
+
+func newWalletStore(name, path string, epoch uint64, bitsForBucket int64) *Wallet {
+	bytestore := newByteStore(path, 40, bitsForBucket)
 	bucketstore := NewBucketStore(40, 6, bytestore)
+	bucketstore.SetEpoch(epoch)
 	w := &Wallet{
-		hs: NewHashStore("wallet", bucketstore, int(bitsForBucket), CreditOrDebit),
+		hs: NewHashStore(name, bucketstore, int(bitsForBucket), CreditOrDebit),
 	}
 	w.hs.Start()
 	return w
 }
+
+func NewMemoryWalletStore(epoch uint64, bitsForBucket int64) *Wallet {
+	return newWalletStore("wallet", "", epoch, bitsForBucket)
+}
+
+// NewFileWalletStore creates a new wallet store persisted on the file at path.
+func NewFileWalletStore(path string, epoch uint64, bitsForBucket int64) *Wallet {
+	return newWalletStore(path, path, epoch, bitsForBucket)
+}
+
+// OpenFileWalletStore reopens a wallet store persisted on the file at path. It
+// returns nil if the file cannot be opened.
+func OpenFileWalletStore(path string) *Wallet {
+	hs := openFileHashStore(path, CreditOrDebit)
+	if hs == nil {
+		return nil
+	}
+	hs.Start()
+	return &Wallet{hs: hs}
+}
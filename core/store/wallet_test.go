@@ -41,6 +41,13 @@ func TestWallet(t *testing.T) {
 	if ok := w.DebitHash(hash, 6); ok {
 		t.Errorf("could debit an account withou sufficient balance\n")
 	}
+	other := crypto.Hasher([]byte("other"))
+	w.CreditHash(other, 20)
+	excluded := NewHashVault("excluded", 0, 6)
+	excluded.InsertHash(hash)
+	if total := w.TotalExcept(excluded); total != 20 || w.Total() != 25 {
+		t.Errorf("wrong total except excluded %v\n", total)
+	}
 }
 
 func TestWalletDoubling(t *testing.T) {
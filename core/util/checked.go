@@ -0,0 +1,55 @@
+package util
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Checked arithmetic for balances and signed deltas of balances. Every
+// function returns false instead of a result that overflows.
+
+// AddUint64 returns a + b.
+func AddUint64(a, b uint64) (uint64, bool) {
+	sum, carry := bits.Add64(a, b, 0)
+	return sum, carry == 0
+}
+
+// MulUint64 returns a * b.
+func MulUint64(a, b uint64) (uint64, bool) {
+	high, product := bits.Mul64(a, b)
+	return product, high == 0
+}
+
+// AddInt64 returns a + b.
+func AddInt64(a, b int64) (int64, bool) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, false
+	}
+	return sum, true
+}
+
+// ToDelta converts value to a positive delta, or to a negative delta if
+// debit is set.
+func ToDelta(value uint64, debit bool) (int64, bool) {
+	if value > math.MaxInt64 {
+		return 0, false
+	}
+	if debit {
+		return -int64(value), true
+	}
+	return int64(value), true
+}
+
+// ApplyDelta returns value + delta. It returns false if the result is
+// negative or does not fit in an uint64.
+func ApplyDelta(value uint64, delta int64) (uint64, bool) {
+	if delta >= 0 {
+		return AddUint64(value, uint64(delta))
+	}
+	debit := uint64(-(delta + 1)) + 1
+	if debit > value {
+		return 0, false
+	}
+	return value - debit, true
+}
@@ -0,0 +1,48 @@
+package util
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCheckedArithmetic(t *testing.T) {
+	if _, ok := AddUint64(math.MaxUint64, 1); ok {
+		t.Error("uint64 overflow not detected")
+	}
+	if sum, ok := AddUint64(1, 2); !ok || sum != 3 {
+		t.Error("wrong uint64 sum")
+	}
+	if _, ok := MulUint64(math.MaxUint64/2, 3); ok {
+		t.Error("uint64 product overflow not detected")
+	}
+	if product, ok := MulUint64(4, 5); !ok || product != 20 {
+		t.Error("wrong uint64 product")
+	}
+	if _, ok := AddInt64(math.MaxInt64, 1); ok {
+		t.Error("int64 overflow not detected")
+	}
+	if _, ok := AddInt64(math.MinInt64, -1); ok {
+		t.Error("int64 underflow not detected")
+	}
+	if sum, ok := AddInt64(-5, 3); !ok || sum != -2 {
+		t.Error("wrong int64 sum")
+	}
+	if _, ok := ToDelta(math.MaxInt64+1, false); ok {
+		t.Error("delta overflow not detected")
+	}
+	if delta, ok := ToDelta(7, true); !ok || delta != -7 {
+		t.Error("wrong debit delta")
+	}
+	if _, ok := ApplyDelta(5, -6); ok {
+		t.Error("negative balance not detected")
+	}
+	if _, ok := ApplyDelta(math.MaxUint64, 1); ok {
+		t.Error("balance overflow not detected")
+	}
+	if value, ok := ApplyDelta(10, math.MinInt64); ok || value != 0 {
+		t.Error("minimum delta not handled")
+	}
+	if value, ok := ApplyDelta(math.MaxUint64, math.MinInt64); !ok || value != math.MaxUint64-math.MaxInt64-1 {
+		t.Error("wrong balance after minimum delta")
+	}
+}
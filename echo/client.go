@@ -18,12 +18,14 @@ func BlockListener(token crypto.PrivateKey, db *DB) {
 		if err != nil {
 			log.Fatal("connection error")
 		}
-		block := chain.ParseBlock(data)
+		block, err := chain.ParseBlock(data)
+		if err != nil {
+			log.Printf("invalid block: %v", err)
+			continue
+		}
 		go func(instructions [][]byte) {
-			if block != nil {
-				for _, msg := range block.Instructions {
-					db.Incorporate(msg)
-				}
+			for _, msg := range instructions {
+				db.Incorporate(msg)
 			}
 		}(block.Instructions)
 	}